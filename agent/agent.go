@@ -0,0 +1,235 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/api"
+	"github.com/hackaio/pk/client"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+const (
+	// SocketName is the name of the agent socket inside the pk app dir.
+	SocketName = "agent.sock"
+
+	// LockPath is the route that makes the agent wipe its memory and exit.
+	LockPath = "/lock"
+
+	// agentURL is a placeholder host, requests always go to the socket.
+	agentURL = "http://pk-agent"
+
+	dialTimeout = 2 * time.Second
+)
+
+var (
+	ErrAgentRunning    = errors.New("an agent is already listening on the socket")
+	ErrAgentNotRunning = errors.New("no agent is listening on the socket")
+	ErrSocketDir       = errors.New("the agent socket directory can be changed by other users")
+)
+
+// Agent holds an unlocked PasswordKeeper in memory and serves it over a
+// unix socket until it is locked or has been idle for too long.
+type Agent struct {
+	mu      sync.Mutex
+	keeper  pk.PasswordKeeper
+	wipe    func()
+	idle    time.Duration
+	timer   *time.Timer
+	server  *http.Server
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// New returns an Agent serving keeper. wipe is called when the agent
+// locks and should erase any key material behind keeper.
+func New(keeper pk.PasswordKeeper, idle time.Duration, wipe func()) *Agent {
+	return &Agent{
+		keeper:  keeper,
+		wipe:    wipe,
+		idle:    idle,
+		stopped: make(chan struct{}),
+	}
+}
+
+// SocketPath returns the default location of the agent socket.
+func SocketPath(homeDir string) string {
+	return filepath.Join(homeDir, pk.AppDir, SocketName)
+}
+
+// Serve listens on socket and blocks until the agent is locked.
+func (a *Agent) Serve(socket string) error {
+	if Running(socket) {
+		return ErrAgentRunning
+	}
+
+	dir := filepath.Dir(socket)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := checkSocketDir(dir); err != nil {
+		return err
+	}
+
+	// a socket left behind by an agent that did not exit cleanly
+	_ = os.Remove(socket)
+
+	// the socket is made 0600 straight away, there is no window where
+	// other users can connect to it
+	var listener net.Listener
+	err := withUmask(0177, func() (err error) {
+		listener, err = net.Listen("unix", socket)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", api.MakeHandler(a.keeper))
+	mux.HandleFunc(LockPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		go a.Lock()
+	})
+
+	a.mu.Lock()
+	a.server = &http.Server{Handler: a.touch(mux)}
+	if a.idle > 0 {
+		a.timer = time.AfterFunc(a.idle, a.Lock)
+	}
+	a.mu.Unlock()
+
+	err = a.server.Serve(listener)
+	if err == http.ErrServerClosed {
+		<-a.stopped
+		return nil
+	}
+
+	a.Lock()
+	return err
+}
+
+// checkSocketDir makes sure other users can not swap the socket in dir for
+// their own. MkdirAll keeps the mode of an existing dir, so one writable by
+// others is refused unless it is sticky like /tmp.
+func checkSocketDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.Wrap(ErrSocketDir, errors.New(fmt.Sprintf("%v is not a directory", dir)))
+	}
+
+	mode := info.Mode()
+	if mode.Perm()&0022 != 0 && mode&os.ModeSticky == 0 {
+		return errors.Wrap(ErrSocketDir, errors.New(fmt.Sprintf("%v has mode %v, chmod go-w it", dir, mode.Perm())))
+	}
+	return nil
+}
+
+// Lock wipes the unlocked keeper from memory and stops serving.
+func (a *Agent) Lock() {
+	a.once.Do(func() {
+		a.mu.Lock()
+		if a.timer != nil {
+			a.timer.Stop()
+		}
+		a.keeper = nil
+		server := a.server
+		a.mu.Unlock()
+
+		if a.wipe != nil {
+			a.wipe()
+		}
+
+		if server != nil {
+			_ = server.Shutdown(context.Background())
+		}
+
+		close(a.stopped)
+	})
+}
+
+// touch resets the idle timer on every request.
+func (a *Agent) touch(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		if a.timer != nil {
+			a.timer.Reset(a.idle)
+		}
+		a.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Running reports whether an agent answers on socket.
+func Running(socket string) bool {
+	conn, err := net.DialTimeout("unix", socket, dialTimeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// Dial returns a PasswordKeeper that forwards every call to the agent
+// listening on socket.
+func Dial(socket string) pk.PasswordKeeper {
+	return client.NewWithClient(agentURL, httpClient(socket))
+}
+
+// Lock asks the agent listening on socket to wipe its memory and exit.
+func Lock(socket string) error {
+	if !Running(socket) {
+		return ErrAgentNotRunning
+	}
+
+	res, err := httpClient(socket).Post(agentURL+LockPath, "application/json", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("agent refused to lock: %v", res.Status))
+	}
+
+	return nil
+}
+
+func httpClient(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+type keeperMock struct {
+	pk.PasswordKeeper
+}
+
+func (k keeperMock) Get(ctx context.Context, token, name, username string) (pk.Account, error) {
+	return pk.Account{Name: name, UserName: username, Password: "s3cr3t"}, nil
+}
+
+func TestAgent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pk-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, SocketName)
+	wiped := make(chan struct{})
+	a := New(keeperMock{}, time.Minute, func() { close(wiped) })
+
+	served := make(chan error)
+	go func() { served <- a.Serve(socket) }()
+
+	for i := 0; i < 100 && !Running(socket); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("agent socket not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket permissions = %v, want 0600", perm)
+	}
+
+	account, err := Dial(socket).Get(context.Background(), "token", "github", "alice")
+	if err != nil {
+		t.Fatalf("Get() through agent failed: %v", err)
+	}
+	if account.Password != "s3cr3t" {
+		t.Errorf("Get() password = %v, want s3cr3t", account.Password)
+	}
+
+	if err := Lock(socket); err != nil {
+		t.Fatalf("Lock() failed: %v", err)
+	}
+
+	select {
+	case <-wiped:
+	case <-time.After(time.Second):
+		t.Fatal("agent did not wipe its memory after lock")
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("Serve() returned %v after lock", err)
+	}
+
+	if Running(socket) {
+		t.Error("agent still answering after lock")
+	}
+}
+
+func TestAgentIdleTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pk-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wiped := false
+	a := New(keeperMock{}, 50*time.Millisecond, func() { wiped = true })
+
+	if err := a.Serve(filepath.Join(dir, SocketName)); err != nil {
+		t.Fatalf("Serve() returned %v", err)
+	}
+
+	if !wiped {
+		t.Error("agent did not wipe its memory after idle timeout")
+	}
+}
+
+func TestAgentSocketDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pk-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	a := New(keeperMock{}, time.Minute, func() {})
+	if err := a.Serve(filepath.Join(dir, SocketName)); !errors.Contains(err, ErrSocketDir) {
+		t.Errorf("Serve() in a world writable dir error = %v, want %v", err, ErrSocketDir)
+	}
+}
@@ -0,0 +1,403 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// knownErrors are the pk errors that survive the trip over the wire.
+var knownErrors = []error{
+	pk.ErrPermissionDenied,
+	pk.ErrInternalError,
+	pk.ErrCriticalFailure,
+	pk.ErrNotFound,
+	pk.ErrVersionNotFound,
+	pk.ErrInvalidArgs,
+	pk.ErrCodeRequired,
+	strength.ErrPolicyViolation,
+}
+
+type grpcClient struct {
+	client PasswordKeeperClient
+}
+
+var _ pk.PasswordKeeper = (*grpcClient)(nil)
+
+// NewClient returns a PasswordKeeper that calls the gRPC server on the
+// other end of conn.
+func NewClient(conn *grpc.ClientConn) pk.PasswordKeeper {
+	return &grpcClient{client: NewPasswordKeeperClient(conn)}
+}
+
+func (c grpcClient) Register(ctx context.Context, username, email, password string) (err error) {
+	req := &RegisterRequest{
+		Username: username,
+		Email:    email,
+		Password: password,
+	}
+
+	_, err = c.client.Register(ctx, req)
+	return decodeError(err)
+}
+
+func (c grpcClient) Login(ctx context.Context, username, password, code string) (token string, err error) {
+	req := &LoginRequest{
+		Username: username,
+		Password: password,
+		Code:     code,
+	}
+
+	res, err := c.client.Login(ctx, req)
+	if err != nil {
+		return "", decodeError(err)
+	}
+
+	return res.GetToken(), nil
+}
+
+func (c grpcClient) Add(ctx context.Context, token string, account pk.Account) (err error) {
+	req := &AddRequest{Account: fromAccount(account)}
+
+	_, err = c.client.Add(withToken(ctx, token), req)
+	return decodeError(err)
+}
+
+func (c grpcClient) Get(ctx context.Context, token, name, username string) (account pk.Account, err error) {
+	req := &GetRequest{
+		Name:     name,
+		Username: username,
+	}
+
+	res, err := c.client.Get(withToken(ctx, token), req)
+	if err != nil {
+		return pk.Account{}, decodeError(err)
+	}
+
+	return toAccount(res), nil
+}
+
+func (c grpcClient) Delete(ctx context.Context, token, name, username string) (err error) {
+	req := &DeleteRequest{
+		Name:     name,
+		Username: username,
+	}
+
+	_, err = c.client.Delete(withToken(ctx, token), req)
+	return decodeError(err)
+}
+
+func (c grpcClient) List(ctx context.Context, token string, query pk.Query) (accounts []pk.Account, next string, err error) {
+	stream, err := c.client.List(withToken(ctx, token), fromQuery(query))
+	if err != nil {
+		return nil, "", decodeError(err)
+	}
+
+	for {
+		a, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", decodeError(err)
+		}
+
+		accounts = append(accounts, toAccount(a))
+	}
+
+	if values := stream.Trailer().Get(nextKey); len(values) > 0 {
+		next = values[0]
+	}
+
+	return accounts, next, nil
+}
+
+func (c grpcClient) Update(ctx context.Context, token, name, username string, account pk.Account) (acc pk.Account, err error) {
+	req := &UpdateRequest{
+		Name:     name,
+		Username: username,
+		Account:  fromAccount(account),
+	}
+
+	res, err := c.client.Update(withToken(ctx, token), req)
+	if err != nil {
+		return pk.Account{}, decodeError(err)
+	}
+
+	return toAccount(res), nil
+}
+
+func (c grpcClient) AddAll(ctx context.Context, token string, accounts []pk.Account) (err error) {
+	req := &AddAllRequest{}
+	for _, a := range accounts {
+		req.Accounts = append(req.Accounts, fromAccount(a))
+	}
+
+	_, err = c.client.AddAll(withToken(ctx, token), req)
+	return decodeError(err)
+}
+
+func (c grpcClient) DeleteAll(ctx context.Context, token string, args map[string]interface{}) (err error) {
+	s, err := toStruct(args)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.DeleteAll(withToken(ctx, token), &DeleteAllRequest{Args: s})
+	return decodeError(err)
+}
+
+func (c grpcClient) History(ctx context.Context, token, name, username string) (versions []pk.Version, err error) {
+	req := &HistoryRequest{
+		Name:     name,
+		Username: username,
+	}
+
+	res, err := c.client.History(withToken(ctx, token), req)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	versions = []pk.Version{}
+	for _, v := range res.GetVersions() {
+		versions = append(versions, pk.Version{
+			Version:  int(v.GetVersion()),
+			Account:  toAccount(v.GetAccount()),
+			Replaced: v.GetReplaced(),
+		})
+	}
+
+	return versions, nil
+}
+
+func (c grpcClient) Rollback(ctx context.Context, token, name, username string, version int) (account pk.Account, err error) {
+	req := &RollbackRequest{
+		Name:     name,
+		Username: username,
+		Version:  int64(version),
+	}
+
+	res, err := c.client.Rollback(withToken(ctx, token), req)
+	if err != nil {
+		return pk.Account{}, decodeError(err)
+	}
+
+	return toAccount(res), nil
+}
+
+func (c grpcClient) PruneHistory(ctx context.Context, token, name, username string, keep int, maxAge time.Duration) (removed int, err error) {
+	req := &PruneHistoryRequest{
+		Name:     name,
+		Username: username,
+		Keep:     int64(keep),
+		MaxAge:   int64(maxAge / time.Second),
+	}
+
+	res, err := c.client.PruneHistory(withToken(ctx, token), req)
+	if err != nil {
+		return 0, decodeError(err)
+	}
+
+	return int(res.GetRemoved()), nil
+}
+
+func (c grpcClient) Trash(ctx context.Context, token string) (accounts []pk.Trashed, err error) {
+	res, err := c.client.Trash(withToken(ctx, token), &empty.Empty{})
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	accounts = []pk.Trashed{}
+	for _, a := range res.GetAccounts() {
+		accounts = append(accounts, pk.Trashed{
+			Name:     a.GetName(),
+			UserName: a.GetUsername(),
+			Email:    a.GetEmail(),
+			Created:  a.GetCreated(),
+			Deleted:  a.GetDeleted(),
+		})
+	}
+
+	return accounts, nil
+}
+
+func (c grpcClient) Restore(ctx context.Context, token, name, username string) (err error) {
+	req := &RestoreRequest{
+		Name:     name,
+		Username: username,
+	}
+
+	_, err = c.client.Restore(withToken(ctx, token), req)
+	return decodeError(err)
+}
+
+func (c grpcClient) Purge(ctx context.Context, token string, olderThan time.Duration) (purged int, err error) {
+	req := &PurgeRequest{OlderThan: int64(olderThan / time.Second)}
+
+	res, err := c.client.Purge(withToken(ctx, token), req)
+	if err != nil {
+		return 0, decodeError(err)
+	}
+
+	return int(res.GetPurged()), nil
+}
+
+func (c grpcClient) Search(ctx context.Context, token, text string, limit int) (matches []pk.Match, err error) {
+	req := &SearchRequest{
+		Text:  text,
+		Limit: int64(limit),
+	}
+
+	res, err := c.client.Search(withToken(ctx, token), req)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	matches = []pk.Match{}
+	for _, m := range res.GetMatches() {
+		matches = append(matches, pk.Match{
+			Name:     m.GetName(),
+			UserName: m.GetUsername(),
+			Email:    m.GetEmail(),
+			URLs:     m.GetUrls(),
+			Tags:     m.GetTags(),
+			Folder:   m.GetFolder(),
+			Field:    m.GetField(),
+			Score:    int(m.GetScore()),
+		})
+	}
+
+	return matches, nil
+}
+
+func (c grpcClient) EnableTwoFactor(ctx context.Context, token, secret, code string) (recovery []string, err error) {
+	req := &EnableTwoFactorRequest{
+		Secret: secret,
+		Code:   code,
+	}
+
+	res, err := c.client.EnableTwoFactor(withToken(ctx, token), req)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	return res.GetRecovery(), nil
+}
+
+func (c grpcClient) DisableTwoFactor(ctx context.Context, token, code string) (err error) {
+	req := &DisableTwoFactorRequest{
+		Code: code,
+	}
+
+	_, err = c.client.DisableTwoFactor(withToken(ctx, token), req)
+	return decodeError(err)
+}
+
+func (c grpcClient) Export(ctx context.Context, token string) (entries []pk.Entry, err error) {
+	stream, err := c.client.Export(withToken(ctx, token), &empty.Empty{})
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	entries = []pk.Entry{}
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, decodeError(err)
+		}
+
+		entries = append(entries, toEntry(e))
+	}
+
+	return entries, nil
+}
+
+func (c grpcClient) Import(ctx context.Context, token string, entries []pk.Entry, conflict pk.Conflict) (report pk.ImportReport, err error) {
+	req := &ImportRequest{Conflict: string(conflict)}
+	for _, e := range entries {
+		req.Entries = append(req.Entries, fromEntry(e))
+	}
+
+	res, err := c.client.Import(withToken(ctx, token), req)
+	if err != nil {
+		return pk.ImportReport{}, decodeError(err)
+	}
+
+	return pk.ImportReport{
+		Added:    int(res.GetAdded()),
+		Replaced: int(res.GetReplaced()),
+		Renamed:  int(res.GetRenamed()),
+		Skipped:  int(res.GetSkipped()),
+	}, nil
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, authKey, token)
+}
+
+func toStruct(args map[string]interface{}) (*structpb.Struct, error) {
+	if args == nil {
+		return nil, nil
+	}
+
+	return structpb.NewStruct(args)
+}
+
+// decodeError turns a gRPC status back into the pk error it was made from.
+func decodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	msg := st.Message()
+	for _, known := range knownErrors {
+		prefix := known.Error()
+		if msg == prefix {
+			return known
+		}
+		if strings.HasPrefix(msg, prefix+" : ") {
+			return errors.Wrap(known, errors.New(strings.TrimPrefix(msg, prefix+" : ")))
+		}
+	}
+
+	switch st.Code() {
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return errors.Wrap(pk.ErrPermissionDenied, errors.New(msg))
+	case codes.Internal:
+		return errors.Wrap(pk.ErrInternalError, errors.New(msg))
+	default:
+		return errors.New(msg)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const validToken = "valid"
+
+type keeperMock struct {
+	pk.PasswordKeeper
+	accounts []pk.Account
+}
+
+func (k keeperMock) Get(ctx context.Context, token, name, username string) (pk.Account, error) {
+	if token != validToken {
+		return pk.Account{}, errors.Wrap(pk.ErrPermissionDenied, errors.New("token expired"))
+	}
+
+	for _, a := range k.accounts {
+		if a.Name == name && a.UserName == username {
+			return a, nil
+		}
+	}
+
+	return pk.Account{}, errors.Wrap(pk.ErrInternalError, errors.New("no rows"))
+}
+
+func (k keeperMock) List(ctx context.Context, token string, query pk.Query) ([]pk.Account, string, error) {
+	if token != validToken {
+		return nil, "", pk.ErrPermissionDenied
+	}
+
+	if query.PageSize > 0 && query.PageSize < len(k.accounts) {
+		return k.accounts[:query.PageSize], query.Name + "-next", nil
+	}
+
+	return k.accounts, "", nil
+}
+
+func TestClient(t *testing.T) {
+	accounts := []pk.Account{
+		{Name: "github", UserName: "alice", Email: "alice@example.com", Password: "s3cr3t",
+			URLs: []string{"https://github.com/login"}, Tags: []string{"dev"}, Folder: "work",
+			Fields: []pk.Field{{Name: "recovery", Value: "1234-5678", Secret: true}},
+			OTP:    "otpauth://totp/github:alice?algorithm=SHA1&digits=6&issuer=github&period=30&secret=JBSWY3DPEHPK3PXP"},
+		{Name: "gitlab", UserName: "alice", Email: "alice@example.com", Password: "t0p"},
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	RegisterPasswordKeeperServer(server, NewServer(keeperMock{accounts: accounts}))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	dialer := func(ctx context.Context, s string) (net.Conn, error) {
+		return listener.Dial()
+	}
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+
+	keeper := NewClient(conn)
+	ctx := context.Background()
+
+	got, err := keeper.Get(ctx, validToken, "github", "alice")
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, accounts[0]) {
+		t.Errorf("Get() = %v, want %v", got, accounts[0])
+	}
+
+	_, err = keeper.Get(ctx, "expired", "github", "alice")
+	if !errors.Contains(err, pk.ErrPermissionDenied) {
+		t.Errorf("Get() error = %v, want %v", err, pk.ErrPermissionDenied)
+	}
+
+	_, err = keeper.Get(ctx, validToken, "bitbucket", "alice")
+	if !errors.Contains(err, pk.ErrInternalError) {
+		t.Errorf("Get() error = %v, want %v", err, pk.ErrInternalError)
+	}
+
+	list, next, err := keeper.List(ctx, validToken, pk.Query{Name: "git"})
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(list) != len(accounts) || next != "" {
+		t.Errorf("List() returned %v accounts and cursor %q, want %v and none", len(list), next, len(accounts))
+	}
+
+	list, next, err = keeper.List(ctx, validToken, pk.Query{Name: "git", PageSize: 1})
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(list) != 1 || next != "git-next" {
+		t.Errorf("List() returned %v accounts and cursor %q, want 1 and %q", len(list), next, "git-next")
+	}
+}
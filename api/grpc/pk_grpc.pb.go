@@ -0,0 +1,837 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package grpc
+
+import (
+	context "context"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// PasswordKeeperClient is the client API for PasswordKeeper service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PasswordKeeperClient interface {
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Account, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (PasswordKeeper_ListClient, error)
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Account, error)
+	AddAll(ctx context.Context, in *AddAllRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	DeleteAll(ctx context.Context, in *DeleteAllRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*Account, error)
+	PruneHistory(ctx context.Context, in *PruneHistoryRequest, opts ...grpc.CallOption) (*PruneHistoryResponse, error)
+	Trash(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*TrashResponse, error)
+	Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeResponse, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	EnableTwoFactor(ctx context.Context, in *EnableTwoFactorRequest, opts ...grpc.CallOption) (*EnableTwoFactorResponse, error)
+	DisableTwoFactor(ctx context.Context, in *DisableTwoFactorRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	Export(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (PasswordKeeper_ExportClient, error)
+	Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReport, error)
+}
+
+type passwordKeeperClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPasswordKeeperClient(cc grpc.ClientConnInterface) PasswordKeeperClient {
+	return &passwordKeeperClient{cc}
+}
+
+func (c *passwordKeeperClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Add", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Account, error) {
+	out := new(Account)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (PasswordKeeper_ListClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_PasswordKeeper_serviceDesc.Streams[0], "/pk.PasswordKeeper/List", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &passwordKeeperListClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PasswordKeeper_ListClient interface {
+	Recv() (*Account, error)
+	grpc.ClientStream
+}
+
+type passwordKeeperListClient struct {
+	grpc.ClientStream
+}
+
+func (x *passwordKeeperListClient) Recv() (*Account, error) {
+	m := new(Account)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *passwordKeeperClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Account, error) {
+	out := new(Account)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) AddAll(ctx context.Context, in *AddAllRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/AddAll", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) DeleteAll(ctx context.Context, in *DeleteAllRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/DeleteAll", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/History", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*Account, error) {
+	out := new(Account)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Rollback", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) PruneHistory(ctx context.Context, in *PruneHistoryRequest, opts ...grpc.CallOption) (*PruneHistoryResponse, error) {
+	out := new(PruneHistoryResponse)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/PruneHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Trash(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*TrashResponse, error) {
+	out := new(TrashResponse)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Trash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Restore", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeResponse, error) {
+	out := new(PurgeResponse)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Purge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) EnableTwoFactor(ctx context.Context, in *EnableTwoFactorRequest, opts ...grpc.CallOption) (*EnableTwoFactorResponse, error) {
+	out := new(EnableTwoFactorResponse)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/EnableTwoFactor", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) DisableTwoFactor(ctx context.Context, in *DisableTwoFactorRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/DisableTwoFactor", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordKeeperClient) Export(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (PasswordKeeper_ExportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_PasswordKeeper_serviceDesc.Streams[1], "/pk.PasswordKeeper/Export", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &passwordKeeperExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PasswordKeeper_ExportClient interface {
+	Recv() (*Entry, error)
+	grpc.ClientStream
+}
+
+type passwordKeeperExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *passwordKeeperExportClient) Recv() (*Entry, error) {
+	m := new(Entry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *passwordKeeperClient) Import(ctx context.Context, in *ImportRequest, opts ...grpc.CallOption) (*ImportReport, error) {
+	out := new(ImportReport)
+	err := c.cc.Invoke(ctx, "/pk.PasswordKeeper/Import", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PasswordKeeperServer is the server API for PasswordKeeper service.
+// All implementations must embed UnimplementedPasswordKeeperServer
+// for forward compatibility
+type PasswordKeeperServer interface {
+	Register(context.Context, *RegisterRequest) (*empty.Empty, error)
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	Add(context.Context, *AddRequest) (*empty.Empty, error)
+	Get(context.Context, *GetRequest) (*Account, error)
+	Delete(context.Context, *DeleteRequest) (*empty.Empty, error)
+	List(*ListRequest, PasswordKeeper_ListServer) error
+	Update(context.Context, *UpdateRequest) (*Account, error)
+	AddAll(context.Context, *AddAllRequest) (*empty.Empty, error)
+	DeleteAll(context.Context, *DeleteAllRequest) (*empty.Empty, error)
+	History(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	Rollback(context.Context, *RollbackRequest) (*Account, error)
+	PruneHistory(context.Context, *PruneHistoryRequest) (*PruneHistoryResponse, error)
+	Trash(context.Context, *empty.Empty) (*TrashResponse, error)
+	Restore(context.Context, *RestoreRequest) (*empty.Empty, error)
+	Purge(context.Context, *PurgeRequest) (*PurgeResponse, error)
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	EnableTwoFactor(context.Context, *EnableTwoFactorRequest) (*EnableTwoFactorResponse, error)
+	DisableTwoFactor(context.Context, *DisableTwoFactorRequest) (*empty.Empty, error)
+	Export(*empty.Empty, PasswordKeeper_ExportServer) error
+	Import(context.Context, *ImportRequest) (*ImportReport, error)
+	mustEmbedUnimplementedPasswordKeeperServer()
+}
+
+// UnimplementedPasswordKeeperServer must be embedded to have forward compatible implementations.
+type UnimplementedPasswordKeeperServer struct {
+}
+
+func (UnimplementedPasswordKeeperServer) Register(context.Context, *RegisterRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Add(context.Context, *AddRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Get(context.Context, *GetRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Delete(context.Context, *DeleteRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedPasswordKeeperServer) List(*ListRequest, PasswordKeeper_ListServer) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Update(context.Context, *UpdateRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedPasswordKeeperServer) AddAll(context.Context, *AddAllRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddAll not implemented")
+}
+func (UnimplementedPasswordKeeperServer) DeleteAll(context.Context, *DeleteAllRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAll not implemented")
+}
+func (UnimplementedPasswordKeeperServer) History(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Rollback(context.Context, *RollbackRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (UnimplementedPasswordKeeperServer) PruneHistory(context.Context, *PruneHistoryRequest) (*PruneHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PruneHistory not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Trash(context.Context, *empty.Empty) (*TrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trash not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Restore(context.Context, *RestoreRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Purge(context.Context, *PurgeRequest) (*PurgeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Purge not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedPasswordKeeperServer) EnableTwoFactor(context.Context, *EnableTwoFactorRequest) (*EnableTwoFactorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableTwoFactor not implemented")
+}
+func (UnimplementedPasswordKeeperServer) DisableTwoFactor(context.Context, *DisableTwoFactorRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableTwoFactor not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Export(*empty.Empty, PasswordKeeper_ExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedPasswordKeeperServer) Import(context.Context, *ImportRequest) (*ImportReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (UnimplementedPasswordKeeperServer) mustEmbedUnimplementedPasswordKeeperServer() {}
+
+// UnsafePasswordKeeperServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PasswordKeeperServer will
+// result in compilation errors.
+type UnsafePasswordKeeperServer interface {
+	mustEmbedUnimplementedPasswordKeeperServer()
+}
+
+func RegisterPasswordKeeperServer(s grpc.ServiceRegistrar, srv PasswordKeeperServer) {
+	s.RegisterService(&_PasswordKeeper_serviceDesc, srv)
+}
+
+func _PasswordKeeper_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Add",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Add(ctx, req.(*AddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PasswordKeeperServer).List(m, &passwordKeeperListServer{stream})
+}
+
+type PasswordKeeper_ListServer interface {
+	Send(*Account) error
+	grpc.ServerStream
+}
+
+type passwordKeeperListServer struct {
+	grpc.ServerStream
+}
+
+func (x *passwordKeeperListServer) Send(m *Account) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PasswordKeeper_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_AddAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).AddAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/AddAll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).AddAll(ctx, req.(*AddAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_DeleteAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).DeleteAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/DeleteAll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).DeleteAll(ctx, req.(*DeleteAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/History",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Rollback",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Rollback(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_PruneHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PruneHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).PruneHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/PruneHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).PruneHistory(ctx, req.(*PruneHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Trash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Trash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Trash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Trash(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Restore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Restore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Restore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Restore(ctx, req.(*RestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Purge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Purge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Purge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Purge(ctx, req.(*PurgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_EnableTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnableTwoFactorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).EnableTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/EnableTwoFactor",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).EnableTwoFactor(ctx, req.(*EnableTwoFactorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_DisableTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableTwoFactorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).DisableTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/DisableTwoFactor",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).DisableTwoFactor(ctx, req.(*DisableTwoFactorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordKeeper_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(empty.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PasswordKeeperServer).Export(m, &passwordKeeperExportServer{stream})
+}
+
+type PasswordKeeper_ExportServer interface {
+	Send(*Entry) error
+	grpc.ServerStream
+}
+
+type passwordKeeperExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *passwordKeeperExportServer) Send(m *Entry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PasswordKeeper_Import_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordKeeperServer).Import(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pk.PasswordKeeper/Import",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordKeeperServer).Import(ctx, req.(*ImportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _PasswordKeeper_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pk.PasswordKeeper",
+	HandlerType: (*PasswordKeeperServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _PasswordKeeper_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _PasswordKeeper_Login_Handler,
+		},
+		{
+			MethodName: "Add",
+			Handler:    _PasswordKeeper_Add_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _PasswordKeeper_Get_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _PasswordKeeper_Delete_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _PasswordKeeper_Update_Handler,
+		},
+		{
+			MethodName: "AddAll",
+			Handler:    _PasswordKeeper_AddAll_Handler,
+		},
+		{
+			MethodName: "DeleteAll",
+			Handler:    _PasswordKeeper_DeleteAll_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _PasswordKeeper_History_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _PasswordKeeper_Rollback_Handler,
+		},
+		{
+			MethodName: "PruneHistory",
+			Handler:    _PasswordKeeper_PruneHistory_Handler,
+		},
+		{
+			MethodName: "Trash",
+			Handler:    _PasswordKeeper_Trash_Handler,
+		},
+		{
+			MethodName: "Restore",
+			Handler:    _PasswordKeeper_Restore_Handler,
+		},
+		{
+			MethodName: "Purge",
+			Handler:    _PasswordKeeper_Purge_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _PasswordKeeper_Search_Handler,
+		},
+		{
+			MethodName: "EnableTwoFactor",
+			Handler:    _PasswordKeeper_EnableTwoFactor_Handler,
+		},
+		{
+			MethodName: "DisableTwoFactor",
+			Handler:    _PasswordKeeper_DisableTwoFactor_Handler,
+		},
+		{
+			MethodName: "Import",
+			Handler:    _PasswordKeeper_Import_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _PasswordKeeper_List_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _PasswordKeeper_Export_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pk.proto",
+}
@@ -0,0 +1,462 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+//go:generate protoc -I . --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. pk.proto
+
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authKey is the metadata key carrying the token issued by Login.
+const authKey = "authorization"
+
+// nextKey is the trailer carrying the cursor of the next List page.
+const nextKey = "next"
+
+type grpcServer struct {
+	UnimplementedPasswordKeeperServer
+	keeper pk.PasswordKeeper
+}
+
+var _ PasswordKeeperServer = (*grpcServer)(nil)
+
+// NewServer returns a PasswordKeeperServer backed by keeper. Register it
+// with RegisterPasswordKeeperServer.
+func NewServer(keeper pk.PasswordKeeper) PasswordKeeperServer {
+	return &grpcServer{keeper: keeper}
+}
+
+func (s *grpcServer) Register(ctx context.Context, req *RegisterRequest) (*empty.Empty, error) {
+	err := s.keeper.Register(ctx, req.GetUsername(), req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcServer) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	token, err := s.keeper.Login(ctx, req.GetUsername(), req.GetPassword(), req.GetCode())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &LoginResponse{Token: token}, nil
+}
+
+func (s *grpcServer) Add(ctx context.Context, req *AddRequest) (*empty.Empty, error) {
+	err := s.keeper.Add(ctx, tokenFromContext(ctx), toAccount(req.GetAccount()))
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcServer) Get(ctx context.Context, req *GetRequest) (*Account, error) {
+	account, err := s.keeper.Get(ctx, tokenFromContext(ctx), req.GetName(), req.GetUsername())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return fromAccount(account), nil
+}
+
+func (s *grpcServer) Delete(ctx context.Context, req *DeleteRequest) (*empty.Empty, error) {
+	err := s.keeper.Delete(ctx, tokenFromContext(ctx), req.GetName(), req.GetUsername())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcServer) List(req *ListRequest, stream PasswordKeeper_ListServer) error {
+	ctx := stream.Context()
+
+	query, err := toQuery(req)
+	if err != nil {
+		return encodeError(err)
+	}
+
+	accounts, next, err := s.keeper.List(ctx, tokenFromContext(ctx), query)
+	if err != nil {
+		return encodeError(err)
+	}
+
+	if next != "" {
+		stream.SetTrailer(metadata.Pairs(nextKey, next))
+	}
+
+	for _, account := range accounts {
+		if err := stream.Send(fromAccount(account)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *grpcServer) Update(ctx context.Context, req *UpdateRequest) (*Account, error) {
+	account, err := s.keeper.Update(ctx, tokenFromContext(ctx),
+		req.GetName(), req.GetUsername(), toAccount(req.GetAccount()))
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return fromAccount(account), nil
+}
+
+func (s *grpcServer) AddAll(ctx context.Context, req *AddAllRequest) (*empty.Empty, error) {
+	var accounts []pk.Account
+	for _, a := range req.GetAccounts() {
+		accounts = append(accounts, toAccount(a))
+	}
+
+	err := s.keeper.AddAll(ctx, tokenFromContext(ctx), accounts)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcServer) DeleteAll(ctx context.Context, req *DeleteAllRequest) (*empty.Empty, error) {
+	err := s.keeper.DeleteAll(ctx, tokenFromContext(ctx), req.GetArgs().AsMap())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func tokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(authKey)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (s *grpcServer) History(ctx context.Context, req *HistoryRequest) (*HistoryResponse, error) {
+	versions, err := s.keeper.History(ctx, tokenFromContext(ctx), req.GetName(), req.GetUsername())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	res := &HistoryResponse{}
+	for _, v := range versions {
+		res.Versions = append(res.Versions, &Version{
+			Version:  int64(v.Version),
+			Account:  fromAccount(v.Account),
+			Replaced: v.Replaced,
+		})
+	}
+
+	return res, nil
+}
+
+func (s *grpcServer) Rollback(ctx context.Context, req *RollbackRequest) (*Account, error) {
+	account, err := s.keeper.Rollback(ctx, tokenFromContext(ctx),
+		req.GetName(), req.GetUsername(), int(req.GetVersion()))
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return fromAccount(account), nil
+}
+
+func (s *grpcServer) PruneHistory(ctx context.Context, req *PruneHistoryRequest) (*PruneHistoryResponse, error) {
+	removed, err := s.keeper.PruneHistory(ctx, tokenFromContext(ctx), req.GetName(), req.GetUsername(),
+		int(req.GetKeep()), time.Duration(req.GetMaxAge())*time.Second)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &PruneHistoryResponse{Removed: int64(removed)}, nil
+}
+
+func (s *grpcServer) Trash(ctx context.Context, _ *empty.Empty) (*TrashResponse, error) {
+	accounts, err := s.keeper.Trash(ctx, tokenFromContext(ctx))
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	res := &TrashResponse{}
+	for _, a := range accounts {
+		res.Accounts = append(res.Accounts, &Trashed{
+			Name:     a.Name,
+			Username: a.UserName,
+			Email:    a.Email,
+			Created:  a.Created,
+			Deleted:  a.Deleted,
+		})
+	}
+
+	return res, nil
+}
+
+func (s *grpcServer) Restore(ctx context.Context, req *RestoreRequest) (*empty.Empty, error) {
+	err := s.keeper.Restore(ctx, tokenFromContext(ctx), req.GetName(), req.GetUsername())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcServer) Purge(ctx context.Context, req *PurgeRequest) (*PurgeResponse, error) {
+	purged, err := s.keeper.Purge(ctx, tokenFromContext(ctx), time.Duration(req.GetOlderThan())*time.Second)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &PurgeResponse{Purged: int64(purged)}, nil
+}
+
+func encodeError(err error) error {
+	switch {
+	case errors.Contains(err, pk.ErrCodeRequired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Contains(err, pk.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Contains(err, pk.ErrNotFound),
+		errors.Contains(err, pk.ErrVersionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Contains(err, strength.ErrPolicyViolation),
+		errors.Contains(err, pk.ErrInvalidArgs):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Contains(err, pk.ErrInternalError),
+		errors.Contains(err, pk.ErrCriticalFailure):
+		return status.Error(codes.Internal, err.Error())
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+}
+
+func (s *grpcServer) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	matches, err := s.keeper.Search(ctx, tokenFromContext(ctx), req.GetText(), int(req.GetLimit()))
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	res := &SearchResponse{}
+	for _, m := range matches {
+		res.Matches = append(res.Matches, &Match{
+			Name:     m.Name,
+			Username: m.UserName,
+			Email:    m.Email,
+			Urls:     m.URLs,
+			Tags:     m.Tags,
+			Folder:   m.Folder,
+			Field:    m.Field,
+			Score:    int64(m.Score),
+		})
+	}
+
+	return res, nil
+}
+
+func (s *grpcServer) EnableTwoFactor(ctx context.Context, req *EnableTwoFactorRequest) (*EnableTwoFactorResponse, error) {
+	recovery, err := s.keeper.EnableTwoFactor(ctx, tokenFromContext(ctx), req.GetSecret(), req.GetCode())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &EnableTwoFactorResponse{Recovery: recovery}, nil
+}
+
+func (s *grpcServer) DisableTwoFactor(ctx context.Context, req *DisableTwoFactorRequest) (*empty.Empty, error) {
+	err := s.keeper.DisableTwoFactor(ctx, tokenFromContext(ctx), req.GetCode())
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (s *grpcServer) Export(_ *empty.Empty, stream PasswordKeeper_ExportServer) error {
+	ctx := stream.Context()
+
+	entries, err := s.keeper.Export(ctx, tokenFromContext(ctx))
+	if err != nil {
+		return encodeError(err)
+	}
+
+	for _, e := range entries {
+		if err := stream.Send(fromEntry(e)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *grpcServer) Import(ctx context.Context, req *ImportRequest) (*ImportReport, error) {
+	var entries []pk.Entry
+	for _, e := range req.GetEntries() {
+		entries = append(entries, toEntry(e))
+	}
+
+	report, err := s.keeper.Import(ctx, tokenFromContext(ctx), entries, pk.Conflict(req.GetConflict()))
+	if err != nil {
+		return nil, encodeError(err)
+	}
+
+	return &ImportReport{
+		Added:    int64(report.Added),
+		Replaced: int64(report.Replaced),
+		Renamed:  int64(report.Renamed),
+		Skipped:  int64(report.Skipped),
+	}, nil
+}
+
+func toEntry(e *Entry) pk.Entry {
+	entry := pk.Entry{Account: toAccount(e.GetAccount())}
+	for _, v := range e.GetHistory() {
+		entry.History = append(entry.History, pk.Version{
+			Version:  int(v.GetVersion()),
+			Account:  toAccount(v.GetAccount()),
+			Replaced: v.GetReplaced(),
+		})
+	}
+	return entry
+}
+
+func fromEntry(e pk.Entry) *Entry {
+	entry := &Entry{Account: fromAccount(e.Account)}
+	for _, v := range e.History {
+		entry.History = append(entry.History, &Version{
+			Version:  int64(v.Version),
+			Account:  fromAccount(v.Account),
+			Replaced: v.Replaced,
+		})
+	}
+	return entry
+}
+
+func toQuery(req *ListRequest) (pk.Query, error) {
+	query := pk.Query{
+		Name:     req.GetName(),
+		UserName: req.GetUsername(),
+		Email:    req.GetEmail(),
+		Tag:      req.GetTag(),
+		Folder:   req.GetFolder(),
+		Sort:     req.GetSort(),
+		PageSize: int(req.GetPageSize()),
+		Cursor:   req.GetCursor(),
+	}
+
+	var err error
+	if before := req.GetCreatedBefore(); before != "" {
+		if query.CreatedBefore, err = time.Parse(time.RFC3339, before); err != nil {
+			return pk.Query{}, errors.Wrap(pk.ErrInvalidArgs, err)
+		}
+	}
+	if after := req.GetCreatedAfter(); after != "" {
+		if query.CreatedAfter, err = time.Parse(time.RFC3339, after); err != nil {
+			return pk.Query{}, errors.Wrap(pk.ErrInvalidArgs, err)
+		}
+	}
+
+	return query, nil
+}
+
+func fromQuery(query pk.Query) *ListRequest {
+	req := &ListRequest{
+		Name:     query.Name,
+		Username: query.UserName,
+		Email:    query.Email,
+		Tag:      query.Tag,
+		Folder:   query.Folder,
+		Sort:     query.Sort,
+		PageSize: int64(query.PageSize),
+		Cursor:   query.Cursor,
+	}
+
+	if !query.CreatedBefore.IsZero() {
+		req.CreatedBefore = query.CreatedBefore.Format(time.RFC3339)
+	}
+	if !query.CreatedAfter.IsZero() {
+		req.CreatedAfter = query.CreatedAfter.Format(time.RFC3339)
+	}
+
+	return req
+}
+
+func toAccount(a *Account) pk.Account {
+	account := pk.Account{
+		Name:     a.GetName(),
+		UserName: a.GetUsername(),
+		Email:    a.GetEmail(),
+		Password: a.GetPassword(),
+		Created:  a.GetCreated(),
+		URLs:     a.GetUrls(),
+		Notes:    a.GetNotes(),
+		Tags:     a.GetTags(),
+		Folder:   a.GetFolder(),
+		OTP:      a.GetOtp(),
+	}
+
+	for _, f := range a.GetFields() {
+		account.Fields = append(account.Fields, pk.Field{
+			Name:   f.GetName(),
+			Value:  f.GetValue(),
+			Secret: f.GetSecret(),
+		})
+	}
+
+	return account
+}
+
+func fromAccount(a pk.Account) *Account {
+	account := &Account{
+		Name:     a.Name,
+		Username: a.UserName,
+		Email:    a.Email,
+		Password: a.Password,
+		Created:  a.Created,
+		Urls:     a.URLs,
+		Notes:    a.Notes,
+		Tags:     a.Tags,
+		Folder:   a.Folder,
+		Otp:      a.OTP,
+	}
+
+	for _, f := range a.Fields {
+		account.Fields = append(account.Fields, &Field{
+			Name:   f.Name,
+			Value:  f.Value,
+			Secret: f.Secret,
+		})
+	}
+
+	return account
+}
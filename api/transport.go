@@ -0,0 +1,321 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
+)
+
+const contentType = "application/json"
+
+var (
+	// ErrMalformedEntity indicates a request body that could not be decoded.
+	ErrMalformedEntity = errors.New("malformed entity")
+
+	// ErrUnsupportedContentType indicates a request with a content type other than json.
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+)
+
+// Routes of the HTTP API. Every route accepts a POST with the json
+// encoded request type of the matching PasswordKeeper method.
+const (
+	RegisterPath   = "/register"
+	LoginPath      = "/login"
+	AddPath        = "/add"
+	GetPath        = "/get"
+	DeletePath     = "/delete"
+	ListPath       = "/list"
+	UpdatePath     = "/update"
+	AddAllPath     = "/addall"
+	DeleteAllPath  = "/deleteall"
+	HistoryPath    = "/history"
+	RollbackPath   = "/rollback"
+	PrunePath      = "/prune"
+	TrashPath      = "/trash"
+	RestorePath    = "/restore"
+	PurgePath      = "/purge"
+	SearchPath     = "/search"
+	Enable2FAPath  = "/2fa/enable"
+	Disable2FAPath = "/2fa/disable"
+	ExportPath     = "/export"
+	ImportPath     = "/import"
+)
+
+// ErrorResponse is the body sent back whenever a request fails.
+type ErrorResponse struct {
+	Error    string             `json:"error"`
+	Failures []strength.Failure `json:"failures,omitempty"`
+}
+
+type endpoint func(ctx context.Context, r *http.Request) (pk.Failure, error)
+
+// MakeHandler returns a HTTP handler exposing keeper over json.
+func MakeHandler(keeper pk.PasswordKeeper) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle(RegisterPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.RegisterRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		err := keeper.Register(ctx, req.Username, req.Email, req.Password)
+		return pk.RegisterResponse{Err: err}, nil
+	}))
+
+	mux.Handle(LoginPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.LoginRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		token, err := keeper.Login(ctx, req.Username, req.Password, req.Code)
+		return pk.LoginResponse{Token: token, Err: err}, nil
+	}))
+
+	mux.Handle(AddPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.AddRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		err := keeper.Add(ctx, req.Token, req.Account)
+		return pk.AddResponse{Err: err}, nil
+	}))
+
+	mux.Handle(GetPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.GetRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		account, err := keeper.Get(ctx, req.Token, req.Name, req.Username)
+		return pk.GetResponse{Account: account, Err: err}, nil
+	}))
+
+	mux.Handle(DeletePath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.DeleteRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		err := keeper.Delete(ctx, req.Token, req.Name, req.Username)
+		return pk.DeleteResponse{Err: err}, nil
+	}))
+
+	mux.Handle(ListPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.ListRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		accounts, next, err := keeper.List(ctx, req.Token, req.Query)
+		return pk.ListResponse{Accounts: accounts, Next: next, Err: err}, nil
+	}))
+
+	mux.Handle(UpdatePath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.UpdateRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		acc, err := keeper.Update(ctx, req.Token, req.Name, req.Username, req.Account)
+		return pk.UpdateResponse{Acc: acc, Err: err}, nil
+	}))
+
+	mux.Handle(AddAllPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.AddAllRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		err := keeper.AddAll(ctx, req.Token, req.Accounts)
+		return pk.AddAllResponse{Err: err}, nil
+	}))
+
+	mux.Handle(DeleteAllPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.DeleteAllRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		err := keeper.DeleteAll(ctx, req.Token, req.Args)
+		return pk.DeleteAllResponse{Err: err}, nil
+	}))
+
+	mux.Handle(HistoryPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.HistoryRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		versions, err := keeper.History(ctx, req.Token, req.Name, req.Username)
+		return pk.HistoryResponse{Versions: versions, Err: err}, nil
+	}))
+
+	mux.Handle(RollbackPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.RollbackRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		account, err := keeper.Rollback(ctx, req.Token, req.Name, req.Username, req.Version)
+		return pk.RollbackResponse{Account: account, Err: err}, nil
+	}))
+
+	mux.Handle(PrunePath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.PruneHistoryRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		removed, err := keeper.PruneHistory(ctx, req.Token, req.Name, req.Username, req.Keep, req.MaxAge)
+		return pk.PruneHistoryResponse{Removed: removed, Err: err}, nil
+	}))
+
+	mux.Handle(TrashPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.TrashRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		accounts, err := keeper.Trash(ctx, req.Token)
+		return pk.TrashResponse{Accounts: accounts, Err: err}, nil
+	}))
+
+	mux.Handle(RestorePath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.RestoreRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		err := keeper.Restore(ctx, req.Token, req.Name, req.Username)
+		return pk.RestoreResponse{Err: err}, nil
+	}))
+
+	mux.Handle(PurgePath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.PurgeRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		purged, err := keeper.Purge(ctx, req.Token, req.OlderThan)
+		return pk.PurgeResponse{Purged: purged, Err: err}, nil
+	}))
+
+	mux.Handle(SearchPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.SearchRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		matches, err := keeper.Search(ctx, req.Token, req.Text, req.Limit)
+		return pk.SearchResponse{Matches: matches, Err: err}, nil
+	}))
+
+	mux.Handle(Enable2FAPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.EnableTwoFactorRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		recovery, err := keeper.EnableTwoFactor(ctx, req.Token, req.Secret, req.Code)
+		return pk.EnableTwoFactorResponse{Recovery: recovery, Err: err}, nil
+	}))
+
+	mux.Handle(Disable2FAPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.DisableTwoFactorRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		err := keeper.DisableTwoFactor(ctx, req.Token, req.Code)
+		return pk.DisableTwoFactorResponse{Err: err}, nil
+	}))
+
+	mux.Handle(ExportPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.ExportRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		entries, err := keeper.Export(ctx, req.Token)
+		return pk.ExportResponse{Entries: entries, Err: err}, nil
+	}))
+
+	mux.Handle(ImportPath, handle(func(ctx context.Context, r *http.Request) (pk.Failure, error) {
+		var req pk.ImportRequest
+		if err := decode(r, &req); err != nil {
+			return nil, err
+		}
+		report, err := keeper.Import(ctx, req.Token, req.Entries, req.Conflict)
+		return pk.ImportResponse{Report: report, Err: err}, nil
+	}))
+
+	return mux
+}
+
+func handle(e endpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		response, err := e(r.Context(), r)
+		if err != nil {
+			encodeError(w, err)
+			return
+		}
+
+		if err := response.Failed(); err != nil {
+			encodeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if r.Header.Get("Content-Type") != contentType {
+		return ErrUnsupportedContentType
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errors.Wrap(ErrMalformedEntity, err)
+	}
+
+	return nil
+}
+
+func encodeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", contentType)
+
+	switch {
+	case errors.Contains(err, ErrMalformedEntity),
+		errors.Contains(err, pk.ErrInvalidArgs):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Contains(err, ErrUnsupportedContentType):
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+	case errors.Contains(err, pk.ErrCodeRequired):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Contains(err, pk.ErrPermissionDenied):
+		w.WriteHeader(http.StatusForbidden)
+	case errors.Contains(err, pk.ErrNotFound),
+		errors.Contains(err, pk.ErrVersionNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Contains(err, strength.ErrPolicyViolation):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	res := ErrorResponse{Error: err.Error()}
+	if verr, ok := err.(*strength.ValidationError); ok {
+		res.Failures = verr.Failures
+	}
+
+	_ = json.NewEncoder(w).Encode(res)
+}
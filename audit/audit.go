@@ -0,0 +1,159 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package audit reports on the health of the stored passwords. Reports
+// never contain the passwords themselves.
+package audit
+
+import (
+	"crypto/sha256"
+	"sort"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/breach"
+	"github.com/hackaio/pk/strength"
+)
+
+// Options tune what counts as an issue.
+type Options struct {
+	// MinScore is the lowest strength score not reported as weak.
+	MinScore int
+
+	// MaxAge is how long a password may stay unchanged, zero turns the
+	// check off.
+	MaxAge time.Duration
+
+	// Now is used to compute ages, it defaults to time.Now.
+	Now time.Time
+
+	// Breach looks passwords up in breach corpora when set.
+	Breach breach.Checker
+}
+
+// Ref names an account in a report.
+type Ref struct {
+	Name     string `json:"name" yaml:"name"`
+	UserName string `json:"username" yaml:"username"`
+}
+
+// Weak is an account whose password scored below Options.MinScore.
+type Weak struct {
+	Ref     `yaml:",inline"`
+	Score   int     `json:"score" yaml:"score"`
+	Entropy float64 `json:"entropy" yaml:"entropy"`
+}
+
+// Reused is a group of accounts sharing the same password.
+type Reused struct {
+	Accounts []Ref `json:"accounts" yaml:"accounts"`
+}
+
+// Old is an account whose password is older than Options.MaxAge.
+type Old struct {
+	Ref     `yaml:",inline"`
+	Created string `json:"created" yaml:"created"`
+	AgeDays int    `json:"age_days" yaml:"age_days"`
+}
+
+// Breached is an account whose password appears in a breach corpus.
+type Breached struct {
+	Ref   `yaml:",inline"`
+	Count int `json:"count" yaml:"count"`
+}
+
+// Report is the result of an audit.
+type Report struct {
+	Total    int        `json:"total" yaml:"total"`
+	Weak     []Weak     `json:"weak" yaml:"weak"`
+	Reused   []Reused   `json:"reused" yaml:"reused"`
+	Old      []Old      `json:"old" yaml:"old"`
+	Breached []Breached `json:"breached,omitempty" yaml:"breached,omitempty"`
+}
+
+// Issues is the number of findings in r.
+func (r Report) Issues() int {
+	n := len(r.Weak) + len(r.Old) + len(r.Breached)
+	for _, group := range r.Reused {
+		n += len(group.Accounts)
+	}
+	return n
+}
+
+// Passwords audits accounts.
+func Passwords(accounts []pk.Account, opts Options) (Report, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	report := Report{
+		Total:  len(accounts),
+		Weak:   []Weak{},
+		Reused: []Reused{},
+		Old:    []Old{},
+	}
+	if opts.Breach != nil {
+		report.Breached = []Breached{}
+	}
+
+	// passwords are grouped by their hash so the map holds no secrets
+	groups := map[[sha256.Size]byte][]Ref{}
+	var order [][sha256.Size]byte
+
+	for _, a := range accounts {
+		ref := Ref{Name: a.Name, UserName: a.UserName}
+
+		result := strength.Estimate(a.Password, a.UserName, a.Email, a.Name)
+		if result.Score < opts.MinScore {
+			report.Weak = append(report.Weak, Weak{Ref: ref, Score: result.Score, Entropy: result.Entropy})
+		}
+
+		sum := sha256.Sum256([]byte(a.Password))
+		if _, ok := groups[sum]; !ok {
+			order = append(order, sum)
+		}
+		groups[sum] = append(groups[sum], ref)
+
+		if opts.Breach != nil {
+			count, err := opts.Breach.Count(a.Password)
+			if err != nil {
+				return Report{}, err
+			}
+			if count > 0 {
+				report.Breached = append(report.Breached, Breached{Ref: ref, Count: count})
+			}
+		}
+
+		if opts.MaxAge <= 0 {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339, a.Created)
+		if err != nil {
+			continue
+		}
+		if age := opts.Now.Sub(created); age > opts.MaxAge {
+			report.Old = append(report.Old, Old{Ref: ref, Created: a.Created, AgeDays: int(age.Hours() / 24)})
+		}
+	}
+
+	for _, sum := range order {
+		if refs := groups[sum]; len(refs) > 1 {
+			report.Reused = append(report.Reused, Reused{Accounts: refs})
+		}
+	}
+
+	sort.SliceStable(report.Weak, func(i, j int) bool { return report.Weak[i].Score < report.Weak[j].Score })
+	sort.SliceStable(report.Old, func(i, j int) bool { return report.Old[i].AgeDays > report.Old[j].AgeDays })
+
+	return report, nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackaio/pk"
+)
+
+type checkerMock map[string]int
+
+func (c checkerMock) Count(password string) (int, error) {
+	return c[password], nil
+}
+
+func TestPasswords(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	fresh := now.AddDate(0, 0, -10).Format(time.RFC3339)
+	stale := now.AddDate(-2, 0, 0).Format(time.RFC3339)
+
+	accounts := []pk.Account{
+		{Name: "github", UserName: "alice", Password: "x9#Lq2!vT7@m-orbit", Created: fresh},
+		{Name: "gitlab", UserName: "alice", Password: "x9#Lq2!vT7@m-orbit", Created: fresh},
+		{Name: "forum", UserName: "alice", Password: "password1", Created: stale},
+		{Name: "bank", UserName: "alice", Password: "Tr0ub4dor&3-horse-77", Created: fresh},
+	}
+
+	breached := checkerMock{"password1": 2413945}
+	report, err := Passwords(accounts, Options{MinScore: 3, MaxAge: 365 * 24 * time.Hour, Now: now, Breach: breached})
+	if err != nil {
+		t.Fatalf("Passwords() error = %v", err)
+	}
+
+	if report.Total != 4 {
+		t.Errorf("Total = %v, want 4", report.Total)
+	}
+	if len(report.Weak) != 1 || report.Weak[0].Name != "forum" {
+		t.Errorf("Weak = %+v, want forum", report.Weak)
+	}
+	if len(report.Reused) != 1 || len(report.Reused[0].Accounts) != 2 {
+		t.Errorf("Reused = %+v, want github and gitlab", report.Reused)
+	}
+	if len(report.Old) != 1 || report.Old[0].Name != "forum" || report.Old[0].AgeDays < 365 {
+		t.Errorf("Old = %+v, want forum", report.Old)
+	}
+	if len(report.Breached) != 1 || report.Breached[0].Name != "forum" || report.Breached[0].Count != 2413945 {
+		t.Errorf("Breached = %+v, want forum", report.Breached)
+	}
+	if report.Issues() != 5 {
+		t.Errorf("Issues() = %v, want 5", report.Issues())
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, a := range accounts {
+		if strings.Contains(string(b), a.Password) {
+			t.Errorf("report %s contains the password of %v", b, a.Name)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
+)
+
+//Entry is an account and its previous passwords, decrypted, the way
+//Export hands them out and Import takes them back
+type Entry struct {
+	Account Account   `json:"account" yaml:"account"`
+	History []Version `json:"history,omitempty" yaml:"history,omitempty"`
+}
+
+//Conflict says what Import does with an account that already exists
+type Conflict string
+
+const (
+	//ConflictSkip keeps the existing account
+	ConflictSkip Conflict = "skip"
+	//ConflictOverwrite replaces the existing account, its password moves
+	//to history
+	ConflictOverwrite Conflict = "overwrite"
+	//ConflictRename imports the account as "name (2)", "name (3)" ...
+	ConflictRename Conflict = "rename"
+)
+
+//ImportReport counts what Import did with the entries. Rejected counts
+//the entries the policy or breach middlewares left out, Failures says why
+type ImportReport struct {
+	Added    int                `json:"added" yaml:"added"`
+	Replaced int                `json:"replaced" yaml:"replaced"`
+	Renamed  int                `json:"renamed" yaml:"renamed"`
+	Skipped  int                `json:"skipped" yaml:"skipped"`
+	Rejected int                `json:"rejected" yaml:"rejected"`
+	Failures []strength.Failure `json:"failures,omitempty" yaml:"failures,omitempty"`
+}
+
+func (p passwordKeeper) Export(ctx context.Context, token string) (entries []Entry, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	dbAccounts, err := p.passwords.List(ctx, Query{})
+	if err != nil {
+		return nil, errors.Wrap(ErrInternalError, err)
+	}
+
+	entries = []Entry{}
+	for _, d := range dbAccounts {
+		account, err := d.toAccount(p)
+		if err != nil {
+			err1 := errors.New(fmt.Sprintf("error while decoding %v / %v: %v\n", d.Name, d.UserName, err))
+			return nil, errors.Wrap(ErrCriticalFailure, err1)
+		}
+
+		dbVersions, err := p.passwords.History(ctx, d.Name, d.UserName)
+		if err != nil {
+			return nil, errors.Wrap(ErrInternalError, err)
+		}
+
+		entry := Entry{Account: account}
+		for _, v := range dbVersions {
+			a, err := v.Account.toAccount(p)
+			if err != nil {
+				err1 := errors.New(fmt.Sprintf("error while decoding version %v of %v / %v: %v\n",
+					v.Version, d.Name, d.UserName, err))
+				return nil, errors.Wrap(ErrCriticalFailure, err1)
+			}
+			entry.History = append(entry.History, Version{Version: v.Version, Account: a, Replaced: v.Replaced})
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+//Import encrypts the entries with the keys of this keeper, so a backup
+//moves between machines. History keeps the time passwords were replaced
+//but is numbered after the versions already stored
+func (p passwordKeeper) Import(ctx context.Context, token string, entries []Entry, conflict Conflict) (report ImportReport, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return report, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	switch conflict {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return report, errors.Wrap(ErrInvalidArgs, errors.New(fmt.Sprintf("unknown conflict handling %q", conflict)))
+	}
+
+	for _, e := range entries {
+		if e.Account.Name == "" || e.Account.UserName == "" {
+			return report, errors.Wrap(ErrInvalidArgs, errors.New("account without a name or username"))
+		}
+	}
+
+	//the entries are imported all together or not at all
+	var importErr error
+	err = p.passwords.Transaction(ctx, func(store PasswordStore) error {
+		keeper := p
+		keeper.passwords = store
+		report, importErr = keeper.importEntries(ctx, entries, conflict)
+		return importErr
+	})
+	if importErr != nil {
+		return ImportReport{}, importErr
+	}
+	if err != nil {
+		return ImportReport{}, errors.Wrap(ErrInternalError, err)
+	}
+
+	return report, nil
+}
+
+//importEntries imports entries into the store of p, which runs in the
+//transaction of Import. An entry repeated in entries conflicts with the
+//one imported before it like with an account stored before
+func (p passwordKeeper) importEntries(ctx context.Context, entries []Entry, conflict Conflict) (report ImportReport, err error) {
+	imported := map[[2]string]bool{}
+
+	for _, e := range entries {
+		account := e.Account
+		key := [2]string{account.Name, account.UserName}
+
+		_, err = p.passwords.Get(ctx, account.Name, account.UserName)
+		exists := err == nil || imported[key]
+		if err != nil && !errors.Contains(err, ErrNotFound) {
+			return report, errors.Wrap(ErrInternalError, err)
+		}
+		imported[key] = true
+
+		if exists && conflict == ConflictSkip {
+			report.Skipped++
+			continue
+		}
+
+		if exists && conflict == ConflictRename {
+			account.Name, err = p.freeName(ctx, account.Name, account.UserName)
+			if err != nil {
+				return report, err
+			}
+		}
+
+		dbAccount, err := account.toDBAccount(p)
+		if err != nil {
+			err1 := errors.New(fmt.Sprintf("error while encrypting %v / %v: %v\n", account.Name, account.UserName, err))
+			return report, errors.Wrap(ErrCriticalFailure, err1)
+		}
+
+		if exists && conflict == ConflictOverwrite {
+			err = p.passwords.Update(ctx, account.Name, account.UserName, dbAccount)
+		} else {
+			err = p.passwords.Add(ctx, dbAccount)
+		}
+		if err != nil {
+			return report, errors.Wrap(ErrInternalError, err)
+		}
+
+		if err = p.importHistory(ctx, account, e.History); err != nil {
+			return report, err
+		}
+
+		switch {
+		case !exists:
+			report.Added++
+		case conflict == ConflictOverwrite:
+			report.Replaced++
+		default:
+			report.Renamed++
+		}
+	}
+
+	return report, nil
+}
+
+//importHistory adds versions to the history of account, oldest first
+func (p passwordKeeper) importHistory(ctx context.Context, account Account, versions []Version) error {
+	versions = append([]Version{}, versions...)
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+
+	for _, v := range versions {
+		a := v.Account
+		a.Name, a.UserName = account.Name, account.UserName
+
+		d, err := a.toDBAccount(p)
+		if err != nil {
+			err1 := errors.New(fmt.Sprintf("error while encrypting version %v of %v / %v: %v\n",
+				v.Version, a.Name, a.UserName, err))
+			return errors.Wrap(ErrCriticalFailure, err1)
+		}
+
+		err = p.passwords.AddVersion(ctx, DBVersion{Account: d, Replaced: v.Replaced})
+		if err != nil {
+			return errors.Wrap(ErrInternalError, err)
+		}
+	}
+
+	return nil
+}
+
+//freeName returns the first of "name (2)", "name (3)" ... not taken by
+//username
+func (p passwordKeeper) freeName(ctx context.Context, name, username string) (string, error) {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%v (%d)", name, n)
+
+		_, err := p.passwords.Get(ctx, candidate, username)
+		if errors.Contains(err, ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", errors.Wrap(ErrInternalError, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	old := "2021-01-01T00:00:00Z"
+
+	//the bundle has github with two old passwords and gitlab sharing the
+	//current password of github
+	entries := []Entry{
+		{
+			Account: Account{Name: "github", UserName: "alice", Password: "shared"},
+			History: []Version{
+				{Version: 2, Account: Account{Password: "b2"}, Replaced: old},
+				{Version: 1, Account: Account{Password: "b1"}, Replaced: old},
+			},
+		},
+		{Account: Account{Name: "gitlab", UserName: "alice", Password: "shared"}},
+	}
+
+	tests := []struct {
+		conflict Conflict
+		report   ImportReport
+		accounts map[string]string
+		history  []string
+	}{
+		{
+			conflict: ConflictSkip,
+			report:   ImportReport{Added: 1, Skipped: 1},
+			accounts: map[string]string{"github": "mine", "gitlab": "shared"},
+			history:  nil,
+		},
+		{
+			//the stored password is archived first, the imported history
+			//is numbered after it
+			conflict: ConflictOverwrite,
+			report:   ImportReport{Added: 1, Replaced: 1},
+			accounts: map[string]string{"github": "shared", "gitlab": "shared"},
+			history:  []string{"b2", "b1", "mine"},
+		},
+		{
+			conflict: ConflictRename,
+			report:   ImportReport{Added: 1, Renamed: 1},
+			accounts: map[string]string{"github": "mine", "github (2)": "shared", "gitlab": "shared"},
+			history:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.conflict), func(t *testing.T) {
+			keeper, _ := newTestKeeper()
+			if err := keeper.Add(ctx, "token", Account{Name: "github", UserName: "alice", Password: "mine"}); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+
+			report, err := keeper.Import(ctx, "token", entries, tt.conflict)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if !reflect.DeepEqual(report, tt.report) {
+				t.Errorf("Import() = %+v, want %+v", report, tt.report)
+			}
+
+			accounts, _, _ := keeper.List(ctx, "token", Query{})
+			got := map[string]string{}
+			for _, a := range accounts {
+				got[a.Name] = a.Password
+			}
+			if !reflect.DeepEqual(got, tt.accounts) {
+				t.Errorf("accounts = %v, want %v", got, tt.accounts)
+			}
+
+			versions, _ := keeper.History(ctx, "token", "github", "alice")
+			if h := passwords(versions); !reflect.DeepEqual(h, tt.history) {
+				t.Errorf("History() = %v, want %v", h, tt.history)
+			}
+			if tt.conflict == ConflictOverwrite && (versions[0].Version != 3 || versions[0].Replaced != old) {
+				t.Errorf("History() = %+v, want b2 as version 3 replaced at %v", versions[0], old)
+			}
+		})
+	}
+}
+
+func TestImportDuplicates(t *testing.T) {
+	ctx := context.Background()
+
+	//the bundle holds github twice, the second entry conflicts with the
+	//first one
+	entries := []Entry{
+		{Account: Account{Name: "github", UserName: "alice", Password: "one"}},
+		{Account: Account{Name: "github", UserName: "alice", Password: "two"}},
+	}
+
+	tests := []struct {
+		conflict Conflict
+		report   ImportReport
+		accounts map[string]string
+		history  []string
+	}{
+		{
+			conflict: ConflictSkip,
+			report:   ImportReport{Added: 1, Skipped: 1},
+			accounts: map[string]string{"github": "one"},
+			history:  nil,
+		},
+		{
+			conflict: ConflictOverwrite,
+			report:   ImportReport{Added: 1, Replaced: 1},
+			accounts: map[string]string{"github": "two"},
+			history:  []string{"one"},
+		},
+		{
+			conflict: ConflictRename,
+			report:   ImportReport{Added: 1, Renamed: 1},
+			accounts: map[string]string{"github": "one", "github (2)": "two"},
+			history:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.conflict), func(t *testing.T) {
+			keeper, _ := newTestKeeper()
+
+			report, err := keeper.Import(ctx, "token", entries, tt.conflict)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if !reflect.DeepEqual(report, tt.report) {
+				t.Errorf("Import() = %+v, want %+v", report, tt.report)
+			}
+
+			accounts, _, _ := keeper.List(ctx, "token", Query{})
+			got := map[string]string{}
+			for _, a := range accounts {
+				got[a.Name] = a.Password
+			}
+			if !reflect.DeepEqual(got, tt.accounts) {
+				t.Errorf("accounts = %v, want %v", got, tt.accounts)
+			}
+
+			versions, _ := keeper.History(ctx, "token", "github", "alice")
+			if h := passwords(versions); !reflect.DeepEqual(h, tt.history) {
+				t.Errorf("History() = %v, want %v", h, tt.history)
+			}
+		})
+	}
+}
+
+func TestImportAtomic(t *testing.T) {
+	keeper, store := newTestKeeper()
+	ctx := context.Background()
+
+	store.failAdd = "gitlab"
+	entries := []Entry{
+		{Account: Account{Name: "github", UserName: "alice", Password: "pw1"}},
+		{Account: Account{Name: "gitlab", UserName: "alice", Password: "pw2"}},
+	}
+
+	report, err := keeper.Import(ctx, "token", entries, ConflictSkip)
+	if !errors.Contains(err, ErrInternalError) || !reflect.DeepEqual(report, ImportReport{}) {
+		t.Errorf("Import() = %+v, %v, want nothing imported and %v", report, err, ErrInternalError)
+	}
+	if _, err = keeper.Get(ctx, "token", "github", "alice"); err != ErrNotFound {
+		t.Errorf("Get() of an entry imported before the failure error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package breach looks passwords up in a local copy of the Pwned
+// Passwords list (https://haveibeenpwned.com/Passwords). Nothing is sent
+// over the network.
+//
+// The list has to be the version ordered by hash, SHA-1 or NTLM, one
+// HASH:COUNT line per password. Being sorted it is binary searched, so
+// the multi gigabyte file is never loaded into memory.
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hackaio/pk/pkg/errors"
+	"golang.org/x/crypto/md4"
+)
+
+// Rule is the strength.Failure rule of breached passwords.
+const Rule = "breached"
+
+// Kinds of hash a list can hold.
+const (
+	SHA1 = "sha1"
+	NTLM = "ntlm"
+)
+
+const (
+	// maxLine is longer than any HASH:COUNT line.
+	maxLine = 128
+
+	// scanSize is where the binary search switches to a linear scan.
+	scanSize = 4096
+)
+
+var (
+	ErrInvalidFile = errors.New("invalid pwned passwords file")
+	ErrBreached    = errors.New("password found in a data breach")
+)
+
+// Checker counts how often a password was seen in breaches.
+type Checker interface {
+	// Count returns 0 for passwords that were never seen.
+	Count(password string) (int, error)
+}
+
+var _ Checker = (*File)(nil)
+
+// File is a Checker over a local Pwned Passwords file.
+type File struct {
+	f    *os.File
+	size int64
+	kind string
+}
+
+// Open opens the list at path, the kind of hash is detected from its
+// first line.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	file := &File{f: f, size: info.Size()}
+
+	_, hash, _, err := file.lineAt(0)
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(ErrInvalidFile, errors.New(fmt.Sprintf("%v: %v", path, err)))
+	}
+
+	switch len(hash) {
+	case 2 * sha1.Size:
+		file.kind = SHA1
+	case 2 * md4.Size:
+		file.kind = NTLM
+	default:
+		_ = f.Close()
+		return nil, errors.Wrap(ErrInvalidFile, errors.New(fmt.Sprintf("%v: unknown hash %q", path, hash)))
+	}
+
+	return file, nil
+}
+
+// Kind is SHA1 or NTLM.
+func (f *File) Kind() string {
+	return f.kind
+}
+
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// Count implements Checker.
+func (f *File) Count(password string) (int, error) {
+	target := Hash(f.kind, password)
+
+	// lines starting before lo are lower than target, target if present
+	// starts before hi+maxLine
+	lo, hi := int64(0), f.size
+	for hi-lo > scanSize {
+		mid := lo + (hi-lo)/2
+		_, hash, _, err := f.lineAt(mid)
+		if err == io.EOF || err == nil && hash >= target {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		lo = mid
+	}
+
+	start, _, _, err := f.lineAt(lo)
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(io.NewSectionReader(f.f, start, hi-start+2*maxLine))
+	for scanner.Scan() {
+		hash, count, err := parseLine(scanner.Bytes())
+		if err != nil {
+			// the section may end in the middle of a line
+			break
+		}
+		if hash == target {
+			return count, nil
+		}
+		if hash > target {
+			break
+		}
+	}
+
+	return 0, nil
+}
+
+// lineAt parses the first line starting at or after pos.
+func (f *File) lineAt(pos int64) (start int64, hash string, count int, err error) {
+	start = pos
+	if pos > 0 {
+		// step back one byte to see whether pos starts a line
+		start = pos - 1
+	}
+
+	buf := make([]byte, 2*maxLine)
+	n, err := f.f.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return 0, "", 0, err
+	}
+	buf = buf[:n]
+
+	if pos > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return 0, "", 0, io.EOF
+		}
+		buf = buf[i+1:]
+		start += int64(i + 1)
+	}
+
+	if len(buf) == 0 {
+		return 0, "", 0, io.EOF
+	}
+
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[:i]
+	}
+
+	hash, count, err = parseLine(buf)
+	return start, hash, count, err
+}
+
+func parseLine(line []byte) (string, int, error) {
+	line = bytes.TrimSpace(line)
+	i := bytes.IndexByte(line, ':')
+	if i < 0 {
+		return "", 0, errors.Wrap(ErrInvalidFile, errors.New(fmt.Sprintf("line %q", line)))
+	}
+
+	count, err := strconv.Atoi(string(line[i+1:]))
+	if err != nil {
+		return "", 0, errors.Wrap(ErrInvalidFile, err)
+	}
+
+	return strings.ToUpper(string(line[:i])), count, nil
+}
+
+// Hash returns the upper case hex hash of password the way the lists
+// store it.
+func Hash(kind, password string) string {
+	var sum []byte
+	switch kind {
+	case NTLM:
+		h := md4.New()
+		for _, u := range utf16.Encode([]rune(password)) {
+			_, _ = h.Write([]byte{byte(u), byte(u >> 8)})
+		}
+		sum = h.Sum(nil)
+	default:
+		s := sha1.Sum([]byte(password))
+		sum = s[:]
+	}
+	return strings.ToUpper(hex.EncodeToString(sum))
+}
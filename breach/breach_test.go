@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package breach
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeList(t *testing.T, kind string, breached map[string]int) string {
+	var lines []string
+	for i := 0; i < 5000; i++ {
+		lines = append(lines, fmt.Sprintf("%v:%v", Hash(kind, fmt.Sprintf("filler-%v", i)), i+1))
+	}
+	for password, count := range breached {
+		lines = append(lines, fmt.Sprintf("%v:%v", Hash(kind, password), count))
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), kind+".txt")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCount(t *testing.T) {
+	if got := Hash(NTLM, "password"); got != "8846F7EAEE8FB117AD06BDD830B7586C" {
+		t.Fatalf("Hash(NTLM) = %v", got)
+	}
+
+	breached := map[string]int{"password": 9545824, "letmein": 285, "hunter2": 17}
+
+	for _, kind := range []string{SHA1, NTLM} {
+		t.Run(kind, func(t *testing.T) {
+			f, err := Open(writeList(t, kind, breached))
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer f.Close()
+
+			if f.Kind() != kind {
+				t.Errorf("Kind() = %v, want %v", f.Kind(), kind)
+			}
+
+			for password, want := range breached {
+				if got, err := f.Count(password); err != nil || got != want {
+					t.Errorf("Count(%q) = %v, %v, want %v", password, got, err, want)
+				}
+			}
+
+			for i := 0; i < 5000; i++ {
+				password := fmt.Sprintf("filler-%v", i)
+				if got, err := f.Count(password); err != nil || got != i+1 {
+					t.Errorf("Count(%q) = %v, %v, want %v", password, got, err, i+1)
+				}
+			}
+
+			if got, err := f.Count("x9#Lq2!vT7@m-orbit"); err != nil || got != 0 {
+				t.Errorf("Count() = %v, %v, want 0", got, err)
+			}
+		})
+	}
+}
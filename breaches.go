@@ -0,0 +1,186 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hackaio/pk/breach"
+	"github.com/hackaio/pk/strength"
+)
+
+var _ PasswordKeeper = (*breachMiddleware)(nil)
+
+type breachMiddleware struct {
+	PasswordKeeper
+	checker breach.Checker
+	reject  bool
+	logger  *log.Logger
+}
+
+//BreachMiddleware looks the passwords given to Add, AddAll, Update and
+//Import, and the one Rollback restores, up with checker. Breached
+//passwords are refused with a *strength.ValidationError when reject is
+//set, otherwise a warning is written to logger and the password is stored
+func BreachMiddleware(checker breach.Checker, reject bool, logger *log.Logger) Middleware {
+	return func(keeper PasswordKeeper) PasswordKeeper {
+		return &breachMiddleware{
+			PasswordKeeper: keeper,
+			checker:        checker,
+			reject:         reject,
+			logger:         logger,
+		}
+	}
+}
+
+func (b breachMiddleware) Add(ctx context.Context, token string, account Account) (err error) {
+	if err := b.check([]Account{account}); err != nil {
+		return err
+	}
+
+	return b.PasswordKeeper.Add(ctx, token, account)
+}
+
+func (b breachMiddleware) Update(ctx context.Context, token, name, username string, account Account) (acc Account, err error) {
+	if account.Password != "" {
+		if account.Name == "" {
+			account.Name = name
+		}
+		if account.UserName == "" {
+			account.UserName = username
+		}
+		if err := b.check([]Account{account}); err != nil {
+			return Account{}, err
+		}
+	}
+
+	return b.PasswordKeeper.Update(ctx, token, name, username, account)
+}
+
+//AddAll adds the accounts whose password was not breached, the error
+//lists the others. The accepted accounts stay added when the error is
+//returned
+func (b breachMiddleware) AddAll(ctx context.Context, token string, accounts []Account) (err error) {
+	var accepted []Account
+	var failures []strength.Failure
+
+	for _, account := range accounts {
+		f, err := b.failure(account)
+		if err != nil {
+			return err
+		}
+		if f != nil {
+			failures = append(failures, *f)
+			continue
+		}
+		accepted = append(accepted, account)
+	}
+
+	if len(accepted) > 0 || len(failures) == 0 {
+		if err = b.PasswordKeeper.AddAll(ctx, token, accepted); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		return &strength.ValidationError{Failures: failures}
+	}
+
+	return nil
+}
+
+//Rollback refuses to restore a version whose password was breached since
+//it was replaced
+func (b breachMiddleware) Rollback(ctx context.Context, token, name, username string, v int) (account Account, err error) {
+	restored, ok, err := versionAccount(ctx, b.PasswordKeeper, token, name, username, v)
+	if err != nil {
+		return Account{}, err
+	}
+	if ok {
+		if err := b.check([]Account{restored}); err != nil {
+			return Account{}, err
+		}
+	}
+
+	return b.PasswordKeeper.Rollback(ctx, token, name, username, v)
+}
+
+//Import leaves out the entries whose password was breached, they are
+//counted as rejected in the report
+func (b breachMiddleware) Import(ctx context.Context, token string, entries []Entry, conflict Conflict) (report ImportReport, err error) {
+	var accepted []Entry
+	var failures []strength.Failure
+
+	for _, e := range entries {
+		f, err := b.failure(e.Account)
+		if err != nil {
+			return report, err
+		}
+		if f != nil {
+			failures = append(failures, *f)
+			continue
+		}
+		accepted = append(accepted, e)
+	}
+
+	report, err = b.PasswordKeeper.Import(ctx, token, accepted, conflict)
+	report.Rejected += len(entries) - len(accepted)
+	report.Failures = append(report.Failures, failures...)
+
+	return report, err
+}
+
+func (b breachMiddleware) check(accounts []Account) error {
+	var failures []strength.Failure
+
+	for _, account := range accounts {
+		f, err := b.failure(account)
+		if err != nil {
+			return err
+		}
+		if f != nil {
+			failures = append(failures, *f)
+		}
+	}
+
+	if len(failures) > 0 {
+		return &strength.ValidationError{Failures: failures}
+	}
+
+	return nil
+}
+
+//failure looks the password of account up. A breached one is a failure
+//when reject is set, otherwise it is only logged
+func (b breachMiddleware) failure(account Account) (*strength.Failure, error) {
+	count, err := b.checker.Count(account.Password)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	ref := fmt.Sprintf("%v/%v", account.Name, account.UserName)
+	msg := fmt.Sprintf("password was seen %v times in data breaches", count)
+
+	if !b.reject {
+		b.logger.Printf("warning: %v: %v\n", ref, msg)
+		return nil, nil
+	}
+
+	return &strength.Failure{Account: ref, Rule: breach.Rule, Message: msg}, nil
+}
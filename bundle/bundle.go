@@ -0,0 +1,197 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package bundle reads and writes .pkx files, portable backups of a vault
+// encrypted with a passphrase.
+//
+// A bundle starts with a header
+//
+//	magic  "PKX" and the format, 1
+//	logN   scrypt cost, N = 1 << logN
+//	r, p   scrypt block size and parallelism, a byte each
+//	salt   16 random bytes
+//	nonce  24 random bytes
+//
+// followed by the gzipped json of a Bundle sealed with XChaCha20-Poly1305
+// under the key scrypt derives from the passphrase and salt. The header is
+// authenticated along with the data, so any change to the file is found.
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Ext is the extension of bundle files.
+const Ext = ".pkx"
+
+// Format is the version of the file layout and of Bundle written by Seal.
+const Format = 1
+
+const (
+	magic      = "PKX"
+	saltSize   = 16
+	headerSize = len(magic) + 4 + saltSize + chacha20poly1305.NonceSizeX
+
+	// scrypt needs 128 * r * N bytes, Open only accepts the r and p
+	// bundles are sealed with so a file can not ask for more
+	scryptR = 8
+	scryptP = 1
+
+	// the cost Open accepts, higher ones would let a file exhaust memory
+	minLogN = 10
+	maxLogN = 22
+)
+
+// logN is the scrypt cost of new bundles, 128 MiB of memory with r = 8.
+var logN byte = 17
+
+var (
+	ErrInvalidBundle     = errors.New("not a pk bundle")
+	ErrUnsupportedFormat = errors.New("unsupported bundle format")
+	ErrDecrypt           = errors.New("wrong passphrase or damaged bundle")
+	ErrEmptyPassphrase   = errors.New("empty passphrase")
+)
+
+// Bundle is what a .pkx file holds: every account with its history.
+type Bundle struct {
+	Format   int        `json:"format"`
+	Created  string     `json:"created"`
+	Accounts []pk.Entry `json:"accounts"`
+}
+
+// New returns a Bundle of entries created now.
+func New(entries []pk.Entry) Bundle {
+	return Bundle{
+		Format:   Format,
+		Created:  time.Now().UTC().Format(time.RFC3339),
+		Accounts: entries,
+	}
+}
+
+// Seal encrypts b with passphrase and writes it to w.
+func Seal(w io.Writer, passphrase []byte, b Bundle) error {
+	if len(passphrase) == 0 {
+		return ErrEmptyPassphrase
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	header[3], header[4], header[5], header[6] = Format, logN, scryptR, scryptP
+	if _, err := rand.Read(header[7:]); err != nil {
+		return err
+	}
+
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	if err := json.NewEncoder(zw).Encode(b); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(passphrase, header)
+	if err != nil {
+		return err
+	}
+
+	sealed := aead.Seal(header, nonce(header), plain.Bytes(), header)
+	wipe(plain.Bytes())
+
+	_, err = w.Write(sealed)
+	return err
+}
+
+// Open reads the bundle in r encrypted with passphrase.
+func Open(r io.Reader, passphrase []byte) (Bundle, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	if len(data) < headerSize || string(data[:len(magic)]) != magic {
+		return Bundle{}, ErrInvalidBundle
+	}
+	if data[3] != Format {
+		return Bundle{}, ErrUnsupportedFormat
+	}
+	if data[4] < minLogN || data[4] > maxLogN || data[5] != scryptR || data[6] != scryptP {
+		return Bundle{}, errors.Wrap(ErrInvalidBundle, errors.New("invalid key derivation parameters"))
+	}
+	if len(passphrase) == 0 {
+		return Bundle{}, ErrEmptyPassphrase
+	}
+
+	header := data[:headerSize]
+	aead, err := newAEAD(passphrase, header)
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	plain, err := aead.Open(nil, nonce(header), data[headerSize:], header)
+	if err != nil {
+		return Bundle{}, ErrDecrypt
+	}
+	defer wipe(plain)
+
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return Bundle{}, errors.Wrap(ErrInvalidBundle, err)
+	}
+
+	var b Bundle
+	if err := json.NewDecoder(zr).Decode(&b); err != nil {
+		return Bundle{}, errors.Wrap(ErrInvalidBundle, err)
+	}
+	if b.Format != Format {
+		return Bundle{}, ErrUnsupportedFormat
+	}
+
+	return b, nil
+}
+
+// newAEAD derives the key of the bundle whose header is given.
+func newAEAD(passphrase, header []byte) (cipher.AEAD, error) {
+	n := 1 << uint(header[4])
+	salt := header[7 : 7+saltSize]
+
+	key, err := scrypt.Key(passphrase, salt, n, int(header[5]), int(header[6]), chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(key)
+
+	return chacha20poly1305.NewX(key)
+}
+
+func nonce(header []byte) []byte {
+	return header[7+saltSize : headerSize]
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bundle
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+func init() {
+	// keeps the tests fast, the format does not depend on the cost
+	logN = minLogN
+}
+
+func TestSealOpen(t *testing.T) {
+	b := New([]pk.Entry{
+		{
+			Account: pk.Account{
+				Name:     "github",
+				UserName: "alice",
+				Password: "correct horse battery staple",
+				Tags:     []string{"work"},
+				Fields:   []pk.Field{{Name: "pin", Value: "1234", Secret: true}},
+				Created:  "2021-01-02T15:04:05Z",
+			},
+			History: []pk.Version{
+				{Version: 1, Account: pk.Account{Name: "github", UserName: "alice", Password: "hunter2"}, Replaced: "2021-02-01T00:00:00Z"},
+			},
+		},
+	})
+
+	var buf bytes.Buffer
+	if err := Seal(&buf, []byte("passphrase"), b); err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("hunter2")) || bytes.Contains(buf.Bytes(), []byte("github")) {
+		t.Fatalf("Seal() wrote the accounts in the clear")
+	}
+
+	got, err := Open(bytes.NewReader(buf.Bytes()), []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, b) {
+		t.Errorf("Open() = %+v, want %+v", got, b)
+	}
+
+	if _, err := Open(bytes.NewReader(buf.Bytes()), []byte("wrong")); !errors.Contains(err, ErrDecrypt) {
+		t.Errorf("Open() with a wrong passphrase error = %v, want %v", err, ErrDecrypt)
+	}
+
+	// the header is authenticated too
+	for _, i := range []int{4, 10, headerSize + 5, buf.Len() - 1} {
+		damaged := append([]byte{}, buf.Bytes()...)
+		damaged[i] ^= 1
+		if _, err := Open(bytes.NewReader(damaged), []byte("passphrase")); !errors.Contains(err, ErrDecrypt) {
+			t.Errorf("Open() of a bundle changed at %d error = %v, want %v", i, err, ErrDecrypt)
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty", data: nil, err: ErrInvalidBundle},
+		{name: "csv", data: []byte("name,username,email,password\ngithub,alice,,secret\n"), err: ErrInvalidBundle},
+		{name: "future format", data: append([]byte("PKX\x02"), make([]byte, headerSize)...), err: ErrUnsupportedFormat},
+		{name: "huge cost", data: append([]byte("PKX\x01\x40\x08\x01"), make([]byte, headerSize)...), err: ErrInvalidBundle},
+		{name: "huge block size", data: append([]byte("PKX\x01\x16\xff\x01"), make([]byte, headerSize)...), err: ErrInvalidBundle},
+		{name: "huge parallelism", data: append([]byte("PKX\x01\x16\x08\xff"), make([]byte, headerSize)...), err: ErrInvalidBundle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(bytes.NewReader(tt.data), []byte("passphrase")); !errors.Contains(err, tt.err) {
+				t.Errorf("Open() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if err := Seal(&bytes.Buffer{}, nil, New(nil)); err != ErrEmptyPassphrase {
+		t.Errorf("Seal() without a passphrase error = %v, want %v", err, ErrEmptyPassphrase)
+	}
+}
@@ -13,22 +13,70 @@
 package cli
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"image"
 	"github.com/hackaio/pk/cli/commands"
+	"github.com/hackaio/pk/cli/csv"
+	"github.com/hackaio/pk/cli/docker"
+	"github.com/hackaio/pk/cli/git"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/agent"
+	"github.com/hackaio/pk/api"
+	"github.com/hackaio/pk/audit"
+	"github.com/hackaio/pk/breach"
+	"github.com/hackaio/pk/bundle"
+	"github.com/hackaio/pk/generator"
+	"github.com/hackaio/pk/importer"
+	"github.com/hackaio/pk/kdbx"
+	"github.com/hackaio/pk/otp"
+	pkgrpc "github.com/hackaio/pk/api/grpc"
 	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/pkg/files"
+	"github.com/hackaio/pk/qr"
+	"github.com/hackaio/pk/resolver"
+	"github.com/hackaio/pk/tui"
+	"github.com/mattn/go-isatty"
+	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/zalando/go-keyring"
 	"golang.org/x/crypto/ssh/terminal"
+	"google.golang.org/grpc"
 )
 
 const (
 	minPasswordLen = 6
+
+	//passphraseEnv holds the passphrase of bundles for scripts
+	passphraseEnv = "PK_PASSPHRASE"
+
+	//kdbxPasswordEnv holds the password of KeePass databases for scripts
+	kdbxPasswordEnv = "PK_KDBX_PASSWORD"
+
+	//auditExitCode is returned by pk audit --fail when issues are found,
+	//it differs from 1 so CI can tell findings from errors
+	auditExitCode = 3
+
+	//defaultHistoryKeep is how many versions of a password are kept
+	//unless history.keep says otherwise
+	defaultHistoryKeep = 10
 )
 
 var (
@@ -42,6 +90,7 @@ type commander struct {
 	csvWriter  pk.Writer
 	jsonReader pk.Reader
 	jsonWriter pk.Writer
+	wipe       func()
 }
 
 var _ commands.Runner = (*commander)(nil)
@@ -70,6 +119,24 @@ type Commands struct {
 	Update   *cobra.Command
 	DB       *cobra.Command
 	List     *cobra.Command
+	Serve    *cobra.Command
+	Agent    *cobra.Command
+	Lock     *cobra.Command
+	Git      *cobra.Command
+	Docker   *cobra.Command
+	Exec     *cobra.Command
+	Inject   *cobra.Command
+	Generate *cobra.Command
+	Audit    *cobra.Command
+	History  *cobra.Command
+	Rollback *cobra.Command
+	Trash    *cobra.Command
+	Search   *cobra.Command
+	TUI      *cobra.Command
+	OTP      *cobra.Command
+	TwoFA    *cobra.Command
+	Export   *cobra.Command
+	Import   *cobra.Command
 }
 
 func MakeAllCommands(comm commands.Runner) Commands {
@@ -83,6 +150,24 @@ func MakeAllCommands(comm commands.Runner) Commands {
 		Update:   makeUpdateCommand(comm),
 		DB:       makeDBCommand(comm),
 		List:     makeListCommand(comm),
+		Serve:    makeServeCommand(comm),
+		Agent:    makeAgentCommand(comm),
+		Lock:     makeLockCommand(comm),
+		Git:      makeGitCredentialCommand(comm),
+		Docker:   makeDockerCredentialCommand(comm),
+		Exec:     makeExecCommand(comm),
+		Inject:   makeInjectCommand(comm),
+		Generate: makeGenerateCommand(comm),
+		Audit:    makeAuditCommand(comm),
+		History:  makeHistoryCommand(comm),
+		Rollback: makeRollbackCommand(comm),
+		Trash:    makeTrashCommand(comm),
+		Search:   makeSearchCommand(comm),
+		TUI:      makeTUICommand(comm),
+		OTP:      makeOTPCommand(comm),
+		TwoFA:    makeTwoFactorCommand(comm),
+		Export:   makeExportCommand(comm),
+		Import:   makeImportCommand(comm),
 	}
 }
 
@@ -104,13 +189,13 @@ func (comm *commander) fetchTokenFunc() commands.RunFunc {
 		if err != nil || password == "" {
 
 			if err == keyring.ErrNotFound {
-				fmt.Println("Enter password: ")
+				fmt.Fprintln(os.Stderr, "Enter password: ")
 				passwordBytes, err := terminal.ReadPassword(0)
 				if err != nil {
 					logError(err)
 					os.Exit(1)
 				}
-				fmt.Println("Enter password again: ")
+				fmt.Fprintln(os.Stderr, "Enter password again: ")
 
 				passwordBytes1, err := terminal.ReadPassword(0)
 
@@ -125,12 +210,6 @@ func (comm *commander) fetchTokenFunc() commands.RunFunc {
 					os.Exit(1)
 				}
 
-				if string(passwordBytes) == "" || len(string(passwordBytes)) < minPasswordLen {
-					err1 := errors.New("password length should be >= 6 chars")
-					logError(err1)
-					os.Exit(1)
-				}
-
 				password = string(passwordBytes)
 				//fixme
 				_ = comm.secrets.Set(pk.AppName, username, password)
@@ -161,13 +240,13 @@ func (comm *commander) runInitCommand() commands.RunFunc {
 			os.Exit(1)
 		}
 
-		fmt.Println("Enter password: ")
+		fmt.Fprintln(os.Stderr, "Enter password: ")
 		password, err := terminal.ReadPassword(0)
 		if err != nil {
 			logError(err)
 			os.Exit(1)
 		}
-		fmt.Println("Enter password again: ")
+		fmt.Fprintln(os.Stderr, "Enter password again: ")
 
 		password1, err := terminal.ReadPassword(0)
 
@@ -182,9 +261,23 @@ func (comm *commander) runInitCommand() commands.RunFunc {
 			os.Exit(1)
 		}
 
-		if string(password) == "" || len(string(password)) < minPasswordLen {
-			err1 := errors.New("password length should be >= 6 chars")
-			logError(err1)
+		//the master password is checked against the same policy as the
+		//account ones before anything is stored
+		policy, err := loadPolicy()
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if err = policy.Validate(string(password), username, email); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		err = comm.keeper.Register(context.Background(), username, email, string(password))
+
+		if err != nil {
+			logError(err)
 			os.Exit(1)
 		}
 
@@ -196,10 +289,17 @@ func (comm *commander) runInitCommand() commands.RunFunc {
 			os.Exit(1)
 		}
 
-		err = comm.keeper.Register(context.Background(), username, email, string(password))
+		if twoFactor, _ := cmd.Flags().GetBool("2fa"); twoFactor {
+			token, err := comm.keeper.Login(context.Background(), username, string(password), "")
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
 
-		if err != nil {
-			logError(err)
+			if err = comm.enrollTwoFactor(token, username); err != nil {
+				logError(err)
+				os.Exit(1)
+			}
 			return
 		}
 
@@ -226,13 +326,13 @@ func (comm *commander) runLoginCommand() commands.RunFunc {
 		if err != nil || password == "" {
 
 			if err == keyring.ErrNotFound {
-				fmt.Println("Enter password: ")
+				fmt.Fprintln(os.Stderr, "Enter password: ")
 				passwordBytes, err := terminal.ReadPassword(0)
 				if err != nil {
 					logError(err)
 					os.Exit(1)
 				}
-				fmt.Println("Enter password again: ")
+				fmt.Fprintln(os.Stderr, "Enter password again: ")
 
 				passwordBytes1, err := terminal.ReadPassword(0)
 
@@ -247,23 +347,24 @@ func (comm *commander) runLoginCommand() commands.RunFunc {
 					os.Exit(1)
 				}
 
-				if string(passwordBytes) == "" || len(string(passwordBytes)) < minPasswordLen {
-					err1 := errors.New("password length should be >= 6 chars")
-					logError(err1)
-					os.Exit(1)
-				}
-
 				password = string(passwordBytes)
 				//fixme
 				_ = comm.secrets.Set(pk.AppName, username, password)
-				return
 			} else {
 				logError(err)
 				os.Exit(1)
 			}
 		}
 
-		token, err := comm.keeper.Login(context.Background(), username, password)
+		code, _ := cmd.Flags().GetString("code")
+
+		token, err := comm.keeper.Login(context.Background(), username, password, code)
+		if errors.Contains(err, pk.ErrCodeRequired) && code == "" {
+			code, err = readCode("Enter the code of your authenticator app or a recovery code: ")
+			if err == nil {
+				token, err = comm.keeper.Login(context.Background(), username, password, code)
+			}
+		}
 		if err != nil {
 			logError(err)
 			os.Exit(1)
@@ -288,13 +389,13 @@ func (comm *commander) runAddCommand() commands.RunFunc {
 		username, err := cmd.Flags().GetString("username")
 		fileName, err := cmd.Flags().GetString("file")
 		email, err := cmd.Flags().GetString("email")
-		password, err := cmd.Flags().GetString("password")
+		generate, err := cmd.Flags().GetBool("generate")
 		name, err := cmd.Flags().GetString("name")
 		token, err := comm.secrets.Get(pk.AppName, "token")
 
 		if err != nil {
 			logError(err)
-			return
+			os.Exit(1)
 		}
 
 		ctx := context.Background()
@@ -328,7 +429,39 @@ func (comm *commander) runAddCommand() commands.RunFunc {
 
 			} else if ext == ".csv" {
 
-				accounts, err = comm.csvReader.Read(ctx, fileName)
+				reader, err := csvReader(cmd, comm.csvReader)
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+
+				accounts, err = reader.Read(ctx, fileName)
+
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+
+				err = comm.keeper.AddAll(ctx, token, accounts)
+
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+
+				logOK()
+				return
+
+			} else if ext == kdbx.Ext {
+
+				keyFile, _ := cmd.Flags().GetString("key-file")
+				key, err := kdbxKey(keyFile, false)
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+
+				accounts, err = kdbx.NewReader(key).Read(ctx, fileName)
 
 				if err != nil {
 					logError(err)
@@ -346,16 +479,37 @@ func (comm *commander) runAddCommand() commands.RunFunc {
 				return
 
 			} else {
-				err1 := errors.New("parse json or csv files only")
+				err1 := errors.New("parse json, csv or kdbx files only")
 				logError(err1)
 				os.Exit(1)
 			}
 
-		} else if username == "" || email == "" || password == "" ||
-			name == "" || token == "" {
+		} else if username == "" || email == "" || name == "" || token == "" {
 			logUsage(cmd.Example)
 			os.Exit(1)
 		} else {
+			var password string
+
+			if generate {
+				password, err = generateSecret(cmd)
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+			} else {
+				fmt.Fprintln(os.Stderr, "Enter account password: ")
+				passwordBytes, err := terminal.ReadPassword(0)
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+				password = string(passwordBytes)
+			}
+
+			if password == "" {
+				logError(errors.New("password is empty"))
+				os.Exit(1)
+			}
 
 			account := pk.Account{
 				Name:     name,
@@ -364,11 +518,16 @@ func (comm *commander) runAddCommand() commands.RunFunc {
 				Password: password,
 			}
 
+			if _, err = readDetails(cmd, &account); err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+
 			err = comm.keeper.Add(context.Background(), token, account)
 
 			if err != nil {
 				logError(err)
-				return
+				os.Exit(1)
 			}
 
 			logOK()
@@ -398,11 +557,10 @@ func (comm *commander) runGetCommand() commands.RunFunc {
 
 		if err != nil {
 			logError(err)
-
+			os.Exit(1)
 		}
 
-		logMessage("username", response.Email)
-		logMessage("password", response.Password)
+		logAccount(response)
 
 	}
 
@@ -410,14 +568,34 @@ func (comm *commander) runGetCommand() commands.RunFunc {
 
 func (comm *commander) runDeleteCommand() commands.RunFunc {
 	return func(cmd *cobra.Command, args []string) {
-		logMessage("error", debugMessage)
+		username, err := cmd.Flags().GetString("username")
+		name, err := cmd.Flags().GetString("name")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if username == "" || name == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		err = comm.keeper.Delete(context.Background(), token, name, username)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logOK()
 	}
 }
 
 func (comm *commander) runListCommand() commands.RunFunc {
 
 	return func(cmd *cobra.Command, args []string) {
-		limit, err := cmd.Flags().GetInt("limit")
 		token, err := comm.secrets.Get(pk.AppName, "token")
 
 		if err != nil {
@@ -425,17 +603,28 @@ func (comm *commander) runListCommand() commands.RunFunc {
 			os.Exit(1)
 		}
 
-		var argx map[string]interface{}
-		accounts, err := comm.keeper.List(context.Background(), token, argx)
+		query, err := readQuery(cmd)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		accounts, next, err := comm.keeper.List(context.Background(), token, query)
 
 		if err != nil {
 			logError(err)
 			os.Exit(1)
 		}
 
+		if accounts == nil {
+			accounts = []pk.Account{}
+		}
+
 		out, err := cmd.Flags().GetString("out")
 		format, err := cmd.Flags().GetString("format")
 		dir, err := cmd.Flags().GetString("dir")
+		force, err := cmd.Flags().GetBool("force")
 
 		/*	fileFormat, err := getUploadFormat(format)
 
@@ -444,12 +633,16 @@ func (comm *commander) runListCommand() commands.RunFunc {
 				os.Exit(1)
 			}*/
 
-		if limit == 0 {
-			limit = len(accounts)
-		}
-
 		if out == "" && format == "" && dir == "" {
-			logJSON(accounts[:limit])
+			switch {
+			case query.PageSize > 0 && (outputFormat == outputJSON || outputFormat == outputYAML):
+				logResult(listPage{Accounts: accounts, Next: next})
+			default:
+				logJSON(accounts)
+				if next != "" {
+					fmt.Fprintf(os.Stderr, "next page: --cursor %v\n", next)
+				}
+			}
 		} else {
 			if out == "" {
 				out = "accounts"
@@ -459,7 +652,7 @@ func (comm *commander) runListCommand() commands.RunFunc {
 				format = "json"
 			}
 
-			if !(format == "json" || format == "csv") {
+			if !(format == "json" || format == "csv" || format == "kdbx") {
 				errFormat := errors.New("invalid file format")
 				logError(errFormat)
 				os.Exit(1)
@@ -475,11 +668,21 @@ func (comm *commander) runListCommand() commands.RunFunc {
 				dir = path
 			}
 
+			if format != "kdbx" {
+				fmt.Fprintln(os.Stderr, "warning: passwords are written in clear text, pk export --encrypted writes an encrypted backup")
+			}
+
 			req := pk.FileWriterReq{
-				Accounts: accounts[:limit],
+				Accounts: accounts,
 				FileName: out,
 				FileExt:  format,
 				FileDir:  dir,
+				Force:    force,
+			}
+
+			//-o - streams to stdout
+			if out == "-" {
+				req.Out = os.Stdout
 			}
 
 			if format == "csv" {
@@ -496,110 +699,1790 @@ func (comm *commander) runListCommand() commands.RunFunc {
 					logError(err)
 					os.Exit(1)
 				}
+			} else if format == "kdbx" {
+
+				keyFile, _ := cmd.Flags().GetString("key-file")
+				key, err := kdbxKey(keyFile, true)
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+
+				err = kdbx.NewWriter(key).Write(context.Background(), req)
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+			}
+
+			if req.Out == nil {
+				logMessage("written", req.Path())
 			}
 		}
 
 	}
 }
 
+//runUpdateCommand changes the fields that were given, a new password is
+//either prompted for or generated
 func (comm *commander) runUpdateCommand() commands.RunFunc {
 	return func(cmd *cobra.Command, args []string) {
-		logMessage("error", debugMessage)
-	}
-}
-
-func (comm *commander) runDBCommand() commands.RunFunc {
-	return func(cmd *cobra.Command, args []string) {
-		logMessage("error", debugMessage)
-	}
-}
-
-func (comm *commander) Run(command commands.Command) commands.RunFunc {
-
-	switch command {
+		username, err := cmd.Flags().GetString("username")
+		name, err := cmd.Flags().GetString("name")
+		email, err := cmd.Flags().GetString("email")
+		newName, err := cmd.Flags().GetString("new-name")
+		newUsername, err := cmd.Flags().GetString("new-username")
+		prompt, err := cmd.Flags().GetBool("password")
+		generate, err := cmd.Flags().GetBool("generate")
+		token, err := comm.secrets.Get(pk.AppName, "token")
 
-	case commands.Init:
-		return comm.runInitCommand()
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
 
-	case commands.Login:
-		return comm.runLoginCommand()
+		if username == "" || name == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
 
-	case commands.Add:
-		return comm.runAddCommand()
+		account := pk.Account{
+			Name:     newName,
+			UserName: newUsername,
+			Email:    email,
+		}
 
-	case commands.Get:
-		return comm.runGetCommand()
+		switch {
+		case generate:
+			account.Password, err = generateSecret(cmd)
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
 
-	case commands.Delete:
-		return comm.runDeleteCommand()
+		case prompt:
+			fmt.Fprintln(os.Stderr, "Enter new account password: ")
+			passwordBytes, err := terminal.ReadPassword(0)
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+			if len(passwordBytes) == 0 {
+				logError(errors.New("password is empty"))
+				os.Exit(1)
+			}
+			account.Password = string(passwordBytes)
+		}
 
-	case commands.List:
-		return comm.runListCommand()
+		changed, err := readDetails(cmd, &account)
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
 
-	case commands.Update:
-		return comm.runUpdateCommand()
+		if !changed && account.Name == "" && account.UserName == "" && account.Email == "" && account.Password == "" {
+			logError(errors.New("nothing to update"))
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
 
-	case commands.DB:
-		return comm.runDBCommand()
+		_, err = comm.keeper.Update(context.Background(), token, name, username, account)
 
-	default:
-		return func(cmd *cobra.Command, args []string) {
-			logUsage("this should not happen")
+		if err != nil {
+			logError(err)
+			os.Exit(1)
 		}
+
+		logOK()
 	}
 }
 
-func makeInitCommand(comm commands.Runner) *cobra.Command {
-	var initCmd = &cobra.Command{
-		Use:     "init",
-		Short:   "set up pk",
-		Long:    `init should be run firstly before anything after installation`,
-		Example: "pk init -u <username> -e <email>",
-		Run:     comm.Run(commands.Init),
-	}
+//runHistoryCommand lists the previous passwords of an account, they are
+//only shown with --show
+func (comm *commander) runHistoryCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		username, err := cmd.Flags().GetString("username")
+		name, err := cmd.Flags().GetString("name")
+		show, err := cmd.Flags().GetBool("show")
+		token, err := comm.secrets.Get(pk.AppName, "token")
 
-	initCmd.Flags().BoolP("credstore", "c", true, "store secrets")
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
 
-	return initCmd
-}
+		if username == "" || name == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
 
-func makeRegisterCommand(comm commands.Runner) *cobra.Command {
-	var regCmd = &cobra.Command{
-		Use:     "register",
-		Short:   "set up pk",
-		Long:    `init should be run firstly before anything after installation`,
-		Example: "pk init -u <username> -e <email>",
-		Run:     comm.Run(commands.Register),
-	}
+		versions, err := comm.keeper.History(context.Background(), token, name, username)
 
-	return regCmd
-}
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
 
-func makeLoginCommand(comm commands.Runner) *cobra.Command {
-	// loginCmd represents the login command
-	var loginCmd = &cobra.Command{
-		Use:     "login",
-		Short:   "generate auth token",
-		Example: "pk login -u <username>",
-		Long:    `generates a jwt token string after the user has supplied username along side master password`,
-		Run:     comm.Run(commands.Login),
+		logHistory(versions, show)
 	}
-
-	return loginCmd
-
 }
 
-func makeAddCommand(comm commands.Runner) *cobra.Command {
+func (comm *commander) runRollbackCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		username, err := cmd.Flags().GetString("username")
+		name, err := cmd.Flags().GetString("name")
+		version, err := cmd.Flags().GetInt("version")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if username == "" || name == "" || version <= 0 || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		_, err = comm.keeper.Rollback(context.Background(), token, name, username, version)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logOK()
+	}
+}
+
+func (comm *commander) runTrashListCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		accounts, err := comm.keeper.Trash(context.Background(), token)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if accounts == nil {
+			accounts = []pk.Trashed{}
+		}
+
+		logResult(accounts)
+	}
+}
+
+func (comm *commander) runTrashRestoreCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		username, err := cmd.Flags().GetString("username")
+		name, err := cmd.Flags().GetString("name")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if username == "" || name == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		err = comm.keeper.Restore(context.Background(), token, name, username)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logOK()
+	}
+}
+
+//runTrashPurgeCommand removes the accounts deleted more than --older-than
+//ago for good
+func (comm *commander) runTrashPurgeCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		olderThan, err := cmd.Flags().GetString("older-than")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if olderThan == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		age, err := parseAge(olderThan)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		purged, err := comm.keeper.Purge(context.Background(), token, age)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logMessage("purged", strconv.Itoa(purged))
+	}
+}
+
+//runSearchCommand fuzzy finds accounts and shows the one picked from the
+//matches. Without a terminal, or with --output json or yaml, the matches
+//are printed instead unless --first is given
+func (comm *commander) runSearchCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		limit, err := cmd.Flags().GetInt("limit")
+		first, err := cmd.Flags().GetBool("first")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		text := strings.Join(args, " ")
+		if strings.TrimSpace(text) == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		matches, err := comm.keeper.Search(ctx, token, text, limit)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if len(matches) == 0 {
+			logError(errors.Wrap(pk.ErrNotFound, errors.New(fmt.Sprintf("nothing matches %q", text))))
+			os.Exit(1)
+		}
+
+		interactive := outputFormat == outputPlain && isatty.IsTerminal(os.Stdin.Fd())
+
+		var match pk.Match
+		switch {
+		case first || len(matches) == 1:
+			match = matches[0]
+		case !interactive:
+			logResult(matches)
+			return
+		default:
+			match, err = pickMatch(os.Stdin, os.Stderr, matches)
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+		}
+
+		account, err := comm.keeper.Get(ctx, token, match.Name, match.UserName)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logAccount(account)
+	}
+}
+
+//runTUICommand opens the full screen interface with the saved token, it
+//asks for the master password when there is none or it has expired
+func (comm *commander) runTUICommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		idle, err := cmd.Flags().GetDuration("lock-after")
+		username, err := cmd.Flags().GetString("username")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) {
+			logError(errors.New("pk tui needs a terminal"))
+			os.Exit(1)
+		}
+
+		//a missing token only means the screen starts locked
+		token, _ := comm.secrets.Get(pk.AppName, "token")
+
+		//keeper logs would draw over the screen
+		keeperLog.set(ioutil.Discard)
+		defer keeperLog.set(os.Stderr)
+
+		app := tui.New(comm.keeper, token, tui.Options{Idle: idle, UserName: username})
+
+		if err := app.Run(os.Stdin, os.Stdout); err != nil {
+			keeperLog.set(os.Stderr)
+			logError(err)
+			os.Exit(1)
+		}
+	}
+}
+
+//runTwoFactorEnableCommand sets up a second factor for the master account
+//of the saved token
+func (comm *commander) runTwoFactorEnableCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		username, err := cmd.Flags().GetString("username")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if username == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		if err = comm.enrollTwoFactor(token, username); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func (comm *commander) runTwoFactorDisableCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		code, err := cmd.Flags().GetString("code")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if code == "" {
+			code, err = readCode("Enter the code of your authenticator app or a recovery code: ")
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+		}
+
+		err = comm.keeper.DisableTwoFactor(context.Background(), token, code)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logOK()
+	}
+}
+
+//enrollTwoFactor shows a new seed to add to an authenticator app, turns
+//two factor authentication on with a code of the app and prints the
+//recovery codes
+func (comm *commander) enrollTwoFactor(token, username string) error {
+	secret, err := otp.NewSecret(20)
+	if err != nil {
+		return err
+	}
+
+	key, err := otp.NewKey(secret)
+	if err != nil {
+		return err
+	}
+	key.Issuer, key.Account = pk.AppName, username
+
+	//groups of four are easier to type into an app
+	var grouped []string
+	for i := 0; i < len(secret); i += 4 {
+		end := i + 4
+		if end > len(secret) {
+			end = len(secret)
+		}
+		grouped = append(grouped, secret[i:end])
+	}
+
+	fmt.Fprintf(os.Stderr, "\nadd this key to your authenticator app:\n\n  %v\n\nor open this uri where the app runs:\n\n  %v\n\n",
+		strings.Join(grouped, " "), key.URI())
+
+	code, err := readCode("Enter the code the app shows: ")
+	if err != nil {
+		return err
+	}
+
+	recovery, err := comm.keeper.EnableTwoFactor(context.Background(), token, secret, code)
+	if err != nil {
+		return err
+	}
+
+	logRecoveryCodes(recovery)
+	return nil
+}
+
+//readCode asks for a two factor code on the terminal
+func readCode(prompt string) (string, error) {
+	fmt.Fprintln(os.Stderr, prompt)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrap(errors.New("could not read the code"), err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+//runExportCommand writes every account and its history to a bundle
+//encrypted with a passphrase
+func (comm *commander) runExportCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		path, err := cmd.Flags().GetString("encrypted")
+		force, err := cmd.Flags().GetBool("force")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if path == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		if filepath.Ext(path) == "" {
+			path += bundle.Ext
+		}
+
+		entries, err := comm.keeper.Export(context.Background(), token)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		passphrase, err := readPassphrase(true)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		err = files.WriteFile(path, files.SecretPerm, force, func(w io.Writer) error {
+			return bundle.Seal(w, passphrase, bundle.New(entries))
+		})
+		wipeBytes(passphrase)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logMessage("exported", fmt.Sprintf("%d accounts to %v", len(entries), path))
+	}
+}
+
+//runImportCommand restores the accounts of a bundle written by pk export
+func (comm *commander) runImportCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		conflict, err := cmd.Flags().GetString("on-conflict")
+		from, err := cmd.Flags().GetString("from")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if len(args) != 1 || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		f, err := os.Open(args[0])
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		if from != "" {
+			comm.importFrom(from, f, token, pk.Conflict(conflict))
+			return
+		}
+
+		passphrase, err := readPassphrase(false)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		b, err := bundle.Open(f, passphrase)
+		wipeBytes(passphrase)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		report, err := comm.keeper.Import(context.Background(), token, b.Accounts, pk.Conflict(conflict))
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logResult(report)
+	}
+}
+
+//importFrom imports the export of another password manager and prints
+//what became of its records
+func (comm *commander) importFrom(from string, r io.Reader, token string, conflict pk.Conflict) {
+	imp, err := importer.New(from)
+
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
+	res, err := imp.Parse(r)
+
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
+	entries := make([]pk.Entry, len(res.Accounts))
+	for i, account := range res.Accounts {
+		entries[i] = pk.Entry{Account: account}
+	}
+
+	report, err := comm.keeper.Import(context.Background(), token, entries, conflict)
+
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
+	logResult(res.Summary(report))
+}
+
+//readPassphrase returns the passphrase of a bundle from PK_PASSPHRASE or
+//the terminal, a new one is asked for twice
+func readPassphrase(confirm bool) ([]byte, error) {
+	if env := os.Getenv(passphraseEnv); env != "" {
+		return []byte(env), nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Enter bundle passphrase: ")
+	passphrase, err := terminal.ReadPassword(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) < minPasswordLen {
+		return nil, errors.New(fmt.Sprintf("passphrase length should be >= %d chars", minPasswordLen))
+	}
+
+	if !confirm {
+		return passphrase, nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Enter bundle passphrase again: ")
+	again, err := terminal.ReadPassword(0)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(again)
+
+	if !bytes.Equal(passphrase, again) {
+		wipeBytes(passphrase)
+		return nil, errors.New("passphrase mismatch")
+	}
+
+	return passphrase, nil
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+//otpCode is the output of pk otp, Remaining is in seconds and only set
+//for TOTP
+type otpCode struct {
+	Code      string `json:"code" yaml:"code"`
+	Remaining int    `json:"remaining,omitempty" yaml:"remaining,omitempty"`
+}
+
+//runOTPCommand prints the current two factor code of an account. HOTP
+//codes are used once, so the stored counter moves on
+func (comm *commander) runOTPCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		username, err := cmd.Flags().GetString("username")
+		name, err := cmd.Flags().GetString("name")
+		token, err := comm.secrets.Get(pk.AppName, "token")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if username == "" || name == "" || token == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		account, err := comm.keeper.Get(ctx, token, name, username)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if account.OTP == "" {
+			logError(errors.New(fmt.Sprintf("%v / %v has no two factor seed, add one with pk update --otp", name, username)))
+			os.Exit(1)
+		}
+
+		key, err := otp.Parse(account.OTP)
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		var code otpCode
+		if key.Type == otp.TypeHOTP {
+			code.Code = key.Code(key.Counter)
+			key.Counter++
+
+			_, err = comm.keeper.Update(ctx, token, name, username, pk.Account{OTP: key.URI()})
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+		} else {
+			var remaining time.Duration
+			code.Code, remaining = key.At(time.Now())
+			code.Remaining = int(remaining.Round(time.Second) / time.Second)
+		}
+
+		if outputFormat != outputPlain {
+			logResult(code)
+			return
+		}
+
+		if code.Remaining > 0 {
+			fmt.Printf("%v (%ds left)\n", code.Code, code.Remaining)
+			return
+		}
+		fmt.Println(code.Code)
+	}
+}
+
+func (comm *commander) runDBCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		logError(errors.New(debugMessage))
+		os.Exit(1)
+	}
+}
+
+func (comm *commander) runServeCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		addr, err := cmd.Flags().GetString("addr")
+		grpcAddr, err := cmd.Flags().GetString("grpc-addr")
+		certFile, err := cmd.Flags().GetString("cert")
+		keyFile, err := cmd.Flags().GetString("key")
+		caFile, err := cmd.Flags().GetString("ca")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		useTLS := certFile != "" && keyFile != ""
+		insecure := viper.GetBool("insecure")
+		for _, a := range []string{addr, grpcAddr} {
+			if a != "" && !useTLS && !insecure && !isLoopback(a) {
+				logError(errors.Wrap(pk.ErrInvalidArgs, errors.New(fmt.Sprintf("refusing to serve without tls on %v, pass --cert and --key, listen on a loopback address or pass --insecure", a))))
+				os.Exit(1)
+			}
+		}
+
+		if grpcAddr != "" {
+			var opts []grpc.ServerOption
+
+			if useTLS {
+				creds, err := pkgrpc.ServerCredentials(certFile, keyFile, caFile)
+				if err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+				opts = append(opts, grpc.Creds(creds))
+			}
+
+			listener, err := net.Listen("tcp", grpcAddr)
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+
+			server := grpc.NewServer(opts...)
+			pkgrpc.RegisterPasswordKeeperServer(server, pkgrpc.NewServer(comm.keeper))
+
+			go func() {
+				if err := server.Serve(listener); err != nil {
+					logError(err)
+					os.Exit(1)
+				}
+			}()
+
+			logMessage("grpc listening", grpcAddr)
+		}
+
+		logMessage("listening", addr)
+
+		if useTLS {
+			err = http.ListenAndServeTLS(addr, certFile, keyFile, api.MakeHandler(comm.keeper))
+		} else {
+			err = http.ListenAndServe(addr, api.MakeHandler(comm.keeper))
+		}
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func (comm *commander) runAgentCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		timeout, err := cmd.Flags().GetDuration("timeout")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		socket := agentSocket()
+
+		a := agent.New(comm.keeper, timeout, comm.wipe)
+
+		logMessage("agent listening", socket)
+
+		if err := a.Serve(socket); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logMessage("agent", "locked")
+	}
+}
+
+func (comm *commander) runLockCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		if err := agent.Lock(agentSocket()); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logOK()
+	}
+}
+
+//runGitCredentialCommand implements the git credential helper protocol.
+//Nothing but credentials may be written to stdout as git reads it.
+func (comm *commander) runGitCredentialCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		cred, err := git.Read(os.Stdin)
+		if err != nil {
+			gitError(err)
+		}
+
+		name, err := cred.AccountName()
+		if err != nil {
+			gitError(err)
+		}
+
+		token, err := comm.secrets.Get(pk.AppName, "token")
+		if err != nil {
+			gitError(errors.Wrap(errors.New("could not read token, run pk login"), err))
+		}
+
+		ctx := context.Background()
+
+		switch args[0] {
+		case git.Get:
+			account, err := comm.gitAccount(ctx, token, name, cred.Username)
+			if errors.Contains(err, pk.ErrNotFound) {
+				//let git fall back to the next helper or prompt
+				return
+			}
+			if err != nil {
+				gitError(err)
+			}
+
+			cred.Username = account.UserName
+			cred.Password = account.Password
+			if err := git.Write(os.Stdout, cred); err != nil {
+				gitError(err)
+			}
+
+		case git.Store:
+			if cred.Username == "" || cred.Password == "" {
+				return
+			}
+
+			existing, err := comm.keeper.Get(ctx, token, name, cred.Username)
+			if err == nil && existing.Password == cred.Password {
+				return
+			}
+			if err == nil {
+				//the old password goes to the history of the account
+				_, err = comm.keeper.Update(ctx, token, name, cred.Username, pk.Account{Password: cred.Password})
+				if err != nil {
+					gitError(err)
+				}
+				return
+			}
+			if !errors.Contains(err, pk.ErrNotFound) {
+				gitError(err)
+			}
+
+			account := pk.Account{
+				Name:     name,
+				UserName: cred.Username,
+				Password: cred.Password,
+				Created:  time.Now().UTC().Format(time.RFC3339),
+			}
+			if err := comm.keeper.Add(ctx, token, account); err != nil {
+				gitError(err)
+			}
+
+		case git.Erase:
+			if cred.Username == "" {
+				return
+			}
+
+			err := comm.keeper.Delete(ctx, token, name, cred.Username)
+			if err != nil && !errors.Contains(err, pk.ErrNotFound) {
+				gitError(err)
+			}
+		}
+	}
+}
+
+//gitAccount returns the account stored for name. When git does not know
+//the username yet the first account stored under name is used.
+func (comm *commander) gitAccount(ctx context.Context, token, name, username string) (pk.Account, error) {
+	if username != "" {
+		return comm.keeper.Get(ctx, token, name, username)
+	}
+
+	accounts, err := comm.accountsNamed(ctx, token, name)
+	if err != nil {
+		return pk.Account{}, err
+	}
+	if len(accounts) == 0 {
+		return pk.Account{}, pk.ErrNotFound
+	}
+
+	return accounts[0], nil
+}
+
+//accountsNamed returns the accounts stored under exactly name. The store
+//only decrypts the accounts whose name holds name, a name with glob
+//characters is compared here instead.
+func (comm *commander) accountsNamed(ctx context.Context, token, name string) ([]pk.Account, error) {
+	query := pk.Query{Name: name}
+	if pk.IsGlob(name) {
+		query = pk.Query{}
+	}
+
+	accounts, _, err := comm.keeper.List(ctx, token, query)
+	if err != nil {
+		return nil, err
+	}
+
+	named := accounts[:0]
+	for _, account := range accounts {
+		if account.Name == name {
+			named = append(named, account)
+		}
+	}
+
+	return named, nil
+}
+
+func gitError(err error) {
+	fmt.Fprintf(os.Stderr, "pk: %v\n", err)
+	os.Exit(1)
+}
+
+//runDockerCredentialCommand implements the docker credential helper
+//protocol. Docker shows whatever the helper prints to stdout on failure.
+func (comm *commander) runDockerCredentialCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		token, err := comm.secrets.Get(pk.AppName, "token")
+		if err != nil {
+			dockerError(errors.Wrap(errors.New("could not read token, run pk login"), err))
+		}
+
+		ctx := context.Background()
+
+		switch args[0] {
+		case docker.Store:
+			creds, err := docker.ReadCredentials(os.Stdin)
+			if err != nil {
+				dockerError(err)
+			}
+
+			name := docker.AccountName(creds.ServerURL)
+			if err := comm.eraseDockerCredentials(ctx, token, name, creds.Username); err != nil {
+				dockerError(err)
+			}
+
+			//the old secret of the same login goes to its history
+			existing, err := comm.keeper.Get(ctx, token, name, creds.Username)
+			if err == nil {
+				if existing.Password != creds.Secret {
+					_, err = comm.keeper.Update(ctx, token, name, creds.Username, pk.Account{Password: creds.Secret})
+				}
+				if err != nil {
+					dockerError(err)
+				}
+				return
+			}
+			if !errors.Contains(err, pk.ErrNotFound) {
+				dockerError(err)
+			}
+
+			account := pk.Account{
+				Name:     name,
+				UserName: creds.Username,
+				Password: creds.Secret,
+				Created:  time.Now().UTC().Format(time.RFC3339),
+			}
+			if err := comm.keeper.Add(ctx, token, account); err != nil {
+				dockerError(err)
+			}
+
+		case docker.Get:
+			serverURL, err := docker.ReadServerURL(os.Stdin)
+			if err != nil {
+				dockerError(err)
+			}
+
+			accounts, err := comm.accountsNamed(ctx, token, docker.AccountName(serverURL))
+			if err != nil {
+				dockerError(err)
+			}
+			if len(accounts) == 0 {
+				dockerError(docker.ErrCredentialsNotFound)
+			}
+
+			creds := docker.Credentials{
+				ServerURL: serverURL,
+				Username:  accounts[0].UserName,
+				Secret:    accounts[0].Password,
+			}
+			if err := json.NewEncoder(os.Stdout).Encode(creds); err != nil {
+				dockerError(err)
+			}
+
+		case docker.Erase:
+			serverURL, err := docker.ReadServerURL(os.Stdin)
+			if err != nil {
+				dockerError(err)
+			}
+
+			err = comm.eraseDockerCredentials(ctx, token, docker.AccountName(serverURL), "")
+			if err != nil {
+				dockerError(err)
+			}
+
+		case docker.List:
+			//every registry account name starts with the prefix
+			accounts, _, err := comm.keeper.List(ctx, token, pk.Query{Name: docker.AccountName("")})
+			if err != nil {
+				dockerError(err)
+			}
+
+			registries := map[string]string{}
+			for _, account := range accounts {
+				if serverURL, ok := docker.ServerURL(account.Name); ok {
+					registries[serverURL] = account.UserName
+				}
+			}
+
+			if err := json.NewEncoder(os.Stdout).Encode(registries); err != nil {
+				dockerError(err)
+			}
+
+		default:
+			dockerError(errors.New(fmt.Sprintf("unknown credential action %v", args[0])))
+		}
+	}
+}
+
+//eraseDockerCredentials deletes the accounts stored under name, but the
+//one of username keep when it is not empty.
+func (comm *commander) eraseDockerCredentials(ctx context.Context, token, name, keep string) error {
+	accounts, err := comm.accountsNamed(ctx, token, name)
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		if keep != "" && account.UserName == keep {
+			continue
+		}
+
+		err := comm.keeper.Delete(ctx, token, account.Name, account.UserName)
+		if err != nil && !errors.Contains(err, pk.ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dockerError(err error) {
+	fmt.Fprintln(os.Stdout, err)
+	os.Exit(1)
+}
+
+//exitCode returns the code a shell reports for the child, 128 plus the
+//signal number when a signal killed it
+func exitCode(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+//runExecCommand starts the child with the referenced secrets added to its
+//environment. The secrets are only ever held in memory.
+func (comm *commander) runExecCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		envs, err := cmd.Flags().GetStringArray("env")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if len(args) == 0 {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		token, err := comm.secrets.Get(pk.AppName, "token")
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		r := resolver.New(comm.keeper, token)
+
+		env := os.Environ()
+		for _, e := range envs {
+			kv := strings.SplitN(e, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				logError(errors.New(fmt.Sprintf("invalid --env %q, want NAME=pk://<name>/<username>", e)))
+				os.Exit(1)
+			}
+
+			value, err := r.ResolveURI(ctx, kv[1])
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+
+			env = append(env, kv[0]+"="+value)
+		}
+
+		child := exec.Command(args[0], args[1:]...)
+		child.Env = env
+		child.Stdin = os.Stdin
+		child.Stdout = os.Stdout
+		child.Stderr = os.Stderr
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+		defer signal.Stop(signals)
+
+		if err := child.Start(); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		go func() {
+			for sig := range signals {
+				_ = child.Process.Signal(sig)
+			}
+		}()
+
+		err = child.Wait()
+
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitCode(exitErr))
+		}
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+	}
+}
+
+func (comm *commander) runInjectCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		in, err := cmd.Flags().GetString("in")
+		out, err := cmd.Flags().GetString("out")
+		force, err := cmd.Flags().GetBool("force")
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		if in == "" {
+			logUsage(cmd.Example)
+			os.Exit(1)
+		}
+
+		text, err := ioutil.ReadFile(in)
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		token, err := comm.secrets.Get(pk.AppName, "token")
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		r := resolver.New(comm.keeper, token)
+
+		if out == "" || out == "-" {
+			if err := r.Render(ctx, os.Stdout, filepath.Base(in), string(text)); err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		err = files.WriteFile(out, files.SecretPerm, force, func(w io.Writer) error {
+			return r.Render(ctx, w, filepath.Base(in), string(text))
+		})
+
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logCreated(out)
+	}
+}
+
+//runGenerateCommand prints a new secret, nothing is stored
+func (comm *commander) runGenerateCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		secret, err := generateSecret(cmd)
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logMessage("password", secret)
+	}
+}
+
+//generateSecret generates a secret with the profile named by --profile,
+//adjusted by the other generator flags that were set
+func generateSecret(cmd *cobra.Command) (string, error) {
+	flags := cmd.Flags()
+
+	profile, _ := flags.GetString("profile")
+	policy, err := generator.Profile(profile)
+	if err != nil {
+		return "", err
+	}
+
+	if flags.Changed("length") {
+		policy.Length, _ = flags.GetInt("length")
+		policy.Words = 0
+	}
+	for name, class := range map[string]*bool{
+		"lower":   &policy.Lower,
+		"upper":   &policy.Upper,
+		"digits":  &policy.Digits,
+		"symbols": &policy.Symbols,
+	} {
+		if flags.Changed(name) {
+			*class, _ = flags.GetBool(name)
+		}
+	}
+	if flags.Changed("no-ambiguous") {
+		policy.ExcludeAmbiguous, _ = flags.GetBool("no-ambiguous")
+	}
+	if flags.Changed("words") {
+		policy.Words, _ = flags.GetInt("words")
+	}
+	if flags.Changed("separator") {
+		policy.Separator, _ = flags.GetString("separator")
+	}
+
+	if verboseResp {
+		fmt.Fprintf(os.Stderr, "entropy: %.0f bits\n", policy.Entropy())
+	}
+
+	return generator.Generate(policy)
+}
+
+//parseAge parses a duration such as 30d, 12h or 90m
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, errors.New(fmt.Sprintf("invalid age %q, want e.g 30d or 12h", s))
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.New(fmt.Sprintf("invalid age %q, want e.g 30d or 12h", s))
+	}
+	return d, nil
+}
+
+//listPage is a page of pk list --page-size in the json and yaml outputs
+type listPage struct {
+	Accounts []pk.Account `json:"accounts" yaml:"accounts"`
+	Next     string       `json:"next,omitempty" yaml:"next,omitempty"`
+}
+
+//readQuery builds the query of pk list from its flags. Every --filter is a
+//key=value pair, the keys are those of pk.Query
+func readQuery(cmd *cobra.Command) (pk.Query, error) {
+	var query pk.Query
+
+	filters, err := cmd.Flags().GetStringArray("filter")
+	if err != nil {
+		return query, err
+	}
+
+	for _, filter := range filters {
+		i := strings.Index(filter, "=")
+		if i <= 0 {
+			return query, errors.New(fmt.Sprintf("invalid --filter %q, want key=value", filter))
+		}
+
+		key, value := filter[:i], filter[i+1:]
+		switch key {
+		case "name":
+			query.Name = value
+		case "username":
+			query.UserName = value
+		case "email":
+			query.Email = value
+		case "tag":
+			query.Tag = value
+		case "folder":
+			query.Folder = value
+		case "created-before", "created-after":
+			t, err := parseDate(value)
+			if err != nil {
+				return query, err
+			}
+			if key == "created-before" {
+				query.CreatedBefore = t
+			} else {
+				query.CreatedAfter = t
+			}
+		default:
+			return query, errors.New(fmt.Sprintf("unknown --filter %q, want one of name, username, email, tag, folder, created-before or created-after", key))
+		}
+	}
+
+	if query.Sort, err = cmd.Flags().GetString("sort"); err != nil {
+		return query, err
+	}
+	if query.PageSize, err = cmd.Flags().GetInt("page-size"); err != nil {
+		return query, err
+	}
+	if cmd.Flags().Changed("limit") {
+		if query.PageSize, err = cmd.Flags().GetInt("limit"); err != nil {
+			return query, err
+		}
+	}
+	if query.Cursor, err = cmd.Flags().GetString("cursor"); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+//parseDate parses RFC3339 times and dates such as 2021-03-01
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("invalid date %q, want e.g 2021-03-01", s))
+	}
+	return t, nil
+}
+
+//addDetailFlags adds the flags of the optional account details
+func addDetailFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("url", nil, "login url, repeat for several")
+	cmd.Flags().String("notes", "", "free form notes")
+	cmd.Flags().StringSlice("tag", nil, "tag, repeat or separate with commas")
+	cmd.Flags().String("folder", "", "folder path (e.g work/dev)")
+	cmd.Flags().StringArray("field", nil, "custom field as key=value, repeat for several")
+	cmd.Flags().StringArray("secret-field", nil, "custom field as key=value, stored encrypted")
+	cmd.Flags().String("otp", "", "two factor seed, an otpauth:// uri or a base32 secret")
+	cmd.Flags().String("otp-qr", "", "image file of the two factor qr code")
+}
+
+//csvReader returns reader, or one with the column mapping and delimiter
+//given with --map and --delimiter
+func csvReader(cmd *cobra.Command, reader pk.Reader) (pk.Reader, error) {
+	mapFlag, _ := cmd.Flags().GetString("map")
+	delimiterFlag, _ := cmd.Flags().GetString("delimiter")
+	if mapFlag == "" && delimiterFlag == "" {
+		return reader, nil
+	}
+
+	mapping, err := csv.ParseMapping(mapFlag)
+	if err != nil {
+		return nil, err
+	}
+	delimiter, err := csv.ParseDelimiter(delimiterFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	return csv.NewReaderWith(csv.Options{Mapping: mapping, Delimiter: delimiter}), nil
+}
+
+//kdbxKey returns the key of a KeePass database, the password is read from
+//kdbxPasswordEnv or prompted for and may be left empty with a key file
+func kdbxKey(keyFile string, confirm bool) (kdbx.Key, error) {
+	var key kdbx.Key
+
+	if keyFile != "" {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return key, err
+		}
+		key.KeyFile = data
+	}
+
+	if env, ok := os.LookupEnv(kdbxPasswordEnv); ok {
+		key.Password = env
+		return key, nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Enter database password: ")
+	password, err := terminal.ReadPassword(0)
+	if err != nil {
+		return key, err
+	}
+
+	if confirm {
+		fmt.Fprintln(os.Stderr, "Enter database password again: ")
+		again, err := terminal.ReadPassword(0)
+		if err != nil {
+			return key, err
+		}
+		if !bytes.Equal(password, again) {
+			return key, errors.New("password mismatch")
+		}
+	}
+
+	key.Password = string(password)
+	if key.Password == "" && key.KeyFile == nil {
+		return key, kdbx.ErrNoKey
+	}
+
+	return key, nil
+}
+
+//readDetails sets the details given with the flags of addDetailFlags on
+//account and reports whether any was given. --field and --secret-field
+//together replace the custom fields
+func readDetails(cmd *cobra.Command, account *pk.Account) (changed bool, err error) {
+	flags := cmd.Flags()
+
+	if flags.Changed("url") {
+		if account.URLs, err = flags.GetStringArray("url"); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if flags.Changed("notes") {
+		if account.Notes, err = flags.GetString("notes"); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if flags.Changed("tag") {
+		if account.Tags, err = flags.GetStringSlice("tag"); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if flags.Changed("folder") {
+		if account.Folder, err = flags.GetString("folder"); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	for _, flag := range []string{"field", "secret-field"} {
+		if !flags.Changed(flag) {
+			continue
+		}
+
+		values, err := flags.GetStringArray(flag)
+		if err != nil {
+			return false, err
+		}
+
+		for _, v := range values {
+			i := strings.Index(v, "=")
+			if i <= 0 {
+				return false, errors.New(fmt.Sprintf("invalid --%v %q, want key=value", flag, v))
+			}
+			account.Fields = append(account.Fields, pk.Field{
+				Name:   v[:i],
+				Value:  v[i+1:],
+				Secret: flag == "secret-field",
+			})
+		}
+		changed = true
+	}
+
+	if flags.Changed("otp") {
+		if account.OTP, err = flags.GetString("otp"); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if path, _ := flags.GetString("otp-qr"); path != "" {
+		if account.OTP, err = readOTPImage(path); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+//readOTPImage decodes the otpauth:// URI of the QR code in the image at
+//path, sites show one when two factor authentication is turned on
+func readOTPImage(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%v: %v", path, err))
+	}
+
+	uri, err := qr.Decode(img)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%v: %v", path, err))
+	}
+
+	if !strings.HasPrefix(uri, "otpauth://") {
+		return "", errors.New(fmt.Sprintf("the qr code in %v is not an otpauth:// uri", path))
+	}
+
+	return uri, nil
+}
+
+func addGeneratorFlags(cmd *cobra.Command) {
+	profiles := strings.Join(generator.ProfileNames(), ", ")
+	cmd.Flags().String("profile", generator.DefaultProfile, fmt.Sprintf("generator profile (%v)", profiles))
+	cmd.Flags().Int("length", 0, "password length")
+	cmd.Flags().Bool("lower", false, "use lower case letters")
+	cmd.Flags().Bool("upper", false, "use upper case letters")
+	cmd.Flags().Bool("digits", false, "use digits")
+	cmd.Flags().Bool("symbols", false, "use symbols")
+	cmd.Flags().Bool("no-ambiguous", false, fmt.Sprintf("leave out ambiguous characters (%v)", generator.Ambiguous))
+	cmd.Flags().Int("words", 0, "generate a diceware passphrase with this many words")
+	cmd.Flags().String("separator", "-", "passphrase word separator")
+}
+
+//runAuditPasswordsCommand decrypts every account and reports weak, reused
+//and old passwords
+func (comm *commander) runAuditPasswordsCommand() commands.RunFunc {
+	return func(cmd *cobra.Command, args []string) {
+		minScore, _ := cmd.Flags().GetInt("min-score")
+		maxAge, _ := cmd.Flags().GetInt("max-age")
+		failOnIssues, _ := cmd.Flags().GetBool("fail")
+		hibp, _ := cmd.Flags().GetString("hibp")
+
+		if hibp == "" {
+			hibp = viper.GetString("hibp.file")
+		}
+
+		if !cmd.Flags().Changed("min-score") {
+			policy, err := loadPolicy()
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+			minScore = policy.MinScore
+		}
+
+		token, err := comm.secrets.Get(pk.AppName, "token")
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		accounts, _, err := comm.keeper.List(context.Background(), token, pk.Query{})
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		opts := audit.Options{
+			MinScore: minScore,
+			MaxAge:   time.Duration(maxAge) * 24 * time.Hour,
+		}
+
+		if hibp != "" {
+			path, err := homedir.Expand(hibp)
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+
+			checker, err := breach.Open(path)
+			if err != nil {
+				logError(err)
+				os.Exit(1)
+			}
+			defer checker.Close()
+
+			opts.Breach = checker
+		}
+
+		report, err := audit.Passwords(accounts, opts)
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+		logReport(report)
+
+		if failOnIssues && report.Issues() > 0 {
+			os.Exit(auditExitCode)
+		}
+	}
+}
+
+func (comm *commander) Run(command commands.Command) commands.RunFunc {
+
+	switch command {
+
+	case commands.Init:
+		return comm.runInitCommand()
+
+	case commands.Login:
+		return comm.runLoginCommand()
+
+	case commands.Add:
+		return comm.runAddCommand()
+
+	case commands.Get:
+		return comm.runGetCommand()
+
+	case commands.Delete:
+		return comm.runDeleteCommand()
+
+	case commands.List:
+		return comm.runListCommand()
+
+	case commands.Update:
+		return comm.runUpdateCommand()
+
+	case commands.DB:
+		return comm.runDBCommand()
+
+	case commands.Serve:
+		return comm.runServeCommand()
+
+	case commands.Agent:
+		return comm.runAgentCommand()
+
+	case commands.Lock:
+		return comm.runLockCommand()
+
+	case commands.GitCredential:
+		return comm.runGitCredentialCommand()
+
+	case commands.DockerCredential:
+		return comm.runDockerCredentialCommand()
+
+	case commands.Exec:
+		return comm.runExecCommand()
+
+	case commands.Inject:
+		return comm.runInjectCommand()
+
+	case commands.Generate:
+		return comm.runGenerateCommand()
+
+	case commands.AuditPasswords:
+		return comm.runAuditPasswordsCommand()
+
+	case commands.History:
+		return comm.runHistoryCommand()
+
+	case commands.Rollback:
+		return comm.runRollbackCommand()
+
+	case commands.TrashList:
+		return comm.runTrashListCommand()
+
+	case commands.TrashRestore:
+		return comm.runTrashRestoreCommand()
+
+	case commands.TrashPurge:
+		return comm.runTrashPurgeCommand()
+
+	case commands.Search:
+		return comm.runSearchCommand()
+
+	case commands.TUI:
+		return comm.runTUICommand()
+
+	case commands.OTP:
+		return comm.runOTPCommand()
+
+	case commands.TwoFactorEnable:
+		return comm.runTwoFactorEnableCommand()
+
+	case commands.TwoFactorDisable:
+		return comm.runTwoFactorDisableCommand()
+
+	case commands.Export:
+		return comm.runExportCommand()
+
+	case commands.Import:
+		return comm.runImportCommand()
+
+	default:
+		return func(cmd *cobra.Command, args []string) {
+			logUsage("this should not happen")
+		}
+	}
+}
+
+func makeInitCommand(comm commands.Runner) *cobra.Command {
+	var initCmd = &cobra.Command{
+		Use:     "init",
+		Short:   "set up pk",
+		Long:    `init should be run firstly before anything after installation`,
+		Example: "pk init -u <username> -e <email>",
+		Run:     comm.Run(commands.Init),
+	}
+
+	initCmd.Flags().BoolP("credstore", "c", true, "store secrets")
+	initCmd.Flags().Bool("2fa", false, "ask for a code of an authenticator app at every login")
+
+	return initCmd
+}
+
+func makeRegisterCommand(comm commands.Runner) *cobra.Command {
+	var regCmd = &cobra.Command{
+		Use:     "register",
+		Short:   "set up pk",
+		Long:    `init should be run firstly before anything after installation`,
+		Example: "pk init -u <username> -e <email>",
+		Run:     comm.Run(commands.Register),
+	}
+
+	return regCmd
+}
+
+func makeLoginCommand(comm commands.Runner) *cobra.Command {
+	// loginCmd represents the login command
+	var loginCmd = &cobra.Command{
+		Use:     "login",
+		Short:   "generate auth token",
+		Example: "pk login -u <username>",
+		Long: `generates a jwt token string after the user has supplied username along side master password.
+when two factor authentication is on the code is asked for unless --code is given`,
+		Run: comm.Run(commands.Login),
+	}
+
+	loginCmd.Flags().String("code", "", "code of the authenticator app or a recovery code")
+
+	return loginCmd
+
+}
+
+func makeAddCommand(comm commands.Runner) *cobra.Command {
 	// addCmd represents the add command
 	var addCmd = &cobra.Command{
 		Use:     "add",
 		Short:   "add new details to db",
-		Example: "pk add --file accounts.json",
+		Example: "pk add --file accounts.json\npk add --file export.csv --map name=Title,username=Login\npk add --file team.kdbx --key-file team.keyx\npk add -n github -u alice -e alice@example.com --generate",
 		Long:    `provide name,username,email and password to add new acc`,
 		Run:     comm.Run(commands.Add),
 	}
 
-	addCmd.PersistentFlags().StringP("file", "f", "", "json or csv accounts file")
+	addCmd.PersistentFlags().StringP("file", "f", "", "json, csv or kdbx accounts file")
+	addCmd.Flags().String("key-file", "", "key file of a kdbx database")
+	addCmd.Flags().String("map", "", "csv columns by header, e.g name=Title,username=Login")
+	addCmd.Flags().String("delimiter", "", "csv field delimiter, guessed when not given (tab for tabs)")
+	addCmd.Flags().Bool("generate", false, "store a generated password instead of prompting for one")
+	addGeneratorFlags(addCmd)
+	addDetailFlags(addCmd)
 
 	return addCmd
 
@@ -623,15 +2506,29 @@ func makeListCommand(comm commands.Runner) *cobra.Command {
 	var listCmd = &cobra.Command{
 		Use:     "list",
 		Short:   "retrieve all accounts",
-		Example: "pk list",
-		Long:    `list all accounts details`,
-		Run:     comm.Run(commands.List),
+		Example: "pk list --filter name=git* --filter tag=work --sort -created --page-size 20",
+		Long: `list the accounts matching every --filter, sorted by --sort.
+filters are key=value pairs:
+  name, username, email    substring, or glob when the value has * or ?
+  tag                      accounts carrying the tag
+  folder                   accounts in the folder or its subfolders
+  created-before, created-after   date (2021-03-01) or RFC3339 time
+--sort takes name, username, email or created, prefixed with - for descending order.
+with --page-size the cursor of the next page is printed, pass it back with --cursor`,
+		Run: comm.Run(commands.List),
 	}
 
+	listCmd.Flags().StringArray("filter", nil, "key=value filter, repeat for several")
+	listCmd.Flags().String("sort", "", "name, username, email or created, - for descending")
+	listCmd.Flags().Int("page-size", 0, "accounts per page, 0 lists all of them")
+	listCmd.Flags().String("cursor", "", "cursor of the page to list")
 	listCmd.PersistentFlags().IntP("limit", "l", 0, "limits of accounts to list")
-	listCmd.PersistentFlags().StringP("out", "o", "", "output filename")
-	listCmd.PersistentFlags().StringP("format", "m", "", "output file format")
+	_ = listCmd.PersistentFlags().MarkDeprecated("limit", "use --page-size")
+	listCmd.PersistentFlags().StringP("out", "o", "", "output filename, - for stdout")
+	listCmd.PersistentFlags().StringP("format", "m", "", "output file format, json, csv or kdbx")
+	listCmd.Flags().String("key-file", "", "key file of the kdbx database written")
 	listCmd.PersistentFlags().StringP("dir", "d", "", "output directory")
+	listCmd.PersistentFlags().Bool("force", false, "overwrite the output file if it exists")
 
 	return listCmd
 }
@@ -642,7 +2539,8 @@ func makeDeleteCommand(comm commands.Runner) *cobra.Command {
 		Use:     "delete",
 		Short:   "delete account",
 		Example: "pk delete -n <name> -u <username>",
-		Long:    `delete account details by specifying username and name`,
+		Long: `delete account details by specifying username and name.
+the account is moved to the trash, see pk trash`,
 		Run:     comm.Run(commands.Delete),
 	}
 
@@ -654,14 +2552,229 @@ func makeUpdateCommand(comm commands.Runner) *cobra.Command {
 	var updateCmd = &cobra.Command{
 		Use:     "update",
 		Short:   "update account",
-		Example: "pk update -n <name> -u <username>",
-		Long:    `update account details by specifying username and name`,
-		Run:     comm.Run(commands.Update),
+		Example: "pk update -n <name> -u <username> [-e <email>] [--new-name <name>] [--new-username <username>] [--password | --generate]",
+		Long: `update account details by specifying username and name, only the given fields change.
+the replaced password is kept in history, see pk history and pk rollback`,
+		Run: comm.Run(commands.Update),
 	}
 
+	updateCmd.Flags().String("new-name", "", "new name of the account")
+	updateCmd.Flags().String("new-username", "", "new username of the account")
+	updateCmd.Flags().Bool("password", false, "prompt for a new password")
+	updateCmd.Flags().Bool("generate", false, "store a generated password")
+	addGeneratorFlags(updateCmd)
+	addDetailFlags(updateCmd)
+
 	return updateCmd
 }
 
+func makeHistoryCommand(comm commands.Runner) *cobra.Command {
+	// historyCmd represents the history command
+	var historyCmd = &cobra.Command{
+		Use:     "history",
+		Short:   "list previous passwords of an account",
+		Example: "pk history -n github -u alice",
+		Long: `lists the versions kept every time the password of the account was replaced,
+newest first. the passwords are only printed with --show`,
+		Run: comm.Run(commands.History),
+	}
+
+	historyCmd.Flags().Bool("show", false, "print the passwords")
+
+	return historyCmd
+}
+
+func makeRollbackCommand(comm commands.Runner) *cobra.Command {
+	// rollbackCmd represents the rollback command
+	var rollbackCmd = &cobra.Command{
+		Use:     "rollback",
+		Short:   "restore a previous password",
+		Example: "pk rollback -n github -u alice --version 2",
+		Long: `makes the password of a version listed by pk history the current one,
+the replaced password becomes a new version so the rollback can be undone`,
+		Run: comm.Run(commands.Rollback),
+	}
+
+	rollbackCmd.Flags().Int("version", 0, "version to restore")
+
+	return rollbackCmd
+}
+
+func makeTrashCommand(comm commands.Runner) *cobra.Command {
+	// trashCmd represents the trash command
+	var trashCmd = &cobra.Command{
+		Use:   "trash",
+		Short: "manage deleted accounts",
+		Long: `pk delete moves accounts to the trash, they stay there until they are
+restored or purged`,
+	}
+
+	var listCmd = &cobra.Command{
+		Use:     "list",
+		Short:   "list deleted accounts",
+		Example: "pk trash list",
+		Long:    `lists the accounts in the trash, most recently deleted first`,
+		Run:     comm.Run(commands.TrashList),
+	}
+
+	var restoreCmd = &cobra.Command{
+		Use:     "restore",
+		Short:   "restore a deleted account",
+		Example: "pk trash restore -n github -u alice",
+		Long:    `takes the account out of the trash along with its history`,
+		Run:     comm.Run(commands.TrashRestore),
+	}
+
+	var purgeCmd = &cobra.Command{
+		Use:     "purge",
+		Short:   "remove deleted accounts for good",
+		Example: "pk trash purge --older-than 30d",
+		Long: `removes the accounts deleted more than --older-than ago and their history,
+this can not be undone. --older-than 0 empties the trash`,
+		Run: comm.Run(commands.TrashPurge),
+	}
+
+	purgeCmd.Flags().String("older-than", "", "age of the deleted accounts to remove (e.g 30d or 12h)")
+
+	trashCmd.AddCommand(listCmd, restoreCmd, purgeCmd)
+
+	return trashCmd
+}
+
+func makeSearchCommand(comm commands.Runner) *cobra.Command {
+	// searchCmd represents the search command
+	var searchCmd = &cobra.Command{
+		Use:     "search <query>",
+		Short:   "fuzzy find an account",
+		Example: "pk search gh\npk search github ent --first --output json",
+		Long: `ranks the accounts by how well their name, username, email, urls and tags
+match the query, the way fuzzy finders do: "gh" finds GitHub. nothing is
+decrypted to rank them. pick one of the matches to see its details, --first
+takes the best one`,
+		Run: comm.Run(commands.Search),
+	}
+
+	searchCmd.Flags().Int("limit", 10, "most matches to offer, 0 for all")
+	searchCmd.Flags().Bool("first", false, "show the best match without asking")
+
+	return searchCmd
+}
+
+func makeTUICommand(comm commands.Runner) *cobra.Command {
+	// tuiCmd represents the tui command
+	var tuiCmd = &cobra.Command{
+		Use:     "tui",
+		Short:   "browse and edit accounts in a full screen interface",
+		Example: "pk tui\npk tui --lock-after 2m",
+		Long: `lists the accounts with a filter, shows the details of the selected one
+and has forms to add, edit and delete them. passwords stay masked until
+revealed with r. the screen locks after --lock-after without a key press
+and asks for the master password again. it works the same against the
+local database, the agent or a remote server`,
+		Run: comm.Run(commands.TUI),
+	}
+
+	tuiCmd.Flags().Duration("lock-after", 5*time.Minute, "lock after being idle this long, 0 never locks")
+
+	return tuiCmd
+}
+
+func makeOTPCommand(comm commands.Runner) *cobra.Command {
+	// otpCmd represents the otp command
+	var otpCmd = &cobra.Command{
+		Use:     "otp",
+		Short:   "print the two factor code of an account",
+		Example: "pk otp -n github -u alice",
+		Long: `prints the current TOTP (RFC 6238) code of the account and how many
+seconds it stays valid. for HOTP seeds the code of the stored counter is
+printed and the counter moves on. add a seed with --otp or --otp-qr on
+pk add or pk update`,
+		Run: comm.Run(commands.OTP),
+	}
+
+	return otpCmd
+}
+
+func makeTwoFactorCommand(comm commands.Runner) *cobra.Command {
+	// twoFACmd represents the 2fa command
+	var twoFACmd = &cobra.Command{
+		Use:   "2fa",
+		Short: "manage two factor authentication of the master account",
+		Long: `with two factor authentication on, pk login asks for the code of an
+authenticator app (RFC 6238 TOTP) besides the master password`,
+	}
+
+	var enableCmd = &cobra.Command{
+		Use:     "enable",
+		Short:   "turn two factor authentication on",
+		Example: "pk 2fa enable -u alice",
+		Long: `shows a new key to add to an authenticator app and turns two factor
+authentication on once a code of the app is entered. the recovery codes
+printed then each log in once without the app, they are not shown again`,
+		Run: comm.Run(commands.TwoFactorEnable),
+	}
+
+	var disableCmd = &cobra.Command{
+		Use:     "disable",
+		Short:   "turn two factor authentication off",
+		Example: "pk 2fa disable --code 123456",
+		Long:    `turns two factor authentication off, it takes a code of the app or a recovery code`,
+		Run:     comm.Run(commands.TwoFactorDisable),
+	}
+
+	disableCmd.Flags().String("code", "", "code of the authenticator app or a recovery code")
+
+	twoFACmd.AddCommand(enableCmd, disableCmd)
+
+	return twoFACmd
+}
+
+func makeExportCommand(comm commands.Runner) *cobra.Command {
+	// exportCmd represents the export command
+	var exportCmd = &cobra.Command{
+		Use:     "export",
+		Short:   "back the vault up to an encrypted file",
+		Example: "pk export --encrypted backup.pkx",
+		Long: `writes every account with its custom fields, two factor seed and password
+history to a single file encrypted with a passphrase (scrypt and
+XChaCha20-Poly1305). the passphrase is asked for, or read from PK_PASSPHRASE.
+restore it on any pk with pk import`,
+		Run: comm.Run(commands.Export),
+	}
+
+	exportCmd.Flags().String("encrypted", "", "bundle file to write")
+	exportCmd.Flags().Bool("force", false, "overwrite the file if it exists")
+
+	return exportCmd
+}
+
+func makeImportCommand(comm commands.Runner) *cobra.Command {
+	// importCmd represents the import command
+	var importCmd = &cobra.Command{
+		Use:     "import <file>",
+		Short:   "restore accounts from a backup or another password manager",
+		Example: "pk import backup.pkx\npk import backup.pkx --on-conflict rename\npk import --from bitwarden bitwarden_export.json",
+		Long: `adds the accounts of a bundle written by pk export, along with their
+history, or with --from the logins exported by another password manager:
+  bitwarden   json export, not encrypted
+  keepass     KeePass 2 xml export
+  lastpass    csv export
+  1password   csv export
+  chrome      csv export of the saved passwords
+  firefox     csv export of the saved logins
+--on-conflict says what to do with accounts that already exist:
+  skip        keep the existing account
+  overwrite   replace it, its password is kept in history
+  rename      import the account as "name (2)"`,
+		Run: comm.Run(commands.Import),
+	}
+
+	importCmd.Flags().String("on-conflict", string(pk.ConflictSkip), "skip, overwrite or rename")
+	importCmd.Flags().String("from", "", "password manager the file was exported from: "+strings.Join(importer.Sources, ", "))
+
+	return importCmd
+}
+
 func makeDBCommand(comm commands.Runner) *cobra.Command {
 	// dbCmd represents the get command
 	var dbCmd = &cobra.Command{
@@ -674,3 +2787,163 @@ func makeDBCommand(comm commands.Runner) *cobra.Command {
 
 	return dbCmd
 }
+
+func makeServeCommand(comm commands.Runner) *cobra.Command {
+	// serveCmd represents the serve command
+	var serveCmd = &cobra.Command{
+		Use:         "serve",
+		Short:       "serve pk over http",
+		Example:     "pk serve --addr 127.0.0.1:8080\npk serve --addr :8443 --cert server.pem --key server-key.pem",
+		Long:        `expose the password keeper as a json http api to be used by remote clients`,
+		Annotations: map[string]string{keeperAnnotation: keeperLocal},
+		Run:         comm.Run(commands.Serve),
+	}
+
+	serveCmd.PersistentFlags().StringP("addr", "a", "127.0.0.1:8080", "address to listen on, other than loopback ones need --cert and --key")
+	serveCmd.PersistentFlags().String("grpc-addr", "", "address to serve grpc on (e.g 127.0.0.1:9090), other than loopback ones need --cert and --key")
+	serveCmd.PersistentFlags().String("cert", "", "server tls certificate")
+	serveCmd.PersistentFlags().String("key", "", "server tls key")
+	serveCmd.PersistentFlags().String("ca", "", "ca used to verify grpc client certificates (mtls)")
+
+	return serveCmd
+}
+
+func makeAgentCommand(comm commands.Runner) *cobra.Command {
+	// agentCmd represents the agent command
+	var agentCmd = &cobra.Command{
+		Use:         "agent",
+		Short:       "keep pk unlocked in memory",
+		Example:     "pk agent --timeout 15m",
+		Long:        `opens the database and loads the keys once, then serves the other pk commands over a unix socket until locked or idle for too long`,
+		Annotations: map[string]string{keeperAnnotation: keeperLocal},
+		Run:         comm.Run(commands.Agent),
+	}
+
+	agentCmd.PersistentFlags().Duration("timeout", 15*time.Minute, "lock after being idle this long (0 never)")
+
+	return agentCmd
+}
+
+func makeLockCommand(comm commands.Runner) *cobra.Command {
+	// lockCmd represents the lock command
+	var lockCmd = &cobra.Command{
+		Use:         "lock",
+		Short:       "lock the agent",
+		Example:     "pk lock",
+		Long:        `makes a running agent wipe the keys from its memory and exit`,
+		Annotations: map[string]string{keeperAnnotation: keeperNone},
+		Run:         comm.Run(commands.Lock),
+	}
+
+	return lockCmd
+}
+
+func makeGitCredentialCommand(comm commands.Runner) *cobra.Command {
+	// gitCmd represents the git-credential command
+	var gitCmd = &cobra.Command{
+		Use:       "git-credential get|store|erase",
+		Short:     "git credential helper",
+		Example:   "git config --global credential.helper pk",
+		Long:      `stores git https credentials in pk, accounts are named after the protocol and host e.g https://github.com`,
+		ValidArgs: []string{git.Get, git.Store, git.Erase},
+		Run:       comm.Run(commands.GitCredential),
+	}
+
+	return gitCmd
+}
+
+func makeDockerCredentialCommand(comm commands.Runner) *cobra.Command {
+	// dockerCmd represents the docker-credential command, docker runs it
+	// through the docker-credential-pk binary
+	var dockerCmd = &cobra.Command{
+		Use:       "docker-credential store|get|erase|list",
+		Short:     "docker credential helper",
+		Example:   `set "credsStore": "pk" in ~/.docker/config.json`,
+		Long:      `stores docker registry credentials in pk, accounts are named after the registry e.g docker:https://index.docker.io/v1/`,
+		ValidArgs: []string{docker.Store, docker.Get, docker.Erase, docker.List},
+		Hidden:    true,
+		Run:       comm.Run(commands.DockerCredential),
+	}
+
+	return dockerCmd
+}
+
+func makeExecCommand(comm commands.Runner) *cobra.Command {
+	// execCmd represents the exec command
+	var execCmd = &cobra.Command{
+		Use:     "exec --env NAME=pk://<name>/<username>[#field] -- command [args...]",
+		Short:   "run a command with secrets in its environment",
+		Example: "pk exec --env DB_PASS=pk://postgres/app -- ./migrate.sh",
+		Long:    `resolves the secret references and starts the command with them set as environment variables, signals and the exit code are passed through`,
+		Run:     comm.Run(commands.Exec),
+	}
+
+	execCmd.Flags().StringArray("env", nil, "NAME=pk://<name>/<username>[#field], can be repeated")
+	// everything after the command belongs to it
+	execCmd.Flags().SetInterspersed(false)
+
+	return execCmd
+}
+
+func makeGenerateCommand(comm commands.Runner) *cobra.Command {
+	// generateCmd represents the generate command
+	var generateCmd = &cobra.Command{
+		Use:     "generate",
+		Short:   "generate a password or passphrase",
+		Example: "pk generate --profile phrase\npk generate --length 32 --no-ambiguous",
+		Long: `generates a random password from a profile, the profile can be adjusted with
+the other flags. nothing is stored, use pk add --generate for that`,
+		Annotations: map[string]string{keeperAnnotation: keeperNone},
+		Run:         comm.Run(commands.Generate),
+	}
+
+	addGeneratorFlags(generateCmd)
+
+	return generateCmd
+}
+
+func makeAuditCommand(comm commands.Runner) *cobra.Command {
+	// auditCmd represents the audit command
+	var auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "report on the health of the vault",
+		Long:  `audit subcommands inspect the stored accounts and report problems`,
+	}
+
+	var passwordsCmd = &cobra.Command{
+		Use:     "passwords",
+		Short:   "report weak, reused and old passwords",
+		Example: "pk audit passwords --max-age 180 --fail --output json",
+		Long: `decrypts every account and reports passwords scoring below --min-score,
+passwords shared by several accounts and passwords older than --max-age days.
+the passwords themselves are never printed`,
+		Run: comm.Run(commands.AuditPasswords),
+	}
+
+	passwordsCmd.Flags().Int("min-score", 0, "lowest strength score (0-4) that is not weak, defaults to policy.min_score")
+	passwordsCmd.Flags().Int("max-age", 365, "days after which a password is old, 0 turns the check off")
+	passwordsCmd.Flags().String("hibp", "", "pwned passwords file (sha1 or ntlm, ordered by hash), defaults to hibp.file")
+	passwordsCmd.Flags().Bool("fail", false, fmt.Sprintf("exit with %v when there are issues", auditExitCode))
+
+	auditCmd.AddCommand(passwordsCmd)
+
+	return auditCmd
+}
+
+func makeInjectCommand(comm commands.Runner) *cobra.Command {
+	// injectCmd represents the inject command
+	var injectCmd = &cobra.Command{
+		Use:     "inject",
+		Short:   "render a template with secrets",
+		Example: "pk inject -i app.tmpl -o app.conf",
+		Long: `renders a text/template file where secrets are referenced as {{ pk "github" "alice" "password" }}
+or {{ pk "pk://github/alice#password" }}, the output file is only readable by its owner`,
+		Run: comm.Run(commands.Inject),
+	}
+
+	injectCmd.Flags().StringP("in", "i", "", "template file")
+	injectCmd.Flags().StringP("out", "o", "", "output file (stdout if empty)")
+	injectCmd.Flags().Bool("force", false, "overwrite the output file if it exists")
+
+	return injectCmd
+}
@@ -27,6 +27,27 @@ const (
 	List
 	Update
 	DB
+	Serve
+	Agent
+	Lock
+	GitCredential
+	DockerCredential
+	Exec
+	Inject
+	Generate
+	AuditPasswords
+	History
+	Rollback
+	TrashList
+	TrashRestore
+	TrashPurge
+	Search
+	TUI
+	OTP
+	TwoFactorEnable
+	TwoFactorDisable
+	Export
+	Import
 )
 
 //RunFunc wraps the run func in cobra.Command
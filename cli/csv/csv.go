@@ -14,100 +14,422 @@
 package csv
 
 import (
-	"bufio"
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
 	"io"
-	"os"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
 	_ pk.Reader = (*reader)(nil)
 	_ pk.Writer = (*writer)(nil)
 	_ pk.ReaderWriter = (*readerWriter)(nil)
+	_ errors.Error = (*ValidationError)(nil)
+)
 
+var (
+	ErrInvalidRows      = errors.New("invalid csv rows")
+	ErrInvalidMapping   = errors.New("invalid column mapping")
+	ErrInvalidDelimiter = errors.New("invalid csv delimiter")
+	ErrMissingColumn    = errors.New("missing csv column")
 )
 
-type readerWriter struct {
+//RowError is a row that could not be read
+type RowError struct {
+	Line    int    `json:"line" yaml:"line"`
+	Message string `json:"message" yaml:"message"`
+}
 
+//ValidationError lists every row that could not be read, nothing is
+//read from a file with bad rows. It contains ErrInvalidRows
+type ValidationError struct {
+	Rows []RowError `json:"rows"`
 }
 
-func ReaderWriter() pk.ReaderWriter {
-	return &readerWriter{}
+func (v *ValidationError) Error() string {
+	return v.Msg() + " : " + v.Err().Error()
 }
 
-func (r readerWriter) Read(ctx context.Context, fileName string) (res []pk.Account, err error) {
-	panic("implement me")
+func (v *ValidationError) Msg() string {
+	return ErrInvalidRows.Error()
 }
 
-func (r readerWriter) Write(ctx context.Context, request pk.FileWriterReq) error {
-	panic("implement me")
+func (v *ValidationError) Err() errors.Error {
+	msgs := make([]string, len(v.Rows))
+	for i, row := range v.Rows {
+		msgs[i] = fmt.Sprintf("line %v: %v", row.Line, row.Message)
+	}
+	return errors.New(strings.Join(msgs, ", "))
 }
 
-type reader struct{}
+type readerWriter struct {
+	pk.Reader
+	pk.Writer
+}
+
+func ReaderWriter() pk.ReaderWriter {
+	return &readerWriter{Reader: NewReader(), Writer: NewWriter()}
+}
+
+//Options changes how the reader takes a file apart
+type Options struct {
+	//Mapping gives the header of the file for a column, like Title for
+	//name. Columns left out are looked up by their own name
+	Mapping map[string]string
+
+	//Delimiter separates the fields, it is guessed from the first line
+	//when zero
+	Delimiter rune
+}
+
+type reader struct {
+	opts Options
+}
 
 func NewReader() pk.Reader {
 	return &reader{}
 }
 
-func (r *reader) Read(ctx context.Context, fileName string) (res []pk.Account, err error) {
-	csvFile, err := os.Open(fileName)
-	reader := csv.NewReader(bufio.NewReader(csvFile))
+//NewReaderWith returns a reader that maps and splits columns as opts says
+func NewReaderWith(opts Options) pk.Reader {
+	return &reader{opts: opts}
+}
+
+//columns is the header written by the writer. Files with a header are
+//read by column name, files without one by position, the first four
+//columns being required. otp holds an otpauth:// URI or a base32 TOTP
+//secret
+var columns = []string{"name", "username", "email", "password", "created",
+	"urls", "notes", "tags", "folder", "fields", "otp"}
+
+//required are the columns a file with a header has to have
+var required = []string{"name", "username", "password"}
 
-	for {
-		line, err := reader.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			err = nil
+//delimiters are the ones guessed when none is given
+var delimiters = []rune{',', ';', '\t', '|'}
+
+const bom = "\ufeff"
+
+func (r *reader) Read(ctx context.Context, fileName string) ([]pk.Account, error) {
+	for col := range r.opts.Mapping {
+		if index(columns, col) < 0 {
+			return nil, errors.Wrap(ErrInvalidMapping, errors.New(fmt.Sprintf("unknown column %v", col)))
+		}
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimPrefix(string(data), bom)
+
+	//the header is guessed from its own line, records are only split
+	//once the delimiter is known
+	comma := r.opts.Delimiter
+	if comma == 0 {
+		comma = guessDelimiter(firstLine(text))
+	}
+
+	records := splitRecords(text, comma)
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	var rows []RowError
+	fail := func(line int, msg string) {
+		rows = append(rows, RowError{Line: line, Message: msg})
+	}
+
+	first, err := parseRecord(records[0].text, comma)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRows, errors.New(fmt.Sprintf("line %v: %v", records[0].line, err)))
+	}
+
+	positions, header := r.header(first)
+	if header {
+		records = records[1:]
+		for _, col := range required {
+			if positions[col] < 0 {
+				return nil, errors.Wrap(ErrMissingColumn, errors.New(r.headerName(col)))
+			}
+		}
+	} else if len(r.opts.Mapping) > 0 {
+		return nil, errors.Wrap(ErrInvalidMapping, errors.New("the file has no header row"))
+	}
+
+	var res []pk.Account
+	for _, rec := range records {
+		line, err := parseRecord(rec.text, comma)
+		if err != nil {
+			fail(rec.line, err.Error())
+			continue
+		}
+
+		if !header && len(line) < 4 {
+			fail(rec.line, fmt.Sprintf("%v fields, want at least name, username, email and password", len(line)))
+			continue
+		}
+
+		column := func(col string) string {
+			if i := positions[col]; i >= 0 && i < len(line) {
+				return line[i]
+			}
+			return ""
 		}
 
 		acc := pk.Account{
-			Name:     line[0],
-			UserName: line[1],
-			Email:    line[2],
-			Password: line[3],
+			Name:     strings.TrimSpace(column("name")),
+			UserName: strings.TrimSpace(column("username")),
+			Email:    strings.TrimSpace(column("email")),
+			Password: column("password"),
+			Created:  column("created"),
+			URLs:     split(column("urls"), "\n"),
+			Notes:    column("notes"),
+			Tags:     split(column("tags"), ","),
+			Folder:   column("folder"),
+			OTP:      strings.TrimSpace(column("otp")),
+		}
+
+		var missing []string
+		values := map[string]string{"name": acc.Name, "username": acc.UserName, "password": acc.Password}
+		for _, col := range required {
+			if values[col] == "" {
+				missing = append(missing, col)
+			}
+		}
+		if len(missing) > 0 {
+			fail(rec.line, "missing "+strings.Join(missing, ", "))
+			continue
+		}
+
+		if fields := column("fields"); fields != "" {
+			if err := json.Unmarshal([]byte(fields), &acc.Fields); err != nil {
+				fail(rec.line, fmt.Sprintf("fields: %v", err))
+				continue
+			}
 		}
 
 		res = append(res, acc)
+	}
 
+	if len(rows) > 0 {
+		return nil, &ValidationError{Rows: rows}
 	}
 
-	return res, err
+	return res, nil
 }
 
-type writer struct{}
+//header reports whether line is a header and where each column is, -1
+//when it is not there. A line is a header when it names two columns, or
+//one when it is the only field
+func (r *reader) header(line []string) (map[string]int, bool) {
+	positions := make(map[string]int, len(columns))
+	found := 0
+	for _, col := range columns {
+		positions[col] = -1
+		name := r.headerName(col)
+		for i, field := range line {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				positions[col] = i
+				found++
+				break
+			}
+		}
+	}
 
-func NewWriter() pk.Writer {
-	return &writer{}
+	if found >= 2 || found == 1 && len(line) == 1 {
+		return positions, true
+	}
+
+	for i, col := range columns {
+		positions[col] = i
+	}
+	return positions, false
 }
 
-func (w *writer) Write(ctx context.Context, request pk.FileWriterReq) error {
+func (r *reader) headerName(col string) string {
+	if name, ok := r.opts.Mapping[col]; ok {
+		return name
+	}
+	return col
+}
+
+//record is the text of one csv record and the line it starts on
+type record struct {
+	line int
+	text string
+}
+
+//splitRecords cuts data into records of fields separated by comma,
+//quoted fields may span lines. Blank lines are dropped. Records are
+//parsed one by one so a bad one does not stop the others from being read
+func splitRecords(data string, comma rune) []record {
+	var records []record
+	start, startLine, line := 0, 1, 1
+	quoted, closed := false, false
+
+	emit := func(end int) {
+		text := strings.TrimSuffix(data[start:end], "\r")
+		if strings.TrimSpace(text) != "" {
+			records = append(records, record{line: startLine, text: text})
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case quoted:
+			if c == '"' {
+				quoted, closed = false, true
+				continue
+			}
+		case c == '"':
+			//a quote opens a field, or is the second of an escaped pair
+			prev, _ := utf8.DecodeLastRuneInString(data[:i])
+			quoted = closed || i == start || prev == comma
+		case c == '\n':
+			emit(i)
+			start, startLine = i+1, line+1
+		}
+		closed = false
+		if c == '\n' {
+			line++
+		}
+	}
+	emit(len(data))
+
+	return records
+}
+
+func parseRecord(text string, comma rune) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
 
-	fileName := request.FileName
-	fileNameExt := fmt.Sprintf("%v.csv", fileName)
-	file, err := os.Create(fileNameExt)
+	line, err := reader.Read()
 	if err != nil {
-		return err
+		if perr, ok := err.(*csv.ParseError); ok {
+			return nil, perr.Err
+		}
+		return nil, err
+	}
+	return line, nil
+}
+
+//guessDelimiter picks the delimiter found most in line, comma on a tie
+//firstLine returns the first line of data that is not blank
+func firstLine(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func guessDelimiter(line string) rune {
+	best, most := ',', 0
+	for _, d := range delimiters {
+		if n := strings.Count(line, string(d)); n > most {
+			best, most = d, n
+		}
+	}
+	return best
+}
+
+//ParseMapping reads a mapping given as name=Title,username=Login
+func ParseMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		col := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.Wrap(ErrInvalidMapping, errors.New(fmt.Sprintf("%v: want column=header", pair)))
+		}
+		if index(columns, col) < 0 {
+			return nil, errors.Wrap(ErrInvalidMapping, errors.New(fmt.Sprintf("unknown column %v", col)))
+		}
+		mapping[col] = strings.TrimSpace(parts[1])
 	}
-	defer file.Close()
+	return mapping, nil
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+//ParseDelimiter reads a delimiter given as one character, or tab
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "tab", "\\t":
+		return '\t', nil
+	}
 
-	as := request.Accounts
+	runes := []rune(s)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\n' || runes[0] == '\r' {
+		return 0, errors.Wrap(ErrInvalidDelimiter, errors.New(s))
+	}
+	return runes[0], nil
+}
 
-	for _, acc := range as {
-		var record []string
+func index(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
 
-		record = []string{acc.Name, acc.UserName, acc.Email, acc.Password, acc.Created}
-		err = writer.Write(record)
-		if err != nil {
-			return err
+//split drops the empty parts of s split around sep
+func split(s, sep string) []string {
+	var parts []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
 		}
 	}
+	return parts
+}
+
+type writer struct{}
+
+func NewWriter() pk.Writer {
+	return &writer{}
+}
+
+//Write writes the header and one record per account
+func (w *writer) Write(ctx context.Context, request pk.FileWriterReq) error {
+	return request.WriteWith(func(out io.Writer) error {
+		writer := csv.NewWriter(out)
+
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+
+		for _, acc := range request.Accounts {
+			var fields []byte
+			if len(acc.Fields) > 0 {
+				var err error
+				fields, err = json.Marshal(acc.Fields)
+				if err != nil {
+					return err
+				}
+			}
+
+			record := []string{acc.Name, acc.UserName, acc.Email, acc.Password, acc.Created,
+				strings.Join(acc.URLs, "\n"), acc.Notes, strings.Join(acc.Tags, ","), acc.Folder, string(fields), acc.OTP}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
 
-	return err
+		writer.Flush()
+		return writer.Error()
+	})
 }
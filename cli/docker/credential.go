@@ -0,0 +1,97 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package docker speaks the protocol of docker credential helpers.
+// See https://github.com/docker/docker-credential-helpers
+package docker
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// Actions docker asks a credential helper to perform.
+const (
+	Store = "store"
+	Get   = "get"
+	Erase = "erase"
+	List  = "list"
+)
+
+// accountPrefix marks the accounts holding registry credentials.
+const accountPrefix = "docker:"
+
+var (
+	// ErrCredentialsNotFound is the exact message docker expects when
+	// a helper has no credentials for a registry.
+	ErrCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+	ErrMissingServerURL = errors.New("no server url has been provided")
+)
+
+// Credentials is the json object exchanged with docker.
+type Credentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// AccountName is the name under which the credentials of serverURL are
+// kept in pk, e.g docker:https://index.docker.io/v1/
+func AccountName(serverURL string) string {
+	return accountPrefix + serverURL
+}
+
+// ServerURL reverses AccountName. ok is false for accounts that do not
+// hold registry credentials.
+func ServerURL(accountName string) (serverURL string, ok bool) {
+	if !strings.HasPrefix(accountName, accountPrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(accountName, accountPrefix), true
+}
+
+// ReadCredentials decodes the payload of the store action.
+func ReadCredentials(r io.Reader) (Credentials, error) {
+	var c Credentials
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return c, err
+	}
+
+	c.ServerURL = strings.TrimSpace(c.ServerURL)
+	if c.ServerURL == "" {
+		return c, ErrMissingServerURL
+	}
+
+	return c, nil
+}
+
+// ReadServerURL reads the payload of the get and erase actions.
+func ReadServerURL(r io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	serverURL := strings.TrimSpace(string(b))
+	if serverURL == "" {
+		return "", ErrMissingServerURL
+	}
+
+	return serverURL, nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccountName(t *testing.T) {
+	serverURL := "https://index.docker.io/v1/"
+
+	name := AccountName(serverURL)
+	got, ok := ServerURL(name)
+	if !ok || got != serverURL {
+		t.Errorf("ServerURL(%v) = %v, %v, want %v, true", name, got, ok, serverURL)
+	}
+
+	if _, ok := ServerURL("github"); ok {
+		t.Error("ServerURL() accepted an account that is not a registry")
+	}
+}
+
+func TestReadCredentials(t *testing.T) {
+	input := `{"ServerURL":"ghcr.io","Username":"alice","Secret":"s3cr3t"}`
+
+	c, err := ReadCredentials(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadCredentials() error = %v", err)
+	}
+
+	want := Credentials{ServerURL: "ghcr.io", Username: "alice", Secret: "s3cr3t"}
+	if c != want {
+		t.Errorf("ReadCredentials() = %v, want %v", c, want)
+	}
+
+	if _, err := ReadCredentials(strings.NewReader(`{"Username":"alice"}`)); err != ErrMissingServerURL {
+		t.Errorf("ReadCredentials() error = %v, want %v", err, ErrMissingServerURL)
+	}
+}
+
+func TestReadServerURL(t *testing.T) {
+	got, err := ReadServerURL(strings.NewReader("ghcr.io\n"))
+	if err != nil || got != "ghcr.io" {
+		t.Errorf("ReadServerURL() = %v, %v, want ghcr.io", got, err)
+	}
+
+	if _, err := ReadServerURL(strings.NewReader("\n")); err != ErrMissingServerURL {
+		t.Errorf("ReadServerURL() error = %v, want %v", err, ErrMissingServerURL)
+	}
+}
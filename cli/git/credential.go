@@ -0,0 +1,98 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package git speaks the stdin/stdout protocol of git credential helpers.
+// See https://git-scm.com/docs/git-credential#IOFMT
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// Actions git asks a credential helper to perform.
+const (
+	Get   = "get"
+	Store = "store"
+	Erase = "erase"
+)
+
+var ErrMissingHost = errors.New("credential has no protocol or host")
+
+// Credential holds the attributes exchanged with git.
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// Read parses the key=value lines git writes to the helper up to
+// the first blank line or EOF.
+func Read(r io.Reader) (Credential, error) {
+	var c Credential
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return c, errors.New(fmt.Sprintf("invalid credential line: %q", line))
+		}
+
+		switch kv[0] {
+		case "protocol":
+			c.Protocol = kv[1]
+		case "host":
+			c.Host = kv[1]
+		case "path":
+			c.Path = kv[1]
+		case "username":
+			c.Username = kv[1]
+		case "password":
+			c.Password = kv[1]
+		}
+	}
+
+	return c, scanner.Err()
+}
+
+// Write sends the username and password back to git.
+func Write(w io.Writer, c Credential) error {
+	_, err := fmt.Fprintf(w, "username=%s\npassword=%s\n", c.Username, c.Password)
+	return err
+}
+
+// AccountName is the name under which the credential is kept in pk,
+// e.g https://github.com
+func (c Credential) AccountName() (string, error) {
+	if c.Protocol == "" || c.Host == "" {
+		return "", ErrMissingHost
+	}
+
+	name := fmt.Sprintf("%s://%s", c.Protocol, c.Host)
+	if c.Path != "" {
+		name = fmt.Sprintf("%s/%s", name, strings.TrimPrefix(c.Path, "/"))
+	}
+
+	return name, nil
+}
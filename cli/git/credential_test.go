@@ -0,0 +1,80 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package git
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadCredential(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		user    string
+		wantErr bool
+	}{
+		{
+			name:  "host and username",
+			input: "protocol=https\nhost=github.com\nusername=alice\n\n",
+			want:  "https://github.com",
+			user:  "alice",
+		},
+		{
+			name:  "with path",
+			input: "protocol=https\nhost=example.com\npath=/org/repo.git\n",
+			want:  "https://example.com/org/repo.git",
+		},
+		{
+			name:    "no host",
+			input:   "protocol=https\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Read(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			name, err := c.AccountName()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AccountName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if name != tt.want {
+				t.Errorf("AccountName() = %v, want %v", name, tt.want)
+			}
+			if c.Username != tt.user {
+				t.Errorf("Username = %v, want %v", c.Username, tt.user)
+			}
+		})
+	}
+}
+
+func TestWriteCredential(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Credential{Username: "alice", Password: "s3cr3t"})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := "username=alice\npassword=s3cr3t\n"
+	if buf.String() != want {
+		t.Errorf("Write() = %q, want %q", buf.String(), want)
+	}
+}
@@ -14,55 +14,15 @@
 package json
 
 import (
-	"context"
-	"encoding/json"
 	"github.com/hackaio/pk"
-	"io/ioutil"
-	"os"
 )
 
-var (
-	_ pk.Reader = (*reader)(nil)
-	_ pk.Writer = (*writer)(nil)
-)
-
-type reader struct{}
-
+//NewReader reads a json array of accounts
 func NewReader() pk.Reader {
-	return &reader{}
+	return pk.JsonReaderWriter()
 }
 
-func (r *reader) Read(ctx context.Context, fileName string) (res []pk.Account, err error) {
-	// Open our jsonFile
-	jsonFile, err := os.Open(fileName)
-	// if we os.Open returns an error then handle it
-	if err != nil {
-		return res, err
-	}
-
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer jsonFile.Close()
-
-	// read our opened jsonFile as a byte array.
-	byteValue, _ := ioutil.ReadAll(jsonFile)
-
-	// we unmarshal our byteArray which contains our
-	// jsonFile's content into 'accounts' which we defined above
-	err = json.Unmarshal(byteValue, &res)
-
-	if err != nil {
-		return res, err
-	}
-
-	return res, err
-}
-
-type writer struct{}
-
+//NewWriter writes the accounts as an indented json array
 func NewWriter() pk.Writer {
-	return &writer{}
-}
-
-func (w *writer) Write(ctx context.Context, request pk.FileWriterReq) error {
-	panic("implement me")
+	return pk.JsonReaderWriter()
 }
@@ -0,0 +1,65 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+var errNoSelection = errors.New("nothing selected")
+
+//pickMatch lists matches on w and reads the number of the chosen one from
+//r, an empty answer picks the first. It asks again after an invalid answer
+func pickMatch(r io.Reader, w io.Writer, matches []pk.Match) (pk.Match, error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, m := range matches {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", color.CyanString("%2d", i+1), m.Name, m.UserName, m.Email,
+			strings.Join(m.Tags, ","))
+	}
+	_ = tw.Flush()
+
+	in := bufio.NewReader(r)
+	for {
+		fmt.Fprintf(w, "select an account [1-%d] (enter for 1, q to quit): ", len(matches))
+
+		line, err := in.ReadString('\n')
+		answer := strings.TrimSpace(line)
+
+		switch {
+		case answer == "" && err == nil:
+			return matches[0], nil
+		case answer == "q" || answer == "" && err != nil:
+			return pk.Match{}, errNoSelection
+		}
+
+		n, convErr := strconv.Atoi(answer)
+		if convErr == nil && n >= 1 && n <= len(matches) {
+			return matches[n-1], nil
+		}
+		if err != nil {
+			return pk.Match{}, errNoSelection
+		}
+
+		fmt.Fprintf(w, "%q is not one of the accounts\n", answer)
+	}
+}
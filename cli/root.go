@@ -15,27 +15,63 @@ package cli
 import (
 	"fmt"
 	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/agent"
+	pkgrpc "github.com/hackaio/pk/api/grpc"
 	"github.com/hackaio/pk/bcrypt"
+	"github.com/hackaio/pk/breach"
 	"github.com/hackaio/pk/cli/csv"
 	"github.com/hackaio/pk/cli/json"
 	"github.com/hackaio/pk/cli/keyring"
+	"github.com/hackaio/pk/client"
+	"github.com/hackaio/pk/kdbx"
 	"github.com/hackaio/pk/pg"
 	"github.com/hackaio/pk/pkg/errors"
 	"github.com/hackaio/pk/rsa"
+	"github.com/hackaio/pk/strength"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"io"
 	"log"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hackaio/pk/jwt"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 )
 
+const grpcScheme = "grpc://"
+
+// Commands set keeperAnnotation to keeperNone when they do not use the
+// keeper at all, or to keeperLocal when they need the local database even
+// if an agent or a remote server is available.
+const (
+	keeperAnnotation = "keeper"
+	keeperNone       = "none"
+	keeperLocal      = "local"
+)
+
+// wiper is implemented by EncoderSigners that can erase their keys.
+type wiper interface {
+	Wipe()
+}
+
 var cfgFile string
 var verboseResp bool
 var tokenStr string
 var home string
 
+// keeperLog is where the local keeper logs, pk tui mutes it while it
+// draws the screen
+var keeperLog = &logWriter{w: os.Stderr}
+
+// runner is shared by all commands, its keeper is set by initKeeper
+// once the config has been read
+var runner *commander
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "pk",
@@ -49,82 +85,413 @@ var rootCmd = &cobra.Command{
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		logError(err)
 		os.Exit(1)
 	}
 }
 
+// ExecuteCommand runs the pk command called name with args, it is used by
+// the credential helper binaries that git and docker look up by name.
+func ExecuteCommand(name string, args ...string) {
+	rootCmd.SetArgs(append([]string{name}, args...))
+	Execute()
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.SilenceErrors = true
+	rootCmd.PersistentPreRun = initKeeper
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pk.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verboseResp, "verbose", "v", false, "verbose command output")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", outputPlain, "output format: plain, json, yaml or table")
 	rootCmd.PersistentFlags().StringVarP(&tokenStr, "token", "t", "", "auth token")
+	rootCmd.PersistentFlags().Bool("insecure", false, "allow grpc and http without tls to and from other machines")
 	rootCmd.PersistentFlags().StringP("name", "n", "", "name of the account (e.g github)")
 	rootCmd.PersistentFlags().StringP("username", "u", "", "username of the account (e.g alicebob)")
 	rootCmd.PersistentFlags().StringP("email", "e", "", "email of the account")
 	//rootCmd.PersistentFlags().StringP("password","p","","the account password")
 
+	//insecure can also be set in the config or with PK_INSECURE
+	_ = viper.BindPFlag("insecure", rootCmd.PersistentFlags().Lookup("insecure"))
+
+	runner = &commander{
+		secrets:    keyring.New(),
+		csvReader:  csv.NewReader(),
+		csvWriter:  csv.NewWriter(),
+		jsonReader: json.NewReader(),
+		jsonWriter: json.NewWriter(),
+	}
+
+	commands := MakeAllCommands(runner)
+
+	rootCmd.AddCommand(
+		commands.Init,
+		commands.Register,
+		commands.Update,
+		commands.Delete,
+		commands.Get,
+		commands.Login,
+		commands.List,
+		commands.DB,
+		commands.Add,
+		commands.Serve,
+		commands.Agent,
+		commands.Lock,
+		commands.Git,
+		commands.Docker,
+		commands.Exec,
+		commands.Inject,
+		commands.Generate,
+		commands.Audit,
+		commands.History,
+		commands.Rollback,
+		commands.Trash,
+		commands.Search,
+		commands.TUI,
+		commands.OTP,
+		commands.TwoFA,
+		commands.Export,
+		commands.Import,
+	)
+
+}
+
+// initKeeper builds the PasswordKeeper used by cmd. When a remote server
+// is configured (remote: http://host:port in .pk.yaml or PK_REMOTE env)
+// the commands talk to it instead of the local database.
+// grpc://host:port selects the grpc transport, secured by tls_ca,
+// tls_cert and tls_key, see dialGRPC. Otherwise a running agent is preferred
+// over opening the database and loading the keys again.
+func initKeeper(cmd *cobra.Command, args []string) {
+	switch cmd.Annotations[keeperAnnotation] {
+	case keeperNone:
+		return
+	case keeperLocal:
+		initLocalKeeper()
+		return
+	}
+
+	remote := viper.GetString("remote")
+
+	if strings.HasPrefix(remote, grpcScheme) {
+		keeper, err := dialGRPC(strings.TrimPrefix(remote, grpcScheme))
+		if err != nil {
+			msg := fmt.Sprintf("could not connect to %v: %v\n", remote, err)
+			logError(errors.New(msg))
+			os.Exit(1)
+		}
+		runner.keeper = keeper
+		return
+	}
+
+	if remote != "" {
+		if err := checkRemote(remote); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+		runner.keeper = client.New(remote)
+		return
+	}
+
+	if socket := agentSocket(); agent.Running(socket) {
+		runner.keeper = agent.Dial(socket)
+		return
+	}
+
+	initLocalKeeper()
+}
+
+// checkRemote refuses plain http to other machines, the master password
+// and the secrets would cross the network in clear text.
+func checkRemote(remote string) error {
+	u, err := url.Parse(remote)
+	if err != nil {
+		return errors.Wrap(pk.ErrInvalidArgs, err)
+	}
+
+	switch {
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme != "http":
+		return errors.Wrap(pk.ErrInvalidArgs, errors.New(fmt.Sprintf("remote %v is not http(s):// or grpc://", remote)))
+	case isLoopback(u.Host) || viper.GetBool("insecure"):
+		return nil
+	}
+
+	return errors.Wrap(pk.ErrInvalidArgs, errors.New(fmt.Sprintf("refusing plain http to %v, use https:// or pass --insecure", u.Host)))
+}
+
+// logWriter is an io.Writer whose destination can change after the
+// loggers writing to it are made.
+type logWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func (l *logWriter) set(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w = w
+}
+
+// initLocalKeeper opens the database and loads the RSA keys.
+func initLocalKeeper() {
 	pgDatabase, err := pg.Connect()
 	if err != nil {
-		msg := fmt.Sprintf("could not connect to postgres database: %v\n",err)
+		msg := fmt.Sprintf("could not connect to postgres database: %v\n", err)
 		err1 := errors.New(msg)
 		logError(err1)
 		os.Exit(1)
 	}
 
-	store := pg.NewStore(pgDatabase)
 	hasher := bcrypt.New()
-	tokenizer := jwt.NewTokenizer("pk")
-	/*logger := log.New(os.Stdout,"pk",0)
-	logMiddleware := pk.LoggingMiddleware(logger)*/
 
 	homeDir, err := homedir.Dir()
 	es, err := rsa.NewEncoderSigner(homeDir)
 	if err != nil {
-		msg := fmt.Sprintf("could not find credentials : %v\n",err)
+		msg := fmt.Sprintf("could not find credentials : %v\n", err)
 		err1 := errors.New(msg)
 		logError(err1)
 	}
 
+	secret, err := jwt.LoadSecret(homeDir)
+	if err != nil {
+		logError(errors.New(fmt.Sprintf("could not load the token signing secret: %v", err)))
+		os.Exit(1)
+	}
+	tokenizer := jwt.NewTokenizer(secret)
+
+	store, err := loadKDBXStore(es, pg.NewStore(pgDatabase))
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
 	keeper := pk.NewPasswordKeeper(hasher, store, tokenizer, es)
 
-	logg := log.New(os.Stdout, "pk :: ", 1)
+	logg := log.New(keeperLog, "pk :: ", 1)
 
 	mdw := pk.LoggingMiddleware(logg)
 
-	keeper = pk.AddMiddlewares(keeper, []pk.Middleware{mdw})
+	policy, err := loadPolicy()
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
+	keep, maxAge, err := loadHistoryLimits()
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
+	middlewares := []pk.Middleware{pk.HistoryMiddleware(keep, maxAge, log.New(keeperLog, "pk :: ", 0)), pk.PolicyMiddleware(policy)}
+
+	checker, reject, err := loadBreachChecker()
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+	if checker != nil {
+		middlewares = append(middlewares, pk.BreachMiddleware(checker, reject, log.New(keeperLog, "pk :: ", 0)))
+	}
+
+	runner.keeper = pk.AddMiddlewares(keeper, append(middlewares, mdw))
+	runner.wipe = func() {
+		if w, ok := es.(wiper); ok {
+			w.Wipe()
+		}
+	}
+}
 
-	credentials := keyring.New()
+// loadPolicy reads the password policy from the policy section of the
+// config, unset keys keep the strength.DefaultPolicy values.
+//
+//	policy:
+//	  min_length: 12
+//	  min_score: 3
+//	  banned: ["(?i)acme"]
+//	  allow_personal: false
+func loadPolicy() (strength.Policy, error) {
+	policy := strength.DefaultPolicy
 
-	csvReader := csv.NewReader()
+	if viper.IsSet("policy.min_length") {
+		policy.MinLength = viper.GetInt("policy.min_length")
+	}
+	if viper.IsSet("policy.min_score") {
+		policy.MinScore = viper.GetInt("policy.min_score")
+	}
+	policy.AllowPersonal = viper.GetBool("policy.allow_personal")
 
-	csvWriter := csv.NewWriter()
+	banned, err := strength.CompileBanned(viper.GetStringSlice("policy.banned"))
+	if err != nil {
+		return strength.Policy{}, err
+	}
+	policy.Banned = banned
 
-	jsonReader := json.NewReader()
+	return policy, nil
+}
 
-	jsonWriter := json.NewWriter()
+// loadHistoryLimits reads how much password history is kept, by default
+// the last 10 versions of every account regardless of their age.
+//
+//	history:
+//	  keep: 5
+//	  max_age: 365d
+func loadHistoryLimits() (int, time.Duration, error) {
+	keep := defaultHistoryKeep
+	if viper.IsSet("history.keep") {
+		keep = viper.GetInt("history.keep")
+	}
 
-	comm := NewCommandsRunner(keeper, credentials, csvReader, csvWriter, jsonReader, jsonWriter)
+	var maxAge time.Duration
+	if age := viper.GetString("history.max_age"); age != "" {
+		var err error
+		if maxAge, err = parseAge(age); err != nil {
+			return 0, 0, err
+		}
+	}
 
-	commands := MakeAllCommands(comm)
+	return keep, maxAge, nil
+}
 
-	rootCmd.AddCommand(
-		commands.Init,
-		commands.Register,
-		commands.Update,
-		commands.Delete,
-		commands.Get,
-		commands.Login,
-		commands.List,
-		commands.DB,
-		commands.Add,
-	)
+// loadBreachChecker opens the Pwned Passwords file set as hibp.file,
+// hibp.action tells whether breached passwords are refused or only
+// warned about.
+//
+//	hibp:
+//	  file: ~/pk/pwned-passwords-sha1-ordered-by-hash-v7.txt
+//	  action: reject # or warn
+func loadBreachChecker() (breach.Checker, bool, error) {
+	path := viper.GetString("hibp.file")
+	if path == "" {
+		return nil, false, nil
+	}
+
+	var reject bool
+	switch action := viper.GetString("hibp.action"); action {
+	case "", "warn":
+	case "reject":
+		reject = true
+	default:
+		return nil, false, errors.New(fmt.Sprintf("hibp.action should be warn or reject, got %q", action))
+	}
+
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	checker, err := breach.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return checker, reject, nil
+}
+
+// loadKDBXStore serves the accounts of the KeePass database set as
+// kdbx.file instead of those of postgres, which still keeps the owner of
+// the keeper. The database is read only, its password is read from
+// PK_KDBX_PASSWORD or prompted for.
+//
+//	kdbx:
+//	  file: ~/team.kdbx
+//	  key_file: ~/team.keyx # optional
+func loadKDBXStore(es pk.EncoderSigner, owners pk.PasswordStore) (pk.PasswordStore, error) {
+	path := viper.GetString("kdbx.file")
+	if path == "" {
+		return owners, nil
+	}
+	if es == nil {
+		return nil, errors.New("kdbx.file needs the credentials of pk, run pk init first")
+	}
+
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	keyFile, err := homedir.Expand(viper.GetString("kdbx.key_file"))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kdbxKey(keyFile, false)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db, err := kdbx.Open(f, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return kdbx.NewStore(db, es, owners)
+}
+
+// agentSocket returns agent_socket from the config or the default
+// socket in the pk home dir.
+func agentSocket() string {
+	if socket := viper.GetString("agent_socket"); socket != "" {
+		return socket
+	}
 
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
+	return agent.SocketPath(homeDir)
+}
+
+// dialGRPC connects to the grpc server at addr over tls, verified with the
+// system roots unless tls_ca is set. Plaintext is only used for loopback
+// servers without tls settings, or when insecure is set.
+func dialGRPC(addr string) (pk.PasswordKeeper, error) {
+	caFile := viper.GetString("tls_ca")
+	certFile := viper.GetString("tls_cert")
+	keyFile := viper.GetString("tls_key")
+
+	opt := grpc.WithInsecure()
+
+	plain := viper.GetBool("insecure") || isLoopback(addr) && caFile == "" && certFile == ""
+	if !plain {
+		creds, err := pkgrpc.ClientCredentials(caFile, certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		opt = grpc.WithTransportCredentials(creds)
+	}
+
+	conn, err := grpc.Dial(addr, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgrpc.NewClient(conn), nil
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	if err := initOutput(); err != nil {
+		logError(err)
+		os.Exit(1)
+	}
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -132,7 +499,7 @@ func initConfig() {
 		// Find home directory.
 		home, err := homedir.Dir()
 		if err != nil {
-			fmt.Println(err)
+			logError(err)
 			os.Exit(1)
 		}
 
@@ -141,10 +508,11 @@ func initConfig() {
 		viper.SetConfigName(".pk")
 	}
 
+	viper.SetEnvPrefix(pk.AppName)
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	if err := viper.ReadInConfig(); err == nil && verboseResp {
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
@@ -16,19 +16,163 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
 	"github.com/fatih/color"
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/audit"
+	"github.com/hackaio/pk/cli/csv"
+	"github.com/hackaio/pk/importer"
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
 	prettyjson "github.com/hokaccha/go-prettyjson"
+	"github.com/mattn/go-isatty"
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats selected with --output. plain is meant for humans, the
+// others print the same fields for every command so they can be parsed.
+const (
+	outputPlain = "plain"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputTable = "table"
 )
 
+var errInvalidOutput = errors.New("output should be one of plain, json, yaml or table")
+
+// outputFormat is set by the --output flag
+var outputFormat = outputPlain
+
+// errColor tells whether errors written to stderr may be colored, stdout
+// is taken care of by color.NoColor
+var errColor = isatty.IsTerminal(os.Stderr.Fd())
+
+// initOutput validates --output and turns colors off when they would end
+// up in a file or a pipe.
+func initOutput() error {
+	switch outputFormat {
+	case outputPlain, outputJSON, outputYAML, outputTable:
+	default:
+		return errInvalidOutput
+	}
+
+	if outputFormat != outputPlain || os.Getenv("NO_COLOR") != "" {
+		color.NoColor = true
+		errColor = false
+	}
+
+	return nil
+}
+
+// logResult prints v to stdout in the selected format.
+func logResult(v interface{}) {
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+
+	case outputYAML:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+		_, _ = os.Stdout.Write(b)
+
+	default:
+		logTable(os.Stdout, v)
+	}
+}
+
+// logTable lays accounts and key value results out as a table.
+func logTable(w io.Writer, v interface{}) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch r := v.(type) {
+	case []pk.Account:
+		fmt.Fprintln(tw, "NAME\tUSERNAME\tEMAIL\tPASSWORD\tCREATED\tFOLDER\tTAGS")
+		for _, a := range r {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.Name, a.UserName, a.Email, a.Password, a.Created,
+				a.Folder, strings.Join(a.Tags, ","))
+		}
+
+	case pk.Account:
+		logTable(tw, []pk.Account{r})
+
+	case []pk.Match:
+		fmt.Fprintln(tw, "NAME\tUSERNAME\tEMAIL\tTAGS\tFIELD\tSCORE")
+		for _, m := range r {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", m.Name, m.UserName, m.Email,
+				strings.Join(m.Tags, ","), m.Field, m.Score)
+		}
+
+	case []pk.Trashed:
+		fmt.Fprintln(tw, "NAME\tUSERNAME\tEMAIL\tCREATED\tDELETED")
+		for _, a := range r {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Name, a.UserName, a.Email, a.Created, a.Deleted)
+		}
+
+	case pk.ImportReport:
+		fmt.Fprintln(tw, "ADDED\tREPLACED\tRENAMED\tSKIPPED")
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\n", r.Added, r.Replaced, r.Renamed, r.Skipped)
+
+	case importer.Summary:
+		fmt.Fprintln(tw, "SOURCE\tIMPORTED\tSKIPPED\tFAILED")
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", r.Source, r.Imported, r.Skipped, r.Failed)
+
+		if len(r.Records) > 0 {
+			fmt.Fprintln(tw)
+			fmt.Fprintln(tw, "RECORD\tNAME\tSTATUS\tREASON")
+			for _, rec := range r.Records {
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", rec.Record, rec.Name, rec.Status, rec.Reason)
+			}
+		}
+
+	case map[string]string:
+		keys := make([]string, 0, len(r))
+		for k := range r {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(tw, "KEY\tVALUE")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", k, r[k])
+		}
+
+	default:
+		b, _ := json.MarshalIndent(v, "", "  ")
+		fmt.Fprintln(tw, string(b))
+	}
+}
+
 func logJSON(iList ...interface{}) {
 	for _, i := range iList {
+		if outputFormat != outputPlain {
+			logResult(i)
+			continue
+		}
+
 		m, err := json.Marshal(i)
 		if err != nil {
 			logError(err)
 			return
 		}
 
-		pj, err := prettyjson.Format(m)
+		f := prettyjson.NewFormatter()
+		f.DisabledColor = color.NoColor
+		pj, err := f.Format(m)
 		if err != nil {
 			logError(err)
 			return
@@ -38,26 +182,237 @@ func logJSON(iList ...interface{}) {
 	}
 }
 
+func logAccount(account pk.Account) {
+	if outputFormat != outputPlain {
+		logResult(account)
+		return
+	}
+
+	details := fmt.Sprintf("\nname: %s\nusername: %s\nemail: %s\npassword: %s\n",
+		account.Name, account.UserName, account.Email, account.Password)
+
+	for _, url := range account.URLs {
+		details += fmt.Sprintf("url: %s\n", url)
+	}
+	if account.Folder != "" {
+		details += fmt.Sprintf("folder: %s\n", account.Folder)
+	}
+	if len(account.Tags) > 0 {
+		details += fmt.Sprintf("tags: %s\n", strings.Join(account.Tags, ", "))
+	}
+	for _, f := range account.Fields {
+		details += fmt.Sprintf("%s: %s\n", f.Name, f.Value)
+	}
+	if account.OTP != "" {
+		details += fmt.Sprintf("otp: pk otp -n %s -u %s\n", account.Name, account.UserName)
+	}
+	if account.Notes != "" {
+		details += fmt.Sprintf("notes:\n%s\n", account.Notes)
+	}
+
+	fmt.Print(color.YellowString("%s\n", details))
+}
+
+// logReport prints an audit report, the plain and table formats lay every
+// kind of issue out in its own table.
+func logReport(report audit.Report) {
+	if outputFormat == outputJSON || outputFormat == outputYAML {
+		logResult(report)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "\n%v issues in %v accounts\n", report.Issues(), report.Total)
+
+	if len(report.Weak) > 0 {
+		fmt.Fprintln(tw, color.YellowString("\nweak"))
+		fmt.Fprintln(tw, "NAME\tUSERNAME\tSCORE\tENTROPY")
+		for _, w := range report.Weak {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%.0f bits\n", w.Name, w.UserName, w.Score, w.Entropy)
+		}
+	}
+
+	if len(report.Reused) > 0 {
+		fmt.Fprintln(tw, color.YellowString("\nreused"))
+		fmt.Fprintln(tw, "GROUP\tNAME\tUSERNAME")
+		for i, group := range report.Reused {
+			for _, a := range group.Accounts {
+				fmt.Fprintf(tw, "%d\t%s\t%s\n", i+1, a.Name, a.UserName)
+			}
+		}
+	}
+
+	if len(report.Breached) > 0 {
+		fmt.Fprintln(tw, color.YellowString("\nbreached"))
+		fmt.Fprintln(tw, "NAME\tUSERNAME\tSEEN")
+		for _, b := range report.Breached {
+			fmt.Fprintf(tw, "%s\t%s\t%d times\n", b.Name, b.UserName, b.Count)
+		}
+	}
+
+	if len(report.Old) > 0 {
+		fmt.Fprintln(tw, color.YellowString("\nold"))
+		fmt.Fprintln(tw, "NAME\tUSERNAME\tCREATED\tAGE")
+		for _, o := range report.Old {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d days\n", o.Name, o.UserName, o.Created, o.AgeDays)
+		}
+	}
+
+	fmt.Fprintln(tw)
+}
+
+// historyEntry is how a version is printed, the password is left out
+// unless asked for.
+type historyEntry struct {
+	Version  int    `json:"version" yaml:"version"`
+	Email    string `json:"email" yaml:"email"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	Created  string `json:"created" yaml:"created"`
+	Replaced string `json:"replaced" yaml:"replaced"`
+}
+
+func logHistory(versions []pk.Version, show bool) {
+	entries := make([]historyEntry, 0, len(versions))
+	for _, v := range versions {
+		e := historyEntry{
+			Version:  v.Version,
+			Email:    v.Account.Email,
+			Created:  v.Account.Created,
+			Replaced: v.Replaced,
+		}
+		if show {
+			e.Password = v.Account.Password
+		}
+		entries = append(entries, e)
+	}
+
+	if outputFormat == outputJSON || outputFormat == outputYAML {
+		logResult(entries)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	header := "VERSION\tEMAIL\tCREATED\tREPLACED"
+	if show {
+		header += "\tPASSWORD"
+	}
+	fmt.Fprintln(tw, header)
+
+	for _, e := range entries {
+		line := fmt.Sprintf("%d\t%s\t%s\t%s", e.Version, e.Email, e.Created, e.Replaced)
+		if show {
+			line += "\t" + e.Password
+		}
+		fmt.Fprintln(tw, line)
+	}
+}
+
 func logUsage(u string) {
-	fmt.Printf(color.YellowString("\nusage: %s\n\n"), u)
+	if outputFormat != outputPlain {
+		logErrorTo(os.Stderr, errors.New(fmt.Sprintf("usage: %s", u)))
+		return
+	}
+
+	msg := fmt.Sprintf("\nusage: %s\n\n", u)
+	if errColor {
+		msg = color.New(color.FgYellow).Sprint(msg)
+	}
+	fmt.Fprint(os.Stderr, msg)
+}
+
+// isLoopback tells whether addr only listens on or dials this machine,
+// a missing host listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 func logMessage(key, msg string) {
+	if outputFormat != outputPlain {
+		logResult(map[string]string{key: msg})
+		return
+	}
+
 	fmt.Printf(color.YellowString("\n%s: %s\n\n"), key, msg)
 }
 
+// logError writes err to stderr, callers exit with a non zero code
 func logError(err error) {
-	boldRed := color.New(color.FgRed, color.Bold)
-	boldRed.Print("\nerror: ")
+	logErrorTo(os.Stderr, err)
+}
+
+func logErrorTo(w io.Writer, err error) {
+	msg := strings.TrimSpace(err.Error())
+
+	res := map[string]interface{}{"error": msg}
+	if verr, ok := err.(*strength.ValidationError); ok {
+		res["failures"] = verr.Failures
+	}
+	if verr, ok := err.(*csv.ValidationError); ok {
+		res["rows"] = verr.Rows
+	}
 
-	fmt.Printf("%s\n\n", color.RedString(err.Error()))
+	switch outputFormat {
+	case outputJSON:
+		b, _ := json.Marshal(res)
+		fmt.Fprintln(w, string(b))
+
+	case outputYAML:
+		b, _ := yaml.Marshal(res)
+		_, _ = w.Write(b)
+
+	default:
+		if !errColor {
+			fmt.Fprintf(w, "\nerror: %s\n\n", msg)
+			return
+		}
+
+		boldRed := color.New(color.FgRed, color.Bold)
+		red := color.New(color.FgRed)
+		fmt.Fprintf(w, "\n%s%s\n\n", boldRed.Sprint("error: "), red.Sprint(msg))
+	}
+}
+
+//logRecoveryCodes prints the codes issued when two factor authentication
+//is turned on, they are not shown again
+func logRecoveryCodes(codes []string) {
+	if outputFormat != outputPlain {
+		logResult(map[string][]string{"recovery": codes})
+		return
+	}
+
+	fmt.Print(color.YellowString("\nrecovery codes, each logs in once without the app. keep them safe:\n\n"))
+	for _, code := range codes {
+		fmt.Printf("  %s\n", code)
+	}
+	fmt.Println()
 }
 
 func logOK() {
+	if outputFormat != outputPlain {
+		logResult(map[string]string{"status": "ok"})
+		return
+	}
+
 	fmt.Printf("\n%s\n\n", color.BlueString("ok"))
 }
 
 func logCreated(e string) {
+	if outputFormat != outputPlain {
+		logResult(map[string]string{"created": e})
+		return
+	}
 
 	fmt.Printf(color.BlueString("\ncreated: %s\n\n"), e)
 
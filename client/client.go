@@ -0,0 +1,385 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/api"
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
+)
+
+const (
+	contentType = "application/json"
+	timeout     = 30 * time.Second
+)
+
+// knownErrors are the pk errors that survive the trip over the wire.
+var knownErrors = []error{
+	pk.ErrPermissionDenied,
+	pk.ErrInternalError,
+	pk.ErrCriticalFailure,
+	pk.ErrNotFound,
+	pk.ErrVersionNotFound,
+	pk.ErrInvalidArgs,
+	pk.ErrCodeRequired,
+	strength.ErrPolicyViolation,
+}
+
+type remoteKeeper struct {
+	url    string
+	client *http.Client
+}
+
+var _ pk.PasswordKeeper = (*remoteKeeper)(nil)
+
+// New returns a PasswordKeeper that talks to the pk server listening
+// at url e.g http://localhost:8080
+func New(url string) pk.PasswordKeeper {
+	return NewWithClient(url, &http.Client{Timeout: timeout})
+}
+
+// NewWithClient is like New but lets the caller supply the http.Client
+// used for every request.
+func NewWithClient(url string, client *http.Client) pk.PasswordKeeper {
+	return &remoteKeeper{
+		url:    strings.TrimSuffix(url, "/"),
+		client: client,
+	}
+}
+
+func (r remoteKeeper) Register(ctx context.Context, username, email, password string) (err error) {
+	req := pk.RegisterRequest{
+		Username: username,
+		Email:    email,
+		Password: password,
+	}
+
+	var res pk.RegisterResponse
+	return r.call(ctx, api.RegisterPath, req, &res)
+}
+
+func (r remoteKeeper) Login(ctx context.Context, username, password, code string) (token string, err error) {
+	req := pk.LoginRequest{
+		Username: username,
+		Password: password,
+		Code:     code,
+	}
+
+	var res pk.LoginResponse
+	if err = r.call(ctx, api.LoginPath, req, &res); err != nil {
+		return "", err
+	}
+
+	return res.Token, nil
+}
+
+func (r remoteKeeper) Add(ctx context.Context, token string, account pk.Account) (err error) {
+	req := pk.AddRequest{
+		Token:   token,
+		Account: account,
+	}
+
+	var res pk.AddResponse
+	return r.call(ctx, api.AddPath, req, &res)
+}
+
+func (r remoteKeeper) Get(ctx context.Context, token, name, username string) (account pk.Account, err error) {
+	req := pk.GetRequest{
+		Token:    token,
+		Name:     name,
+		Username: username,
+	}
+
+	var res pk.GetResponse
+	if err = r.call(ctx, api.GetPath, req, &res); err != nil {
+		return pk.Account{}, err
+	}
+
+	return res.Account, nil
+}
+
+func (r remoteKeeper) Delete(ctx context.Context, token, name, username string) (err error) {
+	req := pk.DeleteRequest{
+		Token:    token,
+		Name:     name,
+		Username: username,
+	}
+
+	var res pk.DeleteResponse
+	return r.call(ctx, api.DeletePath, req, &res)
+}
+
+func (r remoteKeeper) List(ctx context.Context, token string, query pk.Query) (accounts []pk.Account, next string, err error) {
+	req := pk.ListRequest{
+		Token: token,
+		Query: query,
+	}
+
+	var res pk.ListResponse
+	if err = r.call(ctx, api.ListPath, req, &res); err != nil {
+		return nil, "", err
+	}
+
+	return res.Accounts, res.Next, nil
+}
+
+func (r remoteKeeper) Update(ctx context.Context, token, name, username string, account pk.Account) (acc pk.Account, err error) {
+	req := pk.UpdateRequest{
+		Token:    token,
+		Name:     name,
+		Username: username,
+		Account:  account,
+	}
+
+	var res pk.UpdateResponse
+	if err = r.call(ctx, api.UpdatePath, req, &res); err != nil {
+		return pk.Account{}, err
+	}
+
+	return res.Acc, nil
+}
+
+func (r remoteKeeper) AddAll(ctx context.Context, token string, accounts []pk.Account) (err error) {
+	req := pk.AddAllRequest{
+		Token:    token,
+		Accounts: accounts,
+	}
+
+	var res pk.AddAllResponse
+	return r.call(ctx, api.AddAllPath, req, &res)
+}
+
+func (r remoteKeeper) DeleteAll(ctx context.Context, token string, args map[string]interface{}) (err error) {
+	req := pk.DeleteAllRequest{
+		Token: token,
+		Args:  args,
+	}
+
+	var res pk.DeleteAllResponse
+	return r.call(ctx, api.DeleteAllPath, req, &res)
+}
+
+func (r remoteKeeper) History(ctx context.Context, token, name, username string) (versions []pk.Version, err error) {
+	req := pk.HistoryRequest{
+		Token:    token,
+		Name:     name,
+		Username: username,
+	}
+
+	var res pk.HistoryResponse
+	if err = r.call(ctx, api.HistoryPath, req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Versions, nil
+}
+
+func (r remoteKeeper) Rollback(ctx context.Context, token, name, username string, version int) (account pk.Account, err error) {
+	req := pk.RollbackRequest{
+		Token:    token,
+		Name:     name,
+		Username: username,
+		Version:  version,
+	}
+
+	var res pk.RollbackResponse
+	if err = r.call(ctx, api.RollbackPath, req, &res); err != nil {
+		return pk.Account{}, err
+	}
+
+	return res.Account, nil
+}
+
+func (r remoteKeeper) PruneHistory(ctx context.Context, token, name, username string, keep int, maxAge time.Duration) (removed int, err error) {
+	req := pk.PruneHistoryRequest{
+		Token:    token,
+		Name:     name,
+		Username: username,
+		Keep:     keep,
+		MaxAge:   maxAge,
+	}
+
+	var res pk.PruneHistoryResponse
+	if err = r.call(ctx, api.PrunePath, req, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Removed, nil
+}
+
+func (r remoteKeeper) Trash(ctx context.Context, token string) (accounts []pk.Trashed, err error) {
+	req := pk.TrashRequest{Token: token}
+
+	var res pk.TrashResponse
+	if err = r.call(ctx, api.TrashPath, req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Accounts, nil
+}
+
+func (r remoteKeeper) Restore(ctx context.Context, token, name, username string) (err error) {
+	req := pk.RestoreRequest{
+		Token:    token,
+		Name:     name,
+		Username: username,
+	}
+
+	var res pk.RestoreResponse
+	return r.call(ctx, api.RestorePath, req, &res)
+}
+
+func (r remoteKeeper) Purge(ctx context.Context, token string, olderThan time.Duration) (purged int, err error) {
+	req := pk.PurgeRequest{
+		Token:     token,
+		OlderThan: olderThan,
+	}
+
+	var res pk.PurgeResponse
+	if err = r.call(ctx, api.PurgePath, req, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Purged, nil
+}
+
+func (r remoteKeeper) Search(ctx context.Context, token, text string, limit int) (matches []pk.Match, err error) {
+	req := pk.SearchRequest{
+		Token: token,
+		Text:  text,
+		Limit: limit,
+	}
+
+	var res pk.SearchResponse
+	if err = r.call(ctx, api.SearchPath, req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Matches, nil
+}
+
+func (r remoteKeeper) EnableTwoFactor(ctx context.Context, token, secret, code string) (recovery []string, err error) {
+	req := pk.EnableTwoFactorRequest{
+		Token:  token,
+		Secret: secret,
+		Code:   code,
+	}
+
+	var res pk.EnableTwoFactorResponse
+	if err = r.call(ctx, api.Enable2FAPath, req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Recovery, nil
+}
+
+func (r remoteKeeper) DisableTwoFactor(ctx context.Context, token, code string) (err error) {
+	req := pk.DisableTwoFactorRequest{
+		Token: token,
+		Code:  code,
+	}
+
+	return r.call(ctx, api.Disable2FAPath, req, &pk.DisableTwoFactorResponse{})
+}
+
+func (r remoteKeeper) Export(ctx context.Context, token string) (entries []pk.Entry, err error) {
+	req := pk.ExportRequest{
+		Token: token,
+	}
+
+	var res pk.ExportResponse
+	if err = r.call(ctx, api.ExportPath, req, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Entries, nil
+}
+
+func (r remoteKeeper) Import(ctx context.Context, token string, entries []pk.Entry, conflict pk.Conflict) (report pk.ImportReport, err error) {
+	req := pk.ImportRequest{
+		Token:    token,
+		Entries:  entries,
+		Conflict: conflict,
+	}
+
+	var res pk.ImportResponse
+	if err = r.call(ctx, api.ImportPath, req, &res); err != nil {
+		return pk.ImportReport{}, err
+	}
+
+	return res.Report, nil
+}
+
+// call posts req to path and decodes the body into res. Error
+// responses are turned back into pk errors.
+func (r remoteKeeper) call(ctx context.Context, path string, req, res interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, r.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", contentType)
+
+	httpRes, err := r.client.Do(httpReq)
+	if err != nil {
+		errMsg := errors.New(fmt.Sprintf("could not reach pk server at %v", r.url))
+		return errors.Wrap(errMsg, err)
+	}
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		var errRes api.ErrorResponse
+		if err := json.NewDecoder(httpRes.Body).Decode(&errRes); err != nil || errRes.Error == "" {
+			errRes.Error = httpRes.Status
+		}
+		if len(errRes.Failures) > 0 {
+			return &strength.ValidationError{Failures: errRes.Failures}
+		}
+		return decodeError(httpRes.StatusCode, errRes.Error)
+	}
+
+	return json.NewDecoder(httpRes.Body).Decode(res)
+}
+
+func decodeError(status int, msg string) error {
+	for _, known := range knownErrors {
+		prefix := known.Error()
+		if msg == prefix {
+			return known
+		}
+		if strings.HasPrefix(msg, prefix+" : ") {
+			return errors.Wrap(known, errors.New(strings.TrimPrefix(msg, prefix+" : ")))
+		}
+	}
+
+	if status == http.StatusForbidden {
+		return errors.Wrap(pk.ErrPermissionDenied, errors.New(msg))
+	}
+
+	return errors.New(msg)
+}
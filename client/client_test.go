@@ -0,0 +1,115 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/api"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+const validToken = "valid"
+
+// keeperMock answers Get and List from memory and denies anything
+// with a token other than validToken.
+type keeperMock struct {
+	pk.PasswordKeeper
+	accounts []pk.Account
+}
+
+func (k keeperMock) Get(ctx context.Context, token, name, username string) (pk.Account, error) {
+	if token != validToken {
+		return pk.Account{}, errors.Wrap(pk.ErrPermissionDenied, errors.New("token expired"))
+	}
+
+	for _, a := range k.accounts {
+		if a.Name == name && a.UserName == username {
+			return a, nil
+		}
+	}
+
+	return pk.Account{}, errors.Wrap(pk.ErrInternalError, errors.New("no rows"))
+}
+
+func (k keeperMock) List(ctx context.Context, token string, query pk.Query) ([]pk.Account, string, error) {
+	if token != validToken {
+		return nil, "", pk.ErrPermissionDenied
+	}
+
+	return k.accounts, "", nil
+}
+
+func (k keeperMock) Rollback(ctx context.Context, token, name, username string, version int) (pk.Account, error) {
+	if version != 1 {
+		return pk.Account{}, pk.ErrVersionNotFound
+	}
+
+	return k.Get(ctx, token, name, username)
+}
+
+func TestRemoteKeeper(t *testing.T) {
+	github := pk.Account{Name: "github", UserName: "alice", Email: "alice@example.com", Password: "s3cr3t"}
+	keeper := keeperMock{accounts: []pk.Account{github}}
+
+	server := httptest.NewServer(api.MakeHandler(keeper))
+	defer server.Close()
+
+	remote := New(server.URL)
+	ctx := context.Background()
+
+	got, err := remote.Get(ctx, validToken, "github", "alice")
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, github) {
+		t.Errorf("Get() = %v, want %v", got, github)
+	}
+
+	_, err = remote.Get(ctx, "expired", "github", "alice")
+	if !errors.Contains(err, pk.ErrPermissionDenied) {
+		t.Errorf("Get() error = %v, want %v", err, pk.ErrPermissionDenied)
+	}
+
+	_, err = remote.Get(ctx, validToken, "gitlab", "alice")
+	if !errors.Contains(err, pk.ErrInternalError) {
+		t.Errorf("Get() error = %v, want %v", err, pk.ErrInternalError)
+	}
+
+	accounts, _, err := remote.List(ctx, validToken, pk.Query{})
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Errorf("List() returned %v accounts, want 1", len(accounts))
+	}
+
+	_, _, err = remote.List(ctx, "expired", pk.Query{})
+	if !errors.Contains(err, pk.ErrPermissionDenied) {
+		t.Errorf("List() error = %v, want %v", err, pk.ErrPermissionDenied)
+	}
+
+	if got, err := remote.Rollback(ctx, validToken, "github", "alice", 1); err != nil || !reflect.DeepEqual(got, github) {
+		t.Errorf("Rollback() = %v, %v, want %v", got, err, github)
+	}
+
+	_, err = remote.Rollback(ctx, validToken, "github", "alice", 7)
+	if !errors.Contains(err, pk.ErrVersionNotFound) {
+		t.Errorf("Rollback() error = %v, want %v", err, pk.ErrVersionNotFound)
+	}
+}
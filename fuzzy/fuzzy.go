@@ -0,0 +1,144 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fuzzy scores how well a typed pattern matches a piece of text the
+// way fuzzy finders do. The pattern matches when its characters appear in
+// the text in order, matches at word starts and runs of consecutive
+// characters score higher, so "gh" finds GitHub and "ghe" finds GitHub
+// Enterprise first.
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 10
+	bonusFirst       = 8
+	bonusConsecutive = 6
+	penaltyGap       = 1
+	maxGapPenalty    = 8
+
+	// bonusPrefix and bonusExact reward a pattern that is the start of or
+	// the whole text.
+	bonusPrefix = 24
+	bonusExact  = 48
+)
+
+// Score ranks how well pattern matches text, case is ignored. ok is false
+// when the characters of pattern do not all appear in text in order.
+func Score(pattern, text string) (score int, ok bool) {
+	p := toLower([]rune(pattern))
+	t := []rune(text)
+	lower := toLower(t)
+
+	if len(p) == 0 {
+		return 0, true
+	}
+	if len(p) > len(t) {
+		return 0, false
+	}
+
+	best, found := 0, false
+
+	// the greedy walk from every occurrence of the first character keeps
+	// the matches that start at a word over earlier ones that do not
+	for start := range lower {
+		if lower[start] != p[0] {
+			continue
+		}
+
+		s, ok := walk(p, t, lower, start)
+		if ok && (!found || s > best) {
+			best, found = s, true
+		}
+	}
+
+	if !found {
+		return 0, false
+	}
+
+	l := string(lower)
+	switch pl := string(p); {
+	case l == pl:
+		best += bonusExact
+	case strings.HasPrefix(l, pl):
+		best += bonusPrefix
+	}
+
+	return best, true
+}
+
+func walk(p, t, lower []rune, start int) (int, bool) {
+	score := 0
+	last := -1
+
+	i := start
+	for j := 0; j < len(p); j++ {
+		for i < len(lower) && lower[i] != p[j] {
+			i++
+		}
+		if i == len(lower) {
+			return 0, false
+		}
+
+		score += scoreMatch
+		if i == 0 {
+			score += bonusFirst
+		}
+		if boundary(t, i) {
+			score += bonusBoundary
+		}
+		if last >= 0 {
+			if i == last+1 {
+				score += bonusConsecutive
+			} else if gap := (i - last - 1) * penaltyGap; gap < maxGapPenalty {
+				score -= gap
+			} else {
+				score -= maxGapPenalty
+			}
+		}
+
+		last = i
+		i++
+	}
+
+	return score, true
+}
+
+// boundary reports whether t[i] starts a word: it follows a separator or
+// is an upper case letter after a lower case one.
+func boundary(t []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	prev, cur := t[i-1], t[i]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
+
+// toLower lowers every rune on its own so indexes into the result and the
+// original match.
+func toLower(r []rune) []rune {
+	lower := make([]rune, len(r))
+	for i, c := range r {
+		lower[i] = unicode.ToLower(c)
+	}
+	return lower
+}
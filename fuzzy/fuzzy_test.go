@@ -0,0 +1,53 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fuzzy
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	for _, text := range []string{"GitHub", "github", "GitHub Enterprise", "gitlab-hub"} {
+		if _, ok := Score("gh", text); !ok {
+			t.Errorf("Score(gh, %q) did not match", text)
+		}
+	}
+
+	for _, text := range []string{"hg", "gitlab", ""} {
+		if _, ok := Score("gh", text); ok {
+			t.Errorf("Score(gh, %q) matched", text)
+		}
+	}
+
+	// every pair is ordered best first
+	ranked := []struct {
+		pattern      string
+		better, worse string
+	}{
+		{"github", "github", "GitHub Enterprise"},
+		{"gh", "GitHub", "gitlab-hub"},
+		{"ghe", "GitHub Enterprise", "github"},
+		{"ent", "GitHub Enterprise", "element"},
+		{"mail", "mail.google.com", "gmail.com"},
+	}
+
+	for _, r := range ranked {
+		better, ok1 := Score(r.pattern, r.better)
+		worse, ok2 := Score(r.pattern, r.worse)
+		if ok1 && !ok2 {
+			continue
+		}
+		if !ok1 || better <= worse {
+			t.Errorf("Score(%q): %q = %v, %q = %v, want the first higher", r.pattern, r.better, better, r.worse, worse)
+		}
+	}
+}
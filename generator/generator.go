@@ -0,0 +1,218 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package generator creates random passwords and diceware passphrases.
+// Every random choice is drawn from crypto/rand.
+package generator
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+
+	// Ambiguous characters are easily confused when read or typed.
+	Ambiguous = "Il1O0o|"
+
+	// DefaultProfile is used when no profile is named.
+	DefaultProfile = "strong"
+)
+
+var (
+	ErrInvalidPolicy  = errors.New("invalid generator policy")
+	ErrUnknownProfile = errors.New("unknown generator profile")
+)
+
+// Policy describes what a generated secret looks like. A policy with
+// Words set produces a passphrase and ignores the character classes.
+type Policy struct {
+	Length           int
+	Lower            bool
+	Upper            bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+	Words            int
+	Separator        string
+}
+
+// Profiles are the named policies accepted by pk generate --profile.
+var Profiles = map[string]Policy{
+	"strong": {Length: 24, Lower: true, Upper: true, Digits: true, Symbols: true},
+	"alnum":  {Length: 20, Lower: true, Upper: true, Digits: true},
+	"simple": {Length: 16, Lower: true, Upper: true, Digits: true, ExcludeAmbiguous: true},
+	"pin":    {Length: 6, Digits: true},
+	"phrase": {Words: 6, Separator: "-"},
+}
+
+// ProfileNames lists the profiles in alphabetical order.
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the policy registered as name.
+func Profile(name string) (Policy, error) {
+	p, ok := Profiles[name]
+	if !ok {
+		msg := fmt.Sprintf("%q, want one of %v", name, strings.Join(ProfileNames(), ", "))
+		return Policy{}, errors.Wrap(ErrUnknownProfile, errors.New(msg))
+	}
+	return p, nil
+}
+
+// classes returns the character sets enabled by p.
+func (p Policy) classes() []string {
+	var classes []string
+	for _, c := range []struct {
+		on  bool
+		set string
+	}{
+		{p.Lower, Lower},
+		{p.Upper, Upper},
+		{p.Digits, Digits},
+		{p.Symbols, Symbols},
+	} {
+		if !c.on {
+			continue
+		}
+		set := c.set
+		if p.ExcludeAmbiguous {
+			set = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(Ambiguous, r) {
+					return -1
+				}
+				return r
+			}, set)
+		}
+		classes = append(classes, set)
+	}
+	return classes
+}
+
+// Validate reports whether p can produce a secret.
+func (p Policy) Validate() error {
+	if p.Words > 0 {
+		return nil
+	}
+
+	classes := p.classes()
+	if len(classes) == 0 {
+		return errors.Wrap(ErrInvalidPolicy, errors.New("no character class selected"))
+	}
+
+	if p.Length < len(classes) {
+		msg := fmt.Sprintf("length %v is too short for %v character classes", p.Length, len(classes))
+		return errors.Wrap(ErrInvalidPolicy, errors.New(msg))
+	}
+
+	return nil
+}
+
+// Entropy returns the strength of secrets generated with p in bits.
+func (p Policy) Entropy() float64 {
+	if p.Words > 0 {
+		return float64(p.Words) * math.Log2(float64(len(words())))
+	}
+	return float64(p.Length) * math.Log2(float64(len(strings.Join(p.classes(), ""))))
+}
+
+// Generate returns a new secret following p. Passwords contain at least
+// one character of every enabled class.
+func Generate(p Policy) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	if p.Words > 0 {
+		return passphrase(p.Words, p.Separator)
+	}
+
+	classes := p.classes()
+	all := strings.Join(classes, "")
+	secret := make([]byte, p.Length)
+
+	for i := range secret {
+		set := all
+		if i < len(classes) {
+			set = classes[i]
+		}
+		n, err := randInt(len(set))
+		if err != nil {
+			return "", err
+		}
+		secret[i] = set[n]
+	}
+
+	// the first characters were picked per class, shuffle them in
+	for i := len(secret) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		secret[i], secret[j] = secret[j], secret[i]
+	}
+
+	return string(secret), nil
+}
+
+func passphrase(n int, sep string) (string, error) {
+	list := words()
+	phrase := make([]string, n)
+
+	for i := range phrase {
+		j, err := randInt(len(list))
+		if err != nil {
+			return "", err
+		}
+		phrase[i] = list[j]
+	}
+
+	return strings.Join(phrase, sep), nil
+}
+
+var (
+	wordsOnce sync.Once
+	wordSlice []string
+)
+
+func words() []string {
+	wordsOnce.Do(func() {
+		wordSlice = strings.Fields(wordList)
+	})
+	return wordSlice
+}
+
+func randInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
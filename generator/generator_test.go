@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+func TestGenerate(t *testing.T) {
+	p := Policy{Length: 32, Lower: true, Upper: true, Digits: true, Symbols: true, ExcludeAmbiguous: true}
+
+	for i := 0; i < 100; i++ {
+		secret, err := Generate(p)
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if len(secret) != p.Length {
+			t.Fatalf("Generate() = %q, want %v chars", secret, p.Length)
+		}
+		for _, set := range []string{Lower, Upper, Digits, Symbols} {
+			if !strings.ContainsAny(secret, set) {
+				t.Fatalf("Generate() = %q, missing a char of %q", secret, set)
+			}
+		}
+		if strings.ContainsAny(secret, Ambiguous) {
+			t.Fatalf("Generate() = %q, contains ambiguous chars", secret)
+		}
+	}
+
+	if _, err := Generate(Policy{Length: 2, Lower: true, Upper: true, Digits: true}); !errors.Contains(err, ErrInvalidPolicy) {
+		t.Errorf("Generate() error = %v, want %v", err, ErrInvalidPolicy)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	if n := len(words()); n != 7776 {
+		t.Fatalf("word list has %v words, want 7776", n)
+	}
+
+	p, err := Profile("phrase")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+
+	phrase, err := Generate(p)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if n := len(strings.Split(phrase, p.Separator)); n < p.Words {
+		t.Errorf("Generate() = %q, want %v words", phrase, p.Words)
+	}
+
+	if _, err := Profile("nope"); !errors.Contains(err, ErrUnknownProfile) {
+		t.Errorf("Profile() error = %v, want %v", err, ErrUnknownProfile)
+	}
+}
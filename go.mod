@@ -5,11 +5,16 @@ go 1.15
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/fatih/color v1.7.0
+	github.com/golang/protobuf v1.4.3
 	github.com/hokaccha/go-prettyjson v0.0.0-20210113012101-fb4e108d2519
 	github.com/lib/pq v1.9.0
+	github.com/mattn/go-isatty v0.0.3
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/viper v1.7.1
 	github.com/zalando/go-keyring v0.1.1
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
+	google.golang.org/grpc v1.35.0
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.2.8
 )
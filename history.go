@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+var _ PasswordKeeper = (*historyMiddleware)(nil)
+
+type historyMiddleware struct {
+	PasswordKeeper
+	keep   int
+	maxAge time.Duration
+	logger *log.Logger
+}
+
+//HistoryMiddleware prunes the history of an account every time Update or
+//Rollback adds a version to it, keeping at most keep versions none of
+//which is older than maxAge. Zero turns a limit off. The change is already
+//stored when pruning fails, the error is written to logger instead
+func HistoryMiddleware(keep int, maxAge time.Duration, logger *log.Logger) Middleware {
+	return func(keeper PasswordKeeper) PasswordKeeper {
+		return &historyMiddleware{PasswordKeeper: keeper, keep: keep, maxAge: maxAge, logger: logger}
+	}
+}
+
+func (h historyMiddleware) Update(ctx context.Context, token, name, username string, account Account) (acc Account, err error) {
+	acc, err = h.PasswordKeeper.Update(ctx, token, name, username, account)
+	if err != nil {
+		return acc, err
+	}
+
+	h.prune(ctx, token, acc.Name, acc.UserName)
+	return acc, nil
+}
+
+func (h historyMiddleware) Rollback(ctx context.Context, token, name, username string, version int) (account Account, err error) {
+	account, err = h.PasswordKeeper.Rollback(ctx, token, name, username, version)
+	if err != nil {
+		return account, err
+	}
+
+	h.prune(ctx, token, account.Name, account.UserName)
+	return account, nil
+}
+
+func (h historyMiddleware) prune(ctx context.Context, token, name, username string) {
+	_, err := h.PasswordKeeper.PruneHistory(ctx, token, name, username, h.keep, h.maxAge)
+	if err != nil {
+		h.logger.Printf("warning: %v/%v: could not prune the history: %v\n", name, username, err)
+	}
+}
+
+//versionAccount returns the account of version as History shows it, ok
+//is false when there is no such version and the keeper reports that itself
+func versionAccount(ctx context.Context, keeper PasswordKeeper, token, name, username string, version int) (account Account, ok bool, err error) {
+	versions, err := keeper.History(ctx, token, name, username)
+	if err != nil {
+		return Account{}, false, err
+	}
+
+	for _, v := range versions {
+		if v.Version == version {
+			return v.Account, true, nil
+		}
+	}
+
+	return Account{}, false, nil
+}
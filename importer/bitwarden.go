@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package importer
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/hackaio/pk"
+)
+
+// bitwarden item types
+const (
+	bitwardenLogin    = 1
+	bitwardenNote     = 2
+	bitwardenCard     = 3
+	bitwardenIdentity = 4
+)
+
+// bitwarden custom field types, linked fields point at another field
+// and hold no value
+const (
+	bitwardenText    = 0
+	bitwardenHidden  = 1
+	bitwardenBoolean = 2
+)
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []struct {
+		Type         int    `json:"type"`
+		Name         string `json:"name"`
+		Notes        string `json:"notes"`
+		FolderID     string `json:"folderId"`
+		CreationDate string `json:"creationDate"`
+		Fields       []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+			Type  int    `json:"type"`
+		} `json:"fields"`
+		Login *struct {
+			URIs []struct {
+				URI string `json:"uri"`
+			} `json:"uris"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+			TOTP     string `json:"totp"`
+		} `json:"login"`
+	} `json:"items"`
+}
+
+// parseBitwarden reads the unencrypted json export of Bitwarden.
+func parseBitwarden(r io.Reader, now time.Time) (Result, error) {
+	var export bitwardenExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return Result{}, err
+	}
+	if export.Encrypted {
+		return Result{}, ErrEncryptedExport
+	}
+
+	folders := make(map[string]string, len(export.Folders))
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
+
+	var res Result
+	for i, item := range export.Items {
+		n := i + 1
+
+		if item.Type != bitwardenLogin {
+			res.skip(n, item.Name, bitwardenKind(item.Type))
+			continue
+		}
+
+		acc := pk.Account{
+			Name:   item.Name,
+			Notes:  item.Notes,
+			Folder: folders[item.FolderID],
+		}
+
+		if login := item.Login; login != nil {
+			acc.UserName, acc.Password, acc.OTP = login.Username, login.Password, login.TOTP
+			for _, uri := range login.URIs {
+				acc.URLs = append(acc.URLs, uri.URI)
+			}
+		}
+
+		if t, err := time.Parse(time.RFC3339, item.CreationDate); err == nil {
+			acc.Created = t.Format(time.RFC3339)
+		}
+
+		for _, f := range item.Fields {
+			switch f.Type {
+			case bitwardenText, bitwardenBoolean:
+				acc.Fields = append(acc.Fields, pk.Field{Name: f.Name, Value: f.Value})
+			case bitwardenHidden:
+				acc.Fields = append(acc.Fields, pk.Field{Name: f.Name, Value: f.Value, Secret: true})
+			}
+		}
+
+		res.add(n, acc, now)
+	}
+
+	return res, nil
+}
+
+func bitwardenKind(t int) string {
+	switch t {
+	case bitwardenNote:
+		return "secure note"
+	case bitwardenCard:
+		return "card"
+	case bitwardenIdentity:
+		return "identity"
+	default:
+		return "item of type " + strconv.Itoa(t)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package importer
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// csvHeaders maps the columns a source is read by to the headers it may
+// give them, lower case.
+type csvHeaders map[string][]string
+
+// readCSV calls row for every record after the header, get returns the
+// value of a column or "" when the file does not have it.
+func readCSV(r io.Reader, headers csvHeaders, row func(n int, get func(col string) string)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	positions := make(map[string]int, len(headers))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		for col, names := range headers {
+			if _, ok := positions[col]; ok {
+				continue
+			}
+			for _, name := range names {
+				if h == name {
+					positions[col] = i
+				}
+			}
+		}
+	}
+	if _, ok := positions["password"]; !ok {
+		return errors.New("no password column, the file is not an export of this source")
+	}
+
+	for n := 1; ; n++ {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		row(n, func(col string) string {
+			if i, ok := positions[col]; ok && i < len(line) {
+				return line[i]
+			}
+			return ""
+		})
+	}
+}
+
+// lastPassNote is the url LastPass gives secure notes
+const lastPassNote = "http://sn"
+
+// parseLastPass reads the CSV export of LastPass, groups become folders.
+func parseLastPass(r io.Reader, now time.Time) (Result, error) {
+	headers := csvHeaders{
+		"name":     {"name"},
+		"url":      {"url"},
+		"username": {"username"},
+		"password": {"password"},
+		"otp":      {"totp"},
+		"notes":    {"extra"},
+		"folder":   {"grouping"},
+	}
+
+	var res Result
+	err := readCSV(r, headers, func(n int, get func(string) string) {
+		if get("url") == lastPassNote {
+			res.skip(n, get("name"), "secure note")
+			return
+		}
+
+		res.add(n, pk.Account{
+			Name:     get("name"),
+			UserName: get("username"),
+			Password: get("password"),
+			URLs:     []string{get("url")},
+			Notes:    get("notes"),
+			Folder:   strings.Replace(get("folder"), "\\", "/", -1),
+			OTP:      get("otp"),
+		}, now)
+	})
+
+	return res, err
+}
+
+// parseOnePassword reads the CSV export of 1Password, the headers of
+// 1Password 8 and the usual ones picked in 1Password 7.
+func parseOnePassword(r io.Reader, now time.Time) (Result, error) {
+	headers := csvHeaders{
+		"name":     {"title"},
+		"url":      {"url", "website", "urls"},
+		"username": {"username"},
+		"password": {"password"},
+		"otp":      {"otpauth", "one-time password", "otp"},
+		"notes":    {"notes", "notesplain"},
+		"tags":     {"tags"},
+		"type":     {"type", "category"},
+		"archived": {"archived"},
+	}
+
+	var res Result
+	err := readCSV(r, headers, func(n int, get func(string) string) {
+		if kind := strings.ToLower(get("type")); kind != "" && kind != "login" && kind != "001" {
+			res.skip(n, get("name"), kind)
+			return
+		}
+		if archived, _ := strconv.ParseBool(get("archived")); archived {
+			res.skip(n, get("name"), "archived")
+			return
+		}
+
+		res.add(n, pk.Account{
+			Name:     get("name"),
+			UserName: get("username"),
+			Password: get("password"),
+			URLs:     strings.Fields(get("url")),
+			Notes:    get("notes"),
+			Tags:     strings.Split(get("tags"), ","),
+			OTP:      get("otp"),
+		}, now)
+	})
+
+	return res, err
+}
+
+// parseBrowser reads the password CSV export of Chrome and of Firefox.
+// Firefox gives no names, they are taken from the urls.
+func parseBrowser(r io.Reader, now time.Time) (Result, error) {
+	headers := csvHeaders{
+		"name":     {"name"},
+		"url":      {"url", "origin"},
+		"username": {"username"},
+		"password": {"password"},
+		"notes":    {"note", "notes"},
+		"created":  {"timecreated"},
+	}
+
+	var res Result
+	err := readCSV(r, headers, func(n int, get func(string) string) {
+		// the login of the browser's own sync account
+		if strings.HasPrefix(get("url"), "chrome://") {
+			res.skip(n, get("url"), "browser account")
+			return
+		}
+
+		acc := pk.Account{
+			Name:     get("name"),
+			UserName: get("username"),
+			Password: get("password"),
+			URLs:     []string{get("url")},
+			Notes:    get("notes"),
+		}
+
+		// firefox counts milliseconds since the epoch
+		if ms, err := strconv.ParseInt(get("created"), 10, 64); err == nil && ms > 0 {
+			acc.Created = time.Unix(ms/1000, ms%1000*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+		}
+
+		res.add(n, acc, now)
+	})
+
+	return res, err
+}
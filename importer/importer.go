@@ -0,0 +1,278 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package importer reads the exports of other password managers into
+// accounts: Bitwarden JSON, KeePass 2 XML, and the CSV files of
+// LastPass, 1Password, Chrome and Firefox. Records that are not logins,
+// like cards and secure notes, are skipped and records that cannot be
+// imported fail, both with the reason.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/otp"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// Sources an Importer can be made for.
+const (
+	Bitwarden   = "bitwarden"
+	KeePass     = "keepass"
+	LastPass    = "lastpass"
+	OnePassword = "1password"
+	Chrome      = "chrome"
+	Firefox     = "firefox"
+)
+
+// Record statuses.
+const (
+	StatusSkipped = "skipped"
+	StatusFailed  = "failed"
+)
+
+var (
+	ErrUnknownSource   = errors.New("unknown import source")
+	ErrInvalidExport   = errors.New("invalid export")
+	ErrEncryptedExport = errors.New("encrypted exports are not supported")
+)
+
+// Sources lists the sources New knows, in the order they are shown.
+var Sources = []string{Bitwarden, KeePass, LastPass, OnePassword, Chrome, Firefox}
+
+// Importer reads the export of one password manager. Read returns the
+// accounts of an export file, Parse tells what became of every record.
+type Importer interface {
+	pk.Reader
+
+	Parse(r io.Reader) (Result, error)
+}
+
+// Record is a record of an export that was not imported.
+type Record struct {
+	Record int    `json:"record" yaml:"record"`
+	Name   string `json:"name,omitempty" yaml:"name,omitempty"`
+	Status string `json:"status" yaml:"status"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// Result is what Parse made of an export.
+type Result struct {
+	Source   string
+	Accounts []pk.Account
+	Records  []Record
+
+	// numbers holds the record number of every account
+	numbers []int
+}
+
+// Summary is the outcome of importing an export, Skipped counts the
+// accounts pk.Import skipped as well.
+type Summary struct {
+	Source   string   `json:"source" yaml:"source"`
+	Imported int      `json:"imported" yaml:"imported"`
+	Skipped  int      `json:"skipped" yaml:"skipped"`
+	Failed   int      `json:"failed" yaml:"failed"`
+	Records  []Record `json:"records,omitempty" yaml:"records,omitempty"`
+}
+
+// Summary adds report, what pk.Import did with the accounts, to r. The
+// accounts pk.Import rejected fail with the rules they broke.
+func (r Result) Summary(report pk.ImportReport) Summary {
+	s := Summary{
+		Source:   r.Source,
+		Imported: report.Added + report.Replaced + report.Renamed,
+		Skipped:  report.Skipped,
+		Records:  append([]Record{}, r.Records...),
+	}
+
+	rejected := map[string]int{}
+	for _, f := range report.Failures {
+		if i, ok := rejected[f.Account]; ok {
+			s.Records[i].Reason += "; " + f.Message
+			continue
+		}
+		rejected[f.Account] = len(s.Records)
+		s.Records = append(s.Records, Record{
+			Record: r.number(f.Account),
+			Name:   f.Account,
+			Status: StatusFailed,
+			Reason: f.Message,
+		})
+	}
+
+	for _, rec := range s.Records {
+		if rec.Status == StatusFailed {
+			s.Failed++
+		} else {
+			s.Skipped++
+		}
+	}
+	return s
+}
+
+// number returns the record number of the account named ref, name/username
+// like pk names the accounts it rejects, 0 when unknown.
+func (r Result) number(ref string) int {
+	for i, acc := range r.Accounts {
+		if i < len(r.numbers) && fmt.Sprintf("%v/%v", acc.Name, acc.UserName) == ref {
+			return r.numbers[i]
+		}
+	}
+	return 0
+}
+
+func (r *Result) skip(n int, name, reason string) {
+	r.Records = append(r.Records, Record{Record: n, Name: name, Status: StatusSkipped, Reason: reason})
+}
+
+func (r *Result) fail(n int, name, reason string) {
+	r.Records = append(r.Records, Record{Record: n, Name: name, Status: StatusFailed, Reason: reason})
+}
+
+// add checks acc, record n of the export, and keeps it or records why
+// it failed.
+func (r *Result) add(n int, acc pk.Account, now time.Time) {
+	if reason := finish(&acc, now); reason != "" {
+		r.fail(n, acc.Name, reason)
+		return
+	}
+	r.Accounts = append(r.Accounts, acc)
+	r.numbers = append(r.numbers, n)
+}
+
+// New returns the Importer of source.
+func New(source string) (Importer, error) {
+	var parse func(io.Reader, time.Time) (Result, error)
+
+	switch strings.ToLower(source) {
+	case Bitwarden:
+		parse = parseBitwarden
+	case KeePass:
+		parse = parseKeePass
+	case LastPass:
+		parse = parseLastPass
+	case OnePassword:
+		parse = parseOnePassword
+	case Chrome, Firefox:
+		parse = parseBrowser
+	default:
+		return nil, errors.Wrap(ErrUnknownSource, errors.New(fmt.Sprintf("%v, want one of %v", source,
+			strings.Join(Sources, ", "))))
+	}
+
+	return &importer{source: strings.ToLower(source), parse: parse}, nil
+}
+
+type importer struct {
+	source string
+	parse  func(io.Reader, time.Time) (Result, error)
+}
+
+func (i *importer) Read(ctx context.Context, fileName string) ([]pk.Account, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res, err := i.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	return res.Accounts, nil
+}
+
+func (i *importer) Parse(r io.Reader) (Result, error) {
+	res, err := i.parse(r, time.Now())
+	if err != nil {
+		return Result{}, errors.Wrap(ErrInvalidExport, err)
+	}
+	res.Source = i.source
+	return res, nil
+}
+
+// finish trims acc and fills in what exports leave out: the name from
+// the first url, the email from an email username and the creation
+// time. It returns why acc cannot be imported.
+func finish(acc *pk.Account, now time.Time) string {
+	acc.Name = strings.TrimSpace(acc.Name)
+	acc.UserName = strings.TrimSpace(acc.UserName)
+	acc.OTP = strings.TrimSpace(acc.OTP)
+	acc.URLs = clean(acc.URLs)
+	acc.Tags = clean(acc.Tags)
+
+	if acc.Name == "" && len(acc.URLs) > 0 {
+		acc.Name = host(acc.URLs[0])
+	}
+
+	switch {
+	case acc.Name == "":
+		return "missing name"
+	case acc.UserName == "":
+		return "missing username"
+	case acc.Password == "":
+		return "missing password"
+	}
+
+	if acc.Email == "" && strings.Contains(acc.UserName, "@") {
+		acc.Email = acc.UserName
+	}
+
+	if acc.OTP != "" {
+		var err error
+		if strings.HasPrefix(acc.OTP, "otpauth://") {
+			_, err = otp.Parse(acc.OTP)
+		} else {
+			_, err = otp.NewKey(acc.OTP)
+		}
+		if err != nil {
+			return fmt.Sprintf("totp: %v", err)
+		}
+	}
+
+	if acc.Created == "" {
+		acc.Created = now.Format(time.RFC3339)
+	}
+
+	return ""
+}
+
+// host returns the host of a url, or the url when it has none.
+func host(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		if u, err = url.Parse("https://" + raw); err != nil || u.Host == "" {
+			return raw
+		}
+	}
+	return u.Hostname()
+}
+
+// clean trims the items of list and drops the empty ones.
+func clean(list []string) []string {
+	var res []string
+	for _, item := range list {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
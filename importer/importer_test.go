@@ -0,0 +1,257 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
+)
+
+const totpURI = "otpauth://totp/GitHub:alice?secret=JBSWY3DPEHPK3PXP&issuer=GitHub"
+
+const bitwardenJSON = `{
+  "encrypted": false,
+  "folders": [{"id": "f1", "name": "Work"}],
+  "items": [
+    {
+      "type": 1, "name": "GitHub", "notes": "main", "folderId": "f1",
+      "creationDate": "2021-03-04T05:06:07.000Z",
+      "fields": [{"name": "pin", "value": "1234", "type": 1}, {"name": "plan", "value": "pro", "type": 0}],
+      "login": {"uris": [{"uri": "https://github.com"}], "username": "alice@example.com", "password": "pw1", "totp": "` + totpURI + `"}
+    },
+    {"type": 2, "name": "Wifi", "notes": "password"},
+    {"type": 3, "name": "Visa"},
+    {"type": 1, "name": "Bank", "login": {"username": "alice"}},
+    {"type": 1, "name": "Mail", "login": {"username": "alice", "password": "pw2", "totp": "not base32!"}}
+  ]
+}`
+
+var keepassXML = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+  <Meta><RecycleBinUUID>bin</RecycleBinUUID></Meta>
+  <Root>
+    <Group>
+      <UUID>root</UUID><Name>Database</Name>
+      <Entry>
+        <Tags>dev;work</Tags>
+        <Times><CreationTime>2021-03-04T05:06:07Z</CreationTime></Times>
+        <String><Key>Title</Key><Value>GitHub</Value></String>
+        <String><Key>UserName</Key><Value>alice</Value></String>
+        <String><Key>Password</Key><Value ProtectInMemory="True">pw1</Value></String>
+        <String><Key>URL</Key><Value>https://github.com</Value></String>
+        <String><Key>KP2A_URL_1</Key><Value>https://gist.github.com</Value></String>
+        <String><Key>otp</Key><Value>` + strings.Replace(totpURI, "&", "&amp;", -1) + `</Value></String>
+        <String><Key>recovery</Key><Value ProtectInMemory="True">r-123</Value></String>
+        <History><Entry><String><Key>Title</Key><Value>Old</Value></String></Entry></History>
+      </Entry>
+      <Group>
+        <UUID>web</UUID><Name>Web</Name>
+        <Group>
+          <UUID>mail</UUID><Name>Mail</Name>
+          <Entry>
+            <String><Key>Title</Key><Value>Gmail</Value></String>
+            <String><Key>UserName</Key><Value>bob</Value></String>
+            <String><Key>Password</Key><Value>pw2</Value></String>
+            <String><Key>TOTP Seed</Key><Value>JBSW Y3DP EHPK 3PXP</Value></String>
+          </Entry>
+        </Group>
+      </Group>
+      <Group>
+        <UUID>bin</UUID><Name>Recycle Bin</Name>
+        <Entry><String><Key>Title</Key><Value>Gone</Value></String></Entry>
+      </Group>
+    </Group>
+  </Root>
+</KeePassFile>`
+
+const lastPassCSV = "url,username,password,totp,extra,name,grouping,fav\n" +
+	"https://github.com,alice,pw1,JBSWY3DPEHPK3PXP,\"two\nlines\",GitHub,Work\\Dev,0\n" +
+	"http://sn,,,,secret note,Note,,0\n" +
+	"https://example.com,,pw3,,,Example,,0\n"
+
+const onePasswordCSV = "Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+	"GitHub,https://github.com,alice,pw1," + totpURI + ",false,false,\"dev,work\",main\n" +
+	"Old,https://old.example.com,alice,pw2,,false,true,,\n"
+
+const chromeCSV = "\ufeffname,url,username,password,note\n" +
+	"github.com,https://github.com/login,alice,pw1,\n" +
+	"example.com,https://example.com,,pw2,\n"
+
+const firefoxCSV = `"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"
+"https://github.com","alice","pw1",,"https://github.com","{1}","1614834367000","1614834367000","1614834367000"
+"chrome://FirefoxAccounts","sync","pw2","Firefox Accounts credentials",,"{2}","1614834367000","1614834367000","1614834367000"
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		source  string
+		data    string
+		want    []pk.Account
+		records []Record
+	}{
+		{
+			source: Bitwarden,
+			data:   bitwardenJSON,
+			want: []pk.Account{{
+				Name: "GitHub", UserName: "alice@example.com", Email: "alice@example.com", Password: "pw1",
+				Created: "2021-03-04T05:06:07Z", URLs: []string{"https://github.com"}, Notes: "main", Folder: "Work",
+				Fields: []pk.Field{{Name: "pin", Value: "1234", Secret: true}, {Name: "plan", Value: "pro"}}, OTP: totpURI,
+			}},
+			records: []Record{
+				{Record: 2, Name: "Wifi", Status: StatusSkipped, Reason: "secure note"},
+				{Record: 3, Name: "Visa", Status: StatusSkipped, Reason: "card"},
+				{Record: 4, Name: "Bank", Status: StatusFailed, Reason: "missing password"},
+				{Record: 5, Name: "Mail", Status: StatusFailed, Reason: "totp: invalid otp secret : the secret is not base32"},
+			},
+		},
+		{
+			source: KeePass,
+			data:   keepassXML,
+			want: []pk.Account{
+				{
+					Name: "GitHub", UserName: "alice", Password: "pw1", Created: "2021-03-04T05:06:07Z",
+					URLs: []string{"https://github.com", "https://gist.github.com"}, Tags: []string{"dev", "work"},
+					Fields: []pk.Field{{Name: "recovery", Value: "r-123", Secret: true}}, OTP: totpURI,
+				},
+				{Name: "Gmail", UserName: "bob", Password: "pw2", Folder: "Web/Mail", OTP: "JBSW Y3DP EHPK 3PXP"},
+			},
+			records: []Record{{Record: 3, Name: "Gone", Status: StatusSkipped, Reason: "in the recycle bin"}},
+		},
+		{
+			source: LastPass,
+			data:   lastPassCSV,
+			want: []pk.Account{{
+				Name: "GitHub", UserName: "alice", Password: "pw1", URLs: []string{"https://github.com"},
+				Notes: "two\nlines", Folder: "Work/Dev", OTP: "JBSWY3DPEHPK3PXP",
+			}},
+			records: []Record{
+				{Record: 2, Name: "Note", Status: StatusSkipped, Reason: "secure note"},
+				{Record: 3, Name: "Example", Status: StatusFailed, Reason: "missing username"},
+			},
+		},
+		{
+			source: OnePassword,
+			data:   onePasswordCSV,
+			want: []pk.Account{{
+				Name: "GitHub", UserName: "alice", Password: "pw1", URLs: []string{"https://github.com"},
+				Notes: "main", Tags: []string{"dev", "work"}, OTP: totpURI,
+			}},
+			records: []Record{{Record: 2, Name: "Old", Status: StatusSkipped, Reason: "archived"}},
+		},
+		{
+			source:  Chrome,
+			data:    chromeCSV,
+			want:    []pk.Account{{Name: "github.com", UserName: "alice", Password: "pw1", URLs: []string{"https://github.com/login"}}},
+			records: []Record{{Record: 2, Name: "example.com", Status: StatusFailed, Reason: "missing username"}},
+		},
+		{
+			source: Firefox,
+			data:   firefoxCSV,
+			want: []pk.Account{{
+				Name: "github.com", UserName: "alice", Password: "pw1", Created: "2021-03-04T05:06:07Z",
+				URLs: []string{"https://github.com"},
+			}},
+			records: []Record{{Record: 2, Name: "chrome://FirefoxAccounts", Status: StatusSkipped, Reason: "browser account"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			imp, err := New(tt.source)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			res, err := imp.Parse(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			for i := range res.Accounts {
+				if res.Accounts[i].Created == "" {
+					t.Errorf("Parse() account %v has no creation time", res.Accounts[i].Name)
+				}
+				if i < len(tt.want) && tt.want[i].Created == "" {
+					res.Accounts[i].Created = ""
+				}
+			}
+			if !reflect.DeepEqual(res.Accounts, tt.want) {
+				t.Errorf("Parse() accounts = %+v, want %+v", res.Accounts, tt.want)
+			}
+			if !reflect.DeepEqual(res.Records, tt.records) {
+				t.Errorf("Parse() records = %+v, want %+v", res.Records, tt.records)
+			}
+			if res.Source != tt.source {
+				t.Errorf("Parse() source = %v, want %v", res.Source, tt.source)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		data   string
+		err    error
+	}{
+		{source: Bitwarden, data: `{"encrypted": true, "items": []}`, err: ErrEncryptedExport},
+		{source: Bitwarden, data: `[`, err: ErrInvalidExport},
+		{source: KeePass, data: `<KeePassFile><Root>`, err: ErrInvalidExport},
+		{source: Chrome, data: "a,b,c\n1,2,3\n", err: ErrInvalidExport},
+	}
+
+	for _, tt := range tests {
+		imp, _ := New(tt.source)
+		_, err := imp.Parse(strings.NewReader(tt.data))
+		if !errors.Contains(err, tt.err) {
+			t.Errorf("Parse(%v) error = %v, want %v", tt.source, err, tt.err)
+		}
+	}
+
+	if _, err := New("passpack"); !errors.Contains(err, ErrUnknownSource) {
+		t.Errorf("New() error = %v, want %v", err, ErrUnknownSource)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	res := Result{
+		Source: LastPass,
+		Records: []Record{
+			{Record: 2, Status: StatusSkipped, Reason: "secure note"},
+			{Record: 3, Status: StatusFailed, Reason: "missing username"},
+		},
+	}
+
+	got := res.Summary(pk.ImportReport{Added: 3, Replaced: 1, Renamed: 1, Skipped: 2})
+	if got.Imported != 5 || got.Skipped != 3 || got.Failed != 1 || got.Source != LastPass {
+		t.Errorf("Summary() = %+v, want 5 imported, 3 skipped and 1 failed", got)
+	}
+
+	// accounts the policy rejected fail once, with every rule they broke
+	res.Accounts = []pk.Account{{Name: "GitHub", UserName: "alice"}}
+	res.numbers = []int{4}
+	got = res.Summary(pk.ImportReport{Rejected: 1, Failures: []strength.Failure{
+		{Account: "GitHub/alice", Rule: "length", Message: "too short"},
+		{Account: "GitHub/alice", Rule: "score", Message: "too weak"},
+	}})
+	want := Record{Record: 4, Name: "GitHub/alice", Status: StatusFailed, Reason: "too short; too weak"}
+	if got.Failed != 2 || len(got.Records) != 3 || got.Records[2] != want {
+		t.Errorf("Summary() = %+v, want the rejected account to fail as %+v", got, want)
+	}
+}
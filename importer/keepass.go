@@ -0,0 +1,45 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package importer
+
+import (
+	"io"
+	"time"
+
+	"github.com/hackaio/pk/kdbx"
+)
+
+// parseKeePass reads the KeePass 2 XML export. Groups below the top one
+// become folders, entries of the recycle bin are skipped. Databases
+// themselves are read by the kdbx package.
+func parseKeePass(r io.Reader, now time.Time) (Result, error) {
+	db, err := kdbx.DecodeXML(r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var res Result
+	n := 0
+	db.Walk(func(e kdbx.Entry, folder string, recycled bool) {
+		n++
+		acc := e.Account(folder)
+		if recycled {
+			res.skip(n, acc.Name, "in the recycle bin")
+			return
+		}
+		res.add(n, acc, now)
+	})
+
+	return res, nil
+}
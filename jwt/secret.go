@@ -0,0 +1,80 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//SecretFile is the file next to the RSA keys holding the secret tokens
+//are signed with
+const SecretFile = "jwt.secret"
+
+const secretSize = 32
+
+var ErrInvalidSecret = errors.New("invalid token signing secret")
+
+//LoadSecret returns the token signing secret of the install whose home
+//directory is homeDir, making a random one on first use
+func LoadSecret(homeDir string) (string, error) {
+	credsDir := filepath.Join(homeDir, pk.AppDir, pk.CredDir)
+	secretFile := filepath.Join(credsDir, SecretFile)
+
+	data, err := ioutil.ReadFile(secretFile)
+	if err == nil {
+		secret := strings.TrimSpace(string(data))
+		if len(secret) < 2*secretSize {
+			return "", ErrInvalidSecret
+		}
+		return secret, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err = os.MkdirAll(credsDir, 0700); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, secretSize)
+	if _, err = rand.Read(b); err != nil {
+		return "", errors.Wrap(pk.ErrCriticalFailure, err)
+	}
+	secret := hex.EncodeToString(b)
+
+	//O_EXCL keeps the secret of a pk started at the same time
+	f, err := os.OpenFile(secretFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return LoadSecret(homeDir)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err = f.WriteString(secret + "\n"); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
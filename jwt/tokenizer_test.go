@@ -16,6 +16,10 @@ package jwt
 import (
 	"fmt"
 	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -36,3 +40,45 @@ func TestIssueToken(t *testing.T) {
 
 	fmt.Printf("id : %v issuedAt: %v", tokenRecovered.ID, tokenRecovered.IssuedAt)
 }
+
+func TestLoadSecret(t *testing.T) {
+	home, err := ioutil.TempDir("", "pk-jwt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	secret, err := LoadSecret(home)
+	if err != nil {
+		t.Fatalf("LoadSecret() error = %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(home, pk.AppDir, pk.CredDir, SecretFile))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("secret file = %v, %v, want mode 0600", info, err)
+	}
+
+	again, err := LoadSecret(home)
+	if err != nil || again != secret {
+		t.Errorf("LoadSecret() = %v, %v, want the first secret", again, err)
+	}
+
+	other, err := ioutil.TempDir("", "pk-jwt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(other)
+	otherSecret, err := LoadSecret(other)
+	if err != nil || otherSecret == secret {
+		t.Fatalf("LoadSecret() of another install = %v, %v, want another secret", otherSecret, err)
+	}
+
+	//tokens of one install are refused by the others
+	tStr, err := NewTokenizer(otherSecret).Issue(pk.NewToken("piusalfred"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewTokenizer(secret).Parse(tStr); !errors.Contains(err, pk.ErrPermissionDenied) {
+		t.Errorf("Parse() error = %v, want %v", err, pk.ErrPermissionDenied)
+	}
+}
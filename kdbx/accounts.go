@@ -0,0 +1,248 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kdbx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/otp"
+)
+
+// String keys of entries. The otp ones are those of KeePassXC and of the
+// KeePass 2.47 TOTP support, KP2A_URL keys hold more urls of an entry.
+const (
+	KeyTitle    = "Title"
+	KeyUserName = "UserName"
+	KeyPassword = "Password"
+	KeyURL      = "URL"
+	KeyNotes    = "Notes"
+	KeyEmail    = "Email"
+	KeyOTP      = "otp"
+	KeySeed     = "TOTP Seed"
+	KeySettings = "TOTP Settings"
+	KeyTimeOTP  = "TimeOtp-Secret-Base32"
+	KeyMoreURLs = "KP2A_URL"
+)
+
+// Account returns the account of e, folder is the path of its group.
+// Strings pk has no place for become fields, secret when protected.
+func (e Entry) Account(folder string) pk.Account {
+	acc := pk.Account{Tags: e.Tags, Folder: folder}
+	if !e.Created.IsZero() {
+		acc.Created = e.Created.Format(time.RFC3339)
+	}
+
+	var seed string
+	for _, s := range e.Strings {
+		switch {
+		case s.Key == KeyTitle:
+			acc.Name = s.Value
+		case s.Key == KeyUserName:
+			acc.UserName = s.Value
+		case s.Key == KeyPassword:
+			acc.Password = s.Value
+		case s.Key == KeyURL || strings.HasPrefix(s.Key, KeyMoreURLs):
+			if s.Value == "" {
+				continue
+			}
+			if s.Key != KeyURL {
+				acc.URLs = append(acc.URLs, s.Value)
+				continue
+			}
+			acc.URLs = append([]string{s.Value}, acc.URLs...)
+		case s.Key == KeyNotes:
+			acc.Notes = s.Value
+		case s.Key == KeyEmail:
+			acc.Email = s.Value
+		case s.Key == KeyOTP:
+			acc.OTP = s.Value
+		case s.Key == KeySeed || s.Key == KeyTimeOTP:
+			seed = s.Value
+		case s.Key == KeySettings || strings.HasPrefix(s.Key, "TimeOtp-"):
+			// the defaults of the seed are assumed
+		default:
+			acc.Fields = append(acc.Fields, pk.Field{Name: s.Key, Value: s.Value, Secret: s.Protected})
+		}
+	}
+
+	if acc.OTP == "" {
+		acc.OTP = seed
+	}
+
+	return acc
+}
+
+// Walk calls fn for the entries of db, those of a group before its
+// groups. folder is the path of the group of an entry below the top
+// group, recycled tells whether it is in the recycle bin.
+func (db *Database) Walk(fn func(e Entry, folder string, recycled bool)) {
+	var walk func(g Group, path []string, recycled bool)
+	walk = func(g Group, path []string, recycled bool) {
+		recycled = recycled || db.RecycleBin != (UUID{}) && g.UUID == db.RecycleBin
+
+		for _, e := range g.Entries {
+			fn(e, strings.Join(path, "/"), recycled)
+		}
+		for _, sub := range g.Groups {
+			walk(sub, append(path[:len(path):len(path)], sub.Name), recycled)
+		}
+	}
+
+	walk(db.Root, nil, false)
+}
+
+// Accounts returns the accounts of the entries of db, leaving out the
+// recycle bin.
+func (db *Database) Accounts() []pk.Account {
+	var accounts []pk.Account
+	db.Walk(func(e Entry, folder string, recycled bool) {
+		if !recycled {
+			accounts = append(accounts, e.Account(folder))
+		}
+	})
+	return accounts
+}
+
+// FromAccounts returns a database named name holding accounts, their
+// folders become groups.
+func FromAccounts(name string, accounts []pk.Account) *Database {
+	db := &Database{Name: name, Root: Group{UUID: NewUUID(), Name: name}}
+
+	for _, a := range accounts {
+		g := &db.Root
+		for _, part := range strings.Split(a.Folder, "/") {
+			if part = strings.TrimSpace(part); part != "" {
+				g = g.group(part)
+			}
+		}
+		g.Entries = append(g.Entries, entry(a))
+	}
+
+	return db
+}
+
+// group returns the subgroup of g named name, adding it when needed.
+func (g *Group) group(name string) *Group {
+	for i := range g.Groups {
+		if g.Groups[i].Name == name {
+			return &g.Groups[i]
+		}
+	}
+	g.Groups = append(g.Groups, Group{UUID: NewUUID(), Name: name})
+	return &g.Groups[len(g.Groups)-1]
+}
+
+func entry(a pk.Account) Entry {
+	e := Entry{UUID: NewUUID(), Tags: a.Tags}
+	if t, err := time.Parse(time.RFC3339, a.Created); err == nil {
+		e.Created = t
+	}
+
+	add := func(key, value string, protected bool) {
+		e.Strings = append(e.Strings, String{Key: key, Value: value, Protected: protected})
+	}
+
+	add(KeyTitle, a.Name, false)
+	add(KeyUserName, a.UserName, false)
+	add(KeyPassword, a.Password, true)
+
+	url := ""
+	if len(a.URLs) > 0 {
+		url = a.URLs[0]
+	}
+	add(KeyURL, url, false)
+	add(KeyNotes, a.Notes, false)
+
+	for i, u := range a.URLs {
+		if i > 0 {
+			add(fmt.Sprintf("%v_%d", KeyMoreURLs, i), u, false)
+		}
+	}
+	if a.Email != "" && a.Email != a.UserName {
+		add(KeyEmail, a.Email, false)
+	}
+	if a.OTP != "" {
+		add(KeyOTP, otpURI(a), true)
+	}
+	for _, f := range a.Fields {
+		add(f.Name, f.Value, f.Secret)
+	}
+
+	return e
+}
+
+// otpURI returns the otpauth:// URI KeePassXC expects of a bare base32
+// seed, labelled with the name and username of a.
+func otpURI(a pk.Account) string {
+	if strings.HasPrefix(a.OTP, "otpauth://") {
+		return a.OTP
+	}
+
+	key, err := otp.NewKey(a.OTP)
+	if err != nil {
+		return a.OTP
+	}
+	key.Issuer, key.Account = a.Name, a.UserName
+	return key.URI()
+}
+
+var _ pk.ReaderWriter = (*readerWriter)(nil)
+
+type readerWriter struct {
+	key Key
+}
+
+// NewReader returns a pk.Reader of the accounts of databases opened with
+// key.
+func NewReader(key Key) pk.Reader {
+	return &readerWriter{key: key}
+}
+
+// NewWriter returns a pk.Writer of databases encrypted with key.
+func NewWriter(key Key) pk.Writer {
+	return &readerWriter{key: key}
+}
+
+func (rw readerWriter) Read(ctx context.Context, fileName string) ([]pk.Account, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db, err := Open(f, rw.key)
+	if err != nil {
+		return nil, err
+	}
+	return db.Accounts(), nil
+}
+
+func (rw readerWriter) Write(ctx context.Context, request pk.FileWriterReq) error {
+	name := strings.TrimSuffix(filepath.Base(request.Path()), Ext)
+	if request.Out != nil || name == "" || name == "." {
+		name = generator
+	}
+
+	db := FromAccounts(name, request.Accounts)
+	return request.WriteWith(func(w io.Writer) error {
+		return db.Write(w, rw.key)
+	})
+}
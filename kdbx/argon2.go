@@ -0,0 +1,330 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kdbx
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// golang.org/x/crypto/argon2 only offers Argon2i and Argon2id, KeePass
+// defaults to Argon2d. This is Argon2 as RFC 9106 describes it, versions
+// 0x10 and 0x13, laid out like the x/crypto one.
+
+// argon2 types
+const (
+	argon2d  = 0
+	argon2id = 2
+)
+
+// argon2 versions
+const (
+	argon2Version10 = 0x10
+	argon2Version13 = 0x13
+)
+
+const (
+	argon2BlockLength = 128
+	argon2SyncPoints  = 4
+)
+
+type argon2Block [argon2BlockLength]uint64
+
+// argon2Params are the parameters of a KDBX Argon2 key derivation, Memory
+// is in KiB.
+type argon2Params struct {
+	mode        int
+	version     uint32
+	iterations  uint32
+	memory      uint32
+	parallelism uint32
+	secret      []byte
+	data        []byte
+}
+
+// argon2Key derives a keyLen bytes key from password and salt.
+func argon2Key(password, salt []byte, p argon2Params, keyLen uint32) []byte {
+	h0 := argon2InitHash(password, salt, p, keyLen)
+
+	memory := p.memory / (argon2SyncPoints * p.parallelism) * (argon2SyncPoints * p.parallelism)
+	if memory < 2*argon2SyncPoints*p.parallelism {
+		memory = 2 * argon2SyncPoints * p.parallelism
+	}
+
+	B := argon2InitBlocks(&h0, memory, p.parallelism)
+	argon2ProcessBlocks(B, memory, p)
+	return argon2ExtractKey(B, memory, p.parallelism, keyLen)
+}
+
+func argon2InitHash(password, salt []byte, p argon2Params, keyLen uint32) [blake2b.Size + 8]byte {
+	var (
+		h0     [blake2b.Size + 8]byte
+		params [24]byte
+		tmp    [4]byte
+	)
+
+	b2, _ := blake2b.New512(nil)
+	binary.LittleEndian.PutUint32(params[0:4], p.parallelism)
+	binary.LittleEndian.PutUint32(params[4:8], keyLen)
+	binary.LittleEndian.PutUint32(params[8:12], p.memory)
+	binary.LittleEndian.PutUint32(params[12:16], p.iterations)
+	binary.LittleEndian.PutUint32(params[16:20], p.version)
+	binary.LittleEndian.PutUint32(params[20:24], uint32(p.mode))
+	b2.Write(params[:])
+
+	for _, b := range [][]byte{password, salt, p.secret, p.data} {
+		binary.LittleEndian.PutUint32(tmp[:], uint32(len(b)))
+		b2.Write(tmp[:])
+		b2.Write(b)
+	}
+
+	b2.Sum(h0[:0])
+	return h0
+}
+
+func argon2InitBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []argon2Block {
+	var block0 [1024]byte
+	B := make([]argon2Block, memory)
+
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * (memory / threads)
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+
+		for k := uint32(0); k < 2; k++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], k)
+			argon2Hash(block0[:], h0[:])
+			for i := range B[j+k] {
+				B[j+k][i] = binary.LittleEndian.Uint64(block0[i*8:])
+			}
+		}
+	}
+
+	return B
+}
+
+func argon2ProcessBlocks(B []argon2Block, memory uint32, p argon2Params) {
+	threads := p.parallelism
+	lanes := memory / threads
+	segments := lanes / argon2SyncPoints
+
+	processSegment := func(n, slice, lane uint32, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		// Argon2id picks the first half of the first pass independently
+		// of the data, like Argon2i
+		independent := p.mode == argon2id && n == 0 && slice < argon2SyncPoints/2
+
+		var addresses, in, zero argon2Block
+		if independent {
+			in[0] = uint64(n)
+			in[1] = uint64(lane)
+			in[2] = uint64(slice)
+			in[3] = uint64(memory)
+			in[4] = uint64(p.iterations)
+			in[5] = uint64(p.mode)
+		}
+
+		index := uint32(0)
+		if n == 0 && slice == 0 {
+			// the first two blocks are made by argon2InitBlocks
+			index = 2
+			if independent {
+				in[6]++
+				argon2Compress(&addresses, &in, &zero, false)
+				argon2Compress(&addresses, &addresses, &zero, false)
+			}
+		}
+
+		offset := lane*lanes + slice*segments + index
+		for index < segments {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				prev += lanes
+			}
+
+			var random uint64
+			if independent {
+				if index%argon2BlockLength == 0 {
+					in[6]++
+					argon2Compress(&addresses, &in, &zero, false)
+					argon2Compress(&addresses, &addresses, &zero, false)
+				}
+				random = addresses[index%argon2BlockLength]
+			} else {
+				random = B[prev][0]
+			}
+
+			ref := argon2Index(random, lanes, segments, threads, n, slice, lane, index)
+
+			// version 0x10 overwrites the blocks of later passes
+			xor := n > 0 && p.version == argon2Version13
+			argon2Compress(&B[offset], &B[prev], &B[ref], xor)
+
+			index, offset = index+1, offset+1
+		}
+	}
+
+	for n := uint32(0); n < p.iterations; n++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			var wg sync.WaitGroup
+			for lane := uint32(0); lane < threads; lane++ {
+				wg.Add(1)
+				go processSegment(n, slice, lane, &wg)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+// argon2Index maps the pseudo random value of a block to the block it
+// is compressed with.
+func argon2Index(random uint64, lanes, segments, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+
+	m, s := 3*segments, ((slice+1)%argon2SyncPoints)*segments
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segments, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+
+	x := random & 0xffffffff
+	x = (x * x) >> 32
+	x = (x * uint64(m)) >> 32
+	return refLane*lanes + uint32((uint64(s)+uint64(m)-(x+1))%uint64(lanes))
+}
+
+func argon2ExtractKey(B []argon2Block, memory, threads, keyLen uint32) []byte {
+	lanes := memory / threads
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range B[lane*lanes+lanes-1] {
+			B[memory-1][i] ^= v
+		}
+	}
+
+	var block [1024]byte
+	for i, v := range B[memory-1] {
+		binary.LittleEndian.PutUint64(block[i*8:], v)
+	}
+
+	key := make([]byte, keyLen)
+	argon2Hash(key, block[:])
+	return key
+}
+
+// argon2Compress is the compression function G of in1 and in2, it is
+// xored into out rather than written over it when xor is set.
+func argon2Compress(out, in1, in2 *argon2Block, xor bool) {
+	var t argon2Block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+
+	for i := 0; i < argon2BlockLength; i += 16 {
+		blamka(&t[i+0], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+	for i := 0; i < argon2BlockLength/8; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+
+	for i := range t {
+		if xor {
+			out[i] ^= in1[i] ^ in2[i] ^ t[i]
+		} else {
+			out[i] = in1[i] ^ in2[i] ^ t[i]
+		}
+	}
+}
+
+// blamka is the permutation P, the BLAKE2b round with multiplications.
+func blamka(t00, t01, t02, t03, t04, t05, t06, t07, t08, t09, t10, t11, t12, t13, t14, t15 *uint64) {
+	blamkaG(t00, t04, t08, t12)
+	blamkaG(t01, t05, t09, t13)
+	blamkaG(t02, t06, t10, t14)
+	blamkaG(t03, t07, t11, t15)
+
+	blamkaG(t00, t05, t10, t15)
+	blamkaG(t01, t06, t11, t12)
+	blamkaG(t02, t07, t08, t13)
+	blamkaG(t03, t04, t09, t14)
+}
+
+func blamkaG(a, b, c, d *uint64) {
+	mul := func(x, y uint64) uint64 { return 2 * uint64(uint32(x)) * uint64(uint32(y)) }
+
+	*a += *b + mul(*a, *b)
+	*d = bits.RotateLeft64(*d^*a, -32)
+	*c += *d + mul(*c, *d)
+	*b = bits.RotateLeft64(*b^*c, -24)
+	*a += *b + mul(*a, *b)
+	*d = bits.RotateLeft64(*d^*a, -16)
+	*c += *d + mul(*c, *d)
+	*b = bits.RotateLeft64(*b^*c, -63)
+}
+
+// argon2Hash is the variable length hash H' of Argon2.
+func argon2Hash(out []byte, in []byte) {
+	var b2 hash.Hash
+	if n := len(out); n < blake2b.Size {
+		b2, _ = blake2b.New(n, nil)
+	} else {
+		b2, _ = blake2b.New512(nil)
+	}
+
+	var buffer [blake2b.Size]byte
+	binary.LittleEndian.PutUint32(buffer[:4], uint32(len(out)))
+	b2.Write(buffer[:4])
+	b2.Write(in)
+
+	if len(out) <= blake2b.Size {
+		b2.Sum(out[:0])
+		return
+	}
+
+	outLen := len(out)
+	b2.Sum(buffer[:0])
+	b2.Reset()
+	copy(out, buffer[:32])
+	out = out[32:]
+	for len(out) > blake2b.Size {
+		b2.Write(buffer[:])
+		b2.Sum(buffer[:0])
+		copy(out, buffer[:32])
+		out = out[32:]
+		b2.Reset()
+	}
+
+	if outLen%blake2b.Size > 0 {
+		r := ((outLen + 31) / 32) - 2
+		b2, _ = blake2b.New(outLen-32*r, nil)
+	}
+	b2.Write(buffer[:])
+	b2.Sum(out[:0])
+}
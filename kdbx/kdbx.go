@@ -0,0 +1,580 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kdbx reads and writes KeePass KDBX 4 databases, the format of
+// KeePass 2 and KeePassXC.
+//
+// A database is encrypted with AES-256 or ChaCha20 under a key derived
+// from a password, a key file or both with Argon2d, Argon2id or AES-KDF.
+// Passwords and other protected values are encrypted once more inside
+// the database with ChaCha20 (or Salsa20 when reading). Groups, entries,
+// their strings and history are read, other data like attachments, icons
+// and auto-type settings is not kept, so databases are meant to be read
+// into pk or written from it rather than edited in place.
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/hackaio/pk/pkg/errors"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	signature1 = 0x9aa2d903
+	signature2 = 0xb54bfb67
+
+	majorVersion = 4
+	minorVersion = 0
+
+	// Ext is the extension of KeePass databases.
+	Ext = ".kdbx"
+)
+
+// outer header fields
+const (
+	headerEnd         = 0
+	headerCipher      = 2
+	headerCompression = 3
+	headerMasterSeed  = 4
+	headerIV          = 7
+	headerKDF         = 11
+	headerPublicData  = 12
+)
+
+// inner header fields
+const (
+	innerEnd       = 0
+	innerStreamID  = 1
+	innerStreamKey = 2
+	innerBinary    = 3
+)
+
+// blockSize is the size of the HMAC blocks written.
+const blockSize = 1 << 20
+
+// Ciphers the database can be encrypted with.
+const (
+	CipherAES      = "aes256"
+	CipherChaCha20 = "chacha20"
+)
+
+// Key derivation functions.
+const (
+	KDFArgon2d  = "argon2d"
+	KDFArgon2id = "argon2id"
+	KDFAES      = "aes"
+)
+
+var (
+	ErrNotKDBX         = errors.New("not a kdbx database")
+	ErrUnsupported     = errors.New("unsupported kdbx database")
+	ErrCorrupt         = errors.New("corrupt kdbx database")
+	ErrInvalidKey      = errors.New("invalid kdbx password or key file")
+	ErrNoKey           = errors.New("a password or a key file is needed")
+	ErrProtectedValues = errors.New("protected values are encrypted, export the database as plain xml")
+)
+
+// UUID identifies groups, entries, ciphers and key derivation functions.
+type UUID [16]byte
+
+var (
+	uuidAES         = UUID{0x31, 0xc1, 0xf2, 0xe6, 0xbf, 0x71, 0x43, 0x50, 0xbe, 0x58, 0x05, 0x21, 0x6a, 0xfc, 0x5a, 0xff}
+	uuidChaCha20    = UUID{0xd6, 0x03, 0x8a, 0x2b, 0x8b, 0x6f, 0x4c, 0xb5, 0xa5, 0x24, 0x33, 0x9a, 0x31, 0xdb, 0xb5, 0x9a}
+	uuidArgon2d     = UUID{0xef, 0x63, 0x6d, 0xdf, 0x8c, 0x29, 0x44, 0x4b, 0x91, 0xf7, 0xa9, 0xa4, 0x03, 0xe3, 0x0a, 0x0c}
+	uuidArgon2id    = UUID{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+	uuidAESKDF      = UUID{0xc9, 0xd9, 0xf3, 0x9a, 0x62, 0x8a, 0x44, 0x60, 0xbf, 0x74, 0x0d, 0x08, 0xc1, 0x8a, 0x4f, 0xea}
+	uuidAESKDFKDBX4 = UUID{0x7c, 0x02, 0xbb, 0x82, 0x79, 0xa7, 0x4a, 0xc0, 0x92, 0x7d, 0x11, 0x4a, 0x00, 0x64, 0x82, 0x38}
+)
+
+// NewUUID returns a random UUID.
+func NewUUID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// KDF says how the key of a database is derived.
+type KDF struct {
+	Type string
+
+	// Iterations are the passes of Argon2 or the rounds of AES-KDF.
+	Iterations uint64
+
+	// Memory is the memory Argon2 uses, in bytes.
+	Memory uint64
+
+	// Parallelism is the number of Argon2 lanes.
+	Parallelism uint32
+}
+
+// DefaultKDF is used by databases without a KDF.
+var DefaultKDF = KDF{Type: KDFArgon2id, Iterations: 4, Memory: 64 << 20, Parallelism: 2}
+
+// DefaultCipher is used by databases without a cipher.
+var DefaultCipher = CipherAES
+
+// Key unlocks a database, with a password, the contents of a key file or
+// both.
+type Key struct {
+	Password string
+	KeyFile  []byte
+}
+
+// composite hashes the parts of k the way KeePass does.
+func (k Key) composite() ([]byte, error) {
+	if k.Password == "" && k.KeyFile == nil {
+		return nil, ErrNoKey
+	}
+
+	h := sha256.New()
+	if k.Password != "" {
+		p := sha256.Sum256([]byte(k.Password))
+		h.Write(p[:])
+	}
+	if k.KeyFile != nil {
+		key, err := keyFileKey(k.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(key)
+	}
+	return h.Sum(nil), nil
+}
+
+// header is the outer header of a database.
+type header struct {
+	cipher      UUID
+	compression uint32
+	masterSeed  []byte
+	iv          []byte
+	kdf         variantDict
+	raw         []byte
+}
+
+// Open decrypts the database read from r with key.
+func Open(r io.Reader, key Key) (*Database, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[len(h.raw):]
+
+	if len(rest) < 64 {
+		return nil, errors.Wrap(ErrCorrupt, errors.New("truncated header"))
+	}
+	if sum := sha256.Sum256(h.raw); !hmac.Equal(sum[:], rest[:32]) {
+		return nil, errors.Wrap(ErrCorrupt, errors.New("header checksum mismatch"))
+	}
+
+	kdf, err := readKDF(h.kdf)
+	if err != nil {
+		return nil, err
+	}
+	transformed, err := transformKey(key, h.kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	hmacKey := hmacBaseKey(h.masterSeed, transformed)
+	mac := hmac.New(sha256.New, blockKey(hmacKey, ^uint64(0)))
+	mac.Write(h.raw)
+	if !hmac.Equal(mac.Sum(nil), rest[32:64]) {
+		return nil, ErrInvalidKey
+	}
+
+	payload, err := readBlocks(rest[64:], hmacKey)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &Database{KDF: kdf}
+
+	encKey := sha256.Sum256(append(append([]byte{}, h.masterSeed...), transformed...))
+	switch h.cipher {
+	case uuidAES:
+		db.Cipher = CipherAES
+		payload, err = decryptAES(encKey[:], h.iv, payload)
+	case uuidChaCha20:
+		db.Cipher = CipherChaCha20
+		payload, err = xorChaCha20(encKey[:], h.iv, payload)
+	default:
+		err = errors.Wrap(ErrUnsupported, errors.New("unknown cipher"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = bytes.NewReader(payload)
+	switch h.compression {
+	case 0:
+	case 1:
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, errors.Wrap(ErrCorrupt, err)
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, errors.Wrap(ErrUnsupported, errors.New("unknown compression"))
+	}
+
+	stream, err := readInnerHeader(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.decodeXML(body, stream); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Write encrypts db with key and writes it to w compressed, with fresh
+// seeds.
+func (db *Database) Write(w io.Writer, key Key) error {
+	kdf := db.KDF
+	if kdf.Type == "" {
+		kdf = DefaultKDF
+	}
+	cipherName := db.Cipher
+	if cipherName == "" {
+		cipherName = DefaultCipher
+	}
+
+	h := header{compression: 1, masterSeed: randomBytes(32)}
+
+	switch cipherName {
+	case CipherAES:
+		h.cipher, h.iv = uuidAES, randomBytes(aes.BlockSize)
+	case CipherChaCha20:
+		h.cipher, h.iv = uuidChaCha20, randomBytes(chacha20.NonceSize)
+	default:
+		return errors.Wrap(ErrUnsupported, errors.New(fmt.Sprintf("unknown cipher %v", cipherName)))
+	}
+
+	var err error
+	if h.kdf, err = writeKDF(kdf); err != nil {
+		return err
+	}
+	h.raw = writeHeader(h)
+
+	transformed, err := transformKey(key, h.kdf)
+	if err != nil {
+		return err
+	}
+	hmacKey := hmacBaseKey(h.masterSeed, transformed)
+
+	// the inner header and the xml, compressed and encrypted
+	var plain bytes.Buffer
+	body := gzip.NewWriter(&plain)
+
+	streamKey := randomBytes(64)
+	writeField(body, innerStreamID, uint32Bytes(streamChaCha20))
+	writeField(body, innerStreamKey, streamKey)
+	writeField(body, innerEnd, nil)
+
+	stream, err := newInnerStream(streamChaCha20, streamKey)
+	if err != nil {
+		return err
+	}
+	if err = db.encodeXML(body, stream); err != nil {
+		return err
+	}
+	if err = body.Close(); err != nil {
+		return err
+	}
+
+	encKey := sha256.Sum256(append(append([]byte{}, h.masterSeed...), transformed...))
+	var payload []byte
+	if h.cipher == uuidAES {
+		payload, err = encryptAES(encKey[:], h.iv, plain.Bytes())
+	} else {
+		payload, err = xorChaCha20(encKey[:], h.iv, plain.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	out.Write(h.raw)
+	sum := sha256.Sum256(h.raw)
+	out.Write(sum[:])
+	mac := hmac.New(sha256.New, blockKey(hmacKey, ^uint64(0)))
+	mac.Write(h.raw)
+	out.Write(mac.Sum(nil))
+	writeBlocks(&out, payload, hmacKey)
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func readHeader(data []byte) (header, error) {
+	var h header
+	if len(data) < 12 || binary.LittleEndian.Uint32(data[0:]) != signature1 ||
+		binary.LittleEndian.Uint32(data[4:]) != signature2 {
+		return h, ErrNotKDBX
+	}
+	if major := binary.LittleEndian.Uint16(data[10:]); major != majorVersion {
+		return h, errors.Wrap(ErrUnsupported, errors.New(fmt.Sprintf("version %d, only version 4 is read", major)))
+	}
+
+	pos := 12
+	for {
+		if pos+5 > len(data) {
+			return h, errors.Wrap(ErrCorrupt, errors.New("truncated header"))
+		}
+		id := data[pos]
+		size := int(binary.LittleEndian.Uint32(data[pos+1:]))
+		pos += 5
+		if size < 0 || pos+size > len(data) {
+			return h, errors.Wrap(ErrCorrupt, errors.New("truncated header"))
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		var err error
+		switch id {
+		case headerEnd:
+			h.raw = data[:pos]
+			if len(h.masterSeed) != 32 || h.kdf == nil || h.iv == nil {
+				return h, errors.Wrap(ErrCorrupt, errors.New("incomplete header"))
+			}
+			return h, nil
+		case headerCipher:
+			if len(value) != 16 {
+				return h, errors.Wrap(ErrCorrupt, errors.New("invalid cipher"))
+			}
+			copy(h.cipher[:], value)
+		case headerCompression:
+			if len(value) != 4 {
+				return h, errors.Wrap(ErrCorrupt, errors.New("invalid compression"))
+			}
+			h.compression = binary.LittleEndian.Uint32(value)
+		case headerMasterSeed:
+			h.masterSeed = value
+		case headerIV:
+			h.iv = value
+		case headerKDF:
+			h.kdf, err = readVariantDict(value)
+		case headerPublicData:
+			// plugin data, not needed to read the database
+		}
+		if err != nil {
+			return h, err
+		}
+	}
+}
+
+func writeHeader(h header) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(signature1))
+	binary.Write(&b, binary.LittleEndian, uint32(signature2))
+	binary.Write(&b, binary.LittleEndian, uint16(minorVersion))
+	binary.Write(&b, binary.LittleEndian, uint16(majorVersion))
+
+	writeField(&b, headerCipher, h.cipher[:])
+	writeField(&b, headerCompression, uint32Bytes(h.compression))
+	writeField(&b, headerMasterSeed, h.masterSeed)
+	writeField(&b, headerIV, h.iv)
+	writeField(&b, headerKDF, h.kdf.bytes())
+	writeField(&b, headerEnd, []byte("\r\n\r\n"))
+	return b.Bytes()
+}
+
+// writeField writes a header field, a one byte id and a four byte size.
+func writeField(w io.Writer, id byte, value []byte) {
+	w.Write([]byte{id})
+	w.Write(uint32Bytes(uint32(len(value))))
+	w.Write(value)
+}
+
+// readInnerHeader reads the inner header from r and returns the stream
+// protected values are encrypted with.
+func readInnerHeader(r io.Reader) (cipher.Stream, error) {
+	var id uint32
+	var key []byte
+
+	for {
+		var head [5]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, errors.Wrap(ErrCorrupt, err)
+		}
+		size := binary.LittleEndian.Uint32(head[1:])
+		if size > 1<<30 {
+			return nil, errors.Wrap(ErrCorrupt, errors.New("invalid inner header"))
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, errors.Wrap(ErrCorrupt, err)
+		}
+
+		switch head[0] {
+		case innerEnd:
+			return newInnerStream(id, key)
+		case innerStreamID:
+			if len(value) != 4 {
+				return nil, errors.Wrap(ErrCorrupt, errors.New("invalid inner stream"))
+			}
+			id = binary.LittleEndian.Uint32(value)
+		case innerStreamKey:
+			key = value
+		case innerBinary:
+			// attachments are not kept
+		}
+	}
+}
+
+func readBlocks(data, hmacKey []byte) ([]byte, error) {
+	var payload bytes.Buffer
+	for index := uint64(0); ; index++ {
+		if len(data) < 36 {
+			return nil, errors.Wrap(ErrCorrupt, errors.New("truncated block"))
+		}
+		sum, size := data[:32], binary.LittleEndian.Uint32(data[32:36])
+		if uint64(size) > uint64(len(data)-36) {
+			return nil, errors.Wrap(ErrCorrupt, errors.New("truncated block"))
+		}
+		block := data[36 : 36+size]
+		data = data[36+size:]
+
+		if !hmac.Equal(sum, blockMAC(hmacKey, index, block)) {
+			return nil, errors.Wrap(ErrCorrupt, errors.New(fmt.Sprintf("block %d checksum mismatch", index)))
+		}
+		if size == 0 {
+			return payload.Bytes(), nil
+		}
+		payload.Write(block)
+	}
+}
+
+func writeBlocks(w io.Writer, payload, hmacKey []byte) {
+	for index := uint64(0); ; index++ {
+		n := len(payload)
+		if n > blockSize {
+			n = blockSize
+		}
+		block := payload[:n]
+		payload = payload[n:]
+
+		w.Write(blockMAC(hmacKey, index, block))
+		w.Write(uint32Bytes(uint32(n)))
+		w.Write(block)
+
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func hmacBaseKey(masterSeed, transformed []byte) []byte {
+	h := sha512.New()
+	h.Write(masterSeed)
+	h.Write(transformed)
+	h.Write([]byte{1})
+	return h.Sum(nil)
+}
+
+func blockKey(hmacKey []byte, index uint64) []byte {
+	h := sha512.New()
+	binary.Write(h, binary.LittleEndian, index)
+	h.Write(hmacKey)
+	return h.Sum(nil)
+}
+
+func blockMAC(hmacKey []byte, index uint64, block []byte) []byte {
+	mac := hmac.New(sha256.New, blockKey(hmacKey, index))
+	binary.Write(mac, binary.LittleEndian, index)
+	binary.Write(mac, binary.LittleEndian, uint32(len(block)))
+	mac.Write(block)
+	return mac.Sum(nil)
+}
+
+func decryptAES(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.Wrap(ErrCorrupt, errors.New("invalid aes payload"))
+	}
+
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errors.Wrap(ErrCorrupt, errors.New("invalid aes padding"))
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errors.Wrap(ErrCorrupt, errors.New("invalid aes padding"))
+		}
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+func encryptAES(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	plain := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, plain)
+	return out, nil
+}
+
+func xorChaCha20(key, nonce, data []byte) ([]byte, error) {
+	c, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, errors.Wrap(ErrCorrupt, err)
+	}
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out, nil
+}
+
+func uint32Bytes(v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return b[:]
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kdbx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/otp"
+	"github.com/hackaio/pk/pkg/errors"
+	"golang.org/x/crypto/salsa20"
+)
+
+// testKDF keeps the tests fast, real databases use DefaultKDF
+var testKDF = KDF{Type: KDFArgon2d, Iterations: 2, Memory: 1 << 20, Parallelism: 2}
+
+func TestArgon2(t *testing.T) {
+	// the test vectors of RFC 9106
+	p := argon2Params{
+		version:     argon2Version13,
+		iterations:  3,
+		memory:      32,
+		parallelism: 4,
+		secret:      bytes.Repeat([]byte{3}, 8),
+		data:        bytes.Repeat([]byte{4}, 12),
+	}
+	password, salt := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+
+	tests := []struct {
+		mode int
+		want string
+	}{
+		{mode: argon2d, want: "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{mode: argon2id, want: "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+
+	for _, tt := range tests {
+		p.mode = tt.mode
+		if got := hex.EncodeToString(argon2Key(password, salt, p, 32)); got != tt.want {
+			t.Errorf("argon2Key(mode %d) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestSalsaStream(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	data := bytes.Repeat([]byte("protected"), 30)
+
+	want := make([]byte, len(data))
+	k := [32]byte{}
+	copy(k[:], sha256Sum(key))
+	salsa20.XORKeyStream(want, data, salsaNonce[:], &k)
+
+	stream, err := newInnerStream(streamSalsa20, key)
+	if err != nil {
+		t.Fatalf("newInnerStream() error = %v", err)
+	}
+
+	// protected values are decrypted a few bytes at a time
+	got := make([]byte, len(data))
+	for i := 0; i < len(data); i += 13 {
+		end := i + 13
+		if end > len(data) {
+			end = len(data)
+		}
+		stream.XORKeyStream(got[i:end], data[i:end])
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("salsa stream does not match salsa20")
+	}
+}
+
+func testDatabase() *Database {
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	id := NewUUID()
+	return &Database{
+		Name: "team",
+		Root: Group{
+			UUID: NewUUID(),
+			Name: "team",
+			Entries: []Entry{{
+				UUID:     id,
+				Tags:     []string{"dev", "work"},
+				Created:  created,
+				Modified: created.Add(time.Hour),
+				Strings: []String{
+					{Key: KeyTitle, Value: "GitHub"},
+					{Key: KeyUserName, Value: "alice"},
+					{Key: KeyPassword, Value: "pw1 <&>", Protected: true},
+					{Key: KeyNotes, Value: "two\nlines"},
+				},
+				History: []Entry{{
+					UUID:     id,
+					Created:  created,
+					Modified: created,
+					Strings:  []String{{Key: KeyTitle, Value: "GitHub"}, {Key: KeyPassword, Value: "old", Protected: true}},
+				}},
+			}},
+			Groups: []Group{{
+				UUID: NewUUID(),
+				Name: "Mail",
+				Entries: []Entry{{
+					UUID:     NewUUID(),
+					Created:  created,
+					Modified: created,
+					Strings:  []String{{Key: KeyTitle, Value: "Gmail"}, {Key: KeyPassword, Value: "pw2", Protected: true}},
+				}},
+			}},
+		},
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		cipher string
+		kdf    KDF
+		key    Key
+	}{
+		{name: "argon2d aes", cipher: CipherAES, kdf: testKDF, key: Key{Password: "secret"}},
+		{name: "argon2id chacha20", cipher: CipherChaCha20, kdf: KDF{Type: KDFArgon2id, Iterations: 1, Memory: 1 << 20, Parallelism: 1}, key: Key{Password: "secret"}},
+		{name: "aes-kdf", cipher: CipherAES, kdf: KDF{Type: KDFAES, Iterations: 1000}, key: Key{Password: "secret"}},
+		{name: "key file", cipher: CipherAES, kdf: testKDF, key: Key{Password: "secret", KeyFile: []byte("any file at all")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDatabase()
+			db.Cipher, db.KDF = tt.cipher, tt.kdf
+
+			var buf bytes.Buffer
+			if err := db.Write(&buf, tt.key); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			got, err := Open(bytes.NewReader(buf.Bytes()), tt.key)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+
+			if got.Cipher != tt.cipher || got.KDF != tt.kdf {
+				t.Errorf("Open() cipher, kdf = %v, %+v, want %v, %+v", got.Cipher, got.KDF, tt.cipher, tt.kdf)
+			}
+			if got.Name != db.Name || !reflect.DeepEqual(got.Root, db.Root) {
+				t.Errorf("Open() root = %+v, want %+v", got.Root, db.Root)
+			}
+
+			wrong := tt.key
+			wrong.Password = "wrong"
+			if _, err = Open(bytes.NewReader(buf.Bytes()), wrong); !errors.Contains(err, ErrInvalidKey) {
+				t.Errorf("Open() with a wrong password error = %v, want %v", err, ErrInvalidKey)
+			}
+
+			data := buf.Bytes()
+			data[len(data)-50] ^= 1
+			if _, err = Open(bytes.NewReader(data), tt.key); !errors.Contains(err, ErrCorrupt) {
+				t.Errorf("Open() of a changed database error = %v, want %v", err, ErrCorrupt)
+			}
+		})
+	}
+
+	if _, err := Open(strings.NewReader("not a database"), Key{Password: "secret"}); !errors.Contains(err, ErrNotKDBX) {
+		t.Errorf("Open() error = %v, want %v", err, ErrNotKDBX)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	raw := bytes.Repeat([]byte{9}, 32)
+	hexKey := hex.EncodeToString(raw)
+
+	tests := []struct {
+		name string
+		data string
+		want []byte
+	}{
+		{name: "raw", data: string(raw), want: raw},
+		{name: "hex", data: hexKey, want: raw},
+		{name: "xml v1", data: `<KeyFile><Meta><Version>1.00</Version></Meta><Key><Data>CQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQk=</Data></Key></KeyFile>`, want: raw},
+		{name: "xml v2", data: `<KeyFile><Meta><Version>2.0</Version></Meta><Key><Data Hash="` + hex.EncodeToString(sha256Sum(raw)[:4]) + `">` + strings.ToUpper(hexKey) + `</Data></Key></KeyFile>`, want: raw},
+		{name: "other", data: "hello", want: sha256Sum([]byte("hello"))},
+	}
+
+	for _, tt := range tests {
+		got, err := keyFileKey([]byte(tt.data))
+		if err != nil {
+			t.Errorf("keyFileKey(%v) error = %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("keyFileKey(%v) = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+
+	bad := `<KeyFile><Meta><Version>2.0</Version></Meta><Key><Data Hash="00000000">` + hexKey + `</Data></Key></KeyFile>`
+	if _, err := keyFileKey([]byte(bad)); !errors.Contains(err, ErrInvalidKey) {
+		t.Errorf("keyFileKey() error = %v, want %v", err, ErrInvalidKey)
+	}
+}
+
+func TestReaderWriter(t *testing.T) {
+	defer func(kdf KDF) { DefaultKDF = kdf }(DefaultKDF)
+	DefaultKDF = testKDF
+
+	accounts := []pk.Account{
+		{Name: "Gmail", UserName: "bob", Password: "pw2", Created: "2021-03-04T05:06:07Z", Folder: "Work"},
+		{
+			Name: "GitHub", UserName: "alice", Email: "alice@example.com", Password: "pw1",
+			Created: "2021-03-04T05:06:07Z", URLs: []string{"https://github.com", "https://gist.github.com"},
+			Notes: "main", Tags: []string{"dev", "work"}, Folder: "Work/Dev",
+			Fields: []pk.Field{{Name: "pin", Value: "1234", Secret: true}, {Name: "plan", Value: "pro"}},
+			OTP:    "JBSWY3DPEHPK3PXP",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "kdbx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := Key{Password: "secret"}
+	req := pk.FileWriterReq{Accounts: accounts, FileName: "team", FileExt: Ext, FileDir: dir}
+	if err = NewWriter(key).Write(context.Background(), req); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got, err := NewReader(key).Read(context.Background(), filepath.Join(dir, "team"+Ext))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	// bare seeds are written as the URIs KeePassXC reads
+	seed, _ := otp.NewKey("JBSWY3DPEHPK3PXP")
+	seed.Issuer, seed.Account = "GitHub", "alice"
+
+	want := append([]pk.Account{}, accounts...)
+	want[1].OTP = seed.URI()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+}
+
+func sha256Sum(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
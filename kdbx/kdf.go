@@ -0,0 +1,373 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kdbx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/hackaio/pk/pkg/errors"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+// variant dictionary value types
+const (
+	variantEnd    = 0x00
+	variantUint32 = 0x04
+	variantUint64 = 0x05
+	variantBool   = 0x08
+	variantInt32  = 0x0c
+	variantInt64  = 0x0d
+	variantString = 0x18
+	variantBytes  = 0x42
+
+	variantVersion = 0x0100
+)
+
+// kdf parameters
+const (
+	kdfUUID        = "$UUID"
+	kdfSalt        = "S"
+	kdfParallelism = "P"
+	kdfMemory      = "M"
+	kdfIterations  = "I"
+	kdfVersion     = "V"
+	kdfSecret      = "K"
+	kdfData        = "A"
+	kdfSeed        = "S"
+	kdfRounds      = "R"
+)
+
+// inner streams
+const (
+	streamSalsa20  = 2
+	streamChaCha20 = 3
+)
+
+// salsaNonce is the fixed nonce of the Salsa20 inner stream.
+var salsaNonce = [8]byte{0xe8, 0x30, 0x09, 0x4b, 0x97, 0x20, 0x5d, 0x2a}
+
+// variant is an item of a variant dictionary, the map of typed values
+// KDBX 4 keeps the KDF parameters in.
+type variant struct {
+	key   string
+	typ   byte
+	value []byte
+}
+
+type variantDict []variant
+
+func readVariantDict(data []byte) (variantDict, error) {
+	invalid := errors.Wrap(ErrCorrupt, errors.New("invalid kdf parameters"))
+
+	if len(data) < 2 || binary.LittleEndian.Uint16(data)&0xff00 != variantVersion&0xff00 {
+		return nil, invalid
+	}
+	data = data[2:]
+
+	var d variantDict
+	for {
+		if len(data) < 1 {
+			return nil, invalid
+		}
+		typ := data[0]
+		if typ == variantEnd {
+			return d, nil
+		}
+
+		var parts [2][]byte
+		data = data[1:]
+		for i := range parts {
+			if len(data) < 4 {
+				return nil, invalid
+			}
+			n := binary.LittleEndian.Uint32(data)
+			if uint64(n) > uint64(len(data)-4) {
+				return nil, invalid
+			}
+			parts[i] = data[4 : 4+n]
+			data = data[4+n:]
+		}
+		d = append(d, variant{key: string(parts[0]), typ: typ, value: parts[1]})
+	}
+}
+
+func (d variantDict) bytes() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint16(variantVersion))
+	for _, v := range d {
+		b.WriteByte(v.typ)
+		binary.Write(&b, binary.LittleEndian, uint32(len(v.key)))
+		b.WriteString(v.key)
+		binary.Write(&b, binary.LittleEndian, uint32(len(v.value)))
+		b.Write(v.value)
+	}
+	b.WriteByte(variantEnd)
+	return b.Bytes()
+}
+
+func (d variantDict) get(key string, typ byte) ([]byte, bool) {
+	for _, v := range d {
+		if v.key == key && v.typ == typ {
+			return v.value, true
+		}
+	}
+	return nil, false
+}
+
+func (d variantDict) uint32(key string) (uint32, bool) {
+	v, ok := d.get(key, variantUint32)
+	if !ok || len(v) != 4 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(v), true
+}
+
+func (d variantDict) uint64(key string) (uint64, bool) {
+	v, ok := d.get(key, variantUint64)
+	if !ok || len(v) != 8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(v), true
+}
+
+func (d variantDict) put(key string, typ byte, value []byte) variantDict {
+	return append(d, variant{key: key, typ: typ, value: value})
+}
+
+func (d variantDict) putUint64(key string, value uint64) variantDict {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], value)
+	return d.put(key, variantUint64, b[:])
+}
+
+// readKDF returns the settings of the kdf parameters.
+func readKDF(d variantDict) (KDF, error) {
+	id, _ := d.get(kdfUUID, variantBytes)
+
+	var kdf KDF
+	switch {
+	case bytes.Equal(id, uuidArgon2d[:]), bytes.Equal(id, uuidArgon2id[:]):
+		kdf.Type = KDFArgon2d
+		if bytes.Equal(id, uuidArgon2id[:]) {
+			kdf.Type = KDFArgon2id
+		}
+		kdf.Iterations, _ = d.uint64(kdfIterations)
+		kdf.Memory, _ = d.uint64(kdfMemory)
+		kdf.Parallelism, _ = d.uint32(kdfParallelism)
+	case bytes.Equal(id, uuidAESKDF[:]), bytes.Equal(id, uuidAESKDFKDBX4[:]):
+		kdf.Type = KDFAES
+		kdf.Iterations, _ = d.uint64(kdfRounds)
+	default:
+		return kdf, errors.Wrap(ErrUnsupported, errors.New("unknown key derivation function"))
+	}
+	return kdf, nil
+}
+
+// writeKDF returns the kdf parameters of kdf with a new salt.
+func writeKDF(kdf KDF) (variantDict, error) {
+	var d variantDict
+	switch kdf.Type {
+	case KDFArgon2d, KDFArgon2id:
+		id := uuidArgon2d
+		if kdf.Type == KDFArgon2id {
+			id = uuidArgon2id
+		}
+		if kdf.Iterations < 1 || kdf.Parallelism < 1 || kdf.Memory < 8<<10 {
+			return nil, errors.Wrap(ErrUnsupported, errors.New("invalid argon2 parameters"))
+		}
+		d = d.put(kdfUUID, variantBytes, id[:])
+		d = d.put(kdfSalt, variantBytes, randomBytes(32))
+		d = d.put(kdfParallelism, variantUint32, uint32Bytes(kdf.Parallelism))
+		d = d.putUint64(kdfMemory, kdf.Memory)
+		d = d.putUint64(kdfIterations, kdf.Iterations)
+		d = d.put(kdfVersion, variantUint32, uint32Bytes(argon2Version13))
+	case KDFAES:
+		if kdf.Iterations < 1 {
+			return nil, errors.Wrap(ErrUnsupported, errors.New("invalid aes-kdf rounds"))
+		}
+		d = d.put(kdfUUID, variantBytes, uuidAESKDF[:])
+		d = d.put(kdfSeed, variantBytes, randomBytes(32))
+		d = d.putUint64(kdfRounds, kdf.Iterations)
+	default:
+		return nil, errors.Wrap(ErrUnsupported, errors.New(fmt.Sprintf("unknown key derivation function %v", kdf.Type)))
+	}
+	return d, nil
+}
+
+// transformKey derives the key of a database from key as the kdf
+// parameters d say.
+func transformKey(key Key, d variantDict) ([]byte, error) {
+	composite, err := key.composite()
+	if err != nil {
+		return nil, err
+	}
+
+	kdf, err := readKDF(d)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, ok := d.get(kdfSalt, variantBytes)
+	if !ok || len(salt) == 0 {
+		return nil, errors.Wrap(ErrCorrupt, errors.New("no kdf salt"))
+	}
+
+	if kdf.Type == KDFAES {
+		if len(salt) != 32 {
+			return nil, errors.Wrap(ErrCorrupt, errors.New("invalid aes-kdf seed"))
+		}
+		block, _ := aes.NewCipher(salt)
+		for i := uint64(0); i < kdf.Iterations; i++ {
+			block.Encrypt(composite[:16], composite[:16])
+			block.Encrypt(composite[16:], composite[16:])
+		}
+		sum := sha256.Sum256(composite)
+		return sum[:], nil
+	}
+
+	version, ok := d.uint32(kdfVersion)
+	if !ok {
+		version = argon2Version13
+	}
+	if version != argon2Version10 && version != argon2Version13 {
+		return nil, errors.Wrap(ErrUnsupported, errors.New(fmt.Sprintf("argon2 version %#x", version)))
+	}
+
+	// the memory of argon2 is counted in KiB, large values are refused
+	// rather than allocated
+	if kdf.Iterations < 1 || kdf.Iterations > 1<<32-1 || kdf.Parallelism < 1 || kdf.Parallelism > 1<<24 ||
+		kdf.Memory < 8<<10 || kdf.Memory > 4<<30 {
+		return nil, errors.Wrap(ErrUnsupported, errors.New("argon2 parameters out of range"))
+	}
+
+	p := argon2Params{
+		mode:        argon2d,
+		version:     version,
+		iterations:  uint32(kdf.Iterations),
+		memory:      uint32(kdf.Memory / 1024),
+		parallelism: kdf.Parallelism,
+	}
+	if kdf.Type == KDFArgon2id {
+		p.mode = argon2id
+	}
+	p.secret, _ = d.get(kdfSecret, variantBytes)
+	p.data, _ = d.get(kdfData, variantBytes)
+
+	return argon2Key(composite, salt, p, 32), nil
+}
+
+// keyFile is the XML key file of KeePass, versions 1.0 and 2.0.
+type keyFile struct {
+	XMLName xml.Name `xml:"KeyFile"`
+	Meta    struct {
+		Version string `xml:"Version"`
+	} `xml:"Meta"`
+	Key struct {
+		Data struct {
+			Hash string `xml:"Hash,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"Key"`
+}
+
+// keyFileKey returns the key held by a key file: the data of XML key
+// files, 32 bytes or 64 hex digits as they are, the SHA-256 of anything
+// else.
+func keyFileKey(data []byte) ([]byte, error) {
+	var kf keyFile
+	if xml.Unmarshal(data, &kf) == nil && kf.Key.Data.Text != "" {
+		text := strings.Join(strings.Fields(kf.Key.Data.Text), "")
+
+		if strings.HasPrefix(kf.Meta.Version, "2.") {
+			key, err := hex.DecodeString(text)
+			if err != nil || len(key) != 32 {
+				return nil, errors.Wrap(ErrInvalidKey, errors.New("invalid key file data"))
+			}
+			if kf.Key.Data.Hash != "" {
+				sum := sha256.Sum256(key)
+				if !strings.EqualFold(hex.EncodeToString(sum[:4]), kf.Key.Data.Hash) {
+					return nil, errors.Wrap(ErrInvalidKey, errors.New("key file checksum mismatch"))
+				}
+			}
+			return key, nil
+		}
+
+		key, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidKey, errors.New("invalid key file data"))
+		}
+		return key, nil
+	}
+
+	if len(data) == 32 {
+		return data, nil
+	}
+	if len(data) == 64 {
+		if key, err := hex.DecodeString(string(data)); err == nil {
+			return key, nil
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// newInnerStream returns the stream protected values are encrypted with.
+func newInnerStream(id uint32, key []byte) (cipher.Stream, error) {
+	switch id {
+	case streamChaCha20:
+		h := sha512.Sum512(key)
+		return chacha20.NewUnauthenticatedCipher(h[:32], h[32:44])
+	case streamSalsa20:
+		return &salsaStream{key: sha256.Sum256(key)}, nil
+	default:
+		return nil, errors.Wrap(ErrUnsupported, errors.New(fmt.Sprintf("inner stream %d", id)))
+	}
+}
+
+// salsaStream is the Salsa20 key stream of KDBX 3, which some KDBX 4
+// databases still use.
+type salsaStream struct {
+	key     [32]byte
+	counter uint64
+	block   [64]byte
+	used    int
+}
+
+func (s *salsaStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == 0 || s.used == len(s.block) {
+			var in [16]byte
+			copy(in[:8], salsaNonce[:])
+			binary.LittleEndian.PutUint64(in[8:], s.counter)
+			var zero [64]byte
+			salsa.XORKeyStream(s.block[:], zero[:], &in, &s.key)
+			s.counter++
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.block[s.used]
+		s.used++
+	}
+}
@@ -0,0 +1,405 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kdbx
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// ErrReadOnly is returned by the methods of the store that would change
+// the database.
+var ErrReadOnly = errors.New("the kdbx database is read only, change it with keepass")
+
+var _ pk.PasswordStore = (*store)(nil)
+
+// record is an entry of the database as the store serves it.
+type record struct {
+	account  pk.DBAccount
+	versions []pk.DBVersion
+	deleted  string
+}
+
+type store struct {
+	owners   pk.PasswordStore
+	accounts []record
+	trash    []record
+}
+
+// NewStore returns a read only pk.PasswordStore of the entries of db.
+// They are encrypted with es up front, like the accounts of any other
+// store. The owner of the keeper and its two factor settings are kept by
+// owners, an entry of the recycle bin is in the trash.
+//
+// Entries with the name and username of an earlier one are served as
+// "name (2)", "name (3)" and so on.
+func NewStore(db *Database, es pk.EncoderSigner, owners pk.PasswordStore) (pk.PasswordStore, error) {
+	s := &store{owners: owners}
+	seen := map[[2]string]int{}
+
+	var err error
+	db.Walk(func(e Entry, folder string, recycled bool) {
+		if err != nil {
+			return
+		}
+
+		acc := e.Account(folder)
+		if acc.Name == "" {
+			acc.Name = e.Get(KeyURL)
+		}
+
+		id := [2]string{acc.Name, acc.UserName}
+		if seen[id]++; seen[id] > 1 {
+			acc.Name = fmt.Sprintf("%v (%d)", acc.Name, seen[id])
+		}
+
+		var r record
+		if r, err = newRecord(e, acc, es); err != nil {
+			err = errors.Wrap(errors.New(fmt.Sprintf("could not read entry %v", acc.Name)), err)
+			return
+		}
+
+		if recycled {
+			r.deleted = e.Modified.Format(time.RFC3339)
+			s.trash = append(s.trash, r)
+			return
+		}
+		s.accounts = append(s.accounts, r)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newRecord(e Entry, acc pk.Account, es pk.EncoderSigner) (record, error) {
+	var r record
+
+	var err error
+	if r.account, err = pk.EncodeAccount(noHash{}, es, acc); err != nil {
+		return r, err
+	}
+
+	for i, h := range e.History {
+		old := h.Account(acc.Folder)
+		old.Name, old.UserName = acc.Name, acc.UserName
+
+		v := pk.DBVersion{Version: i + 1}
+		if v.Account, err = pk.EncodeAccount(noHash{}, es, old); err != nil {
+			return r, err
+		}
+
+		// a version is replaced when the next one is made
+		replaced := e.Modified
+		if i+1 < len(e.History) {
+			replaced = e.History[i+1].Modified
+		}
+		if !replaced.IsZero() {
+			v.Replaced = replaced.Format(time.RFC3339)
+		}
+
+		r.versions = append(r.versions, v)
+	}
+
+	return r, nil
+}
+
+// noHash leaves the hash of accounts out, it is only compared when
+// accounts are changed, which the store does not allow, and bcrypt would
+// make opening large databases slow.
+type noHash struct{}
+
+func (noHash) Hash(string) (string, error) {
+	return "", nil
+}
+
+func (noHash) Compare(string, string) error {
+	return ErrReadOnly
+}
+
+func (s *store) find(name, username string) (record, bool) {
+	for _, r := range s.accounts {
+		if r.account.Name == name && r.account.UserName == username {
+			return r, true
+		}
+	}
+	return record{}, false
+}
+
+func (s *store) CheckAccount(ctx context.Context, name, username string) (err error) {
+	if _, ok := s.find(name, username); !ok {
+		return pk.ErrNotFound
+	}
+	return nil
+}
+
+func (s *store) AddOwner(ctx context.Context, account pk.Account) (err error) {
+	return s.owners.AddOwner(ctx, account)
+}
+
+func (s *store) Add(ctx context.Context, account pk.DBAccount) (err error) {
+	return ErrReadOnly
+}
+
+func (s *store) Get(ctx context.Context, name, username string) (account pk.DBAccount, err error) {
+	r, ok := s.find(name, username)
+	if !ok {
+		return account, pk.ErrNotFound
+	}
+	return r.account, nil
+}
+
+func (s *store) GetOwner(ctx context.Context, name, username string) (account pk.Account, err error) {
+	return s.owners.GetOwner(ctx, name, username)
+}
+
+func (s *store) GetTwoFactor(ctx context.Context, name, username string) (tf pk.TwoFactor, err error) {
+	return s.owners.GetTwoFactor(ctx, name, username)
+}
+
+func (s *store) SetTwoFactor(ctx context.Context, name, username string, tf pk.TwoFactor) (err error) {
+	return s.owners.SetTwoFactor(ctx, name, username, tf)
+}
+
+func (s *store) Delete(ctx context.Context, name, username string) (err error) {
+	return ErrReadOnly
+}
+
+func (s *store) DeleteAll(ctx context.Context, filter map[string]string) (deleted int, err error) {
+	return 0, ErrReadOnly
+}
+
+func (s *store) Trash(ctx context.Context) (accounts []pk.Trashed, err error) {
+	for _, r := range s.trash {
+		accounts = append(accounts, pk.Trashed{
+			Name:     r.account.Name,
+			UserName: r.account.UserName,
+			Email:    r.account.Email,
+			Created:  r.account.Created,
+			Deleted:  r.deleted,
+		})
+	}
+	return accounts, nil
+}
+
+func (s *store) Restore(ctx context.Context, name, username string) (err error) {
+	return ErrReadOnly
+}
+
+func (s *store) Purge(ctx context.Context, before string) (purged int, err error) {
+	return 0, ErrReadOnly
+}
+
+func (s *store) Update(ctx context.Context, name, username string, account pk.DBAccount) (err error) {
+	return ErrReadOnly
+}
+
+func (s *store) List(ctx context.Context, query pk.Query) (accounts []pk.DBAccount, err error) {
+	if err = query.Validate(); err != nil {
+		return nil, err
+	}
+
+	matchers := map[string]func(string) bool{}
+	for column, pattern := range map[string]string{
+		pk.SortName:     query.Name,
+		pk.SortUserName: query.UserName,
+		pk.SortEmail:    query.Email,
+	} {
+		if pattern != "" {
+			matchers[column] = matcher(pattern)
+		}
+	}
+
+	var records []record
+	for _, r := range s.accounts {
+		if matches(query, r, matchers) {
+			records = append(records, r)
+		}
+	}
+
+	field, desc := query.SortField()
+	key := func(r record) string {
+		switch field {
+		case pk.SortUserName:
+			return r.account.UserName
+		case pk.SortEmail:
+			return r.account.Email
+		case pk.SortCreated:
+			return createdKey(parseCreated(r.account.Created))
+		default:
+			return r.account.Name
+		}
+	}
+
+	// ordered like the keyset pagination of the other stores, by the sort
+	// field, then name and username
+	less := func(a, b [3]string) bool {
+		for i := range a {
+			if a[i] != b[i] {
+				return a[i] < b[i] != desc
+			}
+		}
+		return false
+	}
+	keys := func(r record) [3]string {
+		return [3]string{key(r), r.account.Name, r.account.UserName}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return less(keys(records[i]), keys(records[j]))
+	})
+
+	after, err := query.After()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range records {
+		if after != nil {
+			value := after.Value
+			if field == pk.SortCreated {
+				value = createdKey(parseCreated(value))
+			}
+			if !less([3]string{value, after.Name, after.UserName}, keys(r)) {
+				continue
+			}
+		}
+
+		accounts = append(accounts, r.account)
+		if query.PageSize > 0 && len(accounts) == query.PageSize {
+			break
+		}
+	}
+
+	return accounts, nil
+}
+
+// matches tells whether the account of r is selected by the filters of
+// query, matchers are those of its name, username and email.
+func matches(query pk.Query, r record, matchers map[string]func(string) bool) bool {
+	a := r.account
+	for column, value := range map[string]string{
+		pk.SortName:     a.Name,
+		pk.SortUserName: a.UserName,
+		pk.SortEmail:    a.Email,
+	} {
+		if match, ok := matchers[column]; ok && !match(value) {
+			return false
+		}
+	}
+
+	if query.Tag != "" {
+		tagged := false
+		for _, tag := range a.Tags {
+			tagged = tagged || tag == query.Tag
+		}
+		if !tagged {
+			return false
+		}
+	}
+
+	if folder := strings.Trim(query.Folder, "/"); folder != "" && a.Folder != folder &&
+		!strings.HasPrefix(a.Folder, folder+"/") {
+		return false
+	}
+
+	created := parseCreated(a.Created)
+	if !query.CreatedBefore.IsZero() && !created.Before(query.CreatedBefore) {
+		return false
+	}
+	if !query.CreatedAfter.IsZero() && !created.After(query.CreatedAfter) {
+		return false
+	}
+
+	return true
+}
+
+// parseCreated reads the creation time of an account, accounts without
+// one are taken as created at the unix epoch like the other stores do.
+func parseCreated(created string) time.Time {
+	t, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		return time.Unix(0, 0).UTC()
+	}
+	return t.UTC()
+}
+
+// createdKey is a creation time that sorts as a string.
+func createdKey(t time.Time) string {
+	return t.UTC().Format("20060102150405.000000000")
+}
+
+func (s *store) History(ctx context.Context, name, username string) (versions []pk.DBVersion, err error) {
+	r, ok := s.find(name, username)
+	if !ok {
+		return nil, pk.ErrNotFound
+	}
+
+	// newest first
+	for i := len(r.versions) - 1; i >= 0; i-- {
+		versions = append(versions, r.versions[i])
+	}
+	return versions, nil
+}
+
+func (s *store) GetVersion(ctx context.Context, name, username string, version int) (v pk.DBVersion, err error) {
+	r, ok := s.find(name, username)
+	if !ok {
+		return v, pk.ErrNotFound
+	}
+	if version < 1 || version > len(r.versions) {
+		return v, pk.ErrVersionNotFound
+	}
+	return r.versions[version-1], nil
+}
+
+func (s *store) AddVersion(ctx context.Context, v pk.DBVersion) (err error) {
+	return ErrReadOnly
+}
+
+func (s *store) PruneHistory(ctx context.Context, name, username string, keep int, before string) (removed int, err error) {
+	return 0, ErrReadOnly
+}
+
+// Transaction runs fn on s, there are no changes to group as the store
+// refuses them all.
+func (s *store) Transaction(ctx context.Context, fn func(store pk.PasswordStore) error) (err error) {
+	return fn(s)
+}
+
+// matcher matches values case insensitively, as a glob when pattern
+// holds * or ? and as a substring otherwise.
+func matcher(pattern string) func(string) bool {
+	pattern = strings.ToLower(pattern)
+	if !pk.IsGlob(pattern) {
+		return func(v string) bool {
+			return strings.Contains(strings.ToLower(v), pattern)
+		}
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+	re := regexp.MustCompile("^(?s:" + expr + ")$")
+	return func(v string) bool {
+		return re.MatchString(strings.ToLower(v))
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kdbx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// plainES keeps secrets as they are
+type plainES struct{}
+
+func (plainES) Encode(password string) ([]byte, error) { return []byte(password), nil }
+
+func (plainES) Decode(encoded []byte) (string, error) { return string(encoded), nil }
+
+func (plainES) Sign(string) ([]byte, []byte, error) { return nil, nil, nil }
+
+func (plainES) Verify(string, []byte, []byte) error { return nil }
+
+func TestStore(t *testing.T) {
+	db := FromAccounts("team", []pk.Account{
+		{Name: "GitHub", UserName: "alice", Password: "pw1", Created: "2021-03-04T05:06:07Z", Tags: []string{"dev"}, Folder: "Work/Dev"},
+		{Name: "Gmail", UserName: "bob", Password: "pw2", Created: "2021-01-01T00:00:00Z", Folder: "Mail"},
+		{Name: "GitHub", UserName: "alice", Password: "pw3", Created: "2021-05-01T00:00:00Z"},
+		{Name: "Old", UserName: "carol", Password: "pw4"},
+	})
+
+	// GitHub has an older password, Old is in the recycle bin
+	db.Root.Entries[0].Modified = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	db.Root.Entries[0].History = []Entry{{Strings: []String{{Key: KeyPassword, Value: "old"}}}}
+	db.Root.Groups = append(db.Root.Groups, Group{UUID: NewUUID(), Name: "Recycle Bin", Entries: db.Root.Entries[1:]})
+	db.Root.Entries = db.Root.Entries[:1]
+	db.RecycleBin = db.Root.Groups[len(db.Root.Groups)-1].UUID
+
+	s, err := NewStore(db, plainES{}, nil)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	ctx := context.Background()
+
+	names := func(q pk.Query) []string {
+		accounts, err := s.List(ctx, q)
+		if err != nil {
+			t.Fatalf("List(%+v) error = %v", q, err)
+		}
+		var got []string
+		for _, a := range accounts {
+			got = append(got, a.Name)
+		}
+		return got
+	}
+
+	tests := []struct {
+		query pk.Query
+		want  []string
+	}{
+		{query: pk.Query{}, want: []string{"GitHub", "GitHub (2)", "Gmail"}},
+		{query: pk.Query{Name: "g?t*"}, want: []string{"GitHub", "GitHub (2)"}},
+		{query: pk.Query{Name: "mail"}, want: []string{"Gmail"}},
+		{query: pk.Query{Tag: "dev"}, want: []string{"GitHub (2)"}},
+		{query: pk.Query{Folder: "Work"}, want: []string{"GitHub (2)"}},
+		{query: pk.Query{Sort: "-" + pk.SortCreated}, want: []string{"GitHub", "GitHub (2)", "Gmail"}},
+		{query: pk.Query{CreatedAfter: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)}, want: []string{"GitHub", "GitHub (2)"}},
+	}
+	for _, tt := range tests {
+		if got := names(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%+v) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// the next page starts after the cursor
+	c, _ := json.Marshal(pk.Cursor{Value: "GitHub", Name: "GitHub", UserName: "alice"})
+	next := pk.Query{PageSize: 1, Cursor: base64.RawURLEncoding.EncodeToString(c)}
+	if got := names(next); !reflect.DeepEqual(got, []string{"GitHub (2)"}) {
+		t.Errorf("List() of the next page = %v, want [GitHub (2)]", got)
+	}
+
+	a, err := s.Get(ctx, "GitHub (2)", "alice")
+	if err != nil || string(a.Encoded) != "pw1" || a.Folder != "Work/Dev" {
+		t.Errorf("Get() = %+v, %v, want the entry of Work/Dev", a, err)
+	}
+	if _, err = s.Get(ctx, "Old", "carol"); !errors.Contains(err, pk.ErrNotFound) {
+		t.Errorf("Get() of a recycled entry error = %v, want %v", err, pk.ErrNotFound)
+	}
+
+	versions, err := s.History(ctx, "GitHub", "alice")
+	if err != nil || len(versions) != 1 || string(versions[0].Account.Encoded) != "old" || versions[0].Replaced != "2021-06-01T00:00:00Z" {
+		t.Errorf("History() = %+v, %v, want the old password", versions, err)
+	}
+
+	trash, err := s.Trash(ctx)
+	if err != nil || len(trash) != 1 || trash[0].Name != "Old" {
+		t.Errorf("Trash() = %+v, %v, want Old", trash, err)
+	}
+
+	if err = s.Add(ctx, pk.DBAccount{Name: "new"}); !errors.Contains(err, ErrReadOnly) {
+		t.Errorf("Add() error = %v, want %v", err, ErrReadOnly)
+	}
+}
@@ -0,0 +1,458 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kdbx
+
+import (
+	"crypto/cipher"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// generator is the application a database says it was written by.
+const generator = "pk"
+
+// epochOffset is the number of seconds between 0001-01-01, the epoch of
+// KDBX 4 times, and the unix epoch.
+const epochOffset = 62135596800
+
+// Database is the content of a KeePass database.
+type Database struct {
+	Name string
+
+	// Root is the top group, it is the database itself rather than a
+	// folder.
+	Root Group
+
+	// RecycleBin is the group deleted entries are moved to, the zero UUID
+	// when the database has none.
+	RecycleBin UUID
+
+	// Cipher and KDF are those the database was read with, Write uses
+	// DefaultCipher and DefaultKDF when they are not set.
+	Cipher string
+	KDF    KDF
+}
+
+// Group holds entries and more groups.
+type Group struct {
+	UUID    UUID
+	Name    string
+	Entries []Entry
+	Groups  []Group
+}
+
+// Entry is a KeePass entry, its fields are strings. History holds its
+// older versions, oldest first.
+type Entry struct {
+	UUID     UUID
+	Strings  []String
+	Tags     []string
+	Created  time.Time
+	Modified time.Time
+	History  []Entry
+}
+
+// String is a field of an entry, protected ones are encrypted inside the
+// database.
+type String struct {
+	Key       string
+	Value     string
+	Protected bool
+}
+
+// Get returns the value of the string key, "" when e does not have it.
+func (e Entry) Get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// DecodeXML reads the plain XML export of KeePass. Values protected in
+// memory are kept as they are, values encrypted with the inner stream of
+// a database can not be read and fail with ErrProtectedValues.
+func DecodeXML(r io.Reader) (*Database, error) {
+	db := &Database{}
+	if err := db.decodeXML(r, nil); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// node is an element of the XML of a database.
+type node struct {
+	name      string
+	attrs     []xml.Attr
+	text      string
+	protected bool
+	children  []*node
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return &node{}
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// parseXML reads the XML of a database into a tree. Protected values are
+// decrypted with stream as they are read, in document order like KeePass
+// encrypted them.
+func parseXML(r io.Reader, stream cipher.Stream) (*node, error) {
+	dec := xml.NewDecoder(r)
+	root := &node{}
+	stack := []*node{root}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(ErrCorrupt, err)
+		}
+
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, attrs: t.Attr}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		case xml.CharData:
+			top.text += string(t)
+		case xml.EndElement:
+			if err = top.decrypt(stream); err != nil {
+				return nil, err
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, errors.Wrap(ErrCorrupt, errors.New("unexpected end of xml"))
+	}
+	return root, nil
+}
+
+func (n *node) decrypt(stream cipher.Stream) error {
+	switch {
+	case n.name != "Value":
+		return nil
+	case isTrue(n.attr("ProtectInMemory")):
+		n.protected = true
+		return nil
+	case !isTrue(n.attr("Protected")):
+		return nil
+	case stream == nil:
+		return ErrProtectedValues
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(n.text))
+	if err != nil {
+		return errors.Wrap(ErrCorrupt, errors.New("invalid protected value"))
+	}
+	stream.XORKeyStream(data, data)
+	n.text, n.protected = string(data), true
+	return nil
+}
+
+func (db *Database) decodeXML(r io.Reader, stream cipher.Stream) error {
+	doc, err := parseXML(r, stream)
+	if err != nil {
+		return err
+	}
+
+	file := doc.child("KeePassFile")
+	if file.name == "" {
+		return errors.Wrap(ErrCorrupt, errors.New("not a keepass xml file"))
+	}
+
+	meta := file.child("Meta")
+	db.Name = meta.child("DatabaseName").text
+	if isTrue(meta.child("RecycleBinEnabled").text) || meta.child("RecycleBinEnabled").name == "" {
+		db.RecycleBin = parseUUID(meta.child("RecycleBinUUID").text)
+	}
+
+	if top := file.child("Root").child("Group"); top.name != "" {
+		db.Root = decodeGroup(top)
+	}
+	return nil
+}
+
+func decodeGroup(n *node) Group {
+	g := Group{UUID: parseUUID(n.child("UUID").text), Name: n.child("Name").text}
+	for _, c := range n.children {
+		switch c.name {
+		case "Entry":
+			g.Entries = append(g.Entries, decodeEntry(c))
+		case "Group":
+			g.Groups = append(g.Groups, decodeGroup(c))
+		}
+	}
+	return g
+}
+
+func decodeEntry(n *node) Entry {
+	e := Entry{UUID: parseUUID(n.child("UUID").text)}
+	if tags := n.child("Tags").text; strings.TrimSpace(tags) != "" {
+		e.Tags = strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == ',' })
+	}
+
+	times := n.child("Times")
+	e.Created = parseTime(times.child("CreationTime").text)
+	e.Modified = parseTime(times.child("LastModificationTime").text)
+
+	for _, c := range n.children {
+		switch c.name {
+		case "String":
+			value := c.child("Value")
+			e.Strings = append(e.Strings, String{Key: c.child("Key").text, Value: value.text, Protected: value.protected})
+		case "History":
+			for _, h := range c.children {
+				if h.name == "Entry" {
+					e.History = append(e.History, decodeEntry(h))
+				}
+			}
+		}
+	}
+	return e
+}
+
+// parseUUID reads a base64 UUID. Those of hand written files, which are
+// not, are hashed so that references between them still hold.
+func parseUUID(s string) UUID {
+	var u UUID
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return u
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == len(u) {
+		copy(u[:], b)
+		return u
+	}
+	return md5.Sum([]byte(s))
+}
+
+// parseTime reads the base64 seconds of KDBX 4 and the ISO 8601 times of
+// older databases and XML exports.
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC()
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == 8 {
+		return time.Unix(int64(binary.LittleEndian.Uint64(b))-epochOffset, 0).UTC()
+	}
+	return time.Time{}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(t.Unix()+epochOffset))
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+func formatUUID(u UUID) string {
+	return base64.StdEncoding.EncodeToString(u[:])
+}
+
+func isTrue(s string) bool {
+	return strings.EqualFold(strings.TrimSpace(s), "true")
+}
+
+// xmlWriter writes the XML of a database, encrypting protected values
+// with stream in the order they are written.
+type xmlWriter struct {
+	enc    *xml.Encoder
+	stream cipher.Stream
+	err    error
+}
+
+func (w *xmlWriter) token(t xml.Token) {
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(t)
+	}
+}
+
+func (w *xmlWriter) start(name string, attrs ...xml.Attr) {
+	w.token(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+}
+
+func (w *xmlWriter) end(name string) {
+	w.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (w *xmlWriter) elem(name, text string, attrs ...xml.Attr) {
+	w.start(name, attrs...)
+	if text != "" {
+		w.token(xml.CharData(text))
+	}
+	w.end(name)
+}
+
+func (w *xmlWriter) bool(name string, v bool) {
+	if v {
+		w.elem(name, "True")
+	} else {
+		w.elem(name, "False")
+	}
+}
+
+func (w *xmlWriter) protected(name, text string) {
+	data := []byte(text)
+	w.stream.XORKeyStream(data, data)
+	w.elem(name, base64.StdEncoding.EncodeToString(data), xml.Attr{Name: xml.Name{Local: "Protected"}, Value: "True"})
+}
+
+func (db *Database) encodeXML(out io.Writer, stream cipher.Stream) error {
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "\t")
+	w := &xmlWriter{enc: enc, stream: stream}
+
+	now := formatTime(time.Now())
+
+	w.token(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="utf-8" standalone="yes"`)})
+	w.start("KeePassFile")
+
+	w.start("Meta")
+	w.elem("Generator", generator)
+	w.elem("DatabaseName", db.Name)
+	w.elem("DatabaseNameChanged", now)
+	w.elem("DefaultUserName", "")
+	w.elem("MaintenanceHistoryDays", "365")
+	w.start("MemoryProtection")
+	w.bool("ProtectTitle", false)
+	w.bool("ProtectUserName", false)
+	w.bool("ProtectPassword", true)
+	w.bool("ProtectURL", false)
+	w.bool("ProtectNotes", false)
+	w.end("MemoryProtection")
+	w.bool("RecycleBinEnabled", db.RecycleBin != UUID{})
+	w.elem("RecycleBinUUID", formatUUID(db.RecycleBin))
+	w.elem("RecycleBinChanged", now)
+	w.elem("HistoryMaxItems", "10")
+	w.elem("HistoryMaxSize", "6291456")
+	w.end("Meta")
+
+	w.start("Root")
+	root := db.Root
+	if root.Name == "" {
+		root.Name = db.Name
+	}
+	w.group(root, now)
+	w.start("DeletedObjects")
+	w.end("DeletedObjects")
+	w.end("Root")
+
+	w.end("KeePassFile")
+	if w.err != nil {
+		return w.err
+	}
+	return enc.Flush()
+}
+
+func (w *xmlWriter) group(g Group, now string) {
+	if g.UUID == (UUID{}) {
+		g.UUID = NewUUID()
+	}
+
+	w.start("Group")
+	w.elem("UUID", formatUUID(g.UUID))
+	w.elem("Name", g.Name)
+	w.start("Times")
+	w.elem("CreationTime", now)
+	w.elem("LastModificationTime", now)
+	w.elem("LastAccessTime", now)
+	w.elem("ExpiryTime", now)
+	w.bool("Expires", false)
+	w.elem("UsageCount", "0")
+	w.elem("LocationChanged", now)
+	w.end("Times")
+	w.bool("IsExpanded", true)
+	for _, e := range g.Entries {
+		w.entry(e, true)
+	}
+	for _, sub := range g.Groups {
+		w.group(sub, now)
+	}
+	w.end("Group")
+}
+
+func (w *xmlWriter) entry(e Entry, history bool) {
+	if e.UUID == (UUID{}) {
+		e.UUID = NewUUID()
+	}
+	modified := e.Modified
+	if modified.IsZero() {
+		modified = e.Created
+	}
+
+	w.start("Entry")
+	w.elem("UUID", formatUUID(e.UUID))
+	w.elem("Tags", strings.Join(e.Tags, ";"))
+	w.start("Times")
+	w.elem("CreationTime", formatTime(e.Created))
+	w.elem("LastModificationTime", formatTime(modified))
+	w.elem("LastAccessTime", formatTime(modified))
+	w.elem("ExpiryTime", formatTime(modified))
+	w.bool("Expires", false)
+	w.elem("UsageCount", "0")
+	w.elem("LocationChanged", formatTime(modified))
+	w.end("Times")
+
+	for _, s := range e.Strings {
+		w.start("String")
+		w.elem("Key", s.Key)
+		if s.Protected {
+			w.protected("Value", s.Value)
+		} else {
+			w.elem("Value", s.Value)
+		}
+		w.end("String")
+	}
+
+	// history entries have no history of their own
+	if history && len(e.History) > 0 {
+		w.start("History")
+		for _, h := range e.History {
+			h.UUID = e.UUID
+			w.entry(h, false)
+		}
+		w.end("History")
+	}
+	w.end("Entry")
+}
@@ -0,0 +1,465 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+//plainHasher, plainES and anyTokenizer keep the keeper tests free of
+//bcrypt, rsa and jwt
+type plainHasher struct{}
+
+func (plainHasher) Hash(s string) (string, error) { return "hash:" + s, nil }
+
+func (plainHasher) Compare(s, hash string) error {
+	if hash != "hash:"+s {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+type plainES struct{}
+
+func (plainES) Encode(password string) ([]byte, error) { return []byte(password), nil }
+
+func (plainES) Decode(encoded []byte) (string, error) { return string(encoded), nil }
+
+func (plainES) Sign(password string) ([]byte, []byte, error) {
+	return []byte("digest:" + password), []byte("signature:" + password), nil
+}
+
+func (plainES) Verify(string, []byte, []byte) error { return nil }
+
+type anyTokenizer struct{}
+
+func (anyTokenizer) Issue(token Token) (string, error) { return token.ID, nil }
+
+func (anyTokenizer) Parse(token string) (Token, error) { return Token{ID: token}, nil }
+
+type memKey struct{ name, username string }
+
+type memAccount struct {
+	account DBAccount
+	deleted string
+}
+
+//memStore is a PasswordStore in memory that keeps history the way the
+//postgres store does
+type memStore struct {
+	PasswordStore
+	accounts  map[memKey]*memAccount
+	history   map[memKey][]DBVersion
+	owners    map[memKey]Account
+	twoFactor map[memKey]TwoFactor
+
+	//failAdd makes Add of the account of that name fail
+	failAdd string
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		accounts:  map[memKey]*memAccount{},
+		history:   map[memKey][]DBVersion{},
+		owners:    map[memKey]Account{},
+		twoFactor: map[memKey]TwoFactor{},
+	}
+}
+
+func newTestKeeper() (PasswordKeeper, *memStore) {
+	store := newMemStore()
+	return NewPasswordKeeper(plainHasher{}, store, anyTokenizer{}, plainES{}), store
+}
+
+func (m *memStore) AddOwner(ctx context.Context, account Account) error {
+	m.owners[memKey{account.Name, account.UserName}] = account
+	return nil
+}
+
+func (m *memStore) GetOwner(ctx context.Context, name, username string) (Account, error) {
+	account, ok := m.owners[memKey{name, username}]
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+	return account, nil
+}
+
+func (m *memStore) GetTwoFactor(ctx context.Context, name, username string) (TwoFactor, error) {
+	return m.twoFactor[memKey{name, username}], nil
+}
+
+func (m *memStore) SetTwoFactor(ctx context.Context, name, username string, tf TwoFactor) error {
+	m.twoFactor[memKey{name, username}] = tf
+	return nil
+}
+
+func (m *memStore) live(name, username string) (*memAccount, bool) {
+	a, ok := m.accounts[memKey{name, username}]
+	return a, ok && a.deleted == ""
+}
+
+func (m *memStore) Add(ctx context.Context, account DBAccount) error {
+	if account.Name == m.failAdd {
+		return ErrCriticalFailure
+	}
+
+	key := memKey{account.Name, account.UserName}
+	if a, ok := m.accounts[key]; ok {
+		if a.deleted == "" {
+			return ErrInvalidArgs
+		}
+		m.archive(key, a.account)
+	}
+	m.accounts[key] = &memAccount{account: account}
+	return nil
+}
+
+func (m *memStore) archive(key memKey, account DBAccount) {
+	version := 1
+	if n := len(m.history[key]); n > 0 {
+		version = m.history[key][n-1].Version + 1
+	}
+	m.history[key] = append(m.history[key], DBVersion{
+		Version:  version,
+		Account:  account,
+		Replaced: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func (m *memStore) Get(ctx context.Context, name, username string) (DBAccount, error) {
+	a, ok := m.live(name, username)
+	if !ok {
+		return DBAccount{}, ErrNotFound
+	}
+	return a.account, nil
+}
+
+func (m *memStore) Delete(ctx context.Context, name, username string) error {
+	a, ok := m.live(name, username)
+	if !ok {
+		return ErrNotFound
+	}
+	a.deleted = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+func (m *memStore) Trash(ctx context.Context) (accounts []Trashed, err error) {
+	for _, a := range m.accounts {
+		if a.deleted != "" {
+			accounts = append(accounts, Trashed{Name: a.account.Name, UserName: a.account.UserName, Deleted: a.deleted})
+		}
+	}
+	return accounts, nil
+}
+
+func (m *memStore) Restore(ctx context.Context, name, username string) error {
+	a, ok := m.accounts[memKey{name, username}]
+	if !ok || a.deleted == "" {
+		return ErrNotFound
+	}
+	a.deleted = ""
+	return nil
+}
+
+func (m *memStore) Purge(ctx context.Context, before string) (purged int, err error) {
+	for key, a := range m.accounts {
+		if a.deleted != "" && (before == "" || a.deleted < before) {
+			delete(m.accounts, key)
+			delete(m.history, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *memStore) Update(ctx context.Context, name, username string, account DBAccount) error {
+	key := memKey{name, username}
+	a, ok := m.live(name, username)
+	if !ok {
+		return ErrNotFound
+	}
+	if !bytes.Equal(a.account.Encoded, account.Encoded) {
+		m.archive(key, a.account)
+	}
+
+	delete(m.accounts, key)
+	to := memKey{account.Name, account.UserName}
+	m.accounts[to] = &memAccount{account: account}
+	if to != key {
+		m.history[to] = m.history[key]
+		delete(m.history, key)
+	}
+	return nil
+}
+
+func (m *memStore) List(ctx context.Context, query Query) (accounts []DBAccount, err error) {
+	for _, a := range m.accounts {
+		if a.deleted == "" {
+			accounts = append(accounts, a.account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	return accounts, nil
+}
+
+func (m *memStore) History(ctx context.Context, name, username string) (versions []DBVersion, err error) {
+	if _, ok := m.live(name, username); !ok {
+		return nil, ErrNotFound
+	}
+	h := m.history[memKey{name, username}]
+	for i := len(h) - 1; i >= 0; i-- {
+		versions = append(versions, h[i])
+	}
+	return versions, nil
+}
+
+func (m *memStore) GetVersion(ctx context.Context, name, username string, version int) (DBVersion, error) {
+	if _, ok := m.live(name, username); !ok {
+		return DBVersion{}, ErrNotFound
+	}
+	for _, v := range m.history[memKey{name, username}] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return DBVersion{}, ErrVersionNotFound
+}
+
+func (m *memStore) AddVersion(ctx context.Context, v DBVersion) error {
+	key := memKey{v.Account.Name, v.Account.UserName}
+	replaced := v.Replaced
+	m.archive(key, v.Account)
+	m.history[key][len(m.history[key])-1].Replaced = replaced
+	return nil
+}
+
+func (m *memStore) PruneHistory(ctx context.Context, name, username string, keep int, before string) (removed int, err error) {
+	key := memKey{name, username}
+	var kept []DBVersion
+	for i, v := range m.history[key] {
+		if keep > 0 && i < len(m.history[key])-keep || before != "" && v.Replaced < before {
+			removed++
+			continue
+		}
+		kept = append(kept, v)
+	}
+	m.history[key] = kept
+	return removed, nil
+}
+
+//Transaction puts back the accounts and history fn started with when it
+//fails
+func (m *memStore) Transaction(ctx context.Context, fn func(store PasswordStore) error) error {
+	accounts := map[memKey]*memAccount{}
+	for key, a := range m.accounts {
+		copied := *a
+		accounts[key] = &copied
+	}
+	history := map[memKey][]DBVersion{}
+	for key, versions := range m.history {
+		history[key] = append([]DBVersion{}, versions...)
+	}
+
+	if err := fn(m); err != nil {
+		m.accounts, m.history = accounts, history
+		return err
+	}
+	return nil
+}
+
+//passwords returns the passwords of versions, newest first
+func passwords(versions []Version) []string {
+	var got []string
+	for _, v := range versions {
+		got = append(got, v.Account.Password)
+	}
+	return got
+}
+
+func TestHistory(t *testing.T) {
+	keeper, _ := newTestKeeper()
+	ctx := context.Background()
+
+	err := keeper.Add(ctx, "token", Account{Name: "github", UserName: "alice", Email: "alice@example.com", Password: "pw1"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	//every new password archives the one it replaces
+	for _, pw := range []string{"pw2", "pw3"} {
+		if _, err = keeper.Update(ctx, "token", "github", "alice", Account{Password: pw}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	//other changes do not
+	if _, err = keeper.Update(ctx, "token", "github", "alice", Account{Notes: "main"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	versions, err := keeper.History(ctx, "token", "github", "alice")
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if got := passwords(versions); !reflect.DeepEqual(got, []string{"pw2", "pw1"}) || versions[0].Version != 2 {
+		t.Errorf("History() = %v, want [pw2 pw1] numbered 2 and 1", got)
+	}
+
+	//a renamed account takes its history along
+	if _, err = keeper.Update(ctx, "token", "github", "alice", Account{Name: "github.com"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err = keeper.History(ctx, "token", "github", "alice"); err != ErrNotFound {
+		t.Errorf("History() of the old name error = %v, want %v", err, ErrNotFound)
+	}
+	versions, err = keeper.History(ctx, "token", "github.com", "alice")
+	if got := passwords(versions); err != nil || !reflect.DeepEqual(got, []string{"pw2", "pw1"}) {
+		t.Errorf("History() after rename = %v, %v, want [pw2 pw1]", got, err)
+	}
+
+	//a rollback makes the old password current and archives the replaced one
+	account, err := keeper.Rollback(ctx, "token", "github.com", "alice", 1)
+	if err != nil || account.Password != "pw1" || account.Notes != "main" {
+		t.Fatalf("Rollback() = %+v, %v, want pw1 with the notes kept", account, err)
+	}
+	versions, _ = keeper.History(ctx, "token", "github.com", "alice")
+	if got := passwords(versions); !reflect.DeepEqual(got, []string{"pw3", "pw2", "pw1"}) || versions[0].Version != 3 {
+		t.Errorf("History() after rollback = %v, want [pw3 pw2 pw1] with pw3 as version 3", got)
+	}
+
+	if _, err = keeper.Rollback(ctx, "token", "github.com", "alice", 9); err != ErrVersionNotFound {
+		t.Errorf("Rollback() of a missing version error = %v, want %v", err, ErrVersionNotFound)
+	}
+	if _, err = keeper.Rollback(ctx, "token", "gitlab", "alice", 1); err != ErrNotFound {
+		t.Errorf("Rollback() of a missing account error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestHistoryPruning(t *testing.T) {
+	keeper, store := newTestKeeper()
+	ctx := context.Background()
+
+	if err := keeper.Add(ctx, "token", Account{Name: "github", UserName: "alice", Password: "pw0"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	//a version replaced long ago
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	old0, _ := store.Get(ctx, "github", "alice")
+	_ = store.AddVersion(ctx, DBVersion{Account: old0, Replaced: old})
+
+	keeper = HistoryMiddleware(3, 0, log.New(ioutil.Discard, "", 0))(keeper)
+	for _, pw := range []string{"pw1", "pw2", "pw3", "pw4"} {
+		if _, err := keeper.Update(ctx, "token", "github", "alice", Account{Password: pw}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+
+	//the count limit keeps the newest versions
+	versions, _ := keeper.History(ctx, "token", "github", "alice")
+	if got := passwords(versions); !reflect.DeepEqual(got, []string{"pw3", "pw2", "pw1"}) || versions[2].Version != 3 {
+		t.Errorf("History() = %v, want the newest 3 versions", got)
+	}
+
+	//the age limit removes what was replaced before it
+	_ = store.AddVersion(ctx, DBVersion{Account: old0, Replaced: old})
+	removed, err := keeper.PruneHistory(ctx, "token", "github", "alice", 0, 24*time.Hour)
+	if err != nil || removed != 1 {
+		t.Errorf("PruneHistory() = %v, %v, want 1 removed", removed, err)
+	}
+	versions, _ = keeper.History(ctx, "token", "github", "alice")
+	if got := passwords(versions); !reflect.DeepEqual(got, []string{"pw3", "pw2", "pw1"}) {
+		t.Errorf("History() after pruning by age = %v, want [pw3 pw2 pw1]", got)
+	}
+
+	//the update is stored when pruning fails, the failure is only logged
+	var logged bytes.Buffer
+	keeper = HistoryMiddleware(3, 0, log.New(&logged, "", 0))(pruneFailer{keeper})
+	if _, err = keeper.Update(ctx, "token", "github", "alice", Account{Password: "pw5"}); err != nil {
+		t.Errorf("Update() with a failing prune error = %v, want nil", err)
+	}
+	if account, _ := keeper.Get(ctx, "token", "github", "alice"); account.Password != "pw5" {
+		t.Errorf("Get() = %v, want pw5", account.Password)
+	}
+	if !bytes.Contains(logged.Bytes(), []byte("could not prune")) {
+		t.Errorf("logged %q, want the prune failure", logged.String())
+	}
+}
+
+//pruneFailer fails every PruneHistory
+type pruneFailer struct {
+	PasswordKeeper
+}
+
+func (pruneFailer) PruneHistory(ctx context.Context, token, name, username string, keep int, maxAge time.Duration) (int, error) {
+	return 0, ErrInternalError
+}
+
+func TestTrash(t *testing.T) {
+	keeper, _ := newTestKeeper()
+	ctx := context.Background()
+
+	if err := keeper.Add(ctx, "token", Account{Name: "github", UserName: "alice", Password: "pw1"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := keeper.Delete(ctx, "token", "github", "alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := keeper.Get(ctx, "token", "github", "alice"); err != ErrNotFound {
+		t.Errorf("Get() of a trashed account error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := keeper.Restore(ctx, "token", "github", "alice"); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if account, err := keeper.Get(ctx, "token", "github", "alice"); err != nil || account.Password != "pw1" {
+		t.Errorf("Get() after restore = %+v, %v, want pw1", account, err)
+	}
+
+	//adding over the trash keeps the old password, the same one included
+	if err := keeper.Delete(ctx, "token", "github", "alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := keeper.Add(ctx, "token", Account{Name: "github", UserName: "alice", Password: "pw1"}); err != nil {
+		t.Fatalf("Add() over the trash error = %v", err)
+	}
+	versions, err := keeper.History(ctx, "token", "github", "alice")
+	if got := passwords(versions); err != nil || !reflect.DeepEqual(got, []string{"pw1"}) {
+		t.Errorf("History() = %v, %v, want [pw1]", got, err)
+	}
+
+	if trash, _ := keeper.Trash(ctx, "token"); len(trash) != 0 {
+		t.Errorf("Trash() = %+v, want it empty", trash)
+	}
+
+	//no age purges what was trashed this very second
+	if err := keeper.Delete(ctx, "token", "github", "alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if purged, err := keeper.Purge(ctx, "token", time.Hour); err != nil || purged != 0 {
+		t.Errorf("Purge() of an hour = %v, %v, want 0", purged, err)
+	}
+	if purged, err := keeper.Purge(ctx, "token", 0); err != nil || purged != 1 {
+		t.Errorf("Purge() = %v, %v, want 1", purged, err)
+	}
+	if _, err := keeper.History(ctx, "token", "github", "alice"); err != ErrNotFound {
+		t.Errorf("History() of a purged account error = %v, want %v", err, ErrNotFound)
+	}
+}
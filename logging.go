@@ -42,13 +42,13 @@ func (l loggingMiddleware) Register(ctx context.Context, username, email, passwo
 	return
 }
 
-func (l loggingMiddleware) Login(ctx context.Context, username, password string) (token string, err error) {
+func (l loggingMiddleware) Login(ctx context.Context, username, password, code string) (token string, err error) {
 	defer func(begin time.Time) {
 		l.logger.Printf("method: login took: %v to generate token for user with id: %v and return err: %v\n",
 			time.Since(begin), username, err)
 	}(time.Now())
 
-	token, err = l.next.Login(ctx, username, password)
+	token, err = l.next.Login(ctx, username, password, code)
 	return
 }
 
@@ -82,17 +82,17 @@ func (l loggingMiddleware) Delete(ctx context.Context, token, name, username str
 	return
 }
 
-func (l loggingMiddleware) List(ctx context.Context, token string, args map[string]interface{}) (accounts []Account, err error) {
+func (l loggingMiddleware) List(ctx context.Context, token string, query Query) (accounts []Account, next string, err error) {
 	defer func(begin time.Time) {
-		l.logger.Printf("method: list took: %v to retrieve all users (%v) and returned with err: %v \n",
-			time.Since(begin), len(accounts), err)
+		l.logger.Printf("method: list took: %v to retrieve users (%v) sorted by %v and returned with err: %v \n",
+			time.Since(begin), len(accounts), query.Sort, err)
 	}(time.Now())
 
-	accounts, err = l.next.List(ctx, token, args)
+	accounts, next, err = l.next.List(ctx, token, query)
 	return
 }
 
-func (l loggingMiddleware) Update(ctx context.Context, token, name, username, account Account) (acc Account, err error) {
+func (l loggingMiddleware) Update(ctx context.Context, token, name, username string, account Account) (acc Account, err error) {
 	defer func(begin time.Time) {
 		l.logger.Printf("method: update took: %v to update acc with username: %v \n",
 			time.Since(begin), username)
@@ -121,3 +121,113 @@ func (l loggingMiddleware) DeleteAll(ctx context.Context, token string, args map
 	err = l.next.DeleteAll(ctx, token, args)
 	return
 }
+
+func (l loggingMiddleware) History(ctx context.Context, token, name, username string) (versions []Version, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: history took: %v to retrieve %v versions of user with id: %v and returned err: %v\n",
+			time.Since(begin), len(versions), username, err)
+	}(time.Now())
+
+	versions, err = l.next.History(ctx, token, name, username)
+	return
+}
+
+func (l loggingMiddleware) Rollback(ctx context.Context, token, name, username string, version int) (account Account, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: rollback took: %v to restore version %v of user with id: %v and returned err: %v\n",
+			time.Since(begin), version, username, err)
+	}(time.Now())
+
+	account, err = l.next.Rollback(ctx, token, name, username, version)
+	return
+}
+
+func (l loggingMiddleware) PruneHistory(ctx context.Context, token, name, username string, keep int, maxAge time.Duration) (removed int, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: pruneHistory took: %v to remove %v versions of user with id: %v and returned err: %v\n",
+			time.Since(begin), removed, username, err)
+	}(time.Now())
+
+	removed, err = l.next.PruneHistory(ctx, token, name, username, keep, maxAge)
+	return
+}
+
+func (l loggingMiddleware) Trash(ctx context.Context, token string) (accounts []Trashed, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: trash took: %v to list %v deleted accounts and returned err: %v\n",
+			time.Since(begin), len(accounts), err)
+	}(time.Now())
+
+	accounts, err = l.next.Trash(ctx, token)
+	return
+}
+
+func (l loggingMiddleware) Restore(ctx context.Context, token, name, username string) (err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: restore took: %v to restore user with id: %v and returned err: %v\n",
+			time.Since(begin), username, err)
+	}(time.Now())
+
+	err = l.next.Restore(ctx, token, name, username)
+	return
+}
+
+func (l loggingMiddleware) Purge(ctx context.Context, token string, olderThan time.Duration) (purged int, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: purge took: %v to purge %v accounts deleted more than %v ago and returned err: %v\n",
+			time.Since(begin), purged, olderThan, err)
+	}(time.Now())
+
+	purged, err = l.next.Purge(ctx, token, olderThan)
+	return
+}
+
+func (l loggingMiddleware) Search(ctx context.Context, token, text string, limit int) (matches []Match, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: search took: %v to find %v matches and returned err: %v\n",
+			time.Since(begin), len(matches), err)
+	}(time.Now())
+
+	matches, err = l.next.Search(ctx, token, text, limit)
+	return
+}
+
+func (l loggingMiddleware) EnableTwoFactor(ctx context.Context, token, secret, code string) (recovery []string, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: enable_two_factor took: %v to issue %v recovery codes and returned err: %v\n",
+			time.Since(begin), len(recovery), err)
+	}(time.Now())
+
+	recovery, err = l.next.EnableTwoFactor(ctx, token, secret, code)
+	return
+}
+
+func (l loggingMiddleware) DisableTwoFactor(ctx context.Context, token, code string) (err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: disable_two_factor took: %v and returned err: %v\n",
+			time.Since(begin), err)
+	}(time.Now())
+
+	err = l.next.DisableTwoFactor(ctx, token, code)
+	return
+}
+
+func (l loggingMiddleware) Export(ctx context.Context, token string) (entries []Entry, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: export took: %v to export %v accounts and returned err: %v\n",
+			time.Since(begin), len(entries), err)
+	}(time.Now())
+
+	entries, err = l.next.Export(ctx, token)
+	return
+}
+
+func (l loggingMiddleware) Import(ctx context.Context, token string, entries []Entry, conflict Conflict) (report ImportReport, err error) {
+	defer func(begin time.Time) {
+		l.logger.Printf("method: import took: %v to import %v accounts (%+v) and returned err: %v\n",
+			time.Since(begin), len(entries), report, err)
+	}(time.Now())
+
+	report, err = l.next.Import(ctx, token, entries, conflict)
+	return
+}
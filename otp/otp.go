@@ -0,0 +1,297 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package otp generates the one time passwords of RFC 4226 (HOTP) and
+// RFC 6238 (TOTP) and reads and writes the otpauth:// URIs that
+// authenticator apps exchange, usually as QR codes.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+
+	SHA1   = "SHA1"
+	SHA256 = "SHA256"
+	SHA512 = "SHA512"
+
+	// DefaultDigits and DefaultPeriod are used when a URI leaves them out.
+	DefaultDigits = 6
+	DefaultPeriod = 30
+
+	scheme = "otpauth"
+)
+
+var (
+	ErrInvalidURI    = errors.New("invalid otpauth uri")
+	ErrInvalidSecret = errors.New("invalid otp secret")
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key is everything needed to generate the codes of an account.
+type Key struct {
+	Type      string
+	Issuer    string
+	Account   string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   uint64
+}
+
+// NewKey returns a TOTP key with the default settings for a base32
+// secret, the way most sites show it next to their QR code.
+func NewKey(secret string) (Key, error) {
+	raw, err := DecodeSecret(secret)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Type:      TypeTOTP,
+		Secret:    raw,
+		Algorithm: SHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// Parse reads an otpauth://totp/Issuer:account?secret=... or
+// otpauth://hotp/...&counter=n URI.
+func Parse(uri string) (Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return Key{}, errors.Wrap(ErrInvalidURI, err)
+	}
+	if u.Scheme != scheme {
+		return Key{}, errors.Wrap(ErrInvalidURI, errors.New("the scheme should be otpauth"))
+	}
+
+	key := Key{
+		Type:      strings.ToLower(u.Host),
+		Algorithm: SHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(label, ":"); i >= 0 {
+		key.Issuer, key.Account = strings.TrimSpace(label[:i]), strings.TrimSpace(label[i+1:])
+	} else {
+		key.Account = strings.TrimSpace(label)
+	}
+
+	q := u.Query()
+
+	if key.Secret, err = DecodeSecret(q.Get("secret")); err != nil {
+		return Key{}, err
+	}
+	if issuer := q.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+	if alg := q.Get("algorithm"); alg != "" {
+		key.Algorithm = strings.ToUpper(alg)
+	}
+	if key.Digits, err = intParam(q, "digits", DefaultDigits); err != nil {
+		return Key{}, err
+	}
+	if key.Period, err = intParam(q, "period", DefaultPeriod); err != nil {
+		return Key{}, err
+	}
+
+	if key.Type == TypeHOTP {
+		counter := q.Get("counter")
+		if counter == "" {
+			return Key{}, errors.Wrap(ErrInvalidURI, errors.New("hotp needs a counter"))
+		}
+		if key.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+			return Key{}, errors.Wrap(ErrInvalidURI, errors.New("invalid counter "+counter))
+		}
+	}
+
+	if err := key.Validate(); err != nil {
+		return Key{}, err
+	}
+
+	return key, nil
+}
+
+func intParam(q url.Values, name string, def int) (int, error) {
+	s := q.Get(name)
+	if s == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Wrap(ErrInvalidURI, errors.New(fmt.Sprintf("invalid %v %q", name, s)))
+	}
+	return n, nil
+}
+
+// Validate checks the settings of the key.
+func (k Key) Validate() error {
+	switch {
+	case k.Type != TypeTOTP && k.Type != TypeHOTP:
+		return errors.Wrap(ErrInvalidURI, errors.New(fmt.Sprintf("unknown type %q, want totp or hotp", k.Type)))
+	case len(k.Secret) == 0:
+		return errors.Wrap(ErrInvalidSecret, errors.New("the secret is empty"))
+	case newHash(k.Algorithm) == nil:
+		return errors.Wrap(ErrInvalidURI, errors.New(fmt.Sprintf("unknown algorithm %q", k.Algorithm)))
+	case k.Digits < 6 || k.Digits > 10:
+		return errors.Wrap(ErrInvalidURI, errors.New("digits should be between 6 and 10"))
+	case k.Type == TypeTOTP && k.Period <= 0:
+		return errors.Wrap(ErrInvalidURI, errors.New("the period should be positive"))
+	}
+
+	return nil
+}
+
+// URI is the otpauth URI of the key.
+func (k Key) URI() string {
+	label := url.PathEscape(k.Account)
+	if k.Issuer != "" {
+		label = url.PathEscape(k.Issuer) + ":" + label
+	}
+
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(k.Secret))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	q.Set("algorithm", k.Algorithm)
+	q.Set("digits", strconv.Itoa(k.Digits))
+	if k.Type == TypeHOTP {
+		q.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		q.Set("period", strconv.Itoa(k.Period))
+	}
+
+	return scheme + "://" + k.Type + "/" + label + "?" + q.Encode()
+}
+
+// Code is the HOTP code of counter.
+func (k Key) Code(counter uint64) string {
+	mac := hmac.New(newHash(k.Algorithm), k.Secret)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", k.Digits, uint64(bin)%mod)
+}
+
+// At is the TOTP code at t and how long it stays valid.
+func (k Key) At(t time.Time) (code string, remaining time.Duration) {
+	period := time.Duration(k.Period) * time.Second
+	step := t.Unix() / int64(k.Period)
+
+	next := time.Unix((step+1)*int64(k.Period), 0)
+	remaining = next.Sub(t)
+	if remaining > period {
+		remaining = period
+	}
+
+	return k.Code(uint64(step)), remaining
+}
+
+// Check reports whether code is the TOTP code of a period within skew
+// periods of t, allowing for clocks that drift apart. It returns the
+// counter of that period so a code can be refused once it was used.
+func (k Key) Check(code string, t time.Time, skew int) (counter uint64, ok bool) {
+	step := t.Unix() / int64(k.Period)
+
+	for i := -skew; i <= skew; i++ {
+		if step+int64(i) < 0 {
+			continue
+		}
+		c := uint64(step + int64(i))
+		if hmac.Equal([]byte(k.Code(c)), []byte(code)) {
+			return c, true
+		}
+	}
+
+	return 0, false
+}
+
+// NewSecret returns a random base32 secret of size bytes, RFC 4226 asks
+// for at least 16 and recommends 20.
+func NewSecret(size int) (string, error) {
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return EncodeSecret(secret), nil
+}
+
+// EncodeSecret is the unpadded base32 form of secret used in URIs.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// DecodeSecret reads a base32 secret, case, spaces, dashes and padding
+// are ignored since sites format them for reading.
+func DecodeSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(secret)
+	s = strings.NewReplacer(" ", "", "-", "", "=", "").Replace(s)
+	if s == "" {
+		return nil, errors.Wrap(ErrInvalidSecret, errors.New("the secret is empty"))
+	}
+
+	raw, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidSecret, errors.New("the secret is not base32"))
+	}
+
+	return raw, nil
+}
+
+func newHash(alg string) func() hash.Hash {
+	switch alg {
+	case SHA1:
+		return sha1.New
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	}
+	return nil
+}
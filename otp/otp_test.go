@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// RFC 4226 appendix D
+func TestHOTP(t *testing.T) {
+	key := Key{Type: TypeHOTP, Secret: []byte("12345678901234567890"), Algorithm: SHA1, Digits: 6}
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		if got := key.Code(uint64(counter)); got != code {
+			t.Errorf("Code(%v) = %v, want %v", counter, got, code)
+		}
+	}
+}
+
+// RFC 6238 appendix B
+func TestTOTP(t *testing.T) {
+	secrets := map[string]string{
+		SHA1:   "12345678901234567890",
+		SHA256: "12345678901234567890123456789012",
+		SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+
+	tests := []struct {
+		unix int64
+		want map[string]string
+	}{
+		{59, map[string]string{SHA1: "94287082", SHA256: "46119246", SHA512: "90693936"}},
+		{1111111109, map[string]string{SHA1: "07081804", SHA256: "68084774", SHA512: "25091201"}},
+		{1111111111, map[string]string{SHA1: "14050471", SHA256: "67062674", SHA512: "99943326"}},
+		{1234567890, map[string]string{SHA1: "89005924", SHA256: "91819424", SHA512: "93441116"}},
+		{2000000000, map[string]string{SHA1: "69279037", SHA256: "90698825", SHA512: "38618901"}},
+		{20000000000, map[string]string{SHA1: "65353130", SHA256: "77737706", SHA512: "47863826"}},
+	}
+
+	for _, tt := range tests {
+		for alg, want := range tt.want {
+			key := Key{Type: TypeTOTP, Secret: []byte(secrets[alg]), Algorithm: alg, Digits: 8, Period: 30}
+			code, remaining := key.At(time.Unix(tt.unix, 0))
+			if code != want {
+				t.Errorf("At(%v) %v = %v, want %v", tt.unix, alg, code, want)
+			}
+			if wantLeft := time.Duration(30-tt.unix%30) * time.Second; remaining != wantLeft {
+				t.Errorf("At(%v) remaining = %v, want %v", tt.unix, remaining, wantLeft)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    Key
+		wantErr error
+	}{
+		{
+			uri: "otpauth://totp/ACME%20Co:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=ACME%20Co",
+			want: Key{Type: TypeTOTP, Issuer: "ACME Co", Account: "alice@example.com", Secret: []byte("Hello!\xde\xad\xbe\xef"),
+				Algorithm: SHA1, Digits: 6, Period: 30},
+		},
+		{
+			uri: "otpauth://hotp/alice?secret=jbswy3dpehpk3pxp&counter=7&digits=8&algorithm=sha256",
+			want: Key{Type: TypeHOTP, Account: "alice", Secret: []byte("Hello!\xde\xad\xbe\xef"),
+				Algorithm: SHA256, Digits: 8, Period: 30, Counter: 7},
+		},
+		{uri: "https://example.com/?secret=JBSWY3DPEHPK3PXP", wantErr: ErrInvalidURI},
+		{uri: "otpauth://totp/alice?secret=not-base32!", wantErr: ErrInvalidSecret},
+		{uri: "otpauth://totp/alice", wantErr: ErrInvalidSecret},
+		{uri: "otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP", wantErr: ErrInvalidURI},
+		{uri: "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5", wantErr: ErrInvalidURI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := Parse(tt.uri)
+			if tt.wantErr != nil {
+				if !errors.Contains(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse() = %+v, want %+v", got, tt.want)
+			}
+
+			again, err := Parse(got.URI())
+			if err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("Parse(URI()) = %+v, %v, want %+v", again, err, got)
+			}
+		})
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	key, err := NewKey("jbsw y3dp ehpk 3pxp")
+	if err != nil {
+		t.Fatalf("NewKey() error = %v", err)
+	}
+	if EncodeSecret(key.Secret) != "JBSWY3DPEHPK3PXP" || key.Type != TypeTOTP || key.Period != DefaultPeriod {
+		t.Errorf("NewKey() = %+v", key)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	secret, err := NewSecret(20)
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+	key, err := NewKey(secret)
+	if err != nil {
+		t.Fatalf("NewKey(%q) error = %v", secret, err)
+	}
+
+	now := time.Unix(1111111109, 0)
+	step := uint64(now.Unix() / DefaultPeriod)
+
+	if counter, ok := key.Check(key.Code(step-1), now, 1); !ok || counter != step-1 {
+		t.Errorf("Check() of the previous code = %d, %v, want %d, true", counter, ok, step-1)
+	}
+	if _, ok := key.Check(key.Code(step+2), now, 1); ok {
+		t.Errorf("Check() accepted a code two periods ahead")
+	}
+}
@@ -21,7 +21,34 @@ type PasswordStore interface {
 	Add(ctx context.Context, account DBAccount) (err error)
 	Get(ctx context.Context, name, username string) (account DBAccount, err error)
 	GetOwner(ctx context.Context, name, username string) (account Account, err error)
+	GetTwoFactor(ctx context.Context, name, username string) (tf TwoFactor, err error)
+	SetTwoFactor(ctx context.Context, name, username string, tf TwoFactor) (err error)
+	//Delete moves the account to the trash, deleted accounts are left out
+	//by every other method but Trash, Restore and Purge
 	Delete(ctx context.Context, name, username string) (err error)
+	//DeleteAll moves the accounts whose columns equal every value of filter
+	//to the trash
+	DeleteAll(ctx context.Context, filter map[string]string) (deleted int, err error)
+	Trash(ctx context.Context) (accounts []Trashed, err error)
+	Restore(ctx context.Context, name, username string) (err error)
+	//Purge removes the accounts deleted before the RFC3339 time before
+	//and their history, every trashed account when before is empty
+	Purge(ctx context.Context, before string) (purged int, err error)
+	//Update replaces the account, when its password changes the old one is
+	//added to history as the next version
 	Update(ctx context.Context, name, username string, account DBAccount) (err error)
-	List(ctx context.Context) (accounts []DBAccount, err error)
+	//List returns the accounts matching query in its sort order, at most
+	//query.PageSize of them when set, starting after query.Cursor
+	List(ctx context.Context, query Query) (accounts []DBAccount, err error)
+	History(ctx context.Context, name, username string) (versions []DBVersion, err error)
+	GetVersion(ctx context.Context, name, username string, version int) (v DBVersion, err error)
+	//AddVersion adds v to the history of its account as the next version,
+	//keeping v.Replaced
+	AddVersion(ctx context.Context, v DBVersion) (err error)
+	//PruneHistory removes versions beyond the newest keep and those replaced
+	//before the RFC3339 time before, zero values turn a limit off
+	PruneHistory(ctx context.Context, name, username string, keep int, before string) (removed int, err error)
+	//Transaction runs fn with a store whose changes are kept together when
+	//fn returns nil and dropped when it fails
+	Transaction(ctx context.Context, fn func(store PasswordStore) error) (err error)
 }
@@ -14,13 +14,18 @@
 package pg
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
+
 	"github.com/hackaio/pk"
 	"github.com/hackaio/pk/pkg/errors"
 	"github.com/hackaio/pk/sql/stmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
@@ -47,6 +52,16 @@ func Connect() (*sql.DB, error) {
 		return nil, err
 	}
 
+	if err = migrate(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+//migrate creates the tables and brings those of older versions up to date
+func migrate(db *sql.DB) (err error) {
+
 	//type Account struct {
 	//	Name     string `json:"name,omitempty"`
 	//	UserName string `json:"username,omitempty"`
@@ -82,28 +97,69 @@ CREATE TABLE IF NOT EXISTS accounts(
     name VARCHAR (200) NOT NULL,
     username VARCHAR (200) NOT NULL,
     email VARCHAR(200) NOT NULL,
-    hash VARCHAR(300) NOT NULL UNIQUE,
-    encoded BYTEA NOT NULL UNIQUE,
-    digest BYTEA NOT NULL UNIQUE,
-    signature BYTEA NOT NULL UNIQUE,
+    hash VARCHAR(300) NOT NULL,
+    encoded BYTEA NOT NULL,
+    digest BYTEA NOT NULL,
+    signature BYTEA NOT NULL,
     created VARCHAR(100) NOT NULL,
     PRIMARY KEY (name,username)
 )
+`
+
+	//history keeps the replaced passwords of accounts, replaced is
+	//RFC3339 in UTC so it can be compared as text
+	createHistoryDb := `
+CREATE TABLE IF NOT EXISTS history(
+    name VARCHAR (200) NOT NULL,
+    username VARCHAR (200) NOT NULL,
+    version INTEGER NOT NULL,
+    email VARCHAR(200) NOT NULL,
+    hash VARCHAR(300) NOT NULL,
+    encoded BYTEA NOT NULL,
+    digest BYTEA NOT NULL,
+    signature BYTEA NOT NULL,
+    created VARCHAR(100) NOT NULL,
+    replaced VARCHAR(100) NOT NULL,
+    PRIMARY KEY (name,username,version)
+)
 `
 
 	_, err = db.Exec(createMasterDb)
-	_, err = db.Exec(createAccountsDb)
+	if err == nil {
+		_, err = db.Exec(stmt.ADD_TWO_FACTOR_COLUMNS)
+	}
+	if err == nil {
+		_, err = db.Exec(createAccountsDb)
+	}
+	if err == nil {
+		_, err = db.Exec(stmt.DROP_UNIQUE_PASSWORDS)
+	}
+	if err == nil {
+		_, err = db.Exec(stmt.ADD_DELETED_COLUMN)
+	}
+	if err == nil {
+		_, err = db.Exec(stmt.ADD_DETAIL_COLUMNS)
+	}
+	if err == nil {
+		_, err = db.Exec(stmt.ADD_OTP_COLUMN)
+	}
+	if err == nil {
+		_, err = db.Exec(createHistoryDb)
+	}
 
 	if err != nil {
 		errMsg := errors.New("could not create tables")
-		return nil, errors.Wrap(err, errMsg)
+		return errors.Wrap(err, errMsg)
 	}
 
-	return db, nil
+	return nil
 }
 
 type pgStore struct {
 	db *sql.DB
+
+	//tx is the transaction of Transaction the methods run in, nil outside
+	tx *sql.Tx
 }
 
 var _ pk.PasswordStore = (*pgStore)(nil)
@@ -112,20 +168,135 @@ func NewStore(db *sql.DB) pk.PasswordStore {
 	return pgStore{db: db}
 }
 
+//querier runs statements on the database or in a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (p pgStore) conn() querier {
+	if p.tx != nil {
+		return p.tx
+	}
+	return p.db
+}
+
+//txn is the transaction of a method. Within Transaction the method joins
+//the transaction of fn, which is committed or rolled back as a whole
+type txn struct {
+	*sql.Tx
+	joined bool
+}
+
+func (t txn) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t txn) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+func (p pgStore) begin(ctx context.Context) (txn, error) {
+	if p.tx != nil {
+		return txn{Tx: p.tx, joined: true}, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	return txn{Tx: tx}, err
+}
+
+func (p pgStore) Transaction(ctx context.Context, fn func(store pk.PasswordStore) error) (err error) {
+	if p.tx != nil {
+		return fn(p)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fn(pgStore{db: p.db, tx: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (p pgStore) AddOwner(ctx context.Context, account pk.Account) (err error) {
-	_, err = p.db.Exec(stmt.ADD_OWNER, account.Name, account.UserName,
+	_, err = p.conn().ExecContext(ctx, stmt.ADD_OWNER, account.Name, account.UserName,
 		account.Email, account.Password, account.Created)
 	return err
 }
 
 func (p pgStore) GetOwner(ctx context.Context, name, username string) (account pk.Account, err error) {
 
-	err = p.db.QueryRow(stmt.GET_OWNER, name, username).Scan(&account.Name, &account.UserName,
+	err = p.conn().QueryRowContext(ctx, stmt.GET_OWNER, name, username).Scan(&account.Name, &account.UserName,
 		&account.Email, &account.Password, &account.Created)
 
 	return account, err
 }
 
+func (p pgStore) GetTwoFactor(ctx context.Context, name, username string) (tf pk.TwoFactor, err error) {
+	var seed []byte
+	var recovery pq.StringArray
+
+	//in a transaction the row stays locked until it ends, the code that
+	//is checked is then used only once
+	query := stmt.GET_TWO_FACTOR
+	if p.tx != nil {
+		query = stmt.GET_TWO_FACTOR_FOR_UPDATE
+	}
+
+	err = p.conn().QueryRowContext(ctx, query, name, username).Scan(&seed, &recovery)
+	if err != nil {
+		return tf, err
+	}
+
+	if seed != nil {
+		if err = json.Unmarshal(seed, &tf.OTP); err != nil {
+			return tf, err
+		}
+	}
+	if len(recovery) > 0 {
+		tf.Recovery = recovery
+	}
+
+	return tf, nil
+}
+
+func (p pgStore) SetTwoFactor(ctx context.Context, name, username string, tf pk.TwoFactor) (err error) {
+	seed, err := dbOTP(tf.OTP)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.conn().ExecContext(ctx, stmt.SET_TWO_FACTOR, name, username, seed, textArray(tf.Recovery))
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 func (p pgStore) CheckAccount(ctx context.Context, name, username string) (err error) {
 	panic("implement me")
 }
@@ -139,31 +310,401 @@ func (p pgStore) Add(ctx context.Context, account pk.DBAccount) (err error) {
 	digest := account.Digest
 	sgn := account.Signature
 	created := account.Created
-	_, err = p.db.Exec(stmt.ADD, name, username, email, hash, encoded, digest, sgn, created)
 
-	return err
+	fields, err := json.Marshal(dbFields(account.Fields))
+	if err != nil {
+		return err
+	}
+
+	seed, err := dbOTP(account.OTP)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	//an account in the trash is replaced, its password is kept in the
+	//history the new account takes over
+	replaced := time.Now().UTC().Format(time.RFC3339)
+	if _, err = tx.ExecContext(ctx, stmt.ARCHIVE_TRASHED, name, username, replaced); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, stmt.DROP_TRASHED, name, username); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt.ADD, name, username, email, hash, encoded, digest, sgn, created,
+		textArray(account.URLs), account.Notes, textArray(account.Tags), account.Folder, fields, seed)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (p pgStore) Get(ctx context.Context, name, username string) (account pk.DBAccount, err error) {
-	err = p.db.QueryRow(stmt.GET, name, username).
-		Scan(&account.Name, &account.UserName, &account.Email,
-			&account.Hash, &account.Encoded, &account.Digest,
-			&account.Signature, &account.Created)
+	account, err = scanAccount(p.conn().QueryRowContext(ctx, stmt.GET, name, username))
+
+	if err == sql.ErrNoRows {
+		return account, pk.ErrNotFound
+	}
 
 	return account, err
 }
 
 func (p pgStore) Delete(ctx context.Context, name, username string) (err error) {
-	panic("implement me")
+	deleted := time.Now().UTC().Format(time.RFC3339)
+	res, err := p.conn().ExecContext(ctx, stmt.DELETE, name, username, deleted)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return pk.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p pgStore) DeleteAll(ctx context.Context, filter map[string]string) (deleted int, err error) {
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		switch key {
+		case "name", "username", "email":
+		default:
+			return 0, errors.New(fmt.Sprintf("unknown column %v", key))
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := []interface{}{time.Now().UTC().Format(time.RFC3339)}
+	query := "UPDATE accounts SET deleted = $1 WHERE deleted = ''"
+	for _, key := range keys {
+		args = append(args, filter[key])
+		query += fmt.Sprintf(" AND %v = $%v", key, len(args))
+	}
+
+	res, err := p.conn().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (p pgStore) Trash(ctx context.Context) (accounts []pk.Trashed, err error) {
+	rows, err := p.conn().QueryContext(ctx, stmt.LIST_TRASH)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var account pk.Trashed
+		err = rows.Scan(&account.Name, &account.UserName, &account.Email, &account.Created, &account.Deleted)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (p pgStore) Restore(ctx context.Context, name, username string) (err error) {
+	res, err := p.conn().ExecContext(ctx, stmt.RESTORE, name, username)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return pk.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p pgStore) Purge(ctx context.Context, before string) (purged int, err error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	purgeHistory, purge, args := stmt.PURGE_HISTORY, stmt.PURGE, []interface{}{before}
+	if before == "" {
+		purgeHistory, purge, args = stmt.PURGE_HISTORY_ALL, stmt.PURGE_ALL, nil
+	}
+
+	if _, err = tx.ExecContext(ctx, purgeHistory, args...); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, purge, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
 }
 
 func (p pgStore) Update(ctx context.Context, name, username string, account pk.DBAccount) (err error) {
-	panic("implement me")
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	current, err := scanAccount(tx.QueryRowContext(ctx, stmt.GET_FOR_UPDATE, name, username))
+	if err == sql.ErrNoRows {
+		return pk.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(current.Encoded, account.Encoded) {
+		var version int
+		if err = tx.QueryRowContext(ctx, stmt.NEXT_VERSION, name, username).Scan(&version); err != nil {
+			return err
+		}
+
+		replaced := time.Now().UTC().Format(time.RFC3339)
+		_, err = tx.ExecContext(ctx, stmt.ADD_VERSION, name, username, version, current.Email,
+			current.Hash, current.Encoded, current.Digest, current.Signature, current.Created, replaced)
+		if err != nil {
+			return err
+		}
+	}
+
+	fields, err := json.Marshal(dbFields(account.Fields))
+	if err != nil {
+		return err
+	}
+
+	seed, err := dbOTP(account.OTP)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt.UPDATE, name, username, account.Name, account.UserName, account.Email,
+		account.Hash, account.Encoded, account.Digest, account.Signature, account.Created,
+		textArray(account.URLs), account.Notes, textArray(account.Tags), account.Folder, fields, seed)
+	if err != nil {
+		return err
+	}
+
+	if account.Name != name || account.UserName != username {
+		_, err = tx.ExecContext(ctx, stmt.RENAME_HISTORY, name, username, account.Name, account.UserName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p pgStore) History(ctx context.Context, name, username string) (versions []pk.DBVersion, err error) {
+	if _, err = p.Get(ctx, name, username); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.conn().QueryContext(ctx, stmt.LIST_HISTORY, name, username)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+func (p pgStore) GetVersion(ctx context.Context, name, username string, version int) (v pk.DBVersion, err error) {
+	if _, err = p.Get(ctx, name, username); err != nil {
+		return v, err
+	}
+
+	v, err = scanVersion(p.conn().QueryRowContext(ctx, stmt.GET_VERSION, name, username, version))
+	if err == sql.ErrNoRows {
+		return v, pk.ErrVersionNotFound
+	}
+
+	return v, err
+}
+
+func (p pgStore) AddVersion(ctx context.Context, v pk.DBVersion) (err error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	a := v.Account
+
+	var version int
+	if err = tx.QueryRowContext(ctx, stmt.NEXT_VERSION, a.Name, a.UserName).Scan(&version); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt.ADD_VERSION, a.Name, a.UserName, version, a.Email,
+		a.Hash, a.Encoded, a.Digest, a.Signature, a.Created, v.Replaced)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p pgStore) PruneHistory(ctx context.Context, name, username string, keep int, before string) (removed int, err error) {
+	if keep > 0 {
+		res, err := p.conn().ExecContext(ctx, stmt.PRUNE_COUNT, name, username, keep)
+		if err != nil {
+			return removed, err
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+
+	if before != "" {
+		res, err := p.conn().ExecContext(ctx, stmt.PRUNE_AGE, name, username, before)
+		if err != nil {
+			return removed, err
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+
+	return removed, nil
 }
 
-func (p pgStore) List(ctx context.Context) (accounts []pk.DBAccount, err error) {
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccount(row scanner) (account pk.DBAccount, err error) {
+	var urls, tags pq.StringArray
+	var fields, seed []byte
+
+	err = row.Scan(&account.Name, &account.UserName, &account.Email,
+		&account.Hash, &account.Encoded, &account.Digest,
+		&account.Signature, &account.Created,
+		&urls, &account.Notes, &tags, &account.Folder, &fields, &seed)
+	if err != nil {
+		return account, err
+	}
+
+	if len(urls) > 0 {
+		account.URLs = urls
+	}
+	if len(tags) > 0 {
+		account.Tags = tags
+	}
+	if err = json.Unmarshal(fields, &account.Fields); err != nil {
+		return account, err
+	}
+	if len(account.Fields) == 0 {
+		account.Fields = nil
+	}
+	if seed != nil {
+		if err = json.Unmarshal(seed, &account.OTP); err != nil {
+			return account, err
+		}
+	}
+
+	return account, nil
+}
+
+//textArray stores nil as an empty array, the columns are not null
+func textArray(a []string) pq.StringArray {
+	if a == nil {
+		return pq.StringArray{}
+	}
+	return a
+}
+
+//dbFields stores nil as an empty json array
+func dbFields(fields []pk.DBField) []pk.DBField {
+	if fields == nil {
+		return []pk.DBField{}
+	}
+	return fields
+}
+
+//dbOTP stores a missing seed as null
+func dbOTP(seed *pk.DBOTP) (interface{}, error) {
+	if seed == nil {
+		return nil, nil
+	}
+	return json.Marshal(seed)
+}
+
+func scanVersion(row scanner) (v pk.DBVersion, err error) {
+	err = row.Scan(&v.Version, &v.Account.Name, &v.Account.UserName, &v.Account.Email,
+		&v.Account.Hash, &v.Account.Encoded, &v.Account.Digest,
+		&v.Account.Signature, &v.Account.Created, &v.Replaced)
+	return v, err
+}
+
+func (p pgStore) List(ctx context.Context, query pk.Query) (accounts []pk.DBAccount, err error) {
+
+	sql, args, err := listQuery(query)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := p.db.Query(stmt.LIST)
+	rows, err := p.conn().QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -171,18 +712,10 @@ func (p pgStore) List(ctx context.Context) (accounts []pk.DBAccount, err error)
 	defer rows.Close()
 
 	for rows.Next() {
-		var account pk.DBAccount
-
-		err = rows.Scan(
-			&account.Name,
-			&account.UserName,
-			&account.Email,
-			&account.Hash,
-			&account.Encoded,
-			account.Digest,
-			&account.Signature,
-			&account.Created,
-		)
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
 
 		accounts = append(accounts, account)
 	}
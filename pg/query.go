@@ -0,0 +1,154 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/sql/stmt"
+)
+
+//createdAt orders accounts by creation time, accounts without one come
+//first
+var createdAt = timestamp("created")
+
+//rfc3339 matches the creation times that can be cast to timestamptz
+const rfc3339 = `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`
+
+//timestamp casts the text expr to timestamptz. Like the kdbx store it
+//takes anything that is not an RFC3339 time as the unix epoch, a bad
+//created value of one account would fail the cast of the whole query
+func timestamp(expr string) string {
+	return fmt.Sprintf("CASE WHEN %v ~ '%v' THEN (%v)::timestamptz ELSE 'epoch'::timestamptz END", expr, rfc3339, expr)
+}
+
+//sortColumns maps the pk.Sort fields to the expressions they order by
+var sortColumns = map[string]string{
+	pk.SortName:     "name",
+	pk.SortUserName: "username",
+	pk.SortEmail:    "email",
+	pk.SortCreated:  createdAt,
+}
+
+//listQuery builds the SELECT of query. Pages are read with keyset
+//pagination on the sort column followed by the primary key, so a page
+//never skips or repeats accounts added or removed in between
+func listQuery(query pk.Query) (string, []interface{}, error) {
+	if err := query.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	var where []string
+	var args []interface{}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where = append(where, "deleted = ''")
+
+	for _, match := range [][2]string{
+		{"name", query.Name},
+		{"username", query.UserName},
+		{"email", query.Email},
+	} {
+		if match[1] != "" {
+			where = append(where, fmt.Sprintf("%v ILIKE %v", match[0], arg(likePattern(match[1]))))
+		}
+	}
+
+	if query.Tag != "" {
+		where = append(where, fmt.Sprintf("%v = ANY(tags)", arg(query.Tag)))
+	}
+
+	if folder := strings.Trim(query.Folder, "/"); folder != "" {
+		where = append(where, fmt.Sprintf("(folder = %v OR folder LIKE %v)",
+			arg(folder), arg(escapeLike(folder)+"/%")))
+	}
+
+	if !query.CreatedBefore.IsZero() {
+		where = append(where, fmt.Sprintf("%v < %v::timestamptz", createdAt, arg(query.CreatedBefore.Format(time.RFC3339))))
+	}
+
+	if !query.CreatedAfter.IsZero() {
+		where = append(where, fmt.Sprintf("%v > %v::timestamptz", createdAt, arg(query.CreatedAfter.Format(time.RFC3339))))
+	}
+
+	field, desc := query.SortField()
+	column := sortColumns[field]
+
+	after, err := query.After()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if after != nil {
+		value := arg(after.Value)
+		if field == pk.SortCreated {
+			value = timestamp(value + "::text")
+		}
+
+		op := ">"
+		if desc {
+			op = "<"
+		}
+
+		where = append(where, fmt.Sprintf("(%v, name, username) %v (%v, %v, %v)",
+			column, op, value, arg(after.Name), arg(after.UserName)))
+	}
+
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+
+	sql := fmt.Sprintf("SELECT %v FROM accounts WHERE %v ORDER BY %v %v, name %v, username %v",
+		stmt.ACCOUNT_COLUMNS, strings.Join(where, " AND "), column, order, order, order)
+
+	if query.PageSize > 0 {
+		sql += fmt.Sprintf(" LIMIT %v", arg(query.PageSize))
+	}
+
+	return sql + ";", args, nil
+}
+
+//likePattern turns a glob into a LIKE pattern, anything else matches as a
+//substring
+func likePattern(pattern string) string {
+	if !pk.IsGlob(pattern) {
+		return "%" + escapeLike(pattern) + "%"
+	}
+
+	var b strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteRune('%')
+		case '?':
+			b.WriteRune('_')
+		default:
+			b.WriteString(escapeLike(string(r)))
+		}
+	}
+
+	return b.String()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
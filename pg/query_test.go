@@ -0,0 +1,106 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pg
+
+import (
+	"encoding/base64"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+func TestLikePattern(t *testing.T) {
+	tests := map[string]string{
+		"git":      "%git%",
+		"git*":     "git%",
+		"g?t*hub":  "g_t%hub",
+		"50%_off":  `%50\%\_off%`,
+		`a\b*`:     `a\\b%`,
+		"*@ex.com": "%@ex.com",
+	}
+
+	for pattern, want := range tests {
+		if got := likePattern(pattern); got != want {
+			t.Errorf("likePattern(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestRFC3339(t *testing.T) {
+	//postgres and go agree on this pattern, what go matches here is what
+	//the created cast accepts
+	tests := map[string]bool{
+		"2021-03-04T05:06:07Z":          true,
+		"2021-03-04T05:06:07.123+02:00": true,
+		"":                              false,
+		"yesterday":                     false,
+		"2021-03-04":                    false,
+		"2021-03-04 05:06:07":           false,
+		"2021-03-04T05:06:07Z; DROP x":  false,
+	}
+
+	re := regexp.MustCompile(rfc3339)
+	for created, want := range tests {
+		if got := re.MatchString(created); got != want {
+			t.Errorf("rfc3339 matches %q = %v, want %v", created, got, want)
+		}
+	}
+}
+
+func TestListQuery(t *testing.T) {
+	sql, args, err := listQuery(pk.Query{Name: "git*", Tag: "work", Sort: "-created", PageSize: 20})
+	if err != nil {
+		t.Fatalf("listQuery() unexpected error: %v", err)
+	}
+
+	for _, part := range []string{
+		"deleted = ''",
+		"name ILIKE $1",
+		"$2 = ANY(tags)",
+		"ORDER BY " + createdAt + " DESC, name DESC, username DESC",
+		"LIMIT $3;",
+	} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("listQuery() = %q, want it to contain %q", sql, part)
+		}
+	}
+
+	if want := []interface{}{"git%", "work", 20}; !reflect.DeepEqual(args, want) {
+		t.Errorf("listQuery() args = %v, want %v", args, want)
+	}
+
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"email","v":"a@b.c","n":"github","u":"alice"}`))
+	sql, args, err = listQuery(pk.Query{Sort: "email", Cursor: cursor})
+	if err != nil {
+		t.Fatalf("listQuery() unexpected error: %v", err)
+	}
+	if !strings.Contains(sql, "(email, name, username) > ($1, $2, $3)") {
+		t.Errorf("listQuery() = %q, want it to start after the cursor", sql)
+	}
+	if want := []interface{}{"a@b.c", "github", "alice"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("listQuery() args = %v, want %v", args, want)
+	}
+
+	if _, _, err = listQuery(pk.Query{Sort: "name", Cursor: cursor}); !errors.Contains(err, pk.ErrInvalidArgs) {
+		t.Errorf("listQuery() error = %v, want %v for a cursor of another sort", err, pk.ErrInvalidArgs)
+	}
+
+	if _, _, err = listQuery(pk.Query{Sort: "password"}); !errors.Contains(err, pk.ErrInvalidArgs) {
+		t.Errorf("listQuery() error = %v, want %v", err, pk.ErrInvalidArgs)
+	}
+}
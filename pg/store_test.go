@@ -0,0 +1,304 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+//testDSNEnv names a postgres database the store tests make a schema of
+//their own in, e.g "host=localhost user=postgres password=postgres
+//sslmode=disable". The tests are skipped when it is not set
+const testDSNEnv = "PK_TEST_POSTGRES"
+
+//testStore returns a store over an empty schema that is dropped by the
+//returned func
+func testStore(t *testing.T) (pk.PasswordStore, func()) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%v is not set", testDSNEnv)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := fmt.Sprintf("pk_test_%d", time.Now().UnixNano())
+	if _, err = admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	sep := " "
+	if strings.Contains(dsn, "://") {
+		sep = "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn+sep+"search_path="+schema)
+	if err == nil {
+		err = migrate(db)
+	}
+
+	cleanup := func() {
+		if db != nil {
+			db.Close()
+		}
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	}
+
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return NewStore(db), cleanup
+}
+
+//testAccount returns an account as a keeper without real keys would
+//store it, the encoded password is the password itself
+func testAccount(name, username, password string) pk.DBAccount {
+	return pk.DBAccount{
+		Name:      name,
+		UserName:  username,
+		Email:     username + "@example.com",
+		Hash:      "hash:" + password,
+		Encoded:   []byte(password),
+		Digest:    []byte("digest:" + password),
+		Signature: []byte("signature:" + password),
+		Created:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+//encoded returns the passwords of versions
+func encoded(versions []pk.DBVersion) []string {
+	var got []string
+	for _, v := range versions {
+		got = append(got, fmt.Sprintf("%d:%s", v.Version, v.Account.Encoded))
+	}
+	return got
+}
+
+func TestStoreHistory(t *testing.T) {
+	store, cleanup := testStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if err := store.Add(ctx, testAccount("github", "alice", "pw1")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	//a new password archives the old one, other changes do not
+	if err := store.Update(ctx, "github", "alice", testAccount("github", "alice", "pw2")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	notes := testAccount("github", "alice", "pw2")
+	notes.Notes = "main"
+	if err := store.Update(ctx, "github", "alice", notes); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	versions, err := store.History(ctx, "github", "alice")
+	if got := strings.Join(encoded(versions), ","); err != nil || got != "1:pw1" {
+		t.Errorf("History() = %v, %v, want 1:pw1", got, err)
+	}
+
+	//the history follows a rename
+	if err = store.Update(ctx, "github", "alice", testAccount("github.com", "alice", "pw2")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err = store.History(ctx, "github", "alice"); !errors.Contains(err, pk.ErrNotFound) {
+		t.Errorf("History() of the old name error = %v, want %v", err, pk.ErrNotFound)
+	}
+	v, err := store.GetVersion(ctx, "github.com", "alice", 1)
+	if err != nil || string(v.Account.Encoded) != "pw1" {
+		t.Errorf("GetVersion() = %+v, %v, want pw1", v, err)
+	}
+	if _, err = store.GetVersion(ctx, "github.com", "alice", 9); !errors.Contains(err, pk.ErrVersionNotFound) {
+		t.Errorf("GetVersion() error = %v, want %v", err, pk.ErrVersionNotFound)
+	}
+
+	//added versions keep the time they were replaced
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	if err = store.AddVersion(ctx, pk.DBVersion{Account: testAccount("github.com", "alice", "pw0"), Replaced: old}); err != nil {
+		t.Fatalf("AddVersion() error = %v", err)
+	}
+	if err = store.Update(ctx, "github.com", "alice", testAccount("github.com", "alice", "pw3")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	versions, _ = store.History(ctx, "github.com", "alice")
+	if got := strings.Join(encoded(versions), ","); got != "3:pw2,2:pw0,1:pw1" || versions[1].Replaced != old {
+		t.Errorf("History() = %v, want 3:pw2,2:pw0,1:pw1 with version 2 replaced at %v", got, old)
+	}
+
+	//pruning by count keeps the newest versions, by age the recent ones
+	removed, err := store.PruneHistory(ctx, "github.com", "alice", 2, "")
+	if err != nil || removed != 1 {
+		t.Errorf("PruneHistory(keep 2) = %v, %v, want 1 removed", removed, err)
+	}
+	removed, err = store.PruneHistory(ctx, "github.com", "alice", 0, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	if err != nil || removed != 1 {
+		t.Errorf("PruneHistory(1h) = %v, %v, want 1 removed", removed, err)
+	}
+
+	versions, _ = store.History(ctx, "github.com", "alice")
+	if got := strings.Join(encoded(versions), ","); got != "3:pw2" {
+		t.Errorf("History() after pruning = %v, want 3:pw2", got)
+	}
+}
+
+func TestStoreTrash(t *testing.T) {
+	store, cleanup := testStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, a := range []pk.DBAccount{
+		testAccount("github", "alice", "pw1"),
+		testAccount("gitlab", "alice", "shared"),
+		//accounts may share a password
+		testAccount("gmail", "alice", "shared"),
+	} {
+		if err := store.Add(ctx, a); err != nil {
+			t.Fatalf("Add(%v) error = %v", a.Name, err)
+		}
+	}
+	if err := store.Update(ctx, "github", "alice", testAccount("github", "alice", "pw2")); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	//a deleted account only shows up in the trash
+	if err := store.Delete(ctx, "github", "alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete(ctx, "github", "alice"); !errors.Contains(err, pk.ErrNotFound) {
+		t.Errorf("Delete() of a trashed account error = %v, want %v", err, pk.ErrNotFound)
+	}
+	if _, err := store.Get(ctx, "github", "alice"); !errors.Contains(err, pk.ErrNotFound) {
+		t.Errorf("Get() of a trashed account error = %v, want %v", err, pk.ErrNotFound)
+	}
+	accounts, err := store.List(ctx, pk.Query{})
+	if err != nil || len(accounts) != 2 {
+		t.Errorf("List() = %v accounts, %v, want 2", len(accounts), err)
+	}
+	trash, err := store.Trash(ctx)
+	if err != nil || len(trash) != 1 || trash[0].Name != "github" || trash[0].Deleted == "" {
+		t.Errorf("Trash() = %+v, %v, want github", trash, err)
+	}
+
+	//restoring brings the account back with its history
+	if err = store.Restore(ctx, "github", "alice"); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if err = store.Restore(ctx, "github", "alice"); !errors.Contains(err, pk.ErrNotFound) {
+		t.Errorf("Restore() of a live account error = %v, want %v", err, pk.ErrNotFound)
+	}
+	versions, err := store.History(ctx, "github", "alice")
+	if got := strings.Join(encoded(versions), ","); err != nil || got != "1:pw1" {
+		t.Errorf("History() after restore = %v, %v, want 1:pw1", got, err)
+	}
+
+	//adding over a trashed account keeps its password in history, even
+	//when the new one is the same
+	if err = store.Delete(ctx, "github", "alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err = store.Add(ctx, testAccount("github", "alice", "pw2")); err != nil {
+		t.Fatalf("Add() over the trash error = %v", err)
+	}
+	versions, _ = store.History(ctx, "github", "alice")
+	if got := strings.Join(encoded(versions), ","); got != "2:pw2,1:pw1" {
+		t.Errorf("History() after adding over the trash = %v, want 2:pw2,1:pw1", got)
+	}
+	if trash, _ = store.Trash(ctx); len(trash) != 0 {
+		t.Errorf("Trash() = %+v, want it empty", trash)
+	}
+
+	//purging removes what was deleted before the time and its history
+	if err = store.Delete(ctx, "github", "alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err = store.Delete(ctx, "gmail", "alice"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	purged, err := store.Purge(ctx, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	if err != nil || purged != 0 {
+		t.Errorf("Purge(1h ago) = %v, %v, want nothing purged", purged, err)
+	}
+	//no time purges the whole trash, this second included
+	purged, err = store.Purge(ctx, "")
+	if err != nil || purged != 2 {
+		t.Errorf("Purge(\"\") = %v, %v, want 2 purged", purged, err)
+	}
+
+	//nothing of github is left to come back
+	if err = store.Add(ctx, testAccount("github", "alice", "pw3")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	versions, _ = store.History(ctx, "github", "alice")
+	if len(versions) != 0 {
+		t.Errorf("History() after purge = %v, want none", encoded(versions))
+	}
+}
+
+func TestStoreTransaction(t *testing.T) {
+	store, cleanup := testStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	//methods with transactions of their own join the one of Transaction
+	fail := errors.New("fail")
+	err := store.Transaction(ctx, func(tx pk.PasswordStore) error {
+		if err := tx.Add(ctx, testAccount("github", "alice", "pw1")); err != nil {
+			return err
+		}
+		if err := tx.Update(ctx, "github", "alice", testAccount("github", "alice", "pw2")); err != nil {
+			return err
+		}
+		return fail
+	})
+	if err != fail {
+		t.Fatalf("Transaction() error = %v, want %v", err, fail)
+	}
+	if _, err = store.Get(ctx, "github", "alice"); !errors.Contains(err, pk.ErrNotFound) {
+		t.Errorf("Get() after a failed transaction error = %v, want %v", err, pk.ErrNotFound)
+	}
+
+	err = store.Transaction(ctx, func(tx pk.PasswordStore) error {
+		if err := tx.Add(ctx, testAccount("github", "alice", "pw1")); err != nil {
+			return err
+		}
+		return tx.Update(ctx, "github", "alice", testAccount("github", "alice", "pw2"))
+	})
+	if err != nil {
+		t.Fatalf("Transaction() error = %v", err)
+	}
+	versions, err := store.History(ctx, "github", "alice")
+	if got := strings.Join(encoded(versions), ","); err != nil || got != "1:pw1" {
+		t.Errorf("History() = %v, %v, want 1:pw1", got, err)
+	}
+}
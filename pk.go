@@ -16,7 +16,9 @@ package pk
 import (
 	"context"
 	"fmt"
+	"github.com/hackaio/pk/otp"
 	"github.com/hackaio/pk/pkg/errors"
+	"strings"
 	"time"
 )
 
@@ -31,25 +33,94 @@ var (
 	ErrPermissionDenied = errors.New("permission denied")
 	ErrInternalError    = errors.New("internal error, possible db compromise")
 	ErrCriticalFailure  = errors.New("could not perform critical operation")
+	ErrNotFound         = errors.New("account not found")
+	ErrVersionNotFound  = errors.New("version not found")
+	ErrInvalidArgs      = errors.New("invalid arguments")
+	ErrCodeRequired     = errors.New("two factor code required")
 )
 
 type Account struct {
-	Name     string `json:"name,omitempty"`
-	UserName string `json:"username,omitempty"`
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password,omitempty"`
-	Created  string `json:"created,omitempty"`
+	Name     string   `json:"name,omitempty" yaml:"name,omitempty"`
+	UserName string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Email    string   `json:"email,omitempty" yaml:"email,omitempty"`
+	Password string   `json:"password,omitempty" yaml:"password,omitempty"`
+	Created  string   `json:"created,omitempty" yaml:"created,omitempty"`
+	URLs     []string `json:"urls,omitempty" yaml:"urls,omitempty"`
+	Notes    string   `json:"notes,omitempty" yaml:"notes,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Folder   string   `json:"folder,omitempty" yaml:"folder,omitempty"`
+	Fields   []Field  `json:"fields,omitempty" yaml:"fields,omitempty"`
+	OTP      string   `json:"otp,omitempty" yaml:"otp,omitempty"`
+}
+
+//Field is a custom key value pair of an account, secret values are
+//encrypted like passwords
+type Field struct {
+	Name   string `json:"name" yaml:"name"`
+	Value  string `json:"value" yaml:"value"`
+	Secret bool   `json:"secret,omitempty" yaml:"secret,omitempty"`
 }
 
 type DBAccount struct {
-	Name      string `json:"name,omitempty"`
-	UserName  string `json:"username,omitempty"`
-	Email     string `json:"email,omitempty"`
-	Hash      string `json:"hash,omitempty"`
-	Encoded   []byte `json:"encoded,omitempty"`
-	Digest    []byte `json:"digest,omitempty"`
-	Signature []byte `json:"signature,omitempty"`
-	Created   string `json:"created,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	UserName  string    `json:"username,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	Encoded   []byte    `json:"encoded,omitempty"`
+	Digest    []byte    `json:"digest,omitempty"`
+	Signature []byte    `json:"signature,omitempty"`
+	Created   string    `json:"created,omitempty"`
+	URLs      []string  `json:"urls,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Folder    string    `json:"folder,omitempty"`
+	Fields    []DBField `json:"fields,omitempty"`
+	OTP       *DBOTP    `json:"otp,omitempty"`
+}
+
+//DBField is a Field as stored, secret fields keep their value in Encoded
+type DBField struct {
+	Name    string `json:"name"`
+	Value   string `json:"value,omitempty"`
+	Encoded []byte `json:"encoded,omitempty"`
+	Secret  bool   `json:"secret,omitempty"`
+}
+
+//DBOTP is the two factor seed of an account as stored, the secret is
+//encrypted like passwords
+type DBOTP struct {
+	Type      string `json:"type"`
+	Issuer    string `json:"issuer,omitempty"`
+	Account   string `json:"account,omitempty"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period,omitempty"`
+	Counter   uint64 `json:"counter,omitempty"`
+	Encoded   []byte `json:"encoded"`
+}
+
+//Version is a previous password of an account, versions are numbered
+//from 1 per account
+type Version struct {
+	Version  int     `json:"version"`
+	Account  Account `json:"account"`
+	Replaced string  `json:"replaced"`
+}
+
+type DBVersion struct {
+	Version  int       `json:"version"`
+	Account  DBAccount `json:"account"`
+	Replaced string    `json:"replaced"`
+}
+
+//Trashed is a deleted account waiting in the trash to be restored or
+//purged, it holds no secrets
+type Trashed struct {
+	Name     string `json:"name" yaml:"name"`
+	UserName string `json:"username" yaml:"username"`
+	Email    string `json:"email" yaml:"email"`
+	Created  string `json:"created" yaml:"created"`
+	Deleted  string `json:"deleted" yaml:"deleted"`
 }
 
 func (a Account) toDBAccount(keeper passwordKeeper) (DBAccount, error) {
@@ -72,6 +143,18 @@ func (a Account) toDBAccount(keeper passwordKeeper) (DBAccount, error) {
 		return DBAccount{}, err
 	}
 
+	fields, err := encodeFields(keeper, a.Fields)
+
+	if err != nil {
+		return DBAccount{}, err
+	}
+
+	seed, err := encodeOTP(keeper, a)
+
+	if err != nil {
+		return DBAccount{}, err
+	}
+
 	return DBAccount{
 		Name:      a.Name,
 		UserName:  a.UserName,
@@ -81,9 +164,21 @@ func (a Account) toDBAccount(keeper passwordKeeper) (DBAccount, error) {
 		Digest:    digestB,
 		Signature: signB,
 		Created:   a.Created,
+		URLs:      a.URLs,
+		Notes:     a.Notes,
+		Tags:      cleanTags(a.Tags),
+		Folder:    cleanFolder(a.Folder),
+		Fields:    fields,
+		OTP:       seed,
 	}, nil
 }
 
+//EncodeAccount returns account as a PasswordStore keeps it, for stores
+//that are filled from elsewhere than a PasswordKeeper
+func EncodeAccount(hasher Hasher, es EncoderSigner, account Account) (DBAccount, error) {
+	return account.toDBAccount(passwordKeeper{hash: hasher, es: es})
+}
+
 func (a DBAccount) toAccount(keeper passwordKeeper) (Account, error) {
 
 	pass, err := keeper.es.Decode(a.Encoded)
@@ -91,15 +186,182 @@ func (a DBAccount) toAccount(keeper passwordKeeper) (Account, error) {
 	if err != nil {
 		return Account{}, err
 	}
+
+	fields, err := decodeFields(keeper, a.Fields)
+
+	if err != nil {
+		return Account{}, err
+	}
+
+	seed, err := decodeOTP(keeper, a.OTP)
+
+	if err != nil {
+		return Account{}, err
+	}
+
 	return Account{
 		Name:     a.Name,
 		UserName: a.UserName,
 		Email:    a.Email,
 		Password: pass,
 		Created:  a.Created,
+		URLs:     a.URLs,
+		Notes:    a.Notes,
+		Tags:     a.Tags,
+		Folder:   a.Folder,
+		Fields:   fields,
+		OTP:      seed,
+	}, nil
+}
+
+func encodeFields(keeper passwordKeeper, fields []Field) ([]DBField, error) {
+	var dbFields []DBField
+	seen := map[string]bool{}
+
+	for _, f := range fields {
+		name := strings.TrimSpace(f.Name)
+		if name == "" {
+			return nil, errors.Wrap(ErrInvalidArgs, errors.New("custom field without a name"))
+		}
+		if seen[name] {
+			return nil, errors.Wrap(ErrInvalidArgs, errors.New(fmt.Sprintf("custom field %v given twice", name)))
+		}
+		seen[name] = true
+
+		if !f.Secret {
+			dbFields = append(dbFields, DBField{Name: name, Value: f.Value})
+			continue
+		}
+
+		encoded, err := keeper.es.Encode(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		dbFields = append(dbFields, DBField{Name: name, Encoded: encoded, Secret: true})
+	}
+
+	return dbFields, nil
+}
+
+func decodeFields(keeper passwordKeeper, dbFields []DBField) ([]Field, error) {
+	var fields []Field
+
+	for _, f := range dbFields {
+		if !f.Secret {
+			fields = append(fields, Field{Name: f.Name, Value: f.Value})
+			continue
+		}
+
+		value, err := keeper.es.Decode(f.Encoded)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{Name: f.Name, Value: value, Secret: true})
+	}
+
+	return fields, nil
+}
+
+//encodeOTP encrypts the seed of a.OTP, an otpauth:// URI or a bare base32
+//TOTP secret. The label defaults to the name and username of a
+func encodeOTP(keeper passwordKeeper, a Account) (*DBOTP, error) {
+	if a.OTP == "" {
+		return nil, nil
+	}
+
+	var key otp.Key
+	var err error
+	if strings.HasPrefix(a.OTP, "otpauth://") {
+		key, err = otp.Parse(a.OTP)
+	} else {
+		key, err = otp.NewKey(a.OTP)
+	}
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidArgs, err)
+	}
+
+	if key.Issuer == "" {
+		key.Issuer = a.Name
+	}
+	if key.Account == "" {
+		key.Account = a.UserName
+	}
+
+	encoded, err := keeper.es.Encode(otp.EncodeSecret(key.Secret))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DBOTP{
+		Type:      key.Type,
+		Issuer:    key.Issuer,
+		Account:   key.Account,
+		Algorithm: key.Algorithm,
+		Digits:    key.Digits,
+		Period:    key.Period,
+		Counter:   key.Counter,
+		Encoded:   encoded,
 	}, nil
 }
 
+//decodeOTP returns the otpauth:// URI of the stored seed
+func decodeOTP(keeper passwordKeeper, seed *DBOTP) (string, error) {
+	if seed == nil {
+		return "", nil
+	}
+
+	secret, err := keeper.es.Decode(seed.Encoded)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := otp.DecodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	key := otp.Key{
+		Type:      seed.Type,
+		Issuer:    seed.Issuer,
+		Account:   seed.Account,
+		Secret:    raw,
+		Algorithm: seed.Algorithm,
+		Digits:    seed.Digits,
+		Period:    seed.Period,
+		Counter:   seed.Counter,
+	}
+
+	return key.URI(), nil
+}
+
+//cleanTags trims tags and drops empty and repeated ones
+func cleanTags(tags []string) []string {
+	var cleaned []string
+	seen := map[string]bool{}
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+
+	return cleaned
+}
+
+//cleanFolder turns folder into a path such as work/dev
+func cleanFolder(folder string) string {
+	var parts []string
+	for _, part := range strings.Split(folder, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
 
 //PasswordKeeper
 type PasswordKeeper interface {
@@ -113,8 +375,9 @@ type PasswordKeeper interface {
 	//Login function returns token after a user has supplied
 	//his correct username and password or else an error
 	//It also works fine if the email is supplied in place of
-	//username
-	Login(ctx context.Context, username, password string) (token string, err error)
+	//username. When two factor authentication is on, code must be the
+	//current code of the authenticator app or an unused recovery code
+	Login(ctx context.Context, username, password, code string) (token string, err error)
 
 	//Add a new account. It takes token and new account details.
 	//The method returns err if the process is not allowed
@@ -132,14 +395,14 @@ type PasswordKeeper interface {
 	//name e.g github
 	Delete(ctx context.Context, token, name, username string) (err error)
 
-	//List returns all the accounts registered under the master
-	//accounts
-	List(ctx context.Context, token string, args map[string]interface{}) (accounts []Account, err error)
+	//List returns the accounts matching query. When query has a PageSize
+	//and more accounts follow, next is the Cursor of the next page
+	List(ctx context.Context, token string, query Query) (accounts []Account, next string, err error)
 
 	//Updates the details of the account
 	//name and username of the account as of right now
 	//Account should have new username and password or email
-	Update(ctx context.Context, token, name, username, account Account) (acc Account, err error)
+	Update(ctx context.Context, token, name, username string, account Account) (acc Account, err error)
 
 	//AddAll is an API for bulk addition. where a lot of accounts are added all at once
 	AddAll(ctx context.Context, token string, accounts []Account) (err error)
@@ -148,6 +411,51 @@ type PasswordKeeper interface {
 	//e.g You want to delete all accounts by name of instagram
 	//or you want to delete all accounts registered under a certain email address
 	DeleteAll(ctx context.Context, token string, args map[string]interface{}) (err error)
+
+	//History returns the previous passwords of the account, newest first.
+	//A version is kept every time Update or Rollback replaces the password
+	History(ctx context.Context, token, name, username string) (versions []Version, err error)
+
+	//Rollback makes the password of version the current one. The replaced
+	//password is kept in history so a rollback can be undone
+	Rollback(ctx context.Context, token, name, username string, version int) (account Account, err error)
+
+	//PruneHistory removes the versions of the account beyond the newest keep
+	//and those replaced more than maxAge ago. Zero turns a limit off
+	PruneHistory(ctx context.Context, token, name, username string, keep int, maxAge time.Duration) (removed int, err error)
+
+	//Trash lists the deleted accounts, Delete and DeleteAll only move
+	//accounts to the trash
+	Trash(ctx context.Context, token string) (accounts []Trashed, err error)
+
+	//Restore takes the account out of the trash
+	Restore(ctx context.Context, token, name, username string) (err error)
+
+	//Purge removes the accounts deleted more than olderThan ago for good,
+	//along with their history. Zero empties the trash
+	Purge(ctx context.Context, token string, olderThan time.Duration) (purged int, err error)
+
+	//Search fuzzy ranks the accounts by name, username, email, urls and
+	//tags against text, best first. Limit caps the matches when set
+	Search(ctx context.Context, token, text string, limit int) (matches []Match, err error)
+
+	//EnableTwoFactor makes Login ask the master account for a code.
+	//secret is the base32 seed added to the authenticator app and code
+	//one it shows, proving it was set up. Every recovery code works once
+	//in place of a code, they are not shown again
+	EnableTwoFactor(ctx context.Context, token, secret, code string) (recovery []string, err error)
+
+	//DisableTwoFactor turns the second factor off, code is a current code
+	//or a recovery code
+	DisableTwoFactor(ctx context.Context, token, code string) (err error)
+
+	//Export returns every account with its history, decrypted. Accounts in
+	//the trash are left out
+	Export(ctx context.Context, token string) (entries []Entry, err error)
+
+	//Import adds the entries exported by Export, conflict says what to do
+	//with accounts that already exist
+	Import(ctx context.Context, token string, entries []Entry, conflict Conflict) (report ImportReport, err error)
 }
 
 type passwordKeeper struct {
@@ -195,7 +503,7 @@ func (p passwordKeeper) Register(ctx context.Context, username, email, password
 	return nil
 }
 
-func (p passwordKeeper) Login(ctx context.Context, username, password string) (tokenStr string, err error) {
+func (p passwordKeeper) Login(ctx context.Context, username, password, code string) (tokenStr string, err error) {
 
 	account, err := p.passwords.GetOwner(ctx, "master", username)
 	if err != nil {
@@ -209,6 +517,11 @@ func (p passwordKeeper) Login(ctx context.Context, username, password string) (t
 		return "", err1
 	}
 
+	err = p.checkSecondFactor(ctx, account.UserName, code)
+	if err != nil {
+		return "", err
+	}
+
 	token := NewToken(account.UserName)
 
 	tokenStr, err = p.tokenizer.Issue(token)
@@ -225,21 +538,28 @@ func (p passwordKeeper) Add(ctx context.Context, token string, account Account)
 	//fixme: check the id in token and compare it to master
 	_, err1 := p.tokenizer.Parse(token)
 	if err1 != nil {
-		err1 := errors.New(fmt.Sprintf("error while parsing the token: %v\n", err))
-		return err1
+		return errors.Wrap(ErrPermissionDenied, err1)
+	}
+
+	if account.Created == "" {
+		account.Created = time.Now().Format(time.RFC3339)
 	}
 
 	dbAccount, err2 := account.toDBAccount(p)
 
+	if errors.Contains(err2, ErrInvalidArgs) {
+		return err2
+	}
+
 	if err2 != nil {
-		err1 := errors.New(fmt.Sprintf("error while encrypting user details: %v\n", err))
+		err1 := errors.New(fmt.Sprintf("error while encrypting user details: %v\n", err2))
 		return err1
 	}
 
 	err3 := p.passwords.Add(ctx, dbAccount)
 
 	if err3 != nil {
-		err1 := errors.New(fmt.Sprintf("could not store user details: %v\n", err))
+		err1 := errors.New(fmt.Sprintf("could not store user details: %v\n", err3))
 		return err1
 	}
 
@@ -251,11 +571,13 @@ func (p passwordKeeper) Get(ctx context.Context, token, name, username string) (
 	_, err = p.tokenizer.Parse(token)
 
 	if err != nil {
-		err1 := errors.New(fmt.Sprintf("error while parsing the token: %v\n", err))
-		return Account{}, err1
+		return Account{}, errors.Wrap(ErrPermissionDenied, err)
 	}
 
 	dbAccount, err := p.passwords.Get(ctx, name, username)
+	if errors.Contains(err, ErrNotFound) {
+		return Account{}, ErrNotFound
+	}
 	if err != nil {
 		err1 := errors.New(fmt.Sprintf("error while retrieving user details: %v\n", err))
 		return Account{}, err1
@@ -272,39 +594,241 @@ func (p passwordKeeper) Get(ctx context.Context, token, name, username string) (
 }
 
 func (p passwordKeeper) Delete(ctx context.Context, token, name, username string) (err error) {
-	panic("implement me")
+
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	err = p.passwords.Delete(ctx, name, username)
+
+	if errors.Contains(err, ErrNotFound) {
+		return ErrNotFound
+	}
+
+	if err != nil {
+		return errors.Wrap(ErrInternalError, err)
+	}
+
+	return nil
 }
 
-func (p passwordKeeper) List(ctx context.Context, token string, args map[string]interface{}) (accounts []Account, err error) {
+func (p passwordKeeper) List(ctx context.Context, token string, query Query) (accounts []Account, next string, err error) {
 
 	_, err = p.tokenizer.Parse(token)
 
 	if err != nil {
-		return nil, errors.Wrap(ErrPermissionDenied, err)
+		return nil, "", errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	if err = query.Validate(); err != nil {
+		return nil, "", err
 	}
 
-	dbAccounts, err := p.passwords.List(ctx)
+	//one more account than asked for tells whether a next page exists
+	q := query
+	if q.PageSize > 0 {
+		q.PageSize++
+	}
+
+	dbAccounts, err := p.passwords.List(ctx, q)
 
 	if err != nil {
-		return nil, errors.Wrap(ErrInternalError, err)
+		return nil, "", errors.Wrap(ErrInternalError, err)
+	}
+
+	if query.PageSize > 0 && len(dbAccounts) > query.PageSize {
+		dbAccounts = dbAccounts[:query.PageSize]
+		next = query.cursorAfter(dbAccounts[len(dbAccounts)-1])
 	}
 
 	for _, dba := range dbAccounts {
 		a, err := dba.toAccount(p)
 
+		//an account that can not be decoded is not left out silently
 		if err != nil {
-			//fixme
-			continue
+			err1 := errors.New(fmt.Sprintf("error while decoding %v/%v: %v\n", dba.Name, dba.UserName, err))
+			return nil, "", err1
 		}
 
 		accounts = append(accounts, a)
 	}
 
-	return accounts, nil
+	return accounts, next, nil
+}
+
+//Update changes the fields of account that are set, the store keeps the
+//replaced password in history
+func (p passwordKeeper) Update(ctx context.Context, token, name, username string, account Account) (acc Account, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return Account{}, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	current, err := p.passwords.Get(ctx, name, username)
+	if errors.Contains(err, ErrNotFound) {
+		return Account{}, ErrNotFound
+	}
+	if err != nil {
+		return Account{}, errors.Wrap(ErrInternalError, err)
+	}
+
+	updated := current
+	if account.Name != "" {
+		updated.Name = account.Name
+	}
+	if account.UserName != "" {
+		updated.UserName = account.UserName
+	}
+	if account.Email != "" {
+		updated.Email = account.Email
+	}
+	if account.URLs != nil {
+		updated.URLs = account.URLs
+	}
+	if account.Notes != "" {
+		updated.Notes = account.Notes
+	}
+	if account.Tags != nil {
+		updated.Tags = cleanTags(account.Tags)
+	}
+	if account.Folder != "" {
+		updated.Folder = cleanFolder(account.Folder)
+	}
+	if account.OTP != "" {
+		account.Name, account.UserName = updated.Name, updated.UserName
+		updated.OTP, err = encodeOTP(p, account)
+		if errors.Contains(err, ErrInvalidArgs) {
+			return Account{}, err
+		}
+		if err != nil {
+			err1 := errors.New(fmt.Sprintf("error while encrypting user details: %v\n", err))
+			return Account{}, err1
+		}
+	}
+	if account.Fields != nil {
+		updated.Fields, err = encodeFields(p, account.Fields)
+		if errors.Contains(err, ErrInvalidArgs) {
+			return Account{}, err
+		}
+		if err != nil {
+			err1 := errors.New(fmt.Sprintf("error while encrypting user details: %v\n", err))
+			return Account{}, err1
+		}
+	}
+
+	//the password is only encrypted again when it changes, the store
+	//archives a version whenever the encrypted password differs
+	if account.Password != "" {
+		a := Account{Password: account.Password}
+
+		encoded, err := a.toDBAccount(p)
+		if err != nil {
+			err1 := errors.New(fmt.Sprintf("error while encrypting user details: %v\n", err))
+			return Account{}, err1
+		}
+
+		updated.Hash = encoded.Hash
+		updated.Encoded = encoded.Encoded
+		updated.Digest = encoded.Digest
+		updated.Signature = encoded.Signature
+		updated.Created = time.Now().Format(time.RFC3339)
+	}
+
+	if err = p.passwords.Update(ctx, name, username, updated); err != nil {
+		return Account{}, errors.Wrap(ErrInternalError, err)
+	}
+
+	return updated.toAccount(p)
+}
+
+func (p passwordKeeper) History(ctx context.Context, token, name, username string) (versions []Version, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	dbVersions, err := p.passwords.History(ctx, name, username)
+	if errors.Contains(err, ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(ErrInternalError, err)
+	}
+
+	versions = []Version{}
+	for _, v := range dbVersions {
+		account, err := v.Account.toAccount(p)
+		if err != nil {
+			err1 := errors.New(fmt.Sprintf("error while decoding version %v: %v\n", v.Version, err))
+			return nil, err1
+		}
+
+		versions = append(versions, Version{Version: v.Version, Account: account, Replaced: v.Replaced})
+	}
+
+	return versions, nil
+}
+
+func (p passwordKeeper) Rollback(ctx context.Context, token, name, username string, version int) (account Account, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return Account{}, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	v, err := p.passwords.GetVersion(ctx, name, username, version)
+	if errors.Contains(err, ErrNotFound) {
+		return Account{}, ErrNotFound
+	}
+	if errors.Contains(err, ErrVersionNotFound) {
+		return Account{}, ErrVersionNotFound
+	}
+	if err != nil {
+		return Account{}, errors.Wrap(ErrInternalError, err)
+	}
+
+	current, err := p.passwords.Get(ctx, name, username)
+	if err != nil {
+		return Account{}, errors.Wrap(ErrInternalError, err)
+	}
+
+	//only the password is rolled back, the account keeps its other details
+	restored := current
+	restored.Hash = v.Account.Hash
+	restored.Encoded = v.Account.Encoded
+	restored.Digest = v.Account.Digest
+	restored.Signature = v.Account.Signature
+	restored.Created = v.Account.Created
+
+	if err = p.passwords.Update(ctx, name, username, restored); err != nil {
+		return Account{}, errors.Wrap(ErrInternalError, err)
+	}
+
+	return restored.toAccount(p)
 }
 
-func (p passwordKeeper) Update(ctx context.Context, token, name, username, account Account) (acc Account, err error) {
-	panic("implement me")
+func (p passwordKeeper) PruneHistory(ctx context.Context, token, name, username string, keep int, maxAge time.Duration) (removed int, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return 0, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	var before string
+	if maxAge > 0 {
+		before = time.Now().Add(-maxAge).UTC().Format(time.RFC3339)
+	}
+
+	removed, err = p.passwords.PruneHistory(ctx, name, username, keep, before)
+	if err != nil {
+		return 0, errors.Wrap(ErrInternalError, err)
+	}
+
+	return removed, nil
 }
 
 func (p passwordKeeper) AddAll(ctx context.Context, token string, accounts []Account) (err error) {
@@ -315,23 +839,10 @@ func (p passwordKeeper) AddAll(ctx context.Context, token string, accounts []Acc
 		return errors.Wrap(ErrPermissionDenied, err1)
 	}
 
-	for index, acc := range accounts {
-		fmt.Printf("adding account no: %v\n", index+1)
-		var a Account
+	for _, acc := range accounts {
 		var d DBAccount
-		now := time.Now().Format(time.RFC3339)
-		name := acc.Name
-		username := acc.UserName
-		password := acc.Password
-		email := acc.Email
-
-		a = Account{
-			Name:     name,
-			UserName: username,
-			Email:    email,
-			Password: password,
-			Created:  now,
-		}
+		a := acc
+		a.Created = time.Now().Format(time.RFC3339)
 
 		d, err = a.toDBAccount(p)
 
@@ -350,6 +861,93 @@ func (p passwordKeeper) AddAll(ctx context.Context, token string, accounts []Acc
 	return nil
 }
 
+//DeleteAll moves the accounts matching every one of args to the trash.
+//args may hold name, username and email, at least one is needed
 func (p passwordKeeper) DeleteAll(ctx context.Context, token string, args map[string]interface{}) (err error) {
-	panic("implement me")
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	filter := map[string]string{}
+	for key, value := range args {
+		switch key {
+		case "name", "username", "email":
+		default:
+			return errors.Wrap(ErrInvalidArgs, errors.New(fmt.Sprintf("unknown key %v", key)))
+		}
+
+		v, ok := value.(string)
+		if !ok || v == "" {
+			return errors.Wrap(ErrInvalidArgs, errors.New(fmt.Sprintf("%v should be a non empty string", key)))
+		}
+		filter[key] = v
+	}
+
+	if len(filter) == 0 {
+		return errors.Wrap(ErrInvalidArgs, errors.New("give name, username or email of the accounts to delete"))
+	}
+
+	_, err = p.passwords.DeleteAll(ctx, filter)
+	if err != nil {
+		return errors.Wrap(ErrInternalError, err)
+	}
+
+	return nil
+}
+
+func (p passwordKeeper) Trash(ctx context.Context, token string) (accounts []Trashed, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	accounts, err = p.passwords.Trash(ctx)
+	if err != nil {
+		return nil, errors.Wrap(ErrInternalError, err)
+	}
+
+	return accounts, nil
+}
+
+func (p passwordKeeper) Restore(ctx context.Context, token, name, username string) (err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	err = p.passwords.Restore(ctx, name, username)
+	if errors.Contains(err, ErrNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return errors.Wrap(ErrInternalError, err)
+	}
+
+	return nil
+}
+
+func (p passwordKeeper) Purge(ctx context.Context, token string, olderThan time.Duration) (purged int, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return 0, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	//deletion times are kept to the second, an account trashed within
+	//the current second would be left over by a time limit of now
+	var before string
+	if olderThan > 0 {
+		before = time.Now().Add(-olderThan).UTC().Format(time.RFC3339)
+	}
+
+	purged, err = p.passwords.Purge(ctx, before)
+	if err != nil {
+		return 0, errors.Wrap(ErrInternalError, err)
+	}
+
+	return purged, nil
 }
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package files
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// SecretPerm is used for every file holding secrets.
+const SecretPerm os.FileMode = 0600
+
+var ErrFileExists = errors.New("file already exists")
+
+// WriteFile writes the output of write to path atomically: the data goes
+// to a temporary file in the same directory which is renamed over path
+// once it is complete. Existing files are only replaced when overwrite
+// is set, otherwise the temporary file is linked to path so a file
+// created there in the meantime is not replaced either.
+func WriteFile(path string, perm os.FileMode, overwrite bool, write func(w io.Writer) error) (err error) {
+	// fail before writing anything, the link below is what guarantees it
+	if !overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return existsError(path)
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if overwrite {
+		return os.Rename(tmp.Name(), path)
+	}
+
+	// unlike a rename, a link fails when path exists
+	if err = os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			err = existsError(path)
+		}
+		return err
+	}
+
+	// path holds the data now, a leftover temporary file is only litter
+	_ = os.Remove(tmp.Name())
+	return nil
+}
+
+func existsError(path string) error {
+	return errors.Wrap(ErrFileExists, errors.New(fmt.Sprintf("%v, use --force to overwrite it", path)))
+}
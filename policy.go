@@ -0,0 +1,152 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hackaio/pk/strength"
+)
+
+var _ PasswordKeeper = (*policyMiddleware)(nil)
+
+//policyMiddleware refuses passwords that do not meet the policy, the
+//methods that do not take passwords are passed through
+type policyMiddleware struct {
+	PasswordKeeper
+	policy strength.Policy
+}
+
+//PolicyMiddleware checks every password given to Register, Add, AddAll,
+//Update and Import, and the one Rollback restores, against policy.
+//Failures are returned as a *strength.ValidationError, Import reports
+//them instead
+func PolicyMiddleware(policy strength.Policy) Middleware {
+	return func(keeper PasswordKeeper) PasswordKeeper {
+		return &policyMiddleware{PasswordKeeper: keeper, policy: policy}
+	}
+}
+
+func (p policyMiddleware) Register(ctx context.Context, username, email, password string) (err error) {
+	if err := p.policy.Validate(password, username, email); err != nil {
+		return err
+	}
+
+	return p.PasswordKeeper.Register(ctx, username, email, password)
+}
+
+func (p policyMiddleware) Add(ctx context.Context, token string, account Account) (err error) {
+	if err := p.validate(account); err != nil {
+		return err
+	}
+
+	return p.PasswordKeeper.Add(ctx, token, account)
+}
+
+func (p policyMiddleware) Update(ctx context.Context, token, name, username string, account Account) (acc Account, err error) {
+	//updates that keep the password are not checked
+	if account.Password != "" {
+		if account.Name == "" {
+			account.Name = name
+		}
+		if account.UserName == "" {
+			account.UserName = username
+		}
+		if err := p.validate(account); err != nil {
+			return Account{}, err
+		}
+	}
+
+	return p.PasswordKeeper.Update(ctx, token, name, username, account)
+}
+
+//AddAll adds the accounts that meet the policy, the error lists the
+//failures of every other account. The accepted accounts stay added when
+//the error is returned
+func (p policyMiddleware) AddAll(ctx context.Context, token string, accounts []Account) (err error) {
+	var accepted []Account
+	var failures []strength.Failure
+
+	for _, account := range accounts {
+		if f := p.check(account); len(f) > 0 {
+			failures = append(failures, f...)
+			continue
+		}
+		accepted = append(accepted, account)
+	}
+
+	if len(accepted) > 0 || len(failures) == 0 {
+		if err = p.PasswordKeeper.AddAll(ctx, token, accepted); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		return &strength.ValidationError{Failures: failures}
+	}
+
+	return nil
+}
+
+//Rollback refuses to restore a version whose password does not meet the
+//policy, it may predate the policy
+func (p policyMiddleware) Rollback(ctx context.Context, token, name, username string, v int) (account Account, err error) {
+	restored, ok, err := versionAccount(ctx, p.PasswordKeeper, token, name, username, v)
+	if err != nil {
+		return Account{}, err
+	}
+	if ok {
+		if err := p.validate(restored); err != nil {
+			return Account{}, err
+		}
+	}
+
+	return p.PasswordKeeper.Rollback(ctx, token, name, username, v)
+}
+
+//Import leaves out the entries whose password does not meet the policy,
+//they are counted as rejected in the report. Old passwords in the
+//history of an entry are not checked
+func (p policyMiddleware) Import(ctx context.Context, token string, entries []Entry, conflict Conflict) (report ImportReport, err error) {
+	var accepted []Entry
+	var failures []strength.Failure
+
+	for _, e := range entries {
+		if f := p.check(e.Account); len(f) > 0 {
+			failures = append(failures, f...)
+			continue
+		}
+		accepted = append(accepted, e)
+	}
+
+	report, err = p.PasswordKeeper.Import(ctx, token, accepted, conflict)
+	report.Rejected += len(entries) - len(accepted)
+	report.Failures = append(report.Failures, failures...)
+
+	return report, err
+}
+
+//check returns the rules account fails, each naming the account
+func (p policyMiddleware) check(account Account) []strength.Failure {
+	failures := p.policy.Check(account.Password, account.UserName, account.Email, account.Name)
+	for i := range failures {
+		failures[i].Account = fmt.Sprintf("%v/%v", account.Name, account.UserName)
+	}
+	return failures
+}
+
+func (p policyMiddleware) validate(account Account) error {
+	return p.policy.Validate(account.Password, account.UserName, account.Email, account.Name)
+}
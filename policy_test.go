@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/strength"
+)
+
+//recorder keeps what reaches the keeper behind a middleware
+type recorder struct {
+	PasswordKeeper
+	added   []Account
+	entries []Entry
+}
+
+func (r *recorder) AddAll(ctx context.Context, token string, accounts []Account) error {
+	r.added = append(r.added, accounts...)
+	return nil
+}
+
+func (r *recorder) Import(ctx context.Context, token string, entries []Entry, conflict Conflict) (ImportReport, error) {
+	r.entries = append(r.entries, entries...)
+	return ImportReport{Added: len(entries)}, nil
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	strong := Account{Name: "github", UserName: "alice", Password: "correct horse battery staple"}
+	weak := Account{Name: "gmail", UserName: "bob", Password: "abc"}
+
+	next := &recorder{}
+	keeper := PolicyMiddleware(strength.DefaultPolicy)(next)
+	ctx := context.Background()
+
+	//one weak row does not keep the others out
+	err := keeper.AddAll(ctx, "token", []Account{strong, weak})
+	verr, ok := err.(*strength.ValidationError)
+	if !ok || !errors.Contains(err, strength.ErrPolicyViolation) {
+		t.Fatalf("AddAll() error = %v, want a %T", err, verr)
+	}
+	for _, f := range verr.Failures {
+		if f.Account != "gmail/bob" {
+			t.Errorf("AddAll() failure of %v, want only gmail/bob", f.Account)
+		}
+	}
+	if !reflect.DeepEqual(next.added, []Account{strong}) {
+		t.Errorf("AddAll() added %+v, want only the strong account", next.added)
+	}
+
+	report, err := keeper.Import(ctx, "token", []Entry{{Account: strong}, {Account: weak}}, ConflictSkip)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if report.Added != 1 || report.Rejected != 1 || len(report.Failures) == 0 || report.Failures[0].Account != "gmail/bob" {
+		t.Errorf("Import() = %+v, want gmail/bob rejected", report)
+	}
+	if !reflect.DeepEqual(next.entries, []Entry{{Account: strong}}) {
+		t.Errorf("Import() passed on %+v, want only the strong account", next.entries)
+	}
+}
+
+func TestPolicyRollback(t *testing.T) {
+	inner, _ := newTestKeeper()
+	keeper := PolicyMiddleware(strength.DefaultPolicy)(inner)
+	ctx := context.Background()
+
+	//the weak password was stored before the policy applied
+	if err := inner.Add(ctx, "token", Account{Name: "github", UserName: "alice", Password: "abc"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, err := keeper.Update(ctx, "token", "github", "alice", Account{Password: "correct horse battery staple"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	_, err := keeper.Rollback(ctx, "token", "github", "alice", 1)
+	if _, ok := err.(*strength.ValidationError); !ok {
+		t.Fatalf("Rollback() to a weak password error = %v, want a *strength.ValidationError", err)
+	}
+	if account, _ := inner.Get(ctx, "token", "github", "alice"); account.Password != "correct horse battery staple" {
+		t.Errorf("Get() after a refused Rollback() = %v, want the strong password kept", account.Password)
+	}
+
+	if _, err = keeper.Rollback(ctx, "token", "github", "alice", 9); err != ErrVersionNotFound {
+		t.Errorf("Rollback() of a missing version error = %v, want %v", err, ErrVersionNotFound)
+	}
+}
@@ -0,0 +1,347 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package qr
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// bitmap is a black and white image, true is dark.
+type bitmap struct {
+	w, h int
+	dark []bool
+}
+
+func (b *bitmap) inside(x, y int) bool {
+	return x >= 0 && y >= 0 && x < b.w && y < b.h
+}
+
+func (b *bitmap) at(x, y int) bool {
+	return b.inside(x, y) && b.dark[y*b.w+x]
+}
+
+// binarize splits img into dark and light with Otsu's threshold,
+// transparent pixels count as white.
+func binarize(img image.Image) *bitmap {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	lum := make([]uint8, w*h)
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			l := (299*r+587*g+114*b)/1000 + 0xffff - a
+			if l > 0xffff {
+				l = 0xffff
+			}
+			lum[y*w+x] = uint8(l >> 8)
+			hist[l>>8]++
+		}
+	}
+
+	threshold := otsu(hist, w*h)
+
+	bm := &bitmap{w: w, h: h, dark: make([]bool, w*h)}
+	for i, l := range lum {
+		bm.dark[i] = int(l) <= threshold
+	}
+	return bm
+}
+
+// otsu returns the luminance that best separates the two classes of hist.
+func otsu(hist [256]int, total int) int {
+	var sum float64
+	for i, n := range hist {
+		sum += float64(i * n)
+	}
+
+	var sumDark float64
+	dark, best, threshold := 0, -1.0, 127
+	for t, n := range hist {
+		dark += n
+		if dark == 0 {
+			continue
+		}
+		light := total - dark
+		if light == 0 {
+			break
+		}
+
+		sumDark += float64(t * n)
+		meanDark := sumDark / float64(dark)
+		meanLight := (sum - sumDark) / float64(light)
+
+		between := float64(dark) * float64(light) * (meanDark - meanLight) * (meanDark - meanLight)
+		if between > best {
+			best, threshold = between, t
+		}
+	}
+	return threshold
+}
+
+// finder is a candidate finder pattern center and its module size.
+type finder struct {
+	x, y, module float64
+	count        int
+}
+
+// ratioOK reports whether the runs look like the 1:1:3:1:1 dark light
+// dark light dark profile of a finder pattern.
+func ratioOK(runs [5]int) bool {
+	total := 0
+	for _, n := range runs {
+		if n == 0 {
+			return false
+		}
+		total += n
+	}
+	if total < 7 {
+		return false
+	}
+
+	module := float64(total) / 7
+	variance := module / 2
+	for i, n := range runs {
+		want := module
+		if i == 2 {
+			want = 3 * module
+		}
+		if math.Abs(want-float64(n)) >= variance*want/module {
+			return false
+		}
+	}
+	return true
+}
+
+// findFinders scans every row for finder profiles and confirms them
+// across the columns.
+func findFinders(b *bitmap) []finder {
+	var found []finder
+
+	for y := 0; y < b.h; y++ {
+		var runs [5]int
+		state := 0
+
+		for x := 0; x <= b.w; x++ {
+			if x < b.w && b.at(x, y) {
+				if state&1 == 1 {
+					state++
+				}
+				runs[state]++
+				continue
+			}
+
+			switch {
+			case state&1 == 1:
+				runs[state]++
+			case state == 0 && runs[0] == 0:
+				// light before the first dark run
+			case state < 4:
+				state++
+				runs[state]++
+			default:
+				if ratioOK(runs) {
+					cx := float64(x-runs[4]-runs[3]) - float64(runs[2])/2
+					found = confirm(b, found, cx, y, runs)
+				}
+				runs = [5]int{runs[2], runs[3], runs[4], 1, 0}
+				state = 3
+			}
+		}
+	}
+
+	return found
+}
+
+// confirm checks the profile found at (cx, y) vertically and again
+// horizontally, and merges it with the candidates already found.
+func confirm(b *bitmap, found []finder, cx float64, y int, runs [5]int) []finder {
+	total := 0
+	for _, n := range runs {
+		total += n
+	}
+
+	cy, vTotal, ok := crossCheck(b, int(cx), y, 0, 1, total)
+	if !ok {
+		return found
+	}
+	cx, hTotal, ok := crossCheck(b, int(cx), int(cy), 1, 0, total)
+	if !ok {
+		return found
+	}
+
+	module := float64(vTotal+hTotal) / 14
+
+	for i, f := range found {
+		if math.Abs(f.x-cx) <= module && math.Abs(f.y-cy) <= module &&
+			math.Abs(f.module-module) <= math.Max(1, f.module/2) {
+			n := float64(f.count)
+			found[i] = finder{
+				x:      (f.x*n + cx) / (n + 1),
+				y:      (f.y*n + cy) / (n + 1),
+				module: (f.module*n + module) / (n + 1),
+				count:  f.count + 1,
+			}
+			return found
+		}
+	}
+
+	return append(found, finder{x: cx, y: cy, module: module, count: 1})
+}
+
+// crossCheck measures the finder through (x, y) along (dx, dy). It
+// returns the center on that axis and the length of the pattern, which
+// should be close to the total of the profile that led to it.
+func crossCheck(b *bitmap, x, y, dx, dy, total int) (float64, int, bool) {
+	if !b.at(x, y) {
+		return 0, 0, false
+	}
+
+	var runs [5]int
+
+	// from the center backwards, then forwards
+	back := 0
+	for b.at(x-back*dx, y-back*dy) {
+		back++
+	}
+	i := back
+	for run := 1; run >= 0; run-- {
+		dark := run == 0
+		for b.inside(x-i*dx, y-i*dy) && b.at(x-i*dx, y-i*dy) == dark && runs[run] <= total {
+			runs[run]++
+			i++
+		}
+	}
+
+	fwd := 0
+	for b.at(x+(fwd+1)*dx, y+(fwd+1)*dy) {
+		fwd++
+	}
+	runs[2] = back + fwd
+	j := fwd + 1
+	for run := 3; run <= 4; run++ {
+		dark := run == 4
+		for b.inside(x+j*dx, y+j*dy) && b.at(x+j*dx, y+j*dy) == dark && runs[run] <= total {
+			runs[run]++
+			j++
+		}
+	}
+
+	if !ratioOK(runs) {
+		return 0, 0, false
+	}
+
+	sum := 0
+	for _, n := range runs {
+		sum += n
+	}
+	if 5*abs(sum-total) >= 2*total {
+		return 0, 0, false
+	}
+
+	pos := x
+	if dy != 0 {
+		pos = y
+	}
+	return float64(pos) + float64(fwd-back+2)/2, sum, true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func distance(a, b finder) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// pickFinders chooses the three candidates that best form the corners of
+// a square and returns them as top left, top right and bottom left.
+func pickFinders(found []finder) (tl, tr, bl finder, ok bool) {
+	sort.SliceStable(found, func(i, j int) bool { return found[i].count > found[j].count })
+	if len(found) > 12 {
+		found = found[:12]
+	}
+
+	best := math.MaxFloat64
+	for i := 0; i < len(found); i++ {
+		for j := i + 1; j < len(found); j++ {
+			for k := j + 1; k < len(found); k++ {
+				a, b, c := found[i], found[j], found[k]
+
+				lo := math.Min(a.module, math.Min(b.module, c.module))
+				hi := math.Max(a.module, math.Max(b.module, c.module))
+				if hi > 1.5*lo {
+					continue
+				}
+
+				// the corner is opposite the longest side
+				ab, bc, ac := distance(a, b), distance(b, c), distance(a, c)
+				corner, p, q, hyp := c, a, b, ab
+				if bc > hyp {
+					corner, p, q, hyp = a, b, c, bc
+				}
+				if ac > hyp {
+					corner, p, q, hyp = b, a, c, ac
+				}
+
+				l1, l2 := distance(corner, p), distance(corner, q)
+				if l1 < 10*lo || l2 < 10*lo {
+					continue
+				}
+
+				skew := math.Abs(l1-l2) / math.Max(l1, l2)
+				square := math.Abs(hyp*hyp-l1*l1-l2*l2) / (hyp * hyp)
+				if skew > 0.2 || square > 0.2 {
+					continue
+				}
+
+				if score := skew + square; score < best {
+					best, tl, tr, bl, ok = score, corner, p, q, true
+				}
+			}
+		}
+	}
+
+	// top right is clockwise from bottom left, with y growing downwards
+	if ok && (tr.x-tl.x)*(bl.y-tl.y)-(tr.y-tl.y)*(bl.x-tl.x) < 0 {
+		tr, bl = bl, tr
+	}
+
+	return tl, tr, bl, ok
+}
+
+// sample reads a dim by dim symbol whose finder centers are tl, tr and bl.
+func sample(b *bitmap, tl, tr, bl finder, dim int) grid {
+	span := float64(dim - 7)
+	ux, uy := (tr.x-tl.x)/span, (tr.y-tl.y)/span
+	vx, vy := (bl.x-tl.x)/span, (bl.y-tl.y)/span
+
+	g := newGrid(dim)
+	for row := range g {
+		for col := range g[row] {
+			// the finder centers are at module 3.5
+			c, r := float64(col)-3, float64(row)-3
+			x := tl.x + c*ux + r*vx
+			y := tl.y + c*uy + r*vy
+			g[row][col] = b.at(int(math.Floor(x)), int(math.Floor(y)))
+		}
+	}
+	return g
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package qr reads QR codes from images. It is meant for the codes sites
+// show when setting up two factor authentication, saved as screenshots or
+// image files: the symbol may be scaled or turned, but not photographed
+// at an angle.
+package qr
+
+import (
+	"image"
+	"math"
+
+	// image formats Decode accepts
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+var (
+	ErrNotFound   = errors.New("no qr code found")
+	ErrUnreadable = errors.New("unreadable qr code")
+)
+
+// Decode returns the text of the QR code in img.
+func Decode(img image.Image) (string, error) {
+	b := binarize(img)
+
+	tl, tr, bl, ok := pickFinders(findFinders(b))
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	// the finder centers are dim - 7 modules apart
+	module := (tl.module + tr.module + bl.module) / 3
+	modules := (distance(tl, tr)+distance(tl, bl))/2/module + 7
+	dim := int(math.Round((modules-17)/4))*4 + 17
+
+	// the neighbouring sizes are tried when the estimate is off, the
+	// error reported is that of the estimate
+	var first error
+	for _, d := range []int{dim, dim - 4, dim + 4} {
+		if d < 21 || d > 177 {
+			continue
+		}
+
+		text, err := decodeGrid(sample(b, tl, tr, bl, d))
+		if err == nil {
+			return text, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+
+	if first == nil {
+		return "", ErrNotFound
+	}
+	return "", errors.Wrap(ErrUnreadable, first)
+}
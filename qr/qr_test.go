@@ -0,0 +1,353 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// the "HELLO WORLD" 1-M example of ISO/IEC 18004 annex I
+var (
+	helloData = []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	helloEC   = []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+)
+
+func TestTables(t *testing.T) {
+	for v := 1; v <= 40; v++ {
+		free := 0
+		for _, row := range functionMask(v) {
+			for _, fn := range row {
+				if !fn {
+					free++
+				}
+			}
+		}
+		if free != rawModules(v) {
+			t.Errorf("version %d has %d data modules, want %d", v, free, rawModules(v))
+		}
+
+		for level := levelL; level <= levelH; level++ {
+			nb, shortLen, _, ecLen := blockSizes(v, level)
+			if shortLen-ecLen < 1 || nb*ecLen >= rawModules(v)/8 {
+				t.Errorf("version %d level %d has invalid blocks", v, level)
+			}
+		}
+	}
+
+	capacities := map[int][4]int{
+		1:  {19, 16, 13, 9},
+		2:  {34, 28, 22, 16},
+		5:  {108, 86, 62, 46},
+		7:  {156, 124, 88, 66},
+		10: {274, 216, 154, 122},
+		40: {2956, 2334, 1666, 1276},
+	}
+	for v, want := range capacities {
+		for level, data := range want {
+			nb, _, _, ecLen := blockSizes(v, level)
+			if got := rawModules(v)/8 - nb*ecLen; got != data {
+				t.Errorf("version %d level %d holds %d data codewords, want %d", v, level, got, data)
+			}
+		}
+	}
+
+	if got := formatCode(1<<3 | 4); got != 0x662f {
+		t.Errorf("formatCode(L, 4) = %015b, want 110011000101111", got)
+	}
+	if got := versionCode(7); got != 0x07c94 {
+		t.Errorf("versionCode(7) = %#x, want 0x07c94", got)
+	}
+	if got := alignmentPositions(32); fmt.Sprint(got) != "[6 34 60 86 112 138]" {
+		t.Errorf("alignmentPositions(32) = %v", got)
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	if got := rsEncode(helloData, len(helloEC)); !bytes.Equal(got, helloEC) {
+		t.Fatalf("rsEncode() = %v, want %v", got, helloEC)
+	}
+
+	block := append(append([]byte{}, helloData...), helloEC...)
+	for _, i := range []int{0, 3, 7, 12, 25} {
+		block[i] ^= 0x5a
+	}
+	if err := correct(block, len(helloEC)); err != nil {
+		t.Fatalf("correct() error = %v", err)
+	}
+	if !bytes.Equal(block[:len(helloData)], helloData) {
+		t.Errorf("correct() = %v, want %v", block[:len(helloData)], helloData)
+	}
+
+	for _, i := range []int{1, 2, 4, 5, 6, 8} {
+		block[i] ^= 0xff
+	}
+	if err := correct(block, len(helloEC)); err == nil && bytes.Equal(block[:len(helloData)], helloData) {
+		t.Errorf("correct() fixed more errors than the code allows")
+	}
+}
+
+func TestDecodeSegments(t *testing.T) {
+	got, err := decodeSegments(helloData, 1)
+	if err != nil || got != "HELLO WORLD" {
+		t.Errorf("decodeSegments() = %q, %v, want HELLO WORLD", got, err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	uri := "otpauth://totp/ACME%20Co:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=ACME%20Co&algorithm=SHA1&digits=6&period=30"
+
+	tests := []struct {
+		text                 string
+		version, level, mask int
+		scale, turns, damage int
+	}{
+		{text: "hello", version: 1, level: levelM, mask: 0, scale: 1},
+		{text: uri, version: 7, level: levelM, mask: 2, scale: 4},
+		{text: uri, version: 8, level: levelM, mask: 5, scale: 3, turns: 1},
+		{text: uri, version: 10, level: levelH, mask: 7, scale: 2, turns: 2, damage: 12},
+		{text: uri, version: 6, level: levelL, mask: 3, scale: 5, turns: 3, damage: 6},
+		{text: strings.Repeat(uri, 4), version: 25, level: levelM, mask: 4, scale: 2},
+	}
+
+	for _, tt := range tests {
+		name := fmt.Sprintf("v%d-l%d-m%d", tt.version, tt.level, tt.mask)
+		t.Run(name, func(t *testing.T) {
+			g := encode(t, tt.text, tt.version, tt.level, tt.mask)
+
+			// break damage codewords spread over the symbol
+			if tt.damage > 0 {
+				step := rawModules(tt.version) / 8 / tt.damage
+				i := 0
+				dataModules(tt.version, func(x, y int) {
+					if i%8 == 0 && i/8%step == 0 && i/8/step < tt.damage {
+						g[y][x] = !g[y][x]
+					}
+					i++
+				})
+			}
+
+			img := render(g, tt.scale)
+			for i := 0; i < tt.turns; i++ {
+				img = turn(img)
+			}
+
+			got, err := Decode(img)
+			if err != nil || got != tt.text {
+				t.Errorf("Decode() = %q, %v, want %q", got, err, tt.text)
+			}
+		})
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, 100, 100))
+	if _, err := Decode(blank); !errors.Contains(err, ErrNotFound) {
+		t.Errorf("Decode() of a blank image error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+// encode draws text as a byte segment, a reference encoder for the tests.
+func encode(t *testing.T, text string, version, level, mask int) grid {
+	nb, shortLen, short, ecLen := blockSizes(version, level)
+	dataLen := shortLen - ecLen
+	capacity := rawModules(version)/8 - nb*ecLen
+
+	var bits []bool
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>uint(i)&1 == 1)
+		}
+	}
+	put(modeByte, 4)
+	put(len(text), countBits(modeByte, version))
+	for i := 0; i < len(text); i++ {
+		put(int(text[i]), 8)
+	}
+	for i := 0; i < 4 && len(bits) < capacity*8; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	data := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			data[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	if len(data) > capacity {
+		t.Fatalf("%d bytes do not fit version %d", len(text), version)
+	}
+	for pad := byte(0xec); len(data) < capacity; pad ^= 0xec ^ 0x11 {
+		data = append(data, pad)
+	}
+
+	var blocks [][]byte
+	for j, off := 0, 0; j < nb; j++ {
+		n := dataLen
+		if j >= short {
+			n++
+		}
+		d := data[off : off+n]
+		off += n
+		blocks = append(blocks, append(append([]byte{}, d...), rsEncode(d, ecLen)...))
+	}
+
+	var codewords []byte
+	for i := 0; i <= shortLen; i++ {
+		for j, block := range blocks {
+			idx := i
+			if j < short {
+				if i == dataLen {
+					continue
+				}
+				if i > dataLen {
+					idx--
+				}
+			}
+			if idx < len(block) {
+				codewords = append(codewords, block[idx])
+			}
+		}
+	}
+
+	dim := dimension(version)
+	g := newGrid(dim)
+
+	for i := 0; i < dim; i++ {
+		g[6][i] = i%2 == 0
+		g[i][6] = i%2 == 0
+	}
+
+	square := func(cx, cy, r int, dark func(d int) bool) {
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				x, y := cx+dx, cy+dy
+				if x >= 0 && y >= 0 && x < dim && y < dim {
+					d := abs(dx)
+					if abs(dy) > d {
+						d = abs(dy)
+					}
+					g[y][x] = dark(d)
+				}
+			}
+		}
+	}
+	finderRing := func(d int) bool { return d <= 1 || d == 3 }
+	square(3, 3, 4, finderRing)
+	square(dim-4, 3, 4, finderRing)
+	square(3, dim-4, 4, finderRing)
+
+	pos := alignmentPositions(version)
+	last := len(pos) - 1
+	for i, y := range pos {
+		for j, x := range pos {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			square(x, y, 2, func(d int) bool { return d != 1 })
+		}
+	}
+
+	g[dim-8][8] = true
+
+	if version >= 7 {
+		code := versionCode(version)
+		first, second := versionPositions(dim)
+		for i := range first {
+			bit := code>>uint(17-i)&1 == 1
+			g[first[i][1]][first[i][0]] = bit
+			g[second[i][1]][second[i][0]] = bit
+		}
+	}
+
+	bitsOfLevel := map[int]int{levelL: 1, levelM: 0, levelQ: 3, levelH: 2}
+	format := formatCode(bitsOfLevel[level]<<3 | mask)
+	first, second := formatPositions(dim)
+	for i := range first {
+		bit := format>>uint(14-i)&1 == 1
+		g[first[i][1]][first[i][0]] = bit
+		g[second[i][1]][second[i][0]] = bit
+	}
+
+	i := 0
+	dataModules(version, func(x, y int) {
+		bit := i < len(codewords)*8 && codewords[i/8]>>uint(7-i%8)&1 == 1
+		g[y][x] = bit != masked(mask, y, x)
+		i++
+	})
+
+	return g
+}
+
+// rsEncode returns the error correction codewords of data.
+func rsEncode(data []byte, ecLen int) []byte {
+	gen := make([]byte, ecLen)
+	gen[ecLen-1] = 1
+	root := byte(1)
+	for i := 0; i < ecLen; i++ {
+		for j := 0; j < ecLen; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < ecLen {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+
+	rem := make([]byte, ecLen)
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[ecLen-1] = 0
+		for i := range rem {
+			rem[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return rem
+}
+
+// render draws g with a four module quiet zone, scale pixels per module.
+func render(g grid, scale int) image.Image {
+	size := (len(g) + 8) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+			mx, my := x/scale-4, y/scale-4
+			if mx >= 0 && my >= 0 && mx < len(g) && my < len(g) && g[my][mx] {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+	return img
+}
+
+// turn rotates img a quarter clockwise.
+func turn(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.Set(b.Dy()-1-y, x, img.At(x, y))
+		}
+	}
+	return out
+}
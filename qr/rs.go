@@ -0,0 +1,174 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package qr
+
+import (
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// QR codes use Reed-Solomon codes over GF(256) with the primitive
+// polynomial x^8 + x^4 + x^3 + x^2 + 1, the generator has the roots
+// α^0 to α^(n-1) for n error correction codewords.
+const primitive = 0x11d
+
+var (
+	gfExp [512]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= primitive
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// gfPow is α^n.
+func gfPow(n int) byte {
+	return gfExp[n%255]
+}
+
+// poly is a polynomial with its constant term first.
+type poly []byte
+
+func (p poly) coeff(i int) byte {
+	if i < len(p) {
+		return p[i]
+	}
+	return 0
+}
+
+func (p poly) eval(x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
+
+// correct fixes up to ecLen/2 wrong codewords of block in place, the
+// last ecLen codewords of block are the error correction ones.
+func correct(block []byte, ecLen int) error {
+	n := len(block)
+
+	synd := make(poly, ecLen)
+	clean := true
+	for i := range synd {
+		a := gfPow(i)
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, a) ^ c
+		}
+		synd[i] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return nil
+	}
+
+	// Berlekamp-Massey finds the error locator
+	lambda, prev := poly{1}, poly{1}
+	l, m, b := 0, 1, byte(1)
+	for r := 0; r < ecLen; r++ {
+		d := synd[r]
+		for i := 1; i <= l; i++ {
+			d ^= gfMul(lambda.coeff(i), synd[r-i])
+		}
+		if d == 0 {
+			m++
+			continue
+		}
+
+		size := len(lambda)
+		if len(prev)+m > size {
+			size = len(prev) + m
+		}
+		next := make(poly, size)
+		copy(next, lambda)
+		coef := gfDiv(d, b)
+		for i, p := range prev {
+			next[i+m] ^= gfMul(coef, p)
+		}
+
+		if 2*l <= r {
+			prev, l, b, m = lambda, r+1-l, d, 1
+		} else {
+			m++
+		}
+		lambda = next
+	}
+
+	if 2*l > ecLen {
+		return errors.New("too many errors")
+	}
+
+	// omega = synd * lambda mod x^ecLen, lambda' keeps the odd terms
+	omega := make(poly, ecLen)
+	for i := range omega {
+		for j := 0; j <= i; j++ {
+			omega[i] ^= gfMul(synd[j], lambda.coeff(i-j))
+		}
+	}
+	deriv := make(poly, len(lambda))
+	for i := 1; i < len(lambda); i += 2 {
+		deriv[i-1] = lambda[i]
+	}
+
+	// Chien search and Forney
+	found := 0
+	for k := 0; k < n; k++ {
+		power := n - 1 - k
+		xInv := gfPow(255 - power%255)
+		if lambda.eval(xInv) != 0 {
+			continue
+		}
+
+		den := deriv.eval(xInv)
+		if den == 0 {
+			return errors.New("uncorrectable codewords")
+		}
+		block[k] ^= gfMul(gfPow(power), gfDiv(omega.eval(xInv), den))
+		found++
+	}
+
+	if found != l {
+		return errors.New("uncorrectable codewords")
+	}
+
+	return nil
+}
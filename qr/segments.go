@@ -0,0 +1,229 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package qr
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// segment modes
+const (
+	modeTerminator = 0x0
+	modeNumeric    = 0x1
+	modeAlnum      = 0x2
+	modeAppend     = 0x3
+	modeByte       = 0x4
+	modeFNC1First  = 0x5
+	modeECI        = 0x7
+	modeKanji      = 0x8
+	modeFNC1Second = 0x9
+)
+
+const alnumChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+var errTruncated = errors.New("truncated data")
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) left() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.left() {
+		return 0, errTruncated
+	}
+
+	v := 0
+	for i := 0; i < n; i++ {
+		b := r.data[r.pos/8] >> uint(7-r.pos%8) & 1
+		v = v<<1 | int(b)
+		r.pos++
+	}
+	return v, nil
+}
+
+// countBits is the length of the character count of mode, it grows with
+// the version.
+func countBits(mode, version int) int {
+	size := 0
+	switch {
+	case version >= 27:
+		size = 2
+	case version >= 10:
+		size = 1
+	}
+
+	switch mode {
+	case modeNumeric:
+		return [3]int{10, 12, 14}[size]
+	case modeAlnum:
+		return [3]int{9, 11, 13}[size]
+	case modeByte:
+		return [3]int{8, 16, 16}[size]
+	default:
+		return [3]int{8, 10, 12}[size]
+	}
+}
+
+// decodeSegments reads the text of the data codewords. Byte segments are
+// taken as UTF-8 and fall back to ISO 8859-1 when they are not valid.
+func decodeSegments(data []byte, version int) (string, error) {
+	r := &bitReader{data: data}
+	var text strings.Builder
+
+	for r.left() >= 4 {
+		mode, _ := r.read(4)
+
+		var err error
+		switch mode {
+		case modeTerminator:
+			return text.String(), nil
+		case modeNumeric:
+			err = readNumeric(r, &text, version)
+		case modeAlnum:
+			err = readAlnum(r, &text, version)
+		case modeByte:
+			err = readBytes(r, &text, version)
+		case modeECI:
+			err = skipECI(r)
+		case modeAppend:
+			_, err = r.read(16)
+		case modeFNC1First:
+		case modeFNC1Second:
+			_, err = r.read(8)
+		case modeKanji:
+			err = errors.New("kanji segments are not supported")
+		default:
+			err = errors.New(fmt.Sprintf("unknown segment mode %d", mode))
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return text.String(), nil
+}
+
+func readNumeric(r *bitReader, text *strings.Builder, version int) error {
+	count, err := r.read(countBits(modeNumeric, version))
+	if err != nil {
+		return err
+	}
+
+	for count > 0 {
+		digits, size := 3, 10
+		switch count {
+		case 1:
+			digits, size = 1, 4
+		case 2:
+			digits, size = 2, 7
+		}
+
+		v, err := r.read(size)
+		if err != nil {
+			return err
+		}
+		s := fmt.Sprintf("%0*d", digits, v)
+		if len(s) != digits {
+			return errors.New("invalid numeric segment")
+		}
+		text.WriteString(s)
+		count -= digits
+	}
+	return nil
+}
+
+func readAlnum(r *bitReader, text *strings.Builder, version int) error {
+	count, err := r.read(countBits(modeAlnum, version))
+	if err != nil {
+		return err
+	}
+
+	for ; count > 1; count -= 2 {
+		v, err := r.read(11)
+		if err != nil {
+			return err
+		}
+		if v >= 45*45 {
+			return errors.New("invalid alphanumeric segment")
+		}
+		text.WriteByte(alnumChars[v/45])
+		text.WriteByte(alnumChars[v%45])
+	}
+
+	if count == 1 {
+		v, err := r.read(6)
+		if err != nil {
+			return err
+		}
+		if v >= 45 {
+			return errors.New("invalid alphanumeric segment")
+		}
+		text.WriteByte(alnumChars[v])
+	}
+	return nil
+}
+
+func readBytes(r *bitReader, text *strings.Builder, version int) error {
+	count, err := r.read(countBits(modeByte, version))
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, count)
+	for i := range b {
+		v, err := r.read(8)
+		if err != nil {
+			return err
+		}
+		b[i] = byte(v)
+	}
+
+	if utf8.Valid(b) {
+		text.Write(b)
+		return nil
+	}
+	for _, c := range b {
+		text.WriteRune(rune(c))
+	}
+	return nil
+}
+
+// skipECI drops the designator, the text is read as UTF-8 anyway.
+func skipECI(r *bitReader) error {
+	first, err := r.read(8)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case first&0x80 == 0:
+	case first&0xc0 == 0x80:
+		_, err = r.read(8)
+	case first&0xe0 == 0xc0:
+		_, err = r.read(16)
+	default:
+		err = errors.New("invalid eci designator")
+	}
+	return err
+}
@@ -0,0 +1,396 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package qr
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// error correction levels, in the order of the tables below
+const (
+	levelL = iota
+	levelM
+	levelQ
+	levelH
+)
+
+// levelBits maps the two level bits of the format information to a level.
+var levelBits = [4]int{levelM, levelL, levelH, levelQ}
+
+// eccPerBlock and numBlocks describe the error correction blocks of
+// every level and version, ISO/IEC 18004 table 9.
+var eccPerBlock = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var numBlocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// grid holds the modules of a symbol by row, true is dark.
+type grid [][]bool
+
+func newGrid(dim int) grid {
+	g := make(grid, dim)
+	for i := range g {
+		g[i] = make([]bool, dim)
+	}
+	return g
+}
+
+func dimension(version int) int {
+	return 4*version + 17
+}
+
+// rawModules is the number of modules left for codewords and remainder
+// bits once the function patterns are drawn.
+func rawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// alignmentPositions are the rows and columns of the alignment pattern
+// centers.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	align := version/7 + 2
+	step := (version*8+align*3+5)/(align*4-4) * 2
+
+	positions := make([]int, align)
+	positions[0] = 6
+	for i, pos := align-1, dimension(version)-7; i > 0; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// functionMask marks the modules of the finder, timing and alignment
+// patterns and of the format and version information.
+func functionMask(version int) grid {
+	dim := dimension(version)
+	fn := newGrid(dim)
+
+	mark := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				fn[y][x] = true
+			}
+		}
+	}
+
+	// finders with their separators and the format information, the
+	// bottom left one includes the dark module
+	mark(0, 0, 9, 9)
+	mark(dim-8, 0, 8, 9)
+	mark(0, dim-8, 9, 8)
+
+	mark(6, 0, 1, dim)
+	mark(0, 6, dim, 1)
+
+	pos := alignmentPositions(version)
+	last := len(pos) - 1
+	for i, y := range pos {
+		for j, x := range pos {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			mark(x-2, y-2, 5, 5)
+		}
+	}
+
+	if version >= 7 {
+		mark(dim-11, 0, 3, 6)
+		mark(0, dim-11, 6, 3)
+	}
+
+	return fn
+}
+
+// bch appends the remainder of data times x^n divided by poly, a
+// polynomial of degree n.
+func bch(data, poly, n int) int {
+	v := data << uint(n)
+	for deg := bits.Len(uint(poly)) - 1; bits.Len(uint(v))-1 >= deg; {
+		v ^= poly << uint(bits.Len(uint(v))-1-deg)
+	}
+	return data<<uint(n) | v
+}
+
+// formatCode is the masked format information of the level bits and mask.
+func formatCode(data int) int {
+	return bch(data, 0x537, 10) ^ 0x5412
+}
+
+func versionCode(version int) int {
+	return bch(version, 0x1f25, 12)
+}
+
+// formatPositions are the (x, y) of both copies of the format
+// information, most significant bit first.
+func formatPositions(dim int) (first, second [15][2]int) {
+	for i := 0; i < 6; i++ {
+		first[i] = [2]int{i, 8}
+	}
+	first[6] = [2]int{7, 8}
+	first[7] = [2]int{8, 8}
+	first[8] = [2]int{8, 7}
+	for i := 9; i < 15; i++ {
+		first[i] = [2]int{8, 14 - i}
+	}
+
+	for i := 0; i < 7; i++ {
+		second[i] = [2]int{8, dim - 1 - i}
+	}
+	for i := 7; i < 15; i++ {
+		second[i] = [2]int{dim - 15 + i, 8}
+	}
+	return first, second
+}
+
+// versionPositions are the (x, y) of both copies of the version
+// information, most significant bit first.
+func versionPositions(dim int) (first, second [18][2]int) {
+	i := 0
+	for a := 5; a >= 0; a-- {
+		for b := dim - 9; b >= dim-11; b-- {
+			first[i] = [2]int{b, a}
+			second[i] = [2]int{a, b}
+			i++
+		}
+	}
+	return first, second
+}
+
+func (g grid) read(positions [][2]int) int {
+	v := 0
+	for _, p := range positions {
+		v <<= 1
+		if g[p[1]][p[0]] {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// closest returns the index of the code nearest to either read value,
+// within the three bit errors both codes correct.
+func closest(codes []int, a, b int) (int, bool) {
+	best, bestDist := -1, 4
+	for i, code := range codes {
+		d := bits.OnesCount(uint(code ^ a))
+		if db := bits.OnesCount(uint(code ^ b)); db < d {
+			d = db
+		}
+		if d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best, best >= 0
+}
+
+func (g grid) format() (level, mask int, err error) {
+	first, second := formatPositions(len(g))
+
+	codes := make([]int, 32)
+	for i := range codes {
+		codes[i] = formatCode(i)
+	}
+
+	data, ok := closest(codes, g.read(first[:]), g.read(second[:]))
+	if !ok {
+		return 0, 0, errors.New("unreadable format information")
+	}
+
+	return levelBits[data>>3], data & 7, nil
+}
+
+// version reads the version information of symbols from version 7 on,
+// ok is false when neither copy can be read.
+func (g grid) version() (version int, ok bool) {
+	first, second := versionPositions(len(g))
+
+	codes := make([]int, 41)
+	for v := 7; v <= 40; v++ {
+		codes[v] = versionCode(v)
+	}
+
+	return closest(codes, g.read(first[:]), g.read(second[:]))
+}
+
+// masked reports whether mask inverts the module at row i, column j.
+func masked(mask, i, j int) bool {
+	switch mask {
+	case 0:
+		return (i+j)%2 == 0
+	case 1:
+		return i%2 == 0
+	case 2:
+		return j%3 == 0
+	case 3:
+		return (i+j)%3 == 0
+	case 4:
+		return (i/2+j/3)%2 == 0
+	case 5:
+		return i*j%2+i*j%3 == 0
+	case 6:
+		return (i*j%2+i*j%3)%2 == 0
+	default:
+		return ((i+j)%2+i*j%3)%2 == 0
+	}
+}
+
+// dataModules calls f with the data modules in placement order: two
+// columns at a time from the right, up and down in turn.
+func dataModules(version int, f func(x, y int)) {
+	fn := functionMask(version)
+	dim := dimension(version)
+
+	for right := dim - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < dim; vert++ {
+			y := vert
+			if upward {
+				y = dim - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if !fn[y][x] {
+					f(x, y)
+				}
+			}
+		}
+	}
+}
+
+// codewords reads the unmasked codewords of the symbol.
+func (g grid) codewords(version, mask int) []byte {
+	var out []byte
+	var cur byte
+	n := 0
+
+	dataModules(version, func(x, y int) {
+		cur <<= 1
+		if g[y][x] != masked(mask, y, x) {
+			cur |= 1
+		}
+		if n++; n == 8 {
+			out = append(out, cur)
+			cur, n = 0, 0
+		}
+	})
+
+	return out
+}
+
+// blockSizes are the number of blocks, the length of the short blocks
+// and how many there are, and the error correction codewords per block.
+func blockSizes(version, level int) (blocks, shortLen, short, ecLen int) {
+	raw := rawModules(version) / 8
+	blocks = numBlocks[level][version]
+	return blocks, raw / blocks, blocks - raw%blocks, eccPerBlock[level][version]
+}
+
+// correctBlocks splits the interleaved codewords into their blocks,
+// corrects them and returns the data codewords.
+func correctBlocks(codewords []byte, version, level int) ([]byte, error) {
+	nb, shortLen, short, ecLen := blockSizes(version, level)
+	dataLen := shortLen - ecLen
+
+	blocks := make([][]byte, nb)
+	for j := range blocks {
+		size := shortLen
+		if j >= short {
+			size++
+		}
+		blocks[j] = make([]byte, size)
+	}
+
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j, block := range blocks {
+			idx := i
+			if j < short {
+				if i == dataLen {
+					continue
+				}
+				if i > dataLen {
+					idx--
+				}
+			}
+			if idx >= len(block) || k >= len(codewords) {
+				continue
+			}
+			block[idx] = codewords[k]
+			k++
+		}
+	}
+
+	var data []byte
+	for j, block := range blocks {
+		if err := correct(block, ecLen); err != nil {
+			return nil, errors.New(fmt.Sprintf("block %d: %v", j, err))
+		}
+		data = append(data, block[:len(block)-ecLen]...)
+	}
+
+	return data, nil
+}
+
+// decodeGrid reads the text of a sampled symbol.
+func decodeGrid(g grid) (string, error) {
+	dim := len(g)
+	if dim < 21 || dim > 177 || (dim-17)%4 != 0 {
+		return "", errors.New(fmt.Sprintf("invalid symbol size %d", dim))
+	}
+
+	version := (dim - 17) / 4
+	if version >= 7 {
+		if read, ok := g.version(); ok && read != version {
+			return "", errors.New(fmt.Sprintf("symbol size %d does not match version %d", dim, read))
+		}
+	}
+
+	level, mask, err := g.format()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := correctBlocks(g.codewords(version, mask), version, level)
+	if err != nil {
+		return "", err
+	}
+
+	return decodeSegments(data, version)
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+//Fields accounts can be sorted by
+const (
+	SortName     = "name"
+	SortUserName = "username"
+	SortEmail    = "email"
+	SortCreated  = "created"
+)
+
+//Query selects the accounts returned by List, the zero Query lists every
+//account sorted by name.
+//
+//Name, UserName and Email match case insensitively, as a glob when they
+//hold * or ? and as a substring otherwise
+type Query struct {
+	Name          string    `json:"name,omitempty"`
+	UserName      string    `json:"username,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	Tag           string    `json:"tag,omitempty"`
+	Folder        string    `json:"folder,omitempty"`
+	CreatedBefore time.Time `json:"created_before,omitempty"`
+	CreatedAfter  time.Time `json:"created_after,omitempty"`
+
+	//Sort is one of the Sort fields, prefixed with - for descending order
+	Sort string `json:"sort,omitempty"`
+
+	//PageSize limits the accounts returned, zero returns all of them
+	PageSize int `json:"page_size,omitempty"`
+
+	//Cursor is the next cursor returned with the previous page
+	Cursor string `json:"cursor,omitempty"`
+}
+
+//Cursor is the position of the last account of a page, the next page
+//starts after it. Value is the sort field of that account
+type Cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	Name     string `json:"n"`
+	UserName string `json:"u"`
+}
+
+//SortField splits Sort into the field and whether the order is descending
+func (q Query) SortField() (field string, desc bool) {
+	field = strings.TrimPrefix(q.Sort, "-")
+	if field == "" {
+		field = SortName
+	}
+	return field, strings.HasPrefix(q.Sort, "-")
+}
+
+//Validate checks the sort field, the page size and the cursor
+func (q Query) Validate() error {
+	field, _ := q.SortField()
+	switch field {
+	case SortName, SortUserName, SortEmail, SortCreated:
+	default:
+		return errors.Wrap(ErrInvalidArgs, errors.New(fmt.Sprintf("can not sort by %q", q.Sort)))
+	}
+
+	if q.PageSize < 0 {
+		return errors.Wrap(ErrInvalidArgs, errors.New("page size can not be negative"))
+	}
+
+	if q.Cursor != "" {
+		if _, err := q.After(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//After decodes Cursor, it is nil for the first page
+func (q Query) After() (*Cursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidArgs, errors.New("invalid cursor"))
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, errors.Wrap(ErrInvalidArgs, errors.New("invalid cursor"))
+	}
+
+	if c.Sort != q.Sort {
+		return nil, errors.Wrap(ErrInvalidArgs, errors.New("the cursor belongs to another sort order"))
+	}
+
+	return &c, nil
+}
+
+//cursorAfter returns the cursor of the page that follows account
+func (q Query) cursorAfter(account DBAccount) string {
+	c := Cursor{Sort: q.Sort, Name: account.Name, UserName: account.UserName}
+
+	switch field, _ := q.SortField(); field {
+	case SortUserName:
+		c.Value = account.UserName
+	case SortEmail:
+		c.Value = account.Email
+	case SortCreated:
+		c.Value = account.Created
+	default:
+		c.Value = account.Name
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//IsGlob reports whether pattern is matched as a glob rather than a substring
+func IsGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?")
+}
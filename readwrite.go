@@ -15,6 +15,13 @@ package pk
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hackaio/pk/pkg/files"
 )
 
 type FileWriterReq struct {
@@ -22,6 +29,32 @@ type FileWriterReq struct {
 	FileName string
 	FileExt  string
 	FileDir  string
+
+	//Force replaces the file when it exists
+	Force bool
+
+	//Out streams the accounts instead of writing a file, e.g os.Stdout
+	Out io.Writer
+}
+
+//Path is FileDir/FileName.FileExt, the extension is not added twice
+func (r FileWriterReq) Path() string {
+	ext := strings.TrimPrefix(r.FileExt, ".")
+	name := r.FileName
+	if ext != "" && !strings.EqualFold(filepath.Ext(name), "."+ext) {
+		name += "." + ext
+	}
+	return filepath.Join(r.FileDir, name)
+}
+
+//WriteWith hands write Out, or a temporary file renamed to Path once write
+//is done. Files are only readable by the user and existing ones are left
+//alone unless Force is set
+func (r FileWriterReq) WriteWith(write func(w io.Writer) error) error {
+	if r.Out != nil {
+		return write(r.Out)
+	}
+	return files.WriteFile(r.Path(), files.SecretPerm, r.Force, write)
 }
 
 type Reader interface {
@@ -36,3 +69,40 @@ type ReaderWriter interface {
 	Writer
 	Reader
 }
+
+var _ ReaderWriter = (*jsonReaderWriter)(nil)
+
+type jsonReaderWriter struct{}
+
+//JsonReaderWriter reads and writes accounts as a json array
+func JsonReaderWriter() ReaderWriter {
+	return &jsonReaderWriter{}
+}
+
+func (j jsonReaderWriter) Read(ctx context.Context, fileName string) (res []Account, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (j jsonReaderWriter) Write(ctx context.Context, request FileWriterReq) error {
+	accounts := request.Accounts
+	if accounts == nil {
+		accounts = []Account{}
+	}
+
+	return request.WriteWith(func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(accounts)
+	})
+}
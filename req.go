@@ -13,6 +13,8 @@
 
 package pk
 
+import "time"
+
 // RegisterRequest collects the request parameters for the Register method.
 type RegisterRequest struct {
 	Username string `json:"username"`
@@ -34,6 +36,7 @@ func (r RegisterResponse) Failed() error {
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Code     string `json:"code,omitempty"`
 }
 
 // LoginResponse collects the response parameters for the Login method.
@@ -100,13 +103,14 @@ func (r DeleteResponse) Failed() error {
 
 // ListRequest collects the request parameters for the List method.
 type ListRequest struct {
-	Token string                 `json:"token"`
-	Args  map[string]interface{} `json:"args"`
+	Token string `json:"token"`
+	Query Query  `json:"query"`
 }
 
 // ListResponse collects the response parameters for the List method.
 type ListResponse struct {
 	Accounts []Account `json:"accounts"`
+	Next     string    `json:"next,omitempty"`
 	Err      error     `json:"err"`
 }
 
@@ -117,9 +121,9 @@ func (r ListResponse) Failed() error {
 
 // UpdateRequest collects the request parameters for the Update method.
 type UpdateRequest struct {
-	Token    Account `json:"token"`
-	Name     Account `json:"name"`
-	Username Account `json:"username"`
+	Token    string  `json:"token"`
+	Name     string  `json:"name"`
+	Username string  `json:"username"`
 	Account  Account `json:"account"`
 }
 
@@ -172,3 +176,196 @@ func (r DeleteAllResponse) Failed() error {
 type Failure interface {
 	Failed() error
 }
+
+// HistoryRequest collects the request parameters for the History method.
+type HistoryRequest struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+// HistoryResponse collects the response parameters for the History method.
+type HistoryResponse struct {
+	Versions []Version `json:"versions"`
+	Err      error     `json:"err"`
+}
+
+// Failed implements Failer.
+func (r HistoryResponse) Failed() error {
+	return r.Err
+}
+
+// RollbackRequest collects the request parameters for the Rollback method.
+type RollbackRequest struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Version  int    `json:"version"`
+}
+
+// RollbackResponse collects the response parameters for the Rollback method.
+type RollbackResponse struct {
+	Account Account `json:"account"`
+	Err     error   `json:"err"`
+}
+
+// Failed implements Failer.
+func (r RollbackResponse) Failed() error {
+	return r.Err
+}
+
+// PruneHistoryRequest collects the request parameters for the PruneHistory method.
+type PruneHistoryRequest struct {
+	Token    string        `json:"token"`
+	Name     string        `json:"name"`
+	Username string        `json:"username"`
+	Keep     int           `json:"keep"`
+	MaxAge   time.Duration `json:"max_age"`
+}
+
+// PruneHistoryResponse collects the response parameters for the PruneHistory method.
+type PruneHistoryResponse struct {
+	Removed int   `json:"removed"`
+	Err     error `json:"err"`
+}
+
+// Failed implements Failer.
+func (r PruneHistoryResponse) Failed() error {
+	return r.Err
+}
+
+// TrashRequest collects the request parameters for the Trash method.
+type TrashRequest struct {
+	Token string `json:"token"`
+}
+
+// TrashResponse collects the response parameters for the Trash method.
+type TrashResponse struct {
+	Accounts []Trashed `json:"accounts"`
+	Err      error     `json:"err"`
+}
+
+// Failed implements Failer.
+func (r TrashResponse) Failed() error {
+	return r.Err
+}
+
+// RestoreRequest collects the request parameters for the Restore method.
+type RestoreRequest struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+// RestoreResponse collects the response parameters for the Restore method.
+type RestoreResponse struct {
+	Err error `json:"err"`
+}
+
+// Failed implements Failer.
+func (r RestoreResponse) Failed() error {
+	return r.Err
+}
+
+// PurgeRequest collects the request parameters for the Purge method.
+type PurgeRequest struct {
+	Token     string        `json:"token"`
+	OlderThan time.Duration `json:"older_than"`
+}
+
+// PurgeResponse collects the response parameters for the Purge method.
+type PurgeResponse struct {
+	Purged int   `json:"purged"`
+	Err    error `json:"err"`
+}
+
+// Failed implements Failer.
+func (r PurgeResponse) Failed() error {
+	return r.Err
+}
+
+// SearchRequest collects the request parameters for the Search method.
+type SearchRequest struct {
+	Token string `json:"token"`
+	Text  string `json:"text"`
+	Limit int    `json:"limit"`
+}
+
+// SearchResponse collects the response parameters for the Search method.
+type SearchResponse struct {
+	Matches []Match `json:"matches"`
+	Err     error   `json:"err"`
+}
+
+// Failed implements Failer.
+func (r SearchResponse) Failed() error {
+	return r.Err
+}
+
+// EnableTwoFactorRequest collects the request parameters for the EnableTwoFactor method.
+type EnableTwoFactorRequest struct {
+	Token  string `json:"token"`
+	Secret string `json:"secret"`
+	Code   string `json:"code"`
+}
+
+// EnableTwoFactorResponse collects the response parameters for the EnableTwoFactor method.
+type EnableTwoFactorResponse struct {
+	Recovery []string `json:"recovery"`
+	Err      error    `json:"err"`
+}
+
+// Failed implements Failer.
+func (r EnableTwoFactorResponse) Failed() error {
+	return r.Err
+}
+
+// DisableTwoFactorRequest collects the request parameters for the DisableTwoFactor method.
+type DisableTwoFactorRequest struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+// DisableTwoFactorResponse collects the response parameters for the DisableTwoFactor method.
+type DisableTwoFactorResponse struct {
+	Err error `json:"err"`
+}
+
+// Failed implements Failer.
+func (r DisableTwoFactorResponse) Failed() error {
+	return r.Err
+}
+
+// ExportRequest collects the request parameters for the Export method.
+type ExportRequest struct {
+	Token string `json:"token"`
+}
+
+// ExportResponse collects the response parameters for the Export method.
+type ExportResponse struct {
+	Entries []Entry `json:"entries"`
+	Err     error   `json:"err"`
+}
+
+// Failed implements Failer.
+func (r ExportResponse) Failed() error {
+	return r.Err
+}
+
+// ImportRequest collects the request parameters for the Import method.
+type ImportRequest struct {
+	Token    string   `json:"token"`
+	Entries  []Entry  `json:"entries"`
+	Conflict Conflict `json:"conflict"`
+}
+
+// ImportResponse collects the response parameters for the Import method.
+type ImportResponse struct {
+	Report ImportReport `json:"report"`
+	Err    error        `json:"err"`
+}
+
+// Failed implements Failer.
+func (r ImportResponse) Failed() error {
+	return r.Err
+}
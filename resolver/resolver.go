@@ -0,0 +1,154 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package resolver turns secret references such as pk://github/alice#password
+// into the values stored in pk.
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+const (
+	// Scheme prefixes every secret reference.
+	Scheme = "pk://"
+
+	// DefaultField is used when a reference does not name a field.
+	DefaultField = "password"
+)
+
+var (
+	ErrInvalidReference = errors.New("invalid secret reference")
+	ErrUnknownField     = errors.New("unknown account field")
+)
+
+// Reference points to a single field of an account.
+type Reference struct {
+	Name     string
+	UserName string
+	Field    string
+}
+
+// IsReference reports whether s looks like a secret reference.
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, Scheme)
+}
+
+// Parse parses pk://<name>/<username>[#field]. The username is what
+// follows the last slash so names may contain slashes.
+func Parse(uri string) (Reference, error) {
+	if !IsReference(uri) {
+		return Reference{}, errors.Wrap(ErrInvalidReference, errors.New(fmt.Sprintf("%q does not start with %v", uri, Scheme)))
+	}
+
+	rest := strings.TrimPrefix(uri, Scheme)
+
+	field := DefaultField
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		field = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 || i == len(rest)-1 || field == "" {
+		return Reference{}, errors.Wrap(ErrInvalidReference, errors.New(fmt.Sprintf("%q, want %v<name>/<username>[#field]", uri, Scheme)))
+	}
+
+	return Reference{
+		Name:     rest[:i],
+		UserName: rest[i+1:],
+		Field:    field,
+	}, nil
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%v%v/%v#%v", Scheme, r.Name, r.UserName, r.Field)
+}
+
+// Resolver looks references up through a PasswordKeeper. Every account
+// is fetched once no matter how many of its fields are referenced.
+type Resolver struct {
+	keeper   pk.PasswordKeeper
+	token    string
+	accounts map[[2]string]pk.Account
+}
+
+// New returns a Resolver calling keeper.Get with token.
+func New(keeper pk.PasswordKeeper, token string) *Resolver {
+	return &Resolver{
+		keeper:   keeper,
+		token:    token,
+		accounts: map[[2]string]pk.Account{},
+	}
+}
+
+// Resolve returns the value ref points to.
+func (r *Resolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	key := [2]string{ref.Name, ref.UserName}
+
+	account, ok := r.accounts[key]
+	if !ok {
+		var err error
+		account, err = r.keeper.Get(ctx, r.token, ref.Name, ref.UserName)
+		if err != nil {
+			return "", errors.Wrap(errors.New(fmt.Sprintf("could not resolve %v", ref)), err)
+		}
+		r.accounts[key] = account
+	}
+
+	switch ref.Field {
+	case "password":
+		return account.Password, nil
+	case "username":
+		return account.UserName, nil
+	case "email":
+		return account.Email, nil
+	case "name":
+		return account.Name, nil
+	case "created":
+		return account.Created, nil
+	case "notes":
+		return account.Notes, nil
+	case "folder":
+		return account.Folder, nil
+	case "url":
+		if len(account.URLs) > 0 {
+			return account.URLs[0], nil
+		}
+		return "", nil
+	}
+
+	// anything else names a custom field
+	for _, f := range account.Fields {
+		if f.Name == ref.Field {
+			return f.Value, nil
+		}
+	}
+
+	return "", errors.Wrap(ErrUnknownField, errors.New(fmt.Sprintf("%v in %v", ref.Field, ref)))
+}
+
+// ResolveURI parses uri and resolves it.
+func (r *Resolver) ResolveURI(ctx context.Context, uri string) (string, error) {
+	ref, err := Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Resolve(ctx, ref)
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    Reference
+		wantErr bool
+	}{
+		{uri: "pk://postgres/app", want: Reference{Name: "postgres", UserName: "app", Field: "password"}},
+		{uri: "pk://github/alice#email", want: Reference{Name: "github", UserName: "alice", Field: "email"}},
+		{uri: "pk://https://github.com/alice", want: Reference{Name: "https://github.com", UserName: "alice", Field: "password"}},
+		{uri: "postgres/app", wantErr: true},
+		{uri: "pk://postgres", wantErr: true},
+		{uri: "pk://postgres/", wantErr: true},
+		{uri: "pk://postgres/app#", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := Parse(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type keeperMock struct {
+	pk.PasswordKeeper
+	calls int
+}
+
+func (k *keeperMock) Get(ctx context.Context, token, name, username string) (pk.Account, error) {
+	k.calls++
+	if name != "github" {
+		return pk.Account{}, pk.ErrNotFound
+	}
+	return pk.Account{Name: name, UserName: username, Email: "alice@example.com", Password: "s3cr3t"}, nil
+}
+
+func TestResolve(t *testing.T) {
+	keeper := &keeperMock{}
+	r := New(keeper, "token")
+	ctx := context.Background()
+
+	got, err := r.ResolveURI(ctx, "pk://github/alice")
+	if err != nil || got != "s3cr3t" {
+		t.Errorf("ResolveURI() = %v, %v, want s3cr3t", got, err)
+	}
+
+	got, err = r.ResolveURI(ctx, "pk://github/alice#email")
+	if err != nil || got != "alice@example.com" {
+		t.Errorf("ResolveURI() = %v, %v, want alice@example.com", got, err)
+	}
+
+	if keeper.calls != 1 {
+		t.Errorf("Get() called %v times, want 1", keeper.calls)
+	}
+
+	if _, err := r.ResolveURI(ctx, "pk://github/alice#pin"); !errors.Contains(err, ErrUnknownField) {
+		t.Errorf("ResolveURI() error = %v, want %v", err, ErrUnknownField)
+	}
+
+	if _, err := r.ResolveURI(ctx, "pk://gitlab/alice"); !errors.Contains(err, pk.ErrNotFound) {
+		t.Errorf("ResolveURI() error = %v, want %v", err, pk.ErrNotFound)
+	}
+}
+
+func TestRender(t *testing.T) {
+	r := New(&keeperMock{}, "token")
+	ctx := context.Background()
+
+	var out strings.Builder
+	text := `user={{ pk "github" "alice" "email" }} pass={{ pk "pk://github/alice" }}`
+	if err := r.Render(ctx, &out, "app.tmpl", text); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	want := "user=alice@example.com pass=s3cr3t"
+	if out.String() != want {
+		t.Errorf("Render() = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	text = `{{ pk "gitlab" "alice" }} {{ pk "bitbucket" "alice" }}`
+	err := r.Render(ctx, &out, "app.tmpl", text)
+	if !errors.Contains(err, ErrUnresolved) {
+		t.Fatalf("Render() error = %v, want %v", err, ErrUnresolved)
+	}
+	if !strings.Contains(err.Error(), "pk://gitlab/alice") || !strings.Contains(err.Error(), "pk://bitbucket/alice") {
+		t.Errorf("Render() error %q does not list every unresolved reference", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Render() wrote %q despite unresolved references", out.String())
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// TemplateFunc is the name of the template function resolving secrets.
+const TemplateFunc = "pk"
+
+var ErrUnresolved = errors.New("unresolved secret references")
+
+// Render executes the text/template text and writes the result to w.
+// Secrets are referenced with either
+//
+//	{{ pk "github" "alice" "password" }}
+//	{{ pk "pk://github/alice#password" }}
+//
+// The field may be left out in both forms and defaults to password.
+// Every reference that cannot be resolved is reported, nothing is
+// written to w unless all of them resolve.
+func (r *Resolver) Render(ctx context.Context, w io.Writer, name, text string) error {
+	var failed []string
+
+	funcs := template.FuncMap{
+		TemplateFunc: func(args ...string) (string, error) {
+			ref, err := referenceFromArgs(args)
+			if err != nil {
+				return "", err
+			}
+
+			value, err := r.Resolve(ctx, ref)
+			if err != nil {
+				failed = append(failed, err.Error())
+				return "", nil
+			}
+
+			return value, nil
+		},
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, nil); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		msg := fmt.Sprintf("%v:\n  %v", name, strings.Join(failed, "\n  "))
+		return errors.Wrap(ErrUnresolved, errors.New(msg))
+	}
+
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+func referenceFromArgs(args []string) (Reference, error) {
+	switch len(args) {
+	case 1:
+		return Parse(args[0])
+	case 2:
+		return Reference{Name: args[0], UserName: args[1], Field: DefaultField}, nil
+	case 3:
+		return Reference{Name: args[0], UserName: args[1], Field: args[2]}, nil
+	default:
+		return Reference{}, errors.Wrap(ErrInvalidReference,
+			errors.New(fmt.Sprintf("%v takes a reference or name, username and field, got %v arguments", TemplateFunc, len(args))))
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"github.com/hackaio/pk"
+	"math/big"
 	"github.com/hackaio/pk/pkg/errors"
 	"os"
 	"path/filepath"
@@ -151,6 +152,34 @@ func (r rsaEncoderSigner) Verify(password string, dbDigest []byte, dbSignature [
 
 }
 
+//Wipe overwrites the private key held in memory and drops it. The
+//EncoderSigner can no longer decode or sign afterwards.
+func (r *rsaEncoderSigner) Wipe() {
+	key := r.PrivateKey
+	r.PrivateKey = nil
+
+	if key == nil {
+		return
+	}
+
+	ints := []*big.Int{key.D, key.Precomputed.Dp, key.Precomputed.Dq, key.Precomputed.Qinv}
+	ints = append(ints, key.Primes...)
+	for _, v := range key.Precomputed.CRTValues {
+		ints = append(ints, v.Exp, v.Coeff, v.R)
+	}
+
+	for _, i := range ints {
+		if i == nil {
+			continue
+		}
+		words := i.Bits()
+		for j := range words {
+			words[j] = 0
+		}
+		i.SetInt64(0)
+	}
+}
+
 func savePEMKey(fileName string, key *rsa.PrivateKey) error {
 	outFile, err := os.Create(fileName)
 	if err != nil {
@@ -272,7 +301,7 @@ func loadCredentials(credentialsDir string) (
 
 func initCredentials(homeDir string) (err error) {
 
-	fmt.Printf("init credentials .....\n")
+	fmt.Fprintf(os.Stderr, "init credentials .....\n")
 	//Check if File exists if not create the credentials and save them
 	//then load the credentials
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -286,7 +315,7 @@ func initCredentials(homeDir string) (err error) {
 	privateKeyPEMFile := filepath.Join(homeDir, pk.AppDir, pk.CredDir, "private.pem")
 	publicKeyPEMFile := filepath.Join(homeDir, pk.AppDir, pk.CredDir, "public.pem")
 
-	fmt.Printf("saving creds at: %v and %v\n", privateKeyPEMFile, publicKeyPEMFile)
+	fmt.Fprintf(os.Stderr, "saving creds at: %v and %v\n", privateKeyPEMFile, publicKeyPEMFile)
 
 	err = savePEMKey(privateKeyPEMFile, privateKey)
 	err = savePublicPEMKey(publicKeyPEMFile, publicKey)
@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/hackaio/pk/fuzzy"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+//Match is an account found by Search, it holds no secrets. Field is the
+//field that matched best
+type Match struct {
+	Name     string   `json:"name" yaml:"name"`
+	UserName string   `json:"username" yaml:"username"`
+	Email    string   `json:"email,omitempty" yaml:"email,omitempty"`
+	URLs     []string `json:"urls,omitempty" yaml:"urls,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Folder   string   `json:"folder,omitempty" yaml:"folder,omitempty"`
+	Field    string   `json:"field" yaml:"field"`
+	Score    int      `json:"score" yaml:"score"`
+}
+
+//searchWeights favour the fields people remember accounts by, in percent
+var searchWeights = map[string]int{
+	"name":     100,
+	"tag":      80,
+	"url":      70,
+	"username": 60,
+	"email":    40,
+}
+
+func (p passwordKeeper) Search(ctx context.Context, token, text string, limit int) (matches []Match, err error) {
+	_, err = p.tokenizer.Parse(token)
+
+	if err != nil {
+		return nil, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	terms := strings.Fields(text)
+	if len(terms) == 0 {
+		return nil, errors.Wrap(ErrInvalidArgs, errors.New("nothing to search for"))
+	}
+
+	//only the metadata is ranked, nothing is decrypted
+	dbAccounts, err := p.passwords.List(ctx, Query{})
+	if err != nil {
+		return nil, errors.Wrap(ErrInternalError, err)
+	}
+
+	for _, a := range dbAccounts {
+		if m, ok := rank(a, terms); ok {
+			matches = append(matches, m)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+//rank scores every term against the best matching field of a, all terms
+//have to match
+func rank(a DBAccount, terms []string) (Match, bool) {
+	type field struct{ name, text string }
+
+	fields := []field{{"name", a.Name}, {"username", a.UserName}, {"email", a.Email}}
+	for _, url := range a.URLs {
+		fields = append(fields, field{"url", trimURL(url)})
+	}
+	for _, tag := range a.Tags {
+		fields = append(fields, field{"tag", tag})
+	}
+
+	m := Match{
+		Name:     a.Name,
+		UserName: a.UserName,
+		Email:    a.Email,
+		URLs:     a.URLs,
+		Tags:     a.Tags,
+		Folder:   a.Folder,
+	}
+
+	bestField := 0
+	for _, term := range terms {
+		termBest, found := 0, false
+
+		for _, f := range fields {
+			score, ok := fuzzy.Score(term, f.text)
+			if !ok {
+				continue
+			}
+
+			score = score * searchWeights[f.name] / 100
+			if !found || score > termBest {
+				termBest, found = score, true
+			}
+			if score > bestField {
+				bestField, m.Field = score, f.name
+			}
+		}
+
+		if !found {
+			return Match{}, false
+		}
+		m.Score += termBest
+	}
+
+	return m, true
+}
+
+//trimURL drops the scheme and www. so they do not match every query
+func trimURL(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	}
+	return strings.TrimPrefix(url, "www.")
+}
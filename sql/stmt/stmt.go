@@ -19,10 +19,65 @@ package stmt
 //password
 //created
 
+//ACCOUNT_COLUMNS are the columns of an account in the order they are
+//scanned, urls and tags are text arrays, fields and otp are jsonb
+const ACCOUNT_COLUMNS = "name, username, email, hash, encoded, digest, signature, created, urls, notes, tags, folder, fields, otp"
+
 const (
 	ADD_OWNER = "INSERT INTO masters (name, username,email,password, created) VALUES ($1, $2, $3, $4, $5);"
-	GET_OWNER = "SELECT * FROM masters WHERE name = $1 AND username = $2;"
-	ADD       = "INSERT INTO accounts (name, username,email,hash,encoded,digest,signature,created) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);"
-	GET       = "SELECT * FROM accounts WHERE name = $1 AND username = $2;"
-	LIST      = "SELECT * FROM accounts;"
+	GET_OWNER = "SELECT name, username, email, password, created FROM masters WHERE name = $1 AND username = $2;"
+	ADD       = "INSERT INTO accounts (" + ACCOUNT_COLUMNS + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);"
+	GET       = "SELECT " + ACCOUNT_COLUMNS + " FROM accounts WHERE name = $1 AND username = $2 AND deleted = '';"
+	DELETE    = "UPDATE accounts SET deleted = $3 WHERE name = $1 AND username = $2 AND deleted = '';"
+	UPDATE    = "UPDATE accounts SET name = $3, username = $4, email = $5, hash = $6, encoded = $7, digest = $8, signature = $9, created = $10, urls = $11, notes = $12, tags = $13, folder = $14, fields = $15, otp = $16 WHERE name = $1 AND username = $2 AND deleted = '';"
+
+	GET_FOR_UPDATE = "SELECT " + ACCOUNT_COLUMNS + " FROM accounts WHERE name = $1 AND username = $2 AND deleted = '' FOR UPDATE;"
+	NEXT_VERSION   = "SELECT COALESCE(MAX(version), 0) + 1 FROM history WHERE name = $1 AND username = $2;"
+	ADD_VERSION    = "INSERT INTO history (name, username, version, email, hash, encoded, digest, signature, created, replaced) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);"
+	RENAME_HISTORY = "UPDATE history SET name = $3, username = $4 WHERE name = $1 AND username = $2;"
+	LIST_HISTORY   = "SELECT version, name, username, email, hash, encoded, digest, signature, created, replaced FROM history WHERE name = $1 AND username = $2 ORDER BY version DESC;"
+	GET_VERSION    = "SELECT version, name, username, email, hash, encoded, digest, signature, created, replaced FROM history WHERE name = $1 AND username = $2 AND version = $3;"
+	PRUNE_COUNT    = "DELETE FROM history WHERE name = $1 AND username = $2 AND version <= (SELECT MAX(version) FROM history WHERE name = $1 AND username = $2) - $3;"
+	PRUNE_AGE      = "DELETE FROM history WHERE name = $1 AND username = $2 AND replaced < $3;"
+
+	//accounts sharing a password have the same digest, and trashed ones
+	//keep theirs, so the password columns of tables made by older
+	//versions lose their UNIQUE constraints
+	DROP_UNIQUE_PASSWORDS = "ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_hash_key, " +
+		"DROP CONSTRAINT IF EXISTS accounts_encoded_key, " +
+		"DROP CONSTRAINT IF EXISTS accounts_digest_key, " +
+		"DROP CONSTRAINT IF EXISTS accounts_signature_key;"
+
+	//deleted is empty for live accounts and the RFC3339 UTC time of the
+	//deletion for those in the trash
+	ADD_DELETED_COLUMN = "ALTER TABLE accounts ADD COLUMN IF NOT EXISTS deleted VARCHAR(100) NOT NULL DEFAULT '';"
+
+	ADD_DETAIL_COLUMNS = "ALTER TABLE accounts ADD COLUMN IF NOT EXISTS urls TEXT[] NOT NULL DEFAULT '{}', " +
+		"ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '', " +
+		"ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}', " +
+		"ADD COLUMN IF NOT EXISTS folder VARCHAR(500) NOT NULL DEFAULT '', " +
+		"ADD COLUMN IF NOT EXISTS fields JSONB NOT NULL DEFAULT '[]';"
+	//otp is null for accounts without a two factor seed
+	ADD_OTP_COLUMN = "ALTER TABLE accounts ADD COLUMN IF NOT EXISTS otp JSONB;"
+	DROP_TRASHED   = "DELETE FROM accounts WHERE name = $1 AND username = $2 AND deleted <> '';"
+	//the password of a trashed account that is added again becomes the
+	//newest version of its history
+	ARCHIVE_TRASHED = "INSERT INTO history (name, username, version, email, hash, encoded, digest, signature, created, replaced) " +
+		"SELECT name, username, (SELECT COALESCE(MAX(version), 0) + 1 FROM history WHERE name = $1 AND username = $2), " +
+		"email, hash, encoded, digest, signature, created, $3 FROM accounts WHERE name = $1 AND username = $2 AND deleted <> '';"
+	LIST_TRASH        = "SELECT name, username, email, created, deleted FROM accounts WHERE deleted <> '' ORDER BY deleted DESC;"
+	RESTORE           = "UPDATE accounts SET deleted = '' WHERE name = $1 AND username = $2 AND deleted <> '';"
+	PURGE_HISTORY     = "DELETE FROM history h USING accounts a WHERE h.name = a.name AND h.username = a.username AND a.deleted <> '' AND a.deleted < $1;"
+	PURGE             = "DELETE FROM accounts WHERE deleted <> '' AND deleted < $1;"
+	PURGE_HISTORY_ALL = "DELETE FROM history h USING accounts a WHERE h.name = a.name AND h.username = a.username AND a.deleted <> '';"
+	PURGE_ALL         = "DELETE FROM accounts WHERE deleted <> '';"
+
+	//otp is null while the master account has no second factor, recovery
+	//holds the bcrypt hashes of the unused recovery codes
+	ADD_TWO_FACTOR_COLUMNS = "ALTER TABLE masters ADD COLUMN IF NOT EXISTS otp JSONB, " +
+		"ADD COLUMN IF NOT EXISTS recovery TEXT[] NOT NULL DEFAULT '{}';"
+	GET_TWO_FACTOR = "SELECT otp, recovery FROM masters WHERE name = $1 AND username = $2;"
+	//GET_TWO_FACTOR_FOR_UPDATE locks the row until the transaction ends
+	GET_TWO_FACTOR_FOR_UPDATE = "SELECT otp, recovery FROM masters WHERE name = $1 AND username = $2 FOR UPDATE;"
+	SET_TWO_FACTOR            = "UPDATE masters SET otp = $3, recovery = $4 WHERE name = $1 AND username = $2;"
 )
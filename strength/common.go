@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package strength
+
+import (
+	"strings"
+	"sync"
+)
+
+// common are frequently used passwords and words in rough order of
+// popularity, the rank of a word is its position in the list.
+const common = `
+password 123456 12345678 qwerty 123456789 12345 1234 111111 1234567 dragon
+123123 baseball abc123 football monkey letmein shadow master 696969 michael
+mustang 666666 qwertyuiop 123321 1234567890 superman 654321 1qaz2wsx
+7777777 qazwsx jordan jennifer 123qwe 121212 killer trustno1 hunter
+harley zxcvbnm asdfgh buster andrew batman soccer tigger charlie robert thomas
+hockey ranger daniel starwars klaster 112233 george computer michelle jessica
+pepper 1111 zxcvbn 555555 11111111 131313 freedom 777777 pass maggie 159753
+aaaaaa ginger princess joshua cheese amanda summer love ashley nicole chelsea
+biteme matthew access yankees 987654321 dallas austin thunder taylor matrix
+minecraft william corvette hello martin heather secret merlin diamond 1234qwer
+gfhjkm hammer silver 222222 88888888 anthony justin test bailey q1w2e3r4t5
+patrick internet scooter orange 11111 golfer cookie richard samantha bigdog
+guitar jackson whatever mickey chicken sparky snoopy maverick phoenix camaro
+peanut morgan welcome falcon cowboy ferrari samsung andrea smokey steelers
+joseph mercedes dakota arsenal eagles melissa boomer booboo spider nascar
+monster tigers yellow xxxxxx 123123123 gateway marina diablo bulldog qwer1234
+compaq purple banana junior hannah 123654 porsche lakers iceman money
+cowboys 987654 london tennis 999999 ncc1701 coffee scooby 0000 miller boston
+q1w2e3r4 brandon yamaha chester mother forever johnny edward 333333 oliver
+redsox player nikita knight fender barney midnight please brandy chicago
+badboy slayer rangers charles angel flower rabbit wizard jasper enter
+rachel chris steven winner adidas victoria natasha 1q2w3e4r jasmine winter
+prince marine ghbdtn fishing cocacola casper james 232323 raiders
+888888 marlboro gandalf asdfasdf crystal 87654321 12344321 golden 8675309
+angels 2000 jackie alexander shannon ginger dolphin
+iloveyou admin login abc welcome1 password1 passw0rd qwerty123 sunshine
+solo starwars1 lovely 000000 letmein1 football1 azerty trustme hello123
+charlie1 aa123456 donald qwerty1 default changeme root toor administrator
+user guest secret1 god love123 pokemon naruto blink182 liverpool chelsea1
+manchester barcelona juventus america canada mexico india china japan
+january february march april may june july august september october
+november december monday tuesday wednesday thursday friday saturday sunday
+spring autumn family happy lucky blessed jesus christ heaven friend friends
+baby babygirl sweet sweetheart honey angel1 princess1 loveme lover mylove
+work office company school college student teacher doctor apple google
+facebook twitter github linkedin amazon netflix microsoft yahoo hotmail
+gmail outlook instagram whatsapp skype dropbox paypal bitcoin
+`
+
+var (
+	rankedOnce sync.Once
+	rankedMap  map[string]int
+)
+
+// ranked maps every common word to its rank.
+func ranked() map[string]int {
+	rankedOnce.Do(func() {
+		rankedMap = map[string]int{}
+		for i, w := range strings.Fields(common) {
+			if _, ok := rankedMap[w]; !ok {
+				rankedMap[w] = i + 1
+			}
+		}
+	})
+	return rankedMap
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package strength
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+// Rules a password can fail.
+const (
+	RuleMinLength = "min_length"
+	RuleMinScore  = "min_score"
+	RuleBanned    = "banned_pattern"
+	RulePersonal  = "personal_info"
+)
+
+var ErrPolicyViolation = errors.New("password does not meet the policy")
+
+var _ errors.Error = (*ValidationError)(nil)
+
+// Failure is a rule a password did not pass.
+type Failure struct {
+	Account string `json:"account,omitempty" yaml:"account,omitempty"`
+	Rule    string `json:"rule" yaml:"rule"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// ValidationError lists every rule that failed. It contains
+// ErrPolicyViolation.
+type ValidationError struct {
+	Failures []Failure `json:"failures"`
+}
+
+func (v *ValidationError) Error() string {
+	return v.Msg() + " : " + v.Err().Error()
+}
+
+func (v *ValidationError) Msg() string {
+	return ErrPolicyViolation.Error()
+}
+
+func (v *ValidationError) Err() errors.Error {
+	msgs := make([]string, len(v.Failures))
+	for i, f := range v.Failures {
+		msgs[i] = f.Message
+		if f.Account != "" {
+			msgs[i] = f.Account + ": " + f.Message
+		}
+	}
+	return errors.New(strings.Join(msgs, ", "))
+}
+
+// Policy is what every stored password has to satisfy.
+type Policy struct {
+	MinLength int
+
+	// MinScore is the lowest Estimate score accepted, 0 to 4.
+	MinScore int
+
+	// Banned passwords matching any of these are refused.
+	Banned []*regexp.Regexp
+
+	// AllowPersonal accepts passwords containing the username, email
+	// or account name.
+	AllowPersonal bool
+}
+
+// DefaultPolicy is used when nothing is configured.
+var DefaultPolicy = Policy{MinLength: 8, MinScore: 2}
+
+// CompileBanned compiles the banned patterns of a policy.
+func CompileBanned(patterns []string) ([]*regexp.Regexp, error) {
+	banned := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrap(errors.New(fmt.Sprintf("invalid banned pattern %q", p)), err)
+		}
+		banned = append(banned, re)
+	}
+	return banned, nil
+}
+
+// Check returns the rules password fails, personal holds the username,
+// email and such of its owner.
+func (p Policy) Check(password string, personal ...string) []Failure {
+	var failures []Failure
+
+	if n := len([]rune(password)); n < p.MinLength {
+		failures = append(failures, Failure{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("password has %v characters, want at least %v", n, p.MinLength),
+		})
+	}
+
+	if result := Estimate(password, personal...); result.Score < p.MinScore {
+		failures = append(failures, Failure{
+			Rule:    RuleMinScore,
+			Message: fmt.Sprintf("password scores %v, want at least %v", result.Score, p.MinScore),
+		})
+	}
+
+	for _, re := range p.Banned {
+		if re.MatchString(password) {
+			failures = append(failures, Failure{
+				Rule:    RuleBanned,
+				Message: fmt.Sprintf("password matches banned pattern %q", re.String()),
+			})
+		}
+	}
+
+	if !p.AllowPersonal {
+		lower := strings.ToLower(password)
+		for _, word := range personalWords(personal) {
+			if strings.Contains(lower, word) {
+				failures = append(failures, Failure{
+					Rule:    RulePersonal,
+					Message: fmt.Sprintf("password contains %q", word),
+				})
+			}
+		}
+	}
+
+	return failures
+}
+
+// Validate returns a *ValidationError when password fails any rule.
+func (p Policy) Validate(password string, personal ...string) error {
+	if failures := p.Check(password, personal...); len(failures) > 0 {
+		return &ValidationError{Failures: failures}
+	}
+	return nil
+}
+
+// personalWords is personal without the full email addresses, their
+// local part is checked already.
+func personalWords(inputs []string) []string {
+	var words []string
+	for w := range personal(inputs) {
+		if !strings.Contains(w, "@") {
+			words = append(words, w)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
@@ -0,0 +1,364 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package strength estimates how hard a password is to guess, the way
+// zxcvbn does: the password is split into the cheapest sequence of known
+// patterns (common passwords, keyboard walks, sequences, repeats, years,
+// personal info) and whatever is left is brute forced.
+package strength
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Patterns a Match can have.
+const (
+	Dictionary = "dictionary"
+	Personal   = "personal"
+	Repeat     = "repeat"
+	Sequence   = "sequence"
+	Keyboard   = "keyboard"
+	Year       = "year"
+	BruteForce = "bruteforce"
+)
+
+// minToken is the shortest token matched against patterns.
+const minToken = 3
+
+// Match is a part of the password and the number of guesses it costs,
+// in bits.
+type Match struct {
+	Pattern string  `json:"pattern"`
+	Token   string  `json:"token"`
+	Entropy float64 `json:"entropy"`
+
+	i, j int
+}
+
+// Result is the estimate for a password.
+type Result struct {
+	// Entropy is log2 of the guesses needed.
+	Entropy float64 `json:"entropy"`
+
+	// Score goes from 0 (guessable within a few tries) to 4 (safe
+	// against offline attacks).
+	Score int `json:"score"`
+
+	// Matches is the sequence the estimate is based on.
+	Matches []Match `json:"matches"`
+}
+
+// score thresholds in bits, 10^3, 10^6, 10^8 and 10^10 guesses
+var thresholds = []float64{10, 20, 26.6, 33.2}
+
+// Estimate returns the strength of password. inputs are words the owner
+// is likely to use in it such as the username or the email address.
+func Estimate(password string, inputs ...string) Result {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return Result{}
+	}
+
+	matches := omnimatch(runes, personal(inputs))
+	bf := math.Log2(cardinality(runes))
+
+	// best[k] is the cheapest way to guess the first k runes
+	best := make([]float64, n+1)
+	last := make([]*Match, n+1)
+	for k := 1; k <= n; k++ {
+		best[k] = best[k-1] + bf
+		last[k] = &Match{Pattern: BruteForce, Entropy: bf, i: k - 1, j: k - 1}
+
+		for m := range matches {
+			match := &matches[m]
+			if match.j != k-1 {
+				continue
+			}
+			// every extra pattern costs a bit for where it starts
+			cost := best[match.i] + match.Entropy
+			if match.i > 0 {
+				cost++
+			}
+			if cost < best[k] {
+				best[k] = cost
+				last[k] = match
+			}
+		}
+	}
+
+	var seq []Match
+	for k := n; k > 0; {
+		m := *last[k]
+		if m.Pattern == BruteForce && len(seq) > 0 && seq[0].Pattern == BruteForce && seq[0].i == m.j+1 {
+			seq[0].i = m.i
+			seq[0].Entropy += m.Entropy
+		} else {
+			seq = append([]Match{m}, seq...)
+		}
+		k = m.i
+	}
+	for i := range seq {
+		seq[i].Token = string(runes[seq[i].i : seq[i].j+1])
+	}
+
+	return Result{
+		Entropy: best[n],
+		Score:   score(best[n]),
+		Matches: seq,
+	}
+}
+
+func score(entropy float64) int {
+	for s, t := range thresholds {
+		if entropy < t {
+			return s
+		}
+	}
+	return len(thresholds)
+}
+
+// cardinality is the size of the smallest alphabet password is drawn from.
+func cardinality(password []rune) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	var c float64
+	for _, class := range []struct {
+		set  bool
+		size float64
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.set {
+			c += class.size
+		}
+	}
+	return c
+}
+
+// personal lowercases inputs and splits email addresses so their local
+// part is matched on its own.
+func personal(inputs []string) map[string]bool {
+	words := map[string]bool{}
+	for _, input := range inputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if i := strings.Index(input, "@"); i > 0 {
+			words[input[:i]] = true
+		}
+		if len([]rune(input)) >= minToken {
+			words[input] = true
+		}
+	}
+	return words
+}
+
+func omnimatch(password []rune, inputs map[string]bool) []Match {
+	var matches []Match
+	matches = append(matches, dictionaryMatches(password, inputs)...)
+	matches = append(matches, repeatMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, keyboardMatches(password)...)
+	matches = append(matches, yearMatches(password)...)
+	return matches
+}
+
+var leet = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i',
+	'!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+func dictionaryMatches(password []rune, inputs map[string]bool) []Match {
+	var matches []Match
+	n := len(password)
+
+	for i := 0; i < n; i++ {
+		for j := i + minToken - 1; j < n; j++ {
+			token := password[i : j+1]
+			word, subs := unleet(token)
+			extra := uppercaseEntropy(token) + float64(subs)
+
+			if inputs[word] {
+				matches = append(matches, Match{Pattern: Personal, Entropy: extra, i: i, j: j})
+			}
+
+			if rank, ok := ranked()[word]; ok {
+				matches = append(matches, Match{Pattern: Dictionary, Entropy: math.Log2(float64(rank)) + extra, i: i, j: j})
+			}
+
+			if rank, ok := ranked()[reverse(word)]; ok {
+				matches = append(matches, Match{Pattern: Dictionary, Entropy: math.Log2(float64(rank)) + extra + 1, i: i, j: j})
+			}
+		}
+	}
+
+	return matches
+}
+
+// unleet lowercases token and undoes l33t substitutions, it returns the
+// number of substitutions made.
+func unleet(token []rune) (string, int) {
+	var b strings.Builder
+	subs := 0
+	for _, r := range token {
+		if l, ok := leet[r]; ok {
+			r = l
+			subs++
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String(), subs
+}
+
+// uppercaseEntropy is what capitalising some letters of a word adds,
+// capitalising the first or all letters is common so it adds a bit.
+func uppercaseEntropy(token []rune) float64 {
+	upper, letters := 0, 0
+	for _, r := range token {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case upper == letters, upper == 1 && unicode.IsUpper(token[0]):
+		return 1
+	default:
+		return float64(upper)
+	}
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func repeatMatches(password []rune) []Match {
+	var matches []Match
+	for i := 0; i < len(password); {
+		j := i
+		for j+1 < len(password) && password[j+1] == password[i] {
+			j++
+		}
+		if j-i+1 >= minToken {
+			size := cardinality(password[i : i+1])
+			matches = append(matches, Match{Pattern: Repeat, Entropy: math.Log2(size * float64(j-i+1)), i: i, j: j})
+		}
+		i = j + 1
+	}
+	return matches
+}
+
+// sequenceMatches finds runs such as abc, 9876 or ACEG.
+func sequenceMatches(password []rune) []Match {
+	var matches []Match
+	for i := 0; i+minToken <= len(password); {
+		delta := password[i+1] - password[i]
+		j := i + 1
+		for j+1 < len(password) && password[j+1]-password[j] == delta && sameClass(password[i], password[j+1]) {
+			j++
+		}
+
+		if j-i+1 >= minToken && delta != 0 && abs(delta) <= 5 && sameClass(password[i], password[j]) {
+			base := 26.0
+			switch {
+			case strings.ContainsRune("aAzZ019", password[i]):
+				base = 4
+			case unicode.IsDigit(password[i]):
+				base = 10
+			}
+			e := math.Log2(base * float64(j-i+1))
+			if delta < 0 {
+				e++
+			}
+			matches = append(matches, Match{Pattern: Sequence, Entropy: e, i: i, j: j})
+			i = j
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	return unicode.IsLower(a) && unicode.IsLower(b) ||
+		unicode.IsUpper(a) && unicode.IsUpper(b) ||
+		unicode.IsDigit(a) && unicode.IsDigit(b)
+}
+
+func abs(r rune) rune {
+	if r < 0 {
+		return -r
+	}
+	return r
+}
+
+// keyboard rows and columns of a qwerty layout
+var keyboard = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"1qaz", "2wsx", "3edc", "4rfv", "5tgb", "6yhn", "7ujm", "8ik,", "9ol.", "0p;/",
+	"qazwsxedcrfvtgbyhnujmikolp",
+}
+
+func keyboardMatches(password []rune) []Match {
+	var matches []Match
+	lower := []rune(strings.ToLower(string(password)))
+
+	for i := 0; i < len(lower); i++ {
+		for j := i + minToken; j < len(lower); j++ {
+			token := string(lower[i : j+1])
+			for _, row := range keyboard {
+				if strings.Contains(row, token) || strings.Contains(row, reverse(token)) {
+					e := math.Log2(float64(len(keyboard))*float64(j-i+1)) + uppercaseEntropy(password[i:j+1])
+					matches = append(matches, Match{Pattern: Keyboard, Entropy: e, i: i, j: j})
+					break
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// yearMatches finds years between 1900 and 2039.
+func yearMatches(password []rune) []Match {
+	var matches []Match
+	for i := 0; i+4 <= len(password); i++ {
+		y := string(password[i : i+4])
+		if (strings.HasPrefix(y, "19") || strings.HasPrefix(y, "20") && y[2] <= '3') &&
+			unicode.IsDigit(password[i+2]) && unicode.IsDigit(password[i+3]) {
+			matches = append(matches, Match{Pattern: Year, Entropy: math.Log2(140), i: i, j: i + 3})
+		}
+	}
+	return matches
+}
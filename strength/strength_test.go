@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package strength
+
+import (
+	"testing"
+
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		password string
+		inputs   []string
+		maxScore int
+		minScore int
+	}{
+		{password: "password", maxScore: 0},
+		{password: "P@ssw0rd", maxScore: 1},
+		{password: "qwertyuiop", maxScore: 1},
+		{password: "abcdefgh", maxScore: 1},
+		{password: "aaaaaaaaaaaa", maxScore: 1},
+		{password: "alice1990", inputs: []string{"alice@example.com"}, maxScore: 1},
+		{password: "correct-horse-battery-staple", minScore: 4, maxScore: 4},
+		{password: "x9#Lq2!vT7@m", minScore: 4, maxScore: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got := Estimate(tt.password, tt.inputs...)
+			if got.Score < tt.minScore || got.Score > tt.maxScore {
+				t.Errorf("Estimate() score = %v (%.1f bits, %+v), want %v to %v",
+					got.Score, got.Entropy, got.Matches, tt.minScore, tt.maxScore)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	banned, err := CompileBanned([]string{"(?i)^pass"})
+	if err != nil {
+		t.Fatalf("CompileBanned() error = %v", err)
+	}
+	policy := Policy{MinLength: 10, MinScore: 3, Banned: banned}
+
+	err = policy.Validate("Password-alice", "alice", "alice@example.com")
+	if !errors.Contains(err, ErrPolicyViolation) {
+		t.Fatalf("Validate() error = %v, want %v", err, ErrPolicyViolation)
+	}
+
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate() error is %T, want *ValidationError", err)
+	}
+
+	rules := map[string]bool{}
+	for _, f := range verr.Failures {
+		rules[f.Rule] = true
+	}
+	for _, rule := range []string{RuleMinScore, RuleBanned, RulePersonal} {
+		if !rules[rule] {
+			t.Errorf("Validate() failures = %+v, missing %v", verr.Failures, rule)
+		}
+	}
+	if rules[RuleMinLength] {
+		t.Errorf("Validate() failures = %+v, %v should pass", verr.Failures, RuleMinLength)
+	}
+
+	if err := policy.Validate("x9#Lq2!vT7@m-orbit", "alice", "alice@example.com"); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+}
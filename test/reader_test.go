@@ -17,6 +17,10 @@ import (
 	"context"
 	"fmt"
 	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/cli/csv"
+	"github.com/hackaio/pk/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -56,3 +60,84 @@ func TestJSONReader(t *testing.T) {
 
 	}
 }
+
+func TestCSVReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		opts    csv.Options
+		want    []string
+		wantErr error
+		rows    []int
+	}{
+		{name: "no header", data: "github,alice,a@x.io,pw1\ngitlab,bob,b@x.io,pw2\n", want: []string{"github", "gitlab"}},
+		{name: "header", data: "name,username,email,password\ngithub,alice,a@x.io,pw1\n", want: []string{"github"}},
+		{name: "header in any order", data: "Password,Name,UserName\npw1,github,alice\n", want: []string{"github"}},
+		{name: "bom and semicolons", data: "\ufeffname;username;email;password\r\ngithub;alice;a@x.io;pw1\r\n", want: []string{"github"}},
+		{name: "tabs", data: "github\talice\ta@x.io\tpw1\n", opts: csv.Options{Delimiter: '\t'}, want: []string{"github"}},
+		{name: "quoted lines", data: "name,username,password,notes\n\"git\"\"hub\",alice,pw1,\"two\nlines\"\n\ngitlab,bob,pw2,\n", want: []string{"git\"hub", "gitlab"}},
+		{
+			name: "mapping",
+			data: "Title,Login,Secret,URL\ngithub,alice,pw1,https://github.com\n",
+			opts: csv.Options{Mapping: map[string]string{"name": "Title", "username": "login", "password": "Secret", "urls": "URL"}},
+			want: []string{"github"},
+		},
+		{name: "mapping without header", data: "github,alice,a@x.io,pw1\n", opts: csv.Options{Mapping: map[string]string{"name": "Title"}}, wantErr: csv.ErrInvalidMapping},
+		{name: "missing column", data: "Title,username,password\ngithub,alice,pw1\n", wantErr: csv.ErrMissingColumn},
+		{
+			name:    "bad rows",
+			data:    "name,username,password,fields\ngithub,alice,pw1,\n\ngitlab,,,\nx\"y,bob,pw,\nbit,bob,pw,{\n",
+			wantErr: csv.ErrInvalidRows,
+			rows:    []int{4, 5, 6},
+		},
+		{name: "short row", data: "github,alice\n", wantErr: csv.ErrInvalidRows, rows: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "accounts.csv")
+			if err := ioutil.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := csv.NewReaderWith(tt.opts).Read(context.Background(), path)
+			if tt.wantErr != nil {
+				if !errors.Contains(err, tt.wantErr) {
+					t.Fatalf("Read() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.rows != nil {
+					verr, ok := err.(*csv.ValidationError)
+					if !ok {
+						t.Fatalf("Read() error = %T, want *csv.ValidationError", err)
+					}
+					var lines []int
+					for _, row := range verr.Rows {
+						lines = append(lines, row.Line)
+					}
+					if !reflect.DeepEqual(lines, tt.rows) {
+						t.Errorf("Read() bad rows = %v, want lines %v", verr.Rows, tt.rows)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			var names []string
+			for _, acc := range got {
+				names = append(names, acc.Name)
+				if acc.UserName == "" || acc.Password == "" {
+					t.Errorf("Read() = %+v, want username and password", acc)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Read() names = %v, want %v", names, tt.want)
+			}
+		})
+	}
+
+	if _, err := csv.NewReader().Read(context.Background(), filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Errorf("Read() of a missing file error = nil")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/cli/csv"
+	"github.com/hackaio/pk/pkg/errors"
+	"github.com/hackaio/pk/pkg/files"
+)
+
+var written = []pk.Account{
+	{Name: "github", UserName: "alice", Email: "alice@example.com", Password: "s3cret", Tags: []string{"work"}},
+	{Name: "gitlab", UserName: "bob", Password: "hunter2"},
+}
+
+func TestWriters(t *testing.T) {
+	writers := map[string]pk.ReaderWriter{
+		"json": pk.JsonReaderWriter(),
+		"csv":  csv.ReaderWriter(),
+	}
+
+	for ext, rw := range writers {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			req := pk.FileWriterReq{Accounts: written, FileName: "accounts", FileExt: ext, FileDir: dir}
+			path := filepath.Join(dir, "accounts."+ext)
+
+			if err := rw.Write(context.Background(), req); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Write() did not create %v: %v", path, err)
+			}
+			if info.Mode().Perm() != files.SecretPerm {
+				t.Errorf("Write() created %v with mode %v, want %v", path, info.Mode().Perm(), files.SecretPerm)
+			}
+
+			got, err := rw.Read(context.Background(), path)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got) != len(written) || !reflect.DeepEqual(got[0].Tags, written[0].Tags) || got[1].Password != written[1].Password {
+				t.Errorf("Read() = %+v, want %+v", got, written)
+			}
+
+			req.Accounts = nil
+			if err := rw.Write(context.Background(), req); !errors.Contains(err, files.ErrFileExists) {
+				t.Errorf("Write() over an existing file error = %v, want %v", err, files.ErrFileExists)
+			}
+
+			req.Force = true
+			if err := rw.Write(context.Background(), req); err != nil {
+				t.Errorf("Write() with Force error = %v", err)
+			}
+			if got, _ := rw.Read(context.Background(), path); len(got) != 0 {
+				t.Errorf("Write() with Force kept %v accounts", len(got))
+			}
+
+			var out bytes.Buffer
+			req = pk.FileWriterReq{Accounts: written, Out: &out}
+			if err := rw.Write(context.Background(), req); err != nil {
+				t.Fatalf("Write() to Out error = %v", err)
+			}
+			if !strings.Contains(out.String(), "hunter2") {
+				t.Errorf("Write() to Out = %q", out.String())
+			}
+
+			entries, _ := filepath.Glob(filepath.Join(dir, ".*"))
+			if len(entries) > 0 {
+				t.Errorf("Write() left temporary files %v", entries)
+			}
+		})
+	}
+}
+
+func TestWriterPath(t *testing.T) {
+	tests := []pk.FileWriterReq{
+		{FileName: "accounts", FileExt: "csv", FileDir: "out"},
+		{FileName: "accounts.csv", FileExt: "csv", FileDir: "out"},
+		{FileName: "accounts", FileExt: ".csv", FileDir: "out"},
+	}
+
+	for _, req := range tests {
+		if got := req.Path(); got != filepath.Join("out", "accounts.csv") {
+			t.Errorf("Path() of %+v = %v", req, got)
+		}
+	}
+}
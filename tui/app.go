@@ -0,0 +1,873 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tui is the full screen terminal interface of pk. It only talks
+// to a pk.PasswordKeeper, so it works the same over the local database, the
+// agent or a remote server.
+//
+// The list keeps no secrets: passwords and secret fields are fetched with
+// Get when revealed and dropped again when the selection moves or the
+// screen locks.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk"
+	"github.com/hackaio/pk/fuzzy"
+	"github.com/hackaio/pk/otp"
+	"github.com/hackaio/pk/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	mask = "••••••••"
+
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+
+	styleReverse = "\x1b[7m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleRed     = "\x1b[31m"
+	styleReset   = "\x1b[0m"
+)
+
+// labels of the account form
+const (
+	labelName     = "name"
+	labelUserName = "username"
+	labelEmail    = "email"
+	labelPassword = "password"
+	labelURLs     = "urls"
+	labelTags     = "tags"
+	labelFolder   = "folder"
+	labelNotes    = "notes"
+	labelCode     = "code"
+)
+
+// Options tune the interface.
+type Options struct {
+	// Idle is how long the screen stays unlocked without a key press,
+	// zero never locks.
+	Idle time.Duration
+
+	// UserName is offered by the unlock form.
+	UserName string
+}
+
+type mode int
+
+const (
+	modeList mode = iota
+	modeSearch
+	modeForm
+	modeConfirm
+	modeLocked
+)
+
+// App is the state of the interface. Run drives it from a terminal.
+type App struct {
+	keeper pk.PasswordKeeper
+	token  string
+	opts   Options
+	ctx    context.Context
+
+	// accounts are listed without their secrets, visible indexes them in
+	// the order shown
+	accounts []pk.Account
+	visible  []int
+	filter   []rune
+	selected int
+	offset   int
+
+	revealed *pk.Account
+	mode     mode
+	form     *form
+	editing  *pk.Account
+
+	status    string
+	statusErr bool
+	quit      bool
+}
+
+// New returns an App that uses token until it expires or the screen locks,
+// an empty token starts locked.
+func New(keeper pk.PasswordKeeper, token string, opts Options) *App {
+	return &App{
+		keeper: keeper,
+		token:  token,
+		opts:   opts,
+		ctx:    context.Background(),
+	}
+}
+
+// Run takes over the terminal behind in until the user quits.
+func (a *App) Run(in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer terminal.Restore(fd, state)
+
+	fmt.Fprint(out, enterScreen)
+	defer fmt.Fprint(out, leaveScreen)
+
+	a.start()
+
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			e, err := readEvent(r)
+			if err != nil {
+				errs <- err
+				return
+			}
+			events <- e
+		}
+	}()
+
+	var idle <-chan time.Time
+	var timer *time.Timer
+	if a.opts.Idle > 0 {
+		timer = time.NewTimer(a.opts.Idle)
+		defer timer.Stop()
+		idle = timer.C
+	}
+
+	// redraws pick up terminal resizes
+	redraw := time.NewTicker(time.Second)
+	defer redraw.Stop()
+
+	for !a.quit {
+		w, h, err := terminal.GetSize(fd)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, a.render(w, h))
+
+		select {
+		case e := <-events:
+			if timer != nil {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(a.opts.Idle)
+			}
+			a.handle(e)
+
+		case <-idle:
+			if a.mode != modeLocked {
+				a.lock("locked after being idle")
+			}
+			timer.Reset(a.opts.Idle)
+
+		case <-redraw.C:
+
+		case err := <-errs:
+			a.lock("")
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+
+	a.lock("")
+	return nil
+}
+
+// start loads the accounts or asks to unlock when the token is refused.
+func (a *App) start() {
+	if a.token == "" {
+		a.lock("")
+		return
+	}
+
+	if err := a.reload(); err != nil {
+		if errors.Contains(err, pk.ErrPermissionDenied) {
+			a.lock("session expired, unlock to continue")
+			return
+		}
+		a.setError(err)
+	}
+}
+
+// reload lists the accounts again and drops their secrets.
+func (a *App) reload() error {
+	accounts, _, err := a.keeper.List(a.ctx, a.token, pk.Query{})
+	if err != nil {
+		return err
+	}
+
+	for i := range accounts {
+		accounts[i].Password, accounts[i].OTP = "", ""
+
+		fields := make([]pk.Field, len(accounts[i].Fields))
+		for j, f := range accounts[i].Fields {
+			if f.Secret {
+				f.Value = ""
+			}
+			fields[j] = f
+		}
+		accounts[i].Fields = fields
+	}
+
+	a.accounts = accounts
+	a.applyFilter()
+	return nil
+}
+
+// applyFilter ranks the accounts against the filter, every word of it has
+// to match the name, username, email, a url or a tag.
+func (a *App) applyFilter() {
+	a.visible = a.visible[:0]
+	a.revealed = nil
+
+	terms := strings.Fields(string(a.filter))
+	scores := map[int]int{}
+
+	for i, account := range a.accounts {
+		total, ok := 0, true
+		for _, term := range terms {
+			best, found := 0, false
+			for _, text := range searchable(account) {
+				if s, matched := fuzzy.Score(term, text); matched && (!found || s > best) {
+					best, found = s, true
+				}
+			}
+			if !found {
+				ok = false
+				break
+			}
+			total += best
+		}
+
+		if ok {
+			a.visible = append(a.visible, i)
+			scores[i] = total
+		}
+	}
+
+	if len(terms) > 0 {
+		sort.SliceStable(a.visible, func(i, j int) bool {
+			return scores[a.visible[i]] > scores[a.visible[j]]
+		})
+	}
+
+	a.selected, a.offset = 0, 0
+}
+
+func searchable(account pk.Account) []string {
+	texts := []string{account.Name, account.UserName, account.Email}
+	texts = append(texts, account.URLs...)
+	return append(texts, account.Tags...)
+}
+
+// current is the selected account, nil when nothing is listed.
+func (a *App) current() *pk.Account {
+	if a.selected < 0 || a.selected >= len(a.visible) {
+		return nil
+	}
+	return &a.accounts[a.visible[a.selected]]
+}
+
+func (a *App) handle(e Event) {
+	if e.Key == KeyCtrlC {
+		a.quit = true
+		return
+	}
+
+	switch a.mode {
+	case modeLocked:
+		a.handleLocked(e)
+	case modeSearch:
+		a.handleSearch(e)
+	case modeForm:
+		a.handleForm(e)
+	case modeConfirm:
+		a.handleConfirm(e)
+	default:
+		a.handleList(e)
+	}
+}
+
+func (a *App) handleList(e Event) {
+	if a.move(e) {
+		return
+	}
+
+	switch {
+	case e.Key == KeyEsc:
+		a.filter = nil
+		a.applyFilter()
+	case e.Key != KeyRune:
+	case e.Rune == 'q':
+		a.quit = true
+	case e.Rune == '/':
+		a.mode = modeSearch
+	case e.Rune == 'r':
+		a.toggleReveal()
+	case e.Rune == 'a':
+		a.openForm(nil)
+	case e.Rune == 'e':
+		if account := a.current(); account != nil {
+			a.openForm(account)
+		}
+	case e.Rune == 'd':
+		if a.current() != nil {
+			a.mode = modeConfirm
+		}
+	case e.Rune == 'l':
+		a.lock("locked")
+	}
+}
+
+// move handles the keys that change the selection.
+func (a *App) move(e Event) bool {
+	selected := a.selected
+
+	switch {
+	case e.Key == KeyUp, e.Key == KeyRune && e.Rune == 'k' && a.mode == modeList:
+		selected--
+	case e.Key == KeyDown, e.Key == KeyRune && e.Rune == 'j' && a.mode == modeList:
+		selected++
+	case e.Key == KeyPgUp:
+		selected -= 10
+	case e.Key == KeyPgDn:
+		selected += 10
+	case e.Key == KeyHome:
+		selected = 0
+	case e.Key == KeyEnd:
+		selected = len(a.visible) - 1
+	default:
+		return false
+	}
+
+	if selected >= len(a.visible) {
+		selected = len(a.visible) - 1
+	}
+	if selected < 0 {
+		selected = 0
+	}
+
+	if selected != a.selected {
+		a.selected = selected
+		a.revealed = nil
+	}
+	return true
+}
+
+func (a *App) handleSearch(e Event) {
+	switch e.Key {
+	case KeyEnter:
+		a.mode = modeList
+	case KeyEsc:
+		a.filter = nil
+		a.applyFilter()
+		a.mode = modeList
+	case KeyBackspace:
+		if len(a.filter) > 0 {
+			a.filter = a.filter[:len(a.filter)-1]
+			a.applyFilter()
+		}
+	case KeyRune:
+		a.filter = append(a.filter, e.Rune)
+		a.applyFilter()
+	default:
+		a.move(e)
+	}
+}
+
+func (a *App) toggleReveal() {
+	account := a.current()
+	if account == nil {
+		return
+	}
+
+	if a.revealed != nil {
+		a.revealed = nil
+		return
+	}
+
+	full, err := a.keeper.Get(a.ctx, a.token, account.Name, account.UserName)
+	if err != nil {
+		a.fail(err)
+		return
+	}
+	a.revealed = &full
+}
+
+// openForm edits account, or adds a new one when it is nil.
+func (a *App) openForm(account *pk.Account) {
+	title := "add account"
+	if account != nil {
+		title = fmt.Sprintf("edit %v / %v (blank password keeps it)", account.Name, account.UserName)
+	}
+
+	f := newForm(title, labelName, labelUserName, labelEmail, labelPassword,
+		labelURLs, labelTags, labelFolder, labelNotes)
+	f.field(labelPassword).masked = true
+
+	if account != nil {
+		f.field(labelName).set(account.Name)
+		f.field(labelUserName).set(account.UserName)
+		f.field(labelEmail).set(account.Email)
+		f.field(labelURLs).set(strings.Join(account.URLs, " "))
+		f.field(labelTags).set(strings.Join(account.Tags, ", "))
+		f.field(labelFolder).set(account.Folder)
+		f.field(labelNotes).set(account.Notes)
+	}
+
+	edited := account
+	if account != nil {
+		copied := *account
+		edited = &copied
+	}
+
+	a.form, a.editing, a.mode = f, edited, modeForm
+	a.revealed = nil
+	a.status = ""
+}
+
+func (a *App) handleForm(e Event) {
+	switch {
+	case e.Key == KeyEsc:
+		a.closeForm()
+		a.mode = modeList
+	case e.Key == KeyCtrlS,
+		e.Key == KeyEnter && a.form.focus == len(a.form.inputs)-1:
+		a.save()
+	default:
+		a.form.handle(e)
+	}
+}
+
+func (a *App) closeForm() {
+	if a.form != nil {
+		a.form.wipe()
+	}
+	a.form, a.editing = nil, nil
+}
+
+func (a *App) save() {
+	f := a.form
+	account := pk.Account{
+		Name:     f.value(labelName),
+		UserName: f.value(labelUserName),
+		Email:    f.value(labelEmail),
+		Password: f.field(labelPassword).text(),
+		Folder:   f.value(labelFolder),
+		Notes:    f.value(labelNotes),
+	}
+
+	urls := strings.Fields(strings.Replace(f.value(labelURLs), ",", " ", -1))
+	tags := splitTags(f.value(labelTags))
+
+	var err error
+	if a.editing == nil {
+		if account.Name == "" || account.UserName == "" || account.Password == "" {
+			a.setError(errors.New("name, username and password are needed"))
+			return
+		}
+		account.URLs, account.Tags = urls, tags
+		err = a.keeper.Add(a.ctx, a.token, account)
+	} else {
+		old := a.editing
+		if strings.Join(urls, " ") != strings.Join(old.URLs, " ") {
+			account.URLs = urls
+			if account.URLs == nil {
+				account.URLs = []string{}
+			}
+		}
+		if strings.Join(tags, ",") != strings.Join(old.Tags, ",") {
+			account.Tags = tags
+			if account.Tags == nil {
+				account.Tags = []string{}
+			}
+		}
+		_, err = a.keeper.Update(a.ctx, a.token, old.Name, old.UserName, account)
+	}
+
+	if err != nil {
+		a.fail(err)
+		return
+	}
+
+	a.closeForm()
+	a.mode = modeList
+	a.refresh(account.Name, account.UserName)
+	a.setStatus("saved")
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (a *App) handleConfirm(e Event) {
+	a.mode = modeList
+
+	account := a.current()
+	if account == nil || e.Key != KeyRune || e.Rune != 'y' {
+		return
+	}
+
+	name, username := account.Name, account.UserName
+	if err := a.keeper.Delete(a.ctx, a.token, name, username); err != nil {
+		a.fail(err)
+		return
+	}
+
+	a.refresh("", "")
+	a.setStatus(fmt.Sprintf("moved %v / %v to the trash", name, username))
+}
+
+// refresh reloads the list and selects the account, if it is shown.
+func (a *App) refresh(name, username string) {
+	if err := a.reload(); err != nil {
+		a.fail(err)
+		return
+	}
+
+	for i, idx := range a.visible {
+		if a.accounts[idx].Name == name && a.accounts[idx].UserName == username {
+			a.selected = i
+		}
+	}
+}
+
+// lock drops everything read from the keeper and asks for the master
+// password again.
+func (a *App) lock(msg string) {
+	a.closeForm()
+
+	for i := range a.accounts {
+		a.accounts[i] = pk.Account{}
+	}
+	a.accounts, a.visible, a.filter = nil, nil, nil
+	a.revealed, a.token = nil, ""
+
+	f := newForm("pk is locked, enter the master password", labelUserName, labelPassword)
+	f.field(labelPassword).masked = true
+	f.field(labelUserName).set(a.opts.UserName)
+	if a.opts.UserName != "" {
+		f.focus = 1
+	}
+
+	a.form, a.mode = f, modeLocked
+	a.setStatus(msg)
+}
+
+func (a *App) handleLocked(e Event) {
+	if e.Key != KeyEnter && e.Key != KeyCtrlS || a.form.focus != len(a.form.inputs)-1 {
+		a.form.handle(e)
+		return
+	}
+
+	username := a.form.value(labelUserName)
+	password := a.form.field(labelPassword).text()
+	code := a.form.field(labelCode)
+
+	var token string
+	var err error
+	if code == nil {
+		token, err = a.keeper.Login(a.ctx, username, password, "")
+	} else {
+		token, err = a.keeper.Login(a.ctx, username, password, code.text())
+		code.set("")
+	}
+
+	if code == nil && errors.Contains(err, pk.ErrCodeRequired) {
+		a.askCode(username, password)
+		return
+	}
+	if code == nil {
+		a.form.field(labelPassword).set("")
+	}
+
+	if err != nil {
+		a.setError(err)
+		return
+	}
+
+	a.opts.UserName = username
+	a.form.wipe()
+	a.form, a.token, a.mode = nil, token, modeList
+
+	if err := a.reload(); err != nil {
+		a.fail(err)
+		return
+	}
+	a.setStatus("unlocked")
+}
+
+// askCode adds the second factor to the unlock form. The password already
+// matched, it is kept until a code is accepted.
+func (a *App) askCode(username, password string) {
+	f := newForm("enter the code of your authenticator app or a recovery code",
+		labelUserName, labelPassword, labelCode)
+	f.field(labelPassword).masked = true
+	f.field(labelUserName).set(username)
+	f.field(labelPassword).set(password)
+	f.focus = 2
+
+	a.form.wipe()
+	a.form = f
+}
+
+// fail shows err, a refused token locks the screen.
+func (a *App) fail(err error) {
+	if errors.Contains(err, pk.ErrPermissionDenied) && a.mode != modeLocked {
+		a.lock("session expired, unlock to continue")
+		return
+	}
+	a.setError(err)
+}
+
+func (a *App) setStatus(msg string) {
+	a.status, a.statusErr = msg, false
+}
+
+func (a *App) setError(err error) {
+	a.status, a.statusErr = err.Error(), true
+}
+
+// render draws the whole screen for a w by h terminal.
+func (a *App) render(w, h int) string {
+	if w < 40 || h < 8 {
+		return "\x1b[H\x1b[2J" + truncate("pk: the terminal is too small", w)
+	}
+
+	var lines []string
+
+	header := fmt.Sprintf(" pk  %d accounts", len(a.visible))
+	if len(a.filter) > 0 || a.mode == modeSearch {
+		header += fmt.Sprintf("  /%s", string(a.filter))
+		if a.mode == modeSearch {
+			header += "_"
+		}
+	}
+	lines = append(lines, styleReverse+pad(header, w)+styleReset)
+
+	rows := h - 3
+	if a.mode == modeLocked {
+		for _, line := range a.formLines(w, rows) {
+			lines = append(lines, pad(line, w))
+		}
+	} else {
+		lw := w * 2 / 5
+		rw := w - lw - 3
+
+		left := a.listLines(lw, rows)
+		var right []string
+		if a.mode == modeForm {
+			right = a.formLines(rw, rows)
+		} else {
+			right = a.detailLines(rw, rows)
+		}
+
+		for i := 0; i < rows; i++ {
+			l, r := pad("", lw), ""
+			if i < len(right) {
+				r = right[i]
+			}
+			if i < len(left) {
+				l = left[i]
+			}
+			lines = append(lines, l+styleDim+" │ "+styleReset+pad(r, rw))
+		}
+	}
+
+	status := pad(" "+a.status, w)
+	if a.statusErr {
+		status = styleRed + status + styleReset
+	}
+	lines = append(lines, status)
+	lines = append(lines, styleDim+pad(" "+a.help(), w)+styleReset)
+
+	return "\x1b[H" + strings.Join(lines, "\x1b[K\r\n")
+}
+
+func (a *App) help() string {
+	switch a.mode {
+	case modeSearch:
+		return "type to filter  ↑↓ move  enter keep  esc clear"
+	case modeForm:
+		return "tab/↑↓ move  ctrl-s save  esc cancel"
+	case modeConfirm:
+		if account := a.current(); account != nil {
+			return fmt.Sprintf("move %v / %v to the trash? y/n", account.Name, account.UserName)
+		}
+	case modeLocked:
+		return "enter unlock  ctrl-c quit"
+	}
+	return "↑↓ move  / search  r reveal  a add  e edit  d delete  l lock  q quit"
+}
+
+// listLines are the visible rows of the list, the selection kept in view.
+func (a *App) listLines(w, rows int) []string {
+	if a.selected < a.offset {
+		a.offset = a.selected
+	}
+	if a.selected >= a.offset+rows {
+		a.offset = a.selected - rows + 1
+	}
+
+	var lines []string
+	for i := a.offset; i < len(a.visible) && len(lines) < rows; i++ {
+		account := a.accounts[a.visible[i]]
+		line := pad(fmt.Sprintf(" %s  %s", account.Name, account.UserName), w)
+		if i == a.selected {
+			line = styleReverse + line + styleReset
+		}
+		lines = append(lines, line)
+	}
+
+	if len(a.visible) == 0 {
+		lines = append(lines, pad(" no accounts", w))
+	}
+
+	return lines
+}
+
+// detailLines describe the selected account with its secrets masked
+// unless revealed.
+func (a *App) detailLines(w, rows int) []string {
+	account := a.current()
+	if account == nil {
+		return nil
+	}
+
+	shown := *account
+	revealed := a.revealed != nil
+	if revealed {
+		shown = *a.revealed
+	}
+
+	var lines []string
+	add := func(label, value string) {
+		lines = append(lines, truncate(fmt.Sprintf("%-10s %s", label, value), w))
+	}
+
+	lines = append(lines, styleBold+truncate(shown.Name, w)+styleReset, "")
+	add("username", shown.UserName)
+	add("email", shown.Email)
+	if revealed {
+		add("password", shown.Password)
+	} else {
+		add("password", mask)
+	}
+	for _, url := range shown.URLs {
+		add("url", url)
+	}
+	if shown.Folder != "" {
+		add("folder", shown.Folder)
+	}
+	if len(shown.Tags) > 0 {
+		add("tags", strings.Join(shown.Tags, ", "))
+	}
+	add("created", shown.Created)
+	if revealed && shown.OTP != "" {
+		add("otp", otpCode(shown.OTP))
+	}
+	for _, f := range shown.Fields {
+		if f.Secret && !revealed {
+			add(f.Name, mask)
+			continue
+		}
+		add(f.Name, f.Value)
+	}
+	if shown.Notes != "" {
+		lines = append(lines, "")
+		for _, line := range strings.Split(shown.Notes, "\n") {
+			lines = append(lines, truncate(line, w))
+		}
+	}
+
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	return lines
+}
+
+// otpCode is the current TOTP code of uri. HOTP codes are not shown, each
+// one moves the stored counter on.
+func otpCode(uri string) string {
+	key, err := otp.Parse(uri)
+	if err != nil {
+		return err.Error()
+	}
+	if key.Type != otp.TypeTOTP {
+		return "hotp, see pk otp"
+	}
+
+	code, remaining := key.At(time.Now())
+	return fmt.Sprintf("%s (%ds left)", code, int(remaining.Round(time.Second)/time.Second))
+}
+
+func (a *App) formLines(w, rows int) []string {
+	f := a.form
+	lines := []string{styleBold + truncate(" "+f.title, w) + styleReset, ""}
+
+	for i, in := range f.inputs {
+		value := in.display()
+		if i == f.focus {
+			value += "_"
+		}
+		line := truncate(fmt.Sprintf(" %-10s %s", in.label, value), w)
+		if i == f.focus {
+			line = styleReverse + pad(line, w) + styleReset
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	return lines
+}
+
+// truncate cuts s to w runes.
+func truncate(s string, w int) string {
+	r := []rune(s)
+	if len(r) > w {
+		return string(r[:w])
+	}
+	return s
+}
+
+// pad truncates or fills s with spaces to w runes.
+func pad(s string, w int) string {
+	s = truncate(s, w)
+	if n := len([]rune(s)); n < w {
+		s += strings.Repeat(" ", w-n)
+	}
+	return s
+}
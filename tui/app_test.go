@@ -0,0 +1,257 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tui
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hackaio/pk"
+)
+
+type keeperMock struct {
+	pk.PasswordKeeper
+	accounts []pk.Account
+	added    []pk.Account
+	updated  []pk.Account
+	deleted  []string
+	code     string
+}
+
+func (k *keeperMock) Login(ctx context.Context, username, password, code string) (string, error) {
+	if password != "master" {
+		return "", pk.ErrPermissionDenied
+	}
+	if k.code != "" && code == "" {
+		return "", pk.ErrCodeRequired
+	}
+	if code != k.code {
+		return "", pk.ErrPermissionDenied
+	}
+	return "token", nil
+}
+
+func (k *keeperMock) List(ctx context.Context, token string, query pk.Query) ([]pk.Account, string, error) {
+	if token != "token" {
+		return nil, "", pk.ErrPermissionDenied
+	}
+	accounts := make([]pk.Account, len(k.accounts))
+	copy(accounts, k.accounts)
+	return accounts, "", nil
+}
+
+func (k *keeperMock) Get(ctx context.Context, token, name, username string) (pk.Account, error) {
+	for _, a := range k.accounts {
+		if a.Name == name && a.UserName == username {
+			return a, nil
+		}
+	}
+	return pk.Account{}, pk.ErrNotFound
+}
+
+func (k *keeperMock) Add(ctx context.Context, token string, account pk.Account) error {
+	k.added = append(k.added, account)
+	k.accounts = append(k.accounts, account)
+	return nil
+}
+
+func (k *keeperMock) Update(ctx context.Context, token, name, username string, account pk.Account) (pk.Account, error) {
+	k.updated = append(k.updated, account)
+	return account, nil
+}
+
+func (k *keeperMock) Delete(ctx context.Context, token, name, username string) error {
+	k.deleted = append(k.deleted, name+"/"+username)
+	return nil
+}
+
+func newKeeper() *keeperMock {
+	return &keeperMock{accounts: []pk.Account{
+		{Name: "github", UserName: "alice", Password: "gh-secret", Tags: []string{"work"}},
+		{Name: "gitlab", UserName: "alice", Password: "gl-secret"},
+		{Name: "postgres", UserName: "app", Password: "pg-secret",
+			Fields: []pk.Field{{Name: "pin", Value: "1234", Secret: true}}},
+	}}
+}
+
+func typeText(a *App, s string) {
+	for _, r := range s {
+		a.handle(Event{Key: KeyRune, Rune: r})
+	}
+}
+
+func TestSecretsMasked(t *testing.T) {
+	a := New(newKeeper(), "token", Options{})
+	a.start()
+
+	for _, account := range a.accounts {
+		if account.Password != "" {
+			t.Fatalf("listed account %v kept its password", account.Name)
+		}
+	}
+
+	typeText(a, "jj")
+	screen := a.render(100, 20)
+	if strings.Contains(screen, "pg-secret") || strings.Contains(screen, "1234") {
+		t.Fatalf("render() shows secrets before reveal")
+	}
+
+	typeText(a, "r")
+	screen = a.render(100, 20)
+	if !strings.Contains(screen, "pg-secret") || !strings.Contains(screen, "1234") {
+		t.Fatalf("render() hides secrets after reveal")
+	}
+
+	a.handle(Event{Key: KeyUp})
+	if strings.Contains(a.render(100, 20), "pg-secret") {
+		t.Errorf("render() shows the secret after the selection moved")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	a := New(newKeeper(), "token", Options{})
+	a.start()
+
+	typeText(a, "/work")
+	if len(a.visible) != 1 || a.current().Name != "github" {
+		t.Fatalf("filter /work shows %v accounts, want github", len(a.visible))
+	}
+
+	a.handle(Event{Key: KeyEsc})
+	if len(a.visible) != 3 {
+		t.Errorf("esc left %v accounts, want 3", len(a.visible))
+	}
+}
+
+func TestForms(t *testing.T) {
+	k := newKeeper()
+	a := New(k, "token", Options{})
+	a.start()
+
+	typeText(a, "a")
+	typeText(a, "aws")
+	a.handle(Event{Key: KeyTab})
+	typeText(a, "root")
+	a.handle(Event{Key: KeyTab})
+	a.handle(Event{Key: KeyTab})
+	typeText(a, "hunter2")
+	if strings.Contains(a.render(100, 20), "hunter2") {
+		t.Errorf("render() shows the typed password")
+	}
+	a.handle(Event{Key: KeyCtrlS})
+
+	if len(k.added) != 1 || k.added[0].Name != "aws" || k.added[0].Password != "hunter2" {
+		t.Fatalf("Add() got %v, want aws/root", k.added)
+	}
+	if a.mode != modeList || a.current().Name != "aws" {
+		t.Fatalf("after saving mode = %v, selected %v", a.mode, a.current().Name)
+	}
+
+	typeText(a, "e")
+	a.form.focus = 6
+	typeText(a, "cloud")
+	a.handle(Event{Key: KeyCtrlS})
+
+	if len(k.updated) != 1 || k.updated[0].Folder != "cloud" || k.updated[0].Password != "" {
+		t.Fatalf("Update() got %v, want folder cloud and the password kept", k.updated)
+	}
+
+	typeText(a, "dn")
+	if len(k.deleted) != 0 {
+		t.Fatalf("Delete() called without confirmation")
+	}
+	typeText(a, "dy")
+	if len(k.deleted) != 1 || k.deleted[0] != "aws/root" {
+		t.Errorf("Delete() got %v, want aws/root", k.deleted)
+	}
+}
+
+func TestLock(t *testing.T) {
+	a := New(newKeeper(), "token", Options{UserName: "alice"})
+	a.start()
+	typeText(a, "r")
+
+	a.lock("locked after being idle")
+	if a.mode != modeLocked || a.token != "" || a.accounts != nil || a.revealed != nil {
+		t.Fatalf("lock() kept the session")
+	}
+
+	typeText(a, "wrong")
+	a.handle(Event{Key: KeyEnter})
+	if a.mode != modeLocked || !a.statusErr {
+		t.Fatalf("a wrong password unlocked")
+	}
+
+	typeText(a, "master")
+	a.handle(Event{Key: KeyEnter})
+	if a.mode != modeList || len(a.accounts) != 3 {
+		t.Errorf("unlock failed: mode %v, %v accounts", a.mode, len(a.accounts))
+	}
+}
+
+func TestUnlockTwoFactor(t *testing.T) {
+	k := newKeeper()
+	k.code = "123456"
+	a := New(k, "", Options{UserName: "alice"})
+	a.start()
+
+	typeText(a, "master")
+	a.handle(Event{Key: KeyEnter})
+	if a.mode != modeLocked || a.form.field(labelCode) == nil {
+		t.Fatalf("unlock did not ask for the code")
+	}
+
+	typeText(a, "000000")
+	a.handle(Event{Key: KeyEnter})
+	if a.mode != modeLocked || !a.statusErr {
+		t.Fatalf("a wrong code unlocked")
+	}
+
+	typeText(a, "123456")
+	a.handle(Event{Key: KeyEnter})
+	if a.mode != modeList {
+		t.Errorf("unlock with the code failed: mode %v", a.mode)
+	}
+}
+
+func TestStartLocked(t *testing.T) {
+	a := New(newKeeper(), "expired", Options{})
+	a.start()
+
+	if a.mode != modeLocked {
+		t.Errorf("start() with a refused token: mode = %v, want locked", a.mode)
+	}
+}
+
+func TestReadEvent(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\x1b[A\x1b[5~\x1b[Z\r\x7fé"))
+	want := []Event{
+		{Key: KeyRune, Rune: 'a'},
+		{Key: KeyUp},
+		{Key: KeyPgUp},
+		{Key: KeyBacktab},
+		{Key: KeyEnter},
+		{Key: KeyBackspace},
+		{Key: KeyRune, Rune: 'é'},
+	}
+
+	for _, w := range want {
+		got, err := readEvent(r)
+		if err != nil || got != w {
+			t.Fatalf("readEvent() = %v, %v, want %v", got, err, w)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tui
+
+import (
+	"strings"
+)
+
+// input is a single line text field of a form.
+type input struct {
+	label  string
+	value  []rune
+	cursor int
+	masked bool
+}
+
+func (in *input) text() string {
+	return string(in.value)
+}
+
+func (in *input) set(s string) {
+	in.value = []rune(s)
+	in.cursor = len(in.value)
+}
+
+// handle edits the field and reports whether it used e.
+func (in *input) handle(e Event) bool {
+	switch e.Key {
+	case KeyRune:
+		in.value = append(in.value[:in.cursor], append([]rune{e.Rune}, in.value[in.cursor:]...)...)
+		in.cursor++
+	case KeyBackspace:
+		if in.cursor > 0 {
+			in.value = append(in.value[:in.cursor-1], in.value[in.cursor:]...)
+			in.cursor--
+		}
+	case KeyDelete:
+		if in.cursor < len(in.value) {
+			in.value = append(in.value[:in.cursor], in.value[in.cursor+1:]...)
+		}
+	case KeyLeft:
+		if in.cursor > 0 {
+			in.cursor--
+		}
+	case KeyRight:
+		if in.cursor < len(in.value) {
+			in.cursor++
+		}
+	case KeyHome:
+		in.cursor = 0
+	case KeyEnd:
+		in.cursor = len(in.value)
+	case KeyCtrlU:
+		in.value, in.cursor = nil, 0
+	default:
+		return false
+	}
+
+	return true
+}
+
+// display is the value as drawn, masked fields show one dot per rune.
+func (in *input) display() string {
+	if in.masked {
+		return strings.Repeat("•", len(in.value))
+	}
+	return in.text()
+}
+
+// form is a list of inputs, Tab and the arrows move between them.
+type form struct {
+	title  string
+	inputs []*input
+	focus  int
+}
+
+func newForm(title string, labels ...string) *form {
+	f := &form{title: title}
+	for _, label := range labels {
+		f.inputs = append(f.inputs, &input{label: label})
+	}
+	return f
+}
+
+func (f *form) field(label string) *input {
+	for _, in := range f.inputs {
+		if in.label == label {
+			return in
+		}
+	}
+	return nil
+}
+
+func (f *form) value(label string) string {
+	return strings.TrimSpace(f.field(label).text())
+}
+
+// handle moves the focus or edits the focused input.
+func (f *form) handle(e Event) {
+	switch e.Key {
+	case KeyTab, KeyDown, KeyEnter:
+		f.focus = (f.focus + 1) % len(f.inputs)
+	case KeyBacktab, KeyUp:
+		f.focus = (f.focus + len(f.inputs) - 1) % len(f.inputs)
+	default:
+		f.inputs[f.focus].handle(e)
+	}
+}
+
+// wipe drops the typed values so secrets do not linger in memory.
+func (f *form) wipe() {
+	for _, in := range f.inputs {
+		for i := range in.value {
+			in.value[i] = 0
+		}
+		in.value, in.cursor = nil, 0
+	}
+}
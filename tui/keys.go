@@ -0,0 +1,143 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tui
+
+import (
+	"bufio"
+)
+
+// Key is a key that does not stand for a character.
+type Key int
+
+const (
+	KeyRune Key = iota
+	KeyEnter
+	KeyEsc
+	KeyBackspace
+	KeyTab
+	KeyBacktab
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyPgUp
+	KeyPgDn
+	KeyDelete
+	KeyCtrlC
+	KeyCtrlS
+	KeyCtrlU
+	KeyUnknown
+)
+
+// Event is a key press, Rune is set for KeyRune.
+type Event struct {
+	Key  Key
+	Rune rune
+}
+
+// readEvent decodes the next key from a terminal in raw mode. Escape
+// sequences arrive in one write, so an escape with nothing buffered
+// behind it is the escape key itself.
+func readEvent(r *bufio.Reader) (Event, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return Event{}, err
+	}
+
+	switch c {
+	case '\r', '\n':
+		return Event{Key: KeyEnter}, nil
+	case 0x7f, 0x08:
+		return Event{Key: KeyBackspace}, nil
+	case '\t':
+		return Event{Key: KeyTab}, nil
+	case 0x03:
+		return Event{Key: KeyCtrlC}, nil
+	case 0x13:
+		return Event{Key: KeyCtrlS}, nil
+	case 0x15:
+		return Event{Key: KeyCtrlU}, nil
+	case 0x1b:
+		if r.Buffered() == 0 {
+			return Event{Key: KeyEsc}, nil
+		}
+		return readEscape(r)
+	}
+
+	if c < 0x20 {
+		return Event{Key: KeyUnknown}, nil
+	}
+
+	return Event{Key: KeyRune, Rune: c}, nil
+}
+
+// readEscape decodes the CSI and SS3 sequences of the keys pk uses.
+func readEscape(r *bufio.Reader) (Event, error) {
+	intro, err := r.ReadByte()
+	if err != nil {
+		return Event{}, err
+	}
+	if intro != '[' && intro != 'O' {
+		return Event{Key: KeyUnknown}, nil
+	}
+
+	var params []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return Event{}, err
+		}
+
+		if b >= 0x40 && b <= 0x7e {
+			return Event{Key: csiKey(b, string(params))}, nil
+		}
+		params = append(params, b)
+	}
+}
+
+func csiKey(final byte, params string) Key {
+	switch final {
+	case 'A':
+		return KeyUp
+	case 'B':
+		return KeyDown
+	case 'C':
+		return KeyRight
+	case 'D':
+		return KeyLeft
+	case 'H':
+		return KeyHome
+	case 'F':
+		return KeyEnd
+	case 'Z':
+		return KeyBacktab
+	case '~':
+		switch params {
+		case "1", "7":
+			return KeyHome
+		case "4", "8":
+			return KeyEnd
+		case "3":
+			return KeyDelete
+		case "5":
+			return KeyPgUp
+		case "6":
+			return KeyPgDn
+		}
+	}
+
+	return KeyUnknown
+}
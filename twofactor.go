@@ -0,0 +1,274 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"github.com/hackaio/pk/otp"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+//TwoFactor is the second factor of a master account as stored. OTP is nil
+//while it is off, its Counter is the period of the last code accepted so
+//a code works once. Recovery holds the hashes of the unused recovery codes
+type TwoFactor struct {
+	OTP      *DBOTP   `json:"otp,omitempty"`
+	Recovery []string `json:"recovery,omitempty"`
+}
+
+const (
+	//RecoveryCodes is the number of recovery codes issued at enrollment
+	RecoveryCodes = 10
+
+	//codeSkew is how many periods a code may be early or late
+	codeSkew = 1
+
+	//minSecretSize is the shortest seed RFC 4226 allows, in bytes
+	minSecretSize = 16
+
+	//recovery codes are written xxxxx-xxxxx without letters that are
+	//easily mistaken for one another
+	recoveryAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+	recoveryLength   = 10
+)
+
+func (p passwordKeeper) EnableTwoFactor(ctx context.Context, token, secret, code string) (recovery []string, err error) {
+	t, err := p.tokenizer.Parse(token)
+	if err != nil {
+		return nil, errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	err = p.transaction(ctx, func(keeper passwordKeeper) error {
+		recovery, err = keeper.enableTwoFactor(ctx, t.ID, secret, code)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recovery, nil
+}
+
+//enableTwoFactor is EnableTwoFactor for the master account username, it
+//runs in the transaction of EnableTwoFactor
+func (p passwordKeeper) enableTwoFactor(ctx context.Context, username, secret, code string) (recovery []string, err error) {
+	tf, err := p.passwords.GetTwoFactor(ctx, "master", username)
+	if err != nil {
+		return nil, errors.Wrap(ErrInternalError, err)
+	}
+	if tf.OTP != nil {
+		return nil, errors.Wrap(ErrInvalidArgs, errors.New("two factor authentication is already on"))
+	}
+
+	key, err := otp.NewKey(secret)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidArgs, err)
+	}
+	if len(key.Secret) < minSecretSize {
+		return nil, errors.Wrap(ErrInvalidArgs, errors.New("the secret is too short"))
+	}
+
+	counter, ok := key.Check(strings.TrimSpace(code), time.Now(), codeSkew)
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidArgs, errors.New("the code does not match the secret"))
+	}
+
+	encoded, err := p.es.Encode(otp.EncodeSecret(key.Secret))
+	if err != nil {
+		return nil, errors.Wrap(ErrCriticalFailure, err)
+	}
+
+	hashes := make([]string, RecoveryCodes)
+	recovery = make([]string, RecoveryCodes)
+	for i := range recovery {
+		recovery[i], err = newRecoveryCode()
+		if err != nil {
+			return nil, errors.Wrap(ErrCriticalFailure, err)
+		}
+		hashes[i], err = p.hash.Hash(normalizeRecoveryCode(recovery[i]))
+		if err != nil {
+			return nil, errors.Wrap(ErrCriticalFailure, err)
+		}
+	}
+
+	tf = TwoFactor{
+		OTP: &DBOTP{
+			Type:      key.Type,
+			Issuer:    AppName,
+			Account:   username,
+			Algorithm: key.Algorithm,
+			Digits:    key.Digits,
+			Period:    key.Period,
+			Counter:   counter,
+			Encoded:   encoded,
+		},
+		Recovery: hashes,
+	}
+
+	err = p.passwords.SetTwoFactor(ctx, "master", username, tf)
+	if err != nil {
+		return nil, errors.Wrap(ErrInternalError, err)
+	}
+
+	return recovery, nil
+}
+
+func (p passwordKeeper) DisableTwoFactor(ctx context.Context, token, code string) (err error) {
+	t, err := p.tokenizer.Parse(token)
+	if err != nil {
+		return errors.Wrap(ErrPermissionDenied, err)
+	}
+
+	return p.transaction(ctx, func(keeper passwordKeeper) error {
+		tf, err := keeper.passwords.GetTwoFactor(ctx, "master", t.ID)
+		if err != nil {
+			return errors.Wrap(ErrInternalError, err)
+		}
+		if tf.OTP == nil {
+			return errors.Wrap(ErrInvalidArgs, errors.New("two factor authentication is off"))
+		}
+
+		err = keeper.useCode(ctx, t.ID, tf, code)
+		if err != nil {
+			return err
+		}
+
+		err = keeper.passwords.SetTwoFactor(ctx, "master", t.ID, TwoFactor{})
+		if err != nil {
+			return errors.Wrap(ErrInternalError, err)
+		}
+
+		return nil
+	})
+}
+
+//checkSecondFactor is the part of Login that runs after the password
+//matched, it passes when two factor authentication is off
+func (p passwordKeeper) checkSecondFactor(ctx context.Context, username, code string) error {
+	return p.transaction(ctx, func(keeper passwordKeeper) error {
+		tf, err := keeper.passwords.GetTwoFactor(ctx, "master", username)
+		if err != nil {
+			return errors.Wrap(ErrInternalError, err)
+		}
+		if tf.OTP == nil {
+			return nil
+		}
+
+		if strings.TrimSpace(code) == "" {
+			return ErrCodeRequired
+		}
+
+		return keeper.useCode(ctx, username, tf, code)
+	})
+}
+
+//transaction runs fn with a keeper on a transaction of the store. The
+//second factor read within it is locked until fn returns, so two logins
+//can not use the same code or recovery code
+func (p passwordKeeper) transaction(ctx context.Context, fn func(keeper passwordKeeper) error) error {
+	var fnErr error
+	err := p.passwords.Transaction(ctx, func(store PasswordStore) error {
+		keeper := p
+		keeper.passwords = store
+		fnErr = fn(keeper)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return errors.Wrap(ErrInternalError, err)
+	}
+
+	return nil
+}
+
+//useCode accepts a code of the authenticator app newer than the last one
+//used, or a recovery code which is then dropped. tf is read in the same
+//transaction, see transaction
+func (p passwordKeeper) useCode(ctx context.Context, username string, tf TwoFactor, code string) error {
+	code = strings.TrimSpace(code)
+
+	secret, err := p.es.Decode(tf.OTP.Encoded)
+	if err != nil {
+		return errors.Wrap(ErrCriticalFailure, err)
+	}
+	raw, err := otp.DecodeSecret(secret)
+	if err != nil {
+		return errors.Wrap(ErrCriticalFailure, err)
+	}
+
+	key := otp.Key{
+		Type:      tf.OTP.Type,
+		Secret:    raw,
+		Algorithm: tf.OTP.Algorithm,
+		Digits:    tf.OTP.Digits,
+		Period:    tf.OTP.Period,
+	}
+
+	used := false
+	if counter, ok := key.Check(code, time.Now(), codeSkew); ok && counter > tf.OTP.Counter {
+		tf.OTP.Counter = counter
+		used = true
+	}
+
+	//bcrypt is slow, only what looks like a recovery code is compared
+	if recovery := normalizeRecoveryCode(code); !used && len(recovery) == recoveryLength {
+		for i, hash := range tf.Recovery {
+			if p.hash.Compare(recovery, hash) == nil {
+				tf.Recovery = append(tf.Recovery[:i:i], tf.Recovery[i+1:]...)
+				used = true
+				break
+			}
+		}
+	}
+
+	if !used {
+		return errors.Wrap(ErrPermissionDenied, errors.New("invalid two factor code"))
+	}
+
+	err = p.passwords.SetTwoFactor(ctx, "master", username, tf)
+	if err != nil {
+		return errors.Wrap(ErrInternalError, err)
+	}
+
+	return nil
+}
+
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := make([]byte, 0, recoveryLength+1)
+	for i, c := range b {
+		if i == recoveryLength/2 {
+			code = append(code, '-')
+		}
+		code = append(code, recoveryAlphabet[int(c)%len(recoveryAlphabet)])
+	}
+	return string(code), nil
+}
+
+//normalizeRecoveryCode drops the dash and spaces and the case so a code
+//can be typed the way it reads
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright © 2021 PIUS ALFRED me.pius1102@gmail.com
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pk
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackaio/pk/otp"
+	"github.com/hackaio/pk/pkg/errors"
+)
+
+//secretTokenizer only accepts the tokens it issued, like the jwt
+//tokenizer does with the secret of an install
+type secretTokenizer struct {
+	secret string
+}
+
+func (s secretTokenizer) Issue(token Token) (string, error) {
+	return s.secret + ":" + token.ID, nil
+}
+
+func (s secretTokenizer) Parse(token string) (Token, error) {
+	if !strings.HasPrefix(token, s.secret+":") {
+		return Token{}, ErrPermissionDenied
+	}
+	return Token{ID: strings.TrimPrefix(token, s.secret+":")}, nil
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	store := newMemStore()
+	keeper := NewPasswordKeeper(plainHasher{}, store, secretTokenizer{secret: "install"}, plainES{})
+	ctx := context.Background()
+
+	if err := keeper.Register(ctx, "alice", "alice@example.com", "correct horse"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	token, err := keeper.Login(ctx, "alice", "correct horse", "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	secret, _ := otp.NewSecret(20)
+	key, _ := otp.NewKey(secret)
+	counter := uint64(time.Now().Unix()) / uint64(key.Period)
+	if _, err = keeper.EnableTwoFactor(ctx, token, secret, key.Code(counter)); err != nil {
+		t.Fatalf("EnableTwoFactor() error = %v", err)
+	}
+
+	if _, err = keeper.Login(ctx, "alice", "correct horse", ""); err != ErrCodeRequired {
+		t.Errorf("Login() without a code error = %v, want %v", err, ErrCodeRequired)
+	}
+	if _, err = keeper.Login(ctx, "alice", "correct horse", key.Code(counter)); err == nil {
+		t.Errorf("Login() with a code used before succeeded")
+	}
+	if _, err = keeper.Login(ctx, "alice", "correct horse", key.Code(counter+1)); err != nil {
+		t.Errorf("Login() with a new code error = %v", err)
+	}
+
+	//the second factor can not be skipped with a token made elsewhere
+	forged, _ := secretTokenizer{secret: "other install"}.Issue(NewToken("alice"))
+	if _, err = keeper.Get(ctx, forged, "github", "alice"); !errors.Contains(err, ErrPermissionDenied) {
+		t.Errorf("Get() with a forged token error = %v, want %v", err, ErrPermissionDenied)
+	}
+	if _, _, err = keeper.List(ctx, forged, Query{}); !errors.Contains(err, ErrPermissionDenied) {
+		t.Errorf("List() with a forged token error = %v, want %v", err, ErrPermissionDenied)
+	}
+	if err = keeper.DisableTwoFactor(ctx, forged, key.Code(counter+1)); !errors.Contains(err, ErrPermissionDenied) {
+		t.Errorf("DisableTwoFactor() with a forged token error = %v, want %v", err, ErrPermissionDenied)
+	}
+}